@@ -0,0 +1,106 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"strings"
+)
+
+// Features of the output that -annotate explains.
+const (
+	annotateImport = iota
+	annotateInstalled
+	annotateUnexportedFields
+	annotateUnexportedMethods
+	annotateTypeGroup
+	annotateSummaryGroup
+	annotatePromoted
+	annotateOverrides
+	annotateShadowed
+)
+
+// annotations holds the explanation of each feature.
+var annotations = []string{
+	annotateImport: `The comment // import "path" on the package clause gives the ` +
+		`path by which the package is imported.`,
+	annotateInstalled: `A warning on standard error that the package source is ` +
+		`installed elsewhere means the package's import comment gives a path other than the one it ` +
+		`is found at; the go command will not build it where it is.`,
+	annotateUnexportedFields: `"// Has unexported fields." means the struct also has ` +
+		`fields that other packages cannot use. The -u flag shows them.`,
+	annotateUnexportedMethods: `"// Has unexported methods." means the interface also ` +
+		`has methods that other packages cannot see, so only types of its own ` +
+		`package can implement it. The -u flag shows them.`,
+	annotateTypeGroup: `The declarations after a type are those go doc lists ` +
+		`with it: constants and variables of the type, functions that return ` +
+		`it, and its methods.`,
+	annotateSummaryGroup: `The lines indented beneath a type are constants and ` +
+		`variables of the type and functions that return it, which are listed ` +
+		`with the type rather than on their own.`,
+	annotatePromoted: `"promoted from T" marks a method of the embedded type T ` +
+		`that can be called as a method of the type that embeds it.`,
+	annotateOverrides: `"overrides T.M" marks a method that hides the method of ` +
+		`the same name of the embedded type T.`,
+	annotateShadowed: `"shadowed by T.M" marks a method of an embedded type ` +
+		`that is not promoted, because T.M, of a type embedded less deeply or the ` +
+		`type itself, has the same name. "not promoted: ambiguous" marks one that is ` +
+		`not promoted because a type embedded as deeply has a field or method of the same name.`,
+}
+
+// annotate records, for the -annotate flag, that the output has the
+// feature, to be explained by printAnnotations.
+func (pkg *Package) annotate(feature int) {
+	if !explain {
+		return
+	}
+	for _, f := range pkg.annotated {
+		if f == feature {
+			return
+		}
+	}
+	pkg.annotated = append(pkg.annotated, feature)
+}
+
+// annotateType records the features of the documentation of the type:
+// the fields or methods hidden from its declaration, and the
+// declarations listed after it.
+func (pkg *Package) annotateType(typ *doc.Type, spec *ast.TypeSpec) {
+	var list *ast.FieldList
+	feature := annotateUnexportedFields
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		list = t.Fields
+	case *ast.InterfaceType:
+		list, feature = t.Methods, annotateUnexportedMethods
+	}
+	if list != nil && len(list.List) > 0 {
+		last := list.List[len(list.List)-1]
+		if last.Comment != nil && strings.HasPrefix(last.Comment.Text(), "Has unexported ") {
+			pkg.annotate(feature)
+		}
+	}
+	if len(typ.Consts) > 0 || len(typ.Vars) > 0 || len(typ.Funcs) > 0 || len(typ.Methods) > 0 {
+		pkg.annotate(annotateTypeGroup)
+	}
+}
+
+// printAnnotations prints the explanations of the features of the
+// output recorded by annotate, in the order they were first seen.
+func (pkg *Package) printAnnotations() {
+	if len(pkg.annotated) == 0 {
+		return
+	}
+	pkg.newlines(2)
+	pkg.Printf("About this output:\n")
+	for i, feature := range pkg.annotated {
+		if i > 0 {
+			pkg.Printf("\n")
+		}
+		doc.ToText(&pkg.buf, annotations[feature], indent, "", indentedWidth())
+	}
+	pkg.annotated = nil
+}
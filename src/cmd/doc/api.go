@@ -0,0 +1,407 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An apiBaseline is a package's exported API as recorded in a file.
+type apiBaseline struct {
+	ImportPath string
+	// API maps the name of each feature of the API, such as "func F" or
+	// "field T.F", to its declaration, in a normal form like that of
+	// cmd/api that omits parameter names and spells out constant
+	// expressions.
+	API map[string]string
+}
+
+// An apiDiffError reports that the API of a package differs from its
+// baseline. Main exits with a status that says whether the differences
+// are only additions, which are compatible, or include removals or
+// changes, which are not.
+type apiDiffError struct {
+	compatible bool
+	msg        string
+}
+
+func (e *apiDiffError) Error() string { return e.msg }
+
+// exitStatus returns the status with which to exit for the error.
+func (e *apiDiffError) exitStatus() int {
+	if e.compatible {
+		return 3
+	}
+	return 4
+}
+
+// checkBaseline compares the package's API with the baseline recorded in
+// file, for the -baseline flag, printing a line for each feature added,
+// removed or changed. It returns an *apiDiffError if there are any.
+func (pkg *Package) checkBaseline(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var base apiBaseline
+	if err := json.Unmarshal(data, &base); err != nil {
+		return fmt.Errorf("invalid baseline %s: %v", file, err)
+	}
+	return pkg.diffAPI(base.API, pkg.apiFeatures(), fmt.Sprintf("API of %s differs from baseline %s", pkg.prettyPath(), file))
+}
+
+// diffAPI prints a line for each feature of the API now that was added
+// to, removed from or changed from the API was, and returns an
+// *apiDiffError whose message, beginning with what, counts them, if there
+// are any. Both APIs are as in an apiBaseline.
+func (pkg *Package) diffAPI(was, now map[string]string, what string) error {
+	var names []string
+	for name := range now {
+		names = append(names, name)
+	}
+	for name := range was {
+		if _, ok := now[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	var added, removed, changed int
+	var lines []string
+	for _, name := range names {
+		n, ok1 := now[name]
+		w, ok2 := was[name]
+		switch {
+		case !ok2:
+			lines = append(lines, "added: "+n)
+			added++
+		case !ok1:
+			lines = append(lines, "removed: "+w)
+			removed++
+		case n != w:
+			lines = append(lines, "changed: "+n+" (was "+w+")")
+			changed++
+		}
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		pkg.Printf("%s\n", line)
+	}
+	if added+removed+changed == 0 {
+		return nil
+	}
+	return &apiDiffError{
+		compatible: removed+changed == 0,
+		msg:        fmt.Sprintf("%s: %d added, %d removed, %d changed", what, added, removed, changed),
+	}
+}
+
+// diffPackages compares, for the -diff flag, the exported APIs of the
+// packages named by the two arguments, such as a fork and its upstream or
+// the import paths of two major versions of a module, as -baseline does a
+// package's with its baseline, the first being taken as the older.
+func diffPackages(writer io.Writer, args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+	var pkgs [2]*Package
+	for i, arg := range args {
+		bpkg, userPath, symbol, _ := parseArgs([]string{arg})
+		if symbol != "" {
+			failf(errPackage, "no such package %s%s", arg, dirs.status())
+		}
+		pkgs[i] = parsePackage(writer, bpkg, userPath)
+		dirs.Reset()
+	}
+	old, pkg := pkgs[0], pkgs[1]
+	defer pkg.flush()
+	return pkg.diffAPI(old.apiFeatures(), pkg.apiFeatures(), fmt.Sprintf("API of %s differs from that of %s", pkg.prettyPath(), old.prettyPath()))
+}
+
+// recordBaseline writes the package's API to file, for the -record flag,
+// as a baseline for -baseline. The form is canonical, with the features
+// sorted and one to a line, so that the file may be committed and its
+// changes reviewed.
+func (pkg *Package) recordBaseline(file string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // Keep << and the like legible.
+	enc.SetIndent("", "\t")
+	err := enc.Encode(&apiBaseline{
+		ImportPath: pkg.prettyPath(),
+		API:        pkg.apiFeatures(),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0666)
+}
+
+// apiFeatures returns the package's exported API, as in an apiBaseline.
+func (pkg *Package) apiFeatures() map[string]string {
+	api := make(map[string]string)
+	values := func(list []*doc.Value) {
+		for _, v := range list {
+			valueFeatures(api, v.Decl)
+		}
+	}
+	funcs := func(list []*doc.Func) {
+		for _, f := range list {
+			if !ast.IsExported(f.Name) {
+				continue
+			}
+			if f.Recv == "" {
+				api["func "+f.Name] = "func " + f.Name + signatureString(f.Decl.Type)
+				continue
+			}
+			recv := types.ExprString(f.Decl.Recv.List[0].Type)
+			typ := strings.TrimPrefix(recv, "*")
+			api["method "+typ+"."+f.Name] = "method (" + recv + ") " + f.Name + signatureString(f.Decl.Type)
+		}
+	}
+	values(pkg.doc.Consts)
+	values(pkg.doc.Vars)
+	funcs(pkg.doc.Funcs)
+	for _, typ := range pkg.doc.Types {
+		if !ast.IsExported(typ.Name) {
+			continue
+		}
+		typeFeatures(api, pkg.findTypeSpec(typ.Decl, typ.Name))
+		values(typ.Consts)
+		values(typ.Vars)
+		funcs(typ.Funcs)
+		funcs(typ.Methods)
+	}
+	return api
+}
+
+// valueFeatures adds the exported constants or variables of decl to api.
+// Constants are given with their values, in which iota is replaced by
+// its value and implicit repetitions of expressions are made explicit.
+func valueFeatures(api map[string]string, decl *ast.GenDecl) {
+	var typ ast.Expr
+	var values []ast.Expr
+	for iota, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		if decl.Tok == token.CONST && spec.Type == nil && spec.Values == nil {
+			// Repeat the previous specification.
+		} else {
+			typ, values = spec.Type, spec.Values
+		}
+		for i, name := range spec.Names {
+			if !ast.IsExported(name.Name) {
+				continue
+			}
+			s := decl.Tok.String() + " " + name.Name
+			if typ != nil {
+				s += " " + typeString(typ)
+			}
+			if i < len(values) && (decl.Tok == token.CONST || typ == nil) {
+				s += " = " + iotaString(values[i], iota)
+			}
+			api[decl.Tok.String()+" "+name.Name] = s
+		}
+	}
+}
+
+// typeFeatures adds the exported type declared by spec to api, with its
+// exported fields or interface methods as features of their own.
+func typeFeatures(api map[string]string, spec *ast.TypeSpec) {
+	name := spec.Name.Name
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		api["type "+name] = "type " + name + " struct"
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				embedded := typeString(field.Type)
+				if ast.IsExported(strings.TrimPrefix(embedded[strings.LastIndex(embedded, ".")+1:], "*")) {
+					api["embedded "+name+"."+embedded] = "type " + name + " struct, embedded " + embedded
+				}
+				continue
+			}
+			for _, f := range field.Names {
+				if ast.IsExported(f.Name) {
+					api["field "+name+"."+f.Name] = "type " + name + " struct, " + f.Name + " " + typeString(field.Type)
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		api["type "+name] = "type " + name + " interface"
+		for _, method := range t.Methods.List {
+			if len(method.Names) == 0 {
+				embedded := typeString(method.Type)
+				api["embedded "+name+"."+embedded] = "type " + name + " interface, embedded " + embedded
+				continue
+			}
+			for _, m := range method.Names {
+				if ast.IsExported(m.Name) {
+					api["method "+name+"."+m.Name] = "type " + name + " interface, " + m.Name + signatureString(method.Type.(*ast.FuncType))
+				}
+			}
+		}
+	default:
+		api["type "+name] = "type " + name + " " + typeString(spec.Type)
+	}
+}
+
+// typeString returns the normal form of a type expression: its source
+// in the compact form of types.ExprString, without parameter names.
+// The names are removed from a copy, since the syntax tree belongs to
+// the package's doc, which may be printed afterwards, as in -batch.
+func typeString(expr ast.Expr) string {
+	return types.ExprString(unnamedExpr(expr))
+}
+
+// signatureString returns the normal form of a function's signature,
+// without the func keyword.
+func signatureString(f *ast.FuncType) string {
+	return strings.TrimPrefix(typeString(f), "func")
+}
+
+// unnamedFields returns a copy of the parameter list without names,
+// with one field for each name.
+func unnamedFields(list *ast.FieldList) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	fields := &ast.FieldList{Opening: list.Opening, Closing: list.Closing}
+	for _, f := range list.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			fields.List = append(fields.List, &ast.Field{Type: unnamedExpr(f.Type)})
+		}
+	}
+	return fields
+}
+
+// unnamedExpr returns a copy of the expression in which the function
+// types have no parameter or result names, as unnamedFields gives them.
+// The nodes of the copy are new down to the function types; the rest,
+// such as identifiers, are shared with the expression.
+func unnamedExpr(expr ast.Expr) ast.Expr {
+	switch x := expr.(type) {
+	case *ast.FuncType:
+		return &ast.FuncType{Func: x.Func, Params: unnamedFields(x.Params), Results: unnamedFields(x.Results)}
+	case *ast.FuncLit:
+		return &ast.FuncLit{Type: unnamedExpr(x.Type).(*ast.FuncType), Body: x.Body}
+	case *ast.StructType:
+		c := *x
+		c.Fields = fieldsOf(x.Fields)
+		return &c
+	case *ast.InterfaceType:
+		c := *x
+		c.Methods = fieldsOf(x.Methods)
+		return &c
+	case *ast.StarExpr:
+		c := *x
+		c.X = unnamedExpr(x.X)
+		return &c
+	case *ast.ParenExpr:
+		c := *x
+		c.X = unnamedExpr(x.X)
+		return &c
+	case *ast.ArrayType:
+		c := *x
+		c.Len, c.Elt = unnamedExpr(x.Len), unnamedExpr(x.Elt)
+		return &c
+	case *ast.MapType:
+		c := *x
+		c.Key, c.Value = unnamedExpr(x.Key), unnamedExpr(x.Value)
+		return &c
+	case *ast.ChanType:
+		c := *x
+		c.Value = unnamedExpr(x.Value)
+		return &c
+	case *ast.Ellipsis:
+		c := *x
+		c.Elt = unnamedExpr(x.Elt)
+		return &c
+	case *ast.UnaryExpr:
+		c := *x
+		c.X = unnamedExpr(x.X)
+		return &c
+	case *ast.BinaryExpr:
+		c := *x
+		c.X, c.Y = unnamedExpr(x.X), unnamedExpr(x.Y)
+		return &c
+	case *ast.CallExpr:
+		c := *x
+		c.Fun, c.Args = unnamedExpr(x.Fun), unnamedExprs(x.Args)
+		return &c
+	case *ast.CompositeLit:
+		c := *x
+		c.Type, c.Elts = unnamedExpr(x.Type), unnamedExprs(x.Elts)
+		return &c
+	case *ast.KeyValueExpr:
+		c := *x
+		c.Key, c.Value = unnamedExpr(x.Key), unnamedExpr(x.Value)
+		return &c
+	case *ast.IndexExpr:
+		c := *x
+		c.X, c.Index = unnamedExpr(x.X), unnamedExpr(x.Index)
+		return &c
+	case *ast.TypeAssertExpr:
+		c := *x
+		c.X, c.Type = unnamedExpr(x.X), unnamedExpr(x.Type)
+		return &c
+	}
+	return expr
+}
+
+// unnamedExprs returns unnamedExpr of each of the expressions.
+func unnamedExprs(list []ast.Expr) []ast.Expr {
+	var exprs []ast.Expr
+	for _, x := range list {
+		exprs = append(exprs, unnamedExpr(x))
+	}
+	return exprs
+}
+
+// fieldsOf returns a copy of the field list of a struct or interface
+// type, keeping the names of its fields and methods, but with their
+// types as unnamedExpr gives them.
+func fieldsOf(list *ast.FieldList) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	c := *list
+	c.List = nil
+	for _, f := range list.List {
+		field := *f
+		field.Type = unnamedExpr(f.Type)
+		c.List = append(c.List, &field)
+	}
+	return &c
+}
+
+// iotaString returns the normal form of a constant expression, with
+// iota replaced by its value.
+func iotaString(expr ast.Expr, iota int) string {
+	var idents []*ast.Ident
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			idents = append(idents, id)
+			id.Name = strconv.Itoa(iota)
+		}
+		return true
+	})
+	s := typeString(expr)
+	for _, id := range idents {
+		id.Name = "iota"
+	}
+	return s
+}
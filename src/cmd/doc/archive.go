@@ -0,0 +1,450 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/doc"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// An archiveHeader records the settings that the documentation in a
+// standard library archive was printed with. An archive is used only with
+// the same settings, and its file name is derived from them.
+type archiveHeader struct {
+	Exe    string // See executableID.
+	GOROOT string
+	Go     string // The version of Go.
+	GOOS   string
+	GOARCH string
+	Tags   []string
+	Styled bool
+	Width  int
+	Indent string
+}
+
+// An archivedPackage is the documentation of a package of the standard
+// library, as the archive holds it, for a query without flags.
+type archivedPackage struct {
+	Files   []string       // The name, size and time of each file, as fileStamps gives them.
+	Clause  string         // The package clause, printed before a symbol's doc if the package was not named by its import path.
+	Package string         // The package doc.
+	Docs    []string       // The docs of the symbols and methods, each once.
+	Symbols map[string]int // The index in Docs of each exported constant, variable, function and type's.
+	Methods map[string]int // The index in Docs of each exported method's, as Type.Method, those of interfaces included.
+}
+
+// currentArchiveHeader returns the header of an archive made with the
+// settings in effect.
+func currentArchiveHeader() archiveHeader {
+	return archiveHeader{
+		Exe:    executableID(),
+		GOROOT: buildCtx.GOROOT,
+		Go:     runtime.Version(),
+		GOOS:   buildCtx.GOOS,
+		GOARCH: buildCtx.GOARCH,
+		Tags:   buildCtx.BuildTags,
+		Styled: styled,
+		Width:  lineWidth,
+		Indent: indent,
+	}
+}
+
+// stdArchiveFile returns the name of the standard library archive for the
+// settings in effect, in go doc's cache, or "" if there is no cache.
+func stdArchiveFile() string {
+	dir := docCacheDir()
+	if dir == "" {
+		return ""
+	}
+	data, err := json.Marshal(currentArchiveHeader())
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, fmt.Sprintf("std-%x.zip", sha256.Sum256(data)))
+}
+
+// archivable reports whether the archive holds the documentation of the
+// package: whether it is in the standard library and neither internal nor
+// vendored nor builtin, whose symbols are shown unexported.
+func archivable(pkg *build.Package) bool {
+	path := "/" + pkg.ImportPath + "/"
+	return pkg.Goroot && pkg.ImportPath != "builtin" && !strings.Contains(path, "/internal/") &&
+		!strings.Contains(path, "/vendor/") && !strings.HasPrefix(pkg.ImportPath, "cmd/")
+}
+
+// fileStamps returns the name, size and modification time of each of the
+// package's files, so that a change to any of them is noticed, or nil if
+// one cannot be found.
+func fileStamps(pkg *build.Package) []string {
+	var stamps []string
+	for _, name := range append(pkg.GoFiles[:len(pkg.GoFiles):len(pkg.GoFiles)], pkg.CgoFiles...) {
+		fi, err := fileSystem.Stat(filepath.Join(pkg.Dir, name))
+		if err != nil {
+			return nil
+		}
+		stamps = append(stamps, fmt.Sprintf("%s %d %d", name, fi.Size(), fi.ModTime().UnixNano()))
+	}
+	return stamps
+}
+
+// writeStdArchive writes, for the -std-archive flag, the documentation of
+// the packages of the standard library, printed with the settings in
+// effect, to an archive in go doc's cache. Go doc then answers a query
+// with no flags about a package of it, if its files have not changed, from
+// the archive, without parsing the package: see archivedDoc.
+func writeStdArchive(writer io.Writer) error {
+	file := stdArchiveFile()
+	if file == "" {
+		return fmt.Errorf("cannot write the standard library archive: there is no cache (GOCACHE=off)")
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	var pkgs []pkgDir
+	for _, p := range matchPackages([]string{"std"}) {
+		if archivable(p.pkg) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	if err := writeArchive(file, pkgs, func(p pkgDir) string { return p.pkg.ImportPath }); err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "wrote the documentation of %d packages to %s\n", len(pkgs), file)
+	return nil
+}
+
+// writeArchive writes the documentation of the packages, as archivePackage
+// prints it, to the zip archive file, in an entry for each named by the
+// path that name returns with .json appended. The file is written whole
+// and renamed into place, so that go doc never reads part of one.
+func writeArchive(file string, pkgs []pkgDir, name func(pkgDir) string) error {
+	f, err := ioutil.TempFile(filepath.Dir(file), "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // Fails once renamed.
+	zw := zip.NewWriter(f)
+	for _, p := range pkgs {
+		data, err := json.Marshal(archivePackage(p.pkg))
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(name(p) + ".json")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// readArchive returns the package with the path in the archive, or nil if
+// there is none.
+func readArchive(r *zip.Reader, path string) (*archivedPackage, error) {
+	for _, f := range r.File {
+		if f.Name != path+".json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		a := new(archivedPackage)
+		if err := json.NewDecoder(rc).Decode(a); err != nil {
+			return nil, fmt.Errorf("%s in archive: %v", f.Name, err)
+		}
+		return a, nil
+	}
+	return nil, nil
+}
+
+// archivePackage parses the package and prints its documentation for the
+// archive: the package doc, and the doc of each exported symbol and method
+// as a query naming it exactly prints it.
+func archivePackage(bpkg *build.Package) *archivedPackage {
+	var buf bytes.Buffer
+	pkg := parsePackage(&buf, bpkg, "")
+	saveMatchCase, saveMatchPrefix := matchCase, matchPrefix
+	defer func() { matchCase, matchPrefix = saveMatchCase, saveMatchPrefix }()
+	matchCase, matchPrefix = true, false
+	render := func(f func()) string {
+		buf.Reset()
+		f()
+		return buf.String()
+	}
+	a := &archivedPackage{
+		Files:   fileStamps(bpkg),
+		Clause:  render(func() { pkg.packageClause(false); pkg.flush() }),
+		Package: render(pkg.packageDoc),
+		Symbols: make(map[string]int),
+		Methods: make(map[string]int),
+	}
+	index := make(map[string]int) // Of each doc in a.Docs.
+	add := func(doc string) int {
+		i, ok := index[doc]
+		if !ok {
+			i = len(a.Docs)
+			index[doc] = i
+			a.Docs = append(a.Docs, doc)
+		}
+		return i
+	}
+	// The doc of a constant or variable is that of its declaration,
+	// so each declaration is printed once.
+	for _, values := range [][]*doc.Value{pkg.doc.Consts, pkg.doc.Vars} {
+		for _, value := range values {
+			var names []string
+			for _, name := range value.Names {
+				if isExported(name) {
+					names = append(names, name)
+				}
+			}
+			if len(names) == 0 {
+				continue
+			}
+			i := add(render(func() { pkg.symbolDoc(names[0]) }))
+			for _, name := range names {
+				a.Symbols[name] = i
+			}
+		}
+	}
+	for _, fun := range pkg.doc.Funcs {
+		if isExported(fun.Name) {
+			a.Symbols[fun.Name] = add(render(func() { pkg.symbolDoc(fun.Name) }))
+		}
+	}
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		a.Symbols[typ.Name] = add(render(func() { pkg.symbolDoc(typ.Name) }))
+		methods := typ.Methods
+		matchPrefix = true // All the methods.
+		methods = append(methods[:len(methods):len(methods)], pkg.interfaceMethodFuncs(typ, "")...)
+		matchPrefix = false
+		for _, meth := range methods {
+			if isExported(meth.Name) {
+				a.Methods[typ.Name+"."+meth.Name] = add(render(func() { pkg.methodDoc(typ.Name, meth.Name) }))
+			}
+		}
+	}
+	return a
+}
+
+// archivedDoc writes, for a query with no flags, the documentation of the
+// symbol and method of the package from the standard library archive, and
+// reports whether it did. It does not if there is no archive for the
+// settings in effect, the package's files have changed since it was made,
+// or the query does not match exactly one symbol or method, as for a
+// prefix such as Dec of several, leaving the query to be answered from
+// the package's source.
+func archivedDoc(writer io.Writer, pkg *build.Package, userPath, symbol, method string) bool {
+	if !archivable(pkg) {
+		return false
+	}
+	file := stdArchiveFile()
+	if file == "" {
+		return false
+	}
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+	a, err := readArchive(&r.Reader, pkg.ImportPath)
+	if a == nil || err != nil {
+		return false
+	}
+	stamps := fileStamps(pkg)
+	if stamps == nil || strings.Join(stamps, "\n") != strings.Join(a.Files, "\n") {
+		return false
+	}
+	if symbol == "" {
+		io.WriteString(writer, a.Package)
+		return true
+	}
+	docs := a.matches(symbol, method)
+	if len(docs) != 1 {
+		return false
+	}
+	if method == "" && userPath != "" && userPath != pkg.ImportPath {
+		io.WriteString(writer, a.Clause)
+	}
+	io.WriteString(writer, a.Docs[docs[0]])
+	return true
+}
+
+// matches returns the indexes in a.Docs of the docs of the symbols that
+// match symbol or, given a method, of the methods that match it of the
+// types that match symbol, in the order of their names, each once.
+func (a *archivedPackage) matches(symbol, method string) []int {
+	var names []string
+	if method == "" {
+		for name := range a.Symbols {
+			if match(symbol, name) {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range a.Methods {
+			dot := strings.Index(name, ".")
+			if match(symbol, name[:dot]) && match(method, name[dot+1:]) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	var docs []int
+	seen := make(map[int]bool)
+	for _, name := range names {
+		i, ok := a.Symbols[name]
+		if method != "" {
+			i, ok = a.Methods[name]
+		}
+		if ok && !seen[i] {
+			seen[i] = true
+			docs = append(docs, i)
+		}
+	}
+	return docs
+}
+
+// writeDocBundle writes, for -bundle given a file name ending in .docz,
+// the documentation of the packages that the patterns match to the file,
+// a zip archive like the standard library's, from which -frombundle
+// answers queries without the packages' source: see bundleDoc. It is
+// printed without terminal typography, at the width given by -width or
+// 80 columns, for reading anywhere.
+func writeDocBundle(file string, patterns []string) error {
+	if len(patterns) == 0 {
+		usage()
+	}
+	pkgs := matchPackages(patterns)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+	styled, lineWidth = false, outputWidth(width, ioutil.Discard)
+	return writeArchive(file, pkgs, bundlePath)
+}
+
+// bundlePath returns the path of the package in a doc bundle: its import
+// path or, if it is not in GOROOT or GOPATH, the path by which the pattern
+// found it, as for -o.
+func bundlePath(p pkgDir) string {
+	if !build.IsLocalImport(p.pkg.ImportPath) && !strings.HasPrefix(p.pkg.ImportPath, "_") {
+		return p.pkg.ImportPath
+	}
+	return strings.TrimSuffix(filepath.ToSlash(outputFile("", p)), ".txt")
+}
+
+// bundleDoc prints, for the -frombundle flag, the documentation that the
+// arguments ask for from the doc bundle file that -bundle wrote: that of a
+// package, named by its path in the bundle or the end of it, or of its
+// symbols or methods that match, as pkg.Symbol, pkg.Symbol.Method or
+// pkg Symbol ask, as for a package's source.
+func bundleDoc(writer io.Writer, file string, args []string) error {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var paths []string
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, ".json") {
+			paths = append(paths, strings.TrimSuffix(f.Name, ".json"))
+		}
+	}
+	var path, userPath, sym string
+	switch len(args) {
+	case 1:
+		path, userPath, sym = bundlePackage(file, paths, args[0], true)
+	case 2:
+		path, userPath, _ = bundlePackage(file, paths, args[0], false)
+		sym = args[1]
+	default:
+		usage()
+	}
+	a, err := readArchive(&r.Reader, path)
+	if err != nil {
+		return err
+	}
+	symbol, method := parseSymbol(sym)
+	if symbol == "" {
+		io.WriteString(writer, a.Package)
+		return nil
+	}
+	docs := a.matches(symbol, method)
+	if len(docs) == 0 {
+		failf(errSymbol, "no symbol %s in package %s in %s", sym, path, file)
+	}
+	if method == "" && userPath != path {
+		io.WriteString(writer, a.Clause)
+	}
+	for i, doc := range docs {
+		if i > 0 {
+			io.WriteString(writer, "\n")
+		}
+		io.WriteString(writer, a.Docs[doc])
+	}
+	return nil
+}
+
+// bundlePackage returns the path of the package in the bundle that arg
+// names, the part of arg naming it and, if withSymbol is set, the rest of
+// arg, after a period, naming a symbol. The package is named by its path
+// or a suffix of it that follows a slash, as for partial paths.
+func bundlePackage(file string, paths []string, arg string, withSymbol bool) (path, userPath, symbol string) {
+	// A full path, which may contain periods.
+	for _, p := range paths {
+		if (arg == p || withSymbol && strings.HasPrefix(arg, p+".")) && len(p) > len(path) {
+			path = p
+		}
+	}
+	if path != "" {
+		return path, path, strings.TrimPrefix(arg[len(path):], ".")
+	}
+	userPath = arg
+	if withSymbol {
+		slash := strings.LastIndex(arg, "/")
+		if dot := strings.Index(arg[slash+1:], "."); dot >= 0 {
+			userPath, symbol = arg[:slash+1+dot], arg[slash+1+dot+1:]
+		}
+	}
+	var matches []string
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/"+userPath) {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		failf(errPackage, "no package %s in %s", userPath, file)
+	case 1:
+	default:
+		failf(errAmbiguous, "%s matches several packages in %s: %s", userPath, file, strings.Join(matches, ", "))
+	}
+	return matches[0], userPath, symbol
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/build"
+	"io"
+	"strings"
+)
+
+// batchEnd begins the line that ends each answer of -batch, followed by
+// ok or error. It is the ASCII record separator, which doc comments and
+// declarations do not contain.
+const batchEnd = "\x1e"
+
+// inBatch is set while -batch answers queries.
+var inBatch bool
+
+// packageCache holds the packages parsed while -batch answers queries,
+// by cacheKey, so that each is parsed once; it is nil otherwise.
+var packageCache map[string]*Package
+
+// runBatch reads queries from in, one to a line, and writes their answers
+// to w, for the -batch flag. A query is the arguments to go doc, flags
+// included, separated by spaces. Each answer is the output go doc would
+// print, or for a failed query its error, followed by a line of batchEnd
+// and ok or error. Packages are parsed once, for the first query that
+// needs them, however many queries use them. The queries are answered
+// from a snapshot of the files, as for docBatch, each read as it was
+// when first read in the session, so that the answers agree even if the
+// files change meanwhile.
+func runBatch(w io.Writer, in io.Reader) error {
+	if inBatch {
+		return fmt.Errorf("-batch cannot be used in a query of -batch")
+	}
+	defer func(f func(string, ...interface{}), fsys FileSystem) {
+		inBatch, packageCache, fatalf, baseFS = false, nil, f, fsys
+	}(fatalf, baseFS)
+	inBatch, packageCache = true, make(map[string]*Package)
+	baseFS = newSnapshotFS(baseFS)
+	fatalf = panicf
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		status := "ok"
+		if err := batchQuery(w, args); err != nil {
+			fmt.Fprintf(w, "doc: %v\n", err)
+			status = "error"
+		}
+		fmt.Fprintf(w, "%s%s\n", batchEnd, status)
+	}
+	return scanner.Err()
+}
+
+// batchQuery writes the answer to the query to w, returning its error,
+// if any, including one reported by fatalf.
+func batchQuery(w io.Writer, args []string) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			pkgError, ok := e.(PackageError)
+			if !ok {
+				panic(e)
+			}
+			err = pkgError
+		}
+	}()
+	return do(w, flag.NewFlagSet("doc", flag.ContinueOnError), args)
+}
+
+// cacheKey returns the key of the package in packageCache: its directory
+// and the files selected from it, which depend on the build context, and
+// the flags that change what is parsed or how it is trimmed when printed.
+func cacheKey(pkg *build.Package) string {
+	files := append(pkg.GoFiles[:len(pkg.GoFiles):len(pkg.GoFiles)], pkg.CgoFiles...)
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%t", pkg.Dir, strings.Join(files, ","), sortOrder, unexported)
+}
@@ -0,0 +1,357 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/doc"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keys understood by the browser, beyond printable characters.
+const (
+	keyUp = -1 - iota
+	keyDown
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyQuit
+)
+
+// A browseItem is an entry in the browser's list: the package itself,
+// one of its symbols (possibly with a .method), or an imported package.
+type browseItem struct {
+	label  string // As listed.
+	symbol string // For a symbol.
+	path   string // For an imported package.
+}
+
+// A browser is the state of the interactive browser of -i: a stack of
+// packages, the current one's items filtered by a search, and the
+// selection, whose documentation is previewed beside the list.
+type browser struct {
+	stack     []*Package
+	items     []browseItem
+	shown     []int // Indexes in items of the items that match the search.
+	selected  int   // Index in shown.
+	top       int   // Index in shown of the first item on the screen.
+	query     string
+	searching bool
+	message   string // Shown in the status line until the next key.
+	preview   map[string]string
+	width     int
+	height    int
+}
+
+func newBrowser(pkg *Package, width, height int) *browser {
+	b := &browser{width: width, height: height}
+	b.push(pkg)
+	return b
+}
+
+// push makes pkg the current package.
+func (b *browser) push(pkg *Package) {
+	b.stack = append(b.stack, pkg)
+	b.load()
+}
+
+// load lists the items of the current package and clears the search.
+func (b *browser) load() {
+	pkg := b.stack[len(b.stack)-1]
+	b.items = []browseItem{{label: "package " + pkg.name}}
+	var symbols []string
+	values := func(list []*doc.Value) {
+		for _, v := range list {
+			symbols = append(symbols, v.Names...)
+		}
+	}
+	values(pkg.doc.Consts)
+	values(pkg.doc.Vars)
+	for _, f := range pkg.doc.Funcs {
+		symbols = append(symbols, f.Name)
+	}
+	// The package's lists include the typed values and constructors.
+	for _, t := range pkg.doc.Types {
+		symbols = append(symbols, t.Name)
+		for _, m := range t.Methods {
+			symbols = append(symbols, t.Name+"."+m.Name)
+		}
+	}
+	sort.Strings(symbols)
+	for _, s := range symbols {
+		if exported(s) {
+			b.items = append(b.items, browseItem{label: s, symbol: s})
+		}
+	}
+	for _, path := range pkg.build.Imports {
+		if path != "C" {
+			b.items = append(b.items, browseItem{label: "import " + path, path: path})
+		}
+	}
+	b.preview = make(map[string]string)
+	b.query, b.searching = "", false
+	b.filter()
+}
+
+// exported reports whether each part of the symbol, which may have a
+// .method, is exported (or -u is set).
+func exported(symbol string) bool {
+	for _, name := range strings.Split(symbol, ".") {
+		if !isExported(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter shows the items that contain the query, ignoring case.
+func (b *browser) filter() {
+	b.shown = b.shown[:0]
+	query := strings.ToLower(b.query)
+	for i, item := range b.items {
+		if strings.Contains(strings.ToLower(item.label), query) {
+			b.shown = append(b.shown, i)
+		}
+	}
+	b.selected, b.top = 0, 0
+}
+
+// selectSymbol selects the item for symbol, if there is one.
+func (b *browser) selectSymbol(symbol string) {
+	for i, index := range b.shown {
+		if strings.EqualFold(b.items[index].symbol, symbol) {
+			b.selected = i
+			return
+		}
+	}
+}
+
+// handleKey acts on a key and reports whether the browser should quit.
+func (b *browser) handleKey(key rune) bool {
+	b.message = ""
+	if b.searching {
+		switch key {
+		case keyEnter, keyEscape:
+			b.searching = false
+		case keyBackspace:
+			if b.query != "" {
+				_, size := utf8.DecodeLastRuneInString(b.query)
+				b.query = b.query[:len(b.query)-size]
+				b.filter()
+			}
+		case keyUp, keyDown:
+			b.searching = false
+			return b.handleKey(key)
+		case keyQuit:
+			return true
+		default:
+			if key >= ' ' {
+				b.query += string(key)
+				b.filter()
+			}
+		}
+		return false
+	}
+	switch key {
+	case keyQuit, 'q':
+		return true
+	case keyUp, 'k':
+		if b.selected > 0 {
+			b.selected--
+		}
+	case keyDown, 'j':
+		if b.selected < len(b.shown)-1 {
+			b.selected++
+		}
+	case '/':
+		b.searching = true
+	case keyEscape:
+		b.query = ""
+		b.filter()
+	case keyEnter, 'l':
+		if len(b.shown) == 0 {
+			break
+		}
+		item := b.items[b.shown[b.selected]]
+		if item.path == "" {
+			break
+		}
+		cur := b.stack[len(b.stack)-1]
+		bpkg, err := buildCtx.Import(item.path, cur.build.Dir, build.ImportComment)
+		if err != nil {
+			b.message = err.Error()
+			break
+		}
+		b.push(parsePackage(cur.writer, bpkg, item.path))
+	case keyBackspace, 'h':
+		if len(b.stack) > 1 {
+			b.stack = b.stack[:len(b.stack)-1]
+			b.load()
+		}
+	}
+	return false
+}
+
+// previewOf returns the documentation of the item, as doc prints it.
+func (b *browser) previewOf(item browseItem) string {
+	key := item.label
+	if text, ok := b.preview[key]; ok {
+		return text
+	}
+	pkg := b.stack[len(b.stack)-1]
+	var buf bytes.Buffer
+	saveWriter := pkg.writer
+	pkg.writer = &buf
+	func() {
+		defer func() {
+			if e := recover(); e != nil {
+				if _, ok := e.(PackageError); !ok {
+					panic(e)
+				}
+				fmt.Fprintln(&buf, e)
+			}
+		}()
+		switch {
+		case item.path != "":
+			fmt.Fprintf(&buf, "Press Enter to browse package %s.\n", item.path)
+		case item.symbol == "":
+			pkg.packageDoc()
+		case strings.Contains(item.symbol, "."):
+			dot := strings.Index(item.symbol, ".")
+			pkg.methodDoc(item.symbol[:dot], item.symbol[dot+1:])
+		default:
+			pkg.symbolDoc(item.symbol)
+		}
+	}()
+	pkg.writer = saveWriter
+	b.preview[key] = buf.String()
+	return b.preview[key]
+}
+
+// render draws the screen: the list on the left, the preview of the
+// selection on the right, and a status line.
+func (b *browser) render(w io.Writer) {
+	listWidth := b.width / 3
+	rows := b.height - 1
+	if b.selected < b.top {
+		b.top = b.selected
+	}
+	if b.selected >= b.top+rows {
+		b.top = b.selected - rows + 1
+	}
+	var preview []string
+	if len(b.shown) > 0 {
+		text := strings.Replace(b.previewOf(b.items[b.shown[b.selected]]), "\t", "    ", -1)
+		preview = strings.Split(text, "\n")
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[2J") // Home and clear.
+	for row := 0; row < rows; row++ {
+		label := ""
+		if i := b.top + row; i < len(b.shown) {
+			label = b.items[b.shown[i]].label
+		}
+		label = fit(label, listWidth-1)
+		if b.top+row == b.selected && len(b.shown) > 0 {
+			buf.WriteString("\x1b[7m" + label + "\x1b[0m")
+		} else {
+			buf.WriteString(label)
+		}
+		buf.WriteString("│")
+		if row < len(preview) {
+			buf.WriteString(strings.TrimRight(fit(preview[row], b.width-listWidth), " "))
+		}
+		buf.WriteString("\r\n")
+	}
+	pkg := b.stack[len(b.stack)-1]
+	status := pkg.prettyPath() + "  (/ search, enter open, h back, q quit)"
+	switch {
+	case b.searching:
+		status = "/" + b.query
+	case b.message != "":
+		status = b.message
+	case b.query != "":
+		status = "/" + b.query + "  " + status
+	}
+	buf.WriteString(strings.TrimRight(fit(status, b.width-1), " "))
+	w.Write(buf.Bytes())
+}
+
+// fit pads or truncates s to width runes.
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	runes := []rune(s)
+	return string(runes[:width])
+}
+
+// readKey reads a key from r, decoding the escape sequences of the
+// arrow keys.
+func readKey(r *bufio.Reader) (rune, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case '\r', '\n':
+		return keyEnter, nil
+	case 127, '\b':
+		return keyBackspace, nil
+	case 3, 4: // Control-C, Control-D.
+		return keyQuit, nil
+	case 27:
+		if r.Buffered() == 0 {
+			return keyEscape, nil
+		}
+		if next, _ := r.Peek(1); next[0] != '[' {
+			return keyEscape, nil
+		}
+		r.ReadByte()
+		c, _, err = r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		}
+		return 0, nil // Ignore other sequences.
+	}
+	return c, nil
+}
+
+// browse runs the interactive browser of -i on the terminal, starting at
+// the package's symbol, if any.
+func (pkg *Package) browse(symbol string) error {
+	restore, width, height, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	styled = false // Escapes would upset the layout.
+	lineWidth = width - width/3 - 1
+	b := newBrowser(pkg, width, height)
+	b.selectSymbol(symbol)
+	in := bufio.NewReader(os.Stdin)
+	for {
+		b.render(os.Stdout)
+		key, err := readKey(in)
+		if err != nil || b.handleKey(key) {
+			os.Stdout.WriteString("\x1b[H\x1b[2J")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import "errors"
+
+// rawTerminal reports that the interactive browser is not available.
+func rawTerminal() (restore func(), width, height int, err error) {
+	return nil, 0, 0, errors.New("-i is not supported on this system")
+}
+
+// terminalWidth reports that the width of the terminal is not known.
+func terminalWidth() int {
+	return 0
+}
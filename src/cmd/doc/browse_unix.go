@@ -0,0 +1,60 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// rawTerminal puts the terminal on standard input into raw mode, without
+// echo, and switches to the alternate screen. It returns a function that
+// restores the terminal, and the terminal's size.
+func rawTerminal() (restore func(), width, height int, err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("-i needs a terminal: %v", err)
+	}
+	size, err := stty("size")
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if _, err := fmt.Sscan(size, &height, &width); err != nil || width < 20 || height < 3 {
+		width, height = 80, 24
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, 0, 0, err
+	}
+	os.Stdout.WriteString("\x1b[?1049h")
+	restore = func() {
+		os.Stdout.WriteString("\x1b[?1049l")
+		stty(strings.TrimSpace(state))
+	}
+	return restore, width, height, nil
+}
+
+// terminalWidth returns the width of the terminal on standard input, or 0
+// if it is not known.
+func terminalWidth() int {
+	size, err := stty("size")
+	if err != nil {
+		return 0
+	}
+	var height, width int
+	fmt.Sscan(size, &height, &width)
+	return width
+}
+
+// stty runs stty with the arguments on the terminal on standard input.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// readOverlay reads the file named by the -overlay flag, or standard
+// input if the name is "-". It holds a JSON object mapping file names,
+// absolute or relative to the current directory, to their contents,
+// as an editor would send its unsaved buffers.
+func readOverlay(name string) map[string]string {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var files map[string]string
+	if err := json.Unmarshal(data, &files); err != nil {
+		fatalf("invalid overlay %s: %v", name, err)
+	}
+	return files
+}
+
+// docBuffers writes to writer the documentation that doc would print for
+// the arguments if run in dir with the buffers, which map file names to
+// contents, in place of the files on disk. Relative names are relative
+// to dir, and buffers may add files, and so packages, that are not on
+// disk. It lets a program such as an editor document the code as it is
+// being typed, without saving it.
+//
+// Directories first found through buffers are not visited when
+// searching for a partial package path.
+func docBuffers(writer io.Writer, dir string, buffers map[string]string, args []string) error {
+	defer func(fsys FileSystem, wd string) {
+		baseFS, workDir = fsys, wd
+	}(baseFS, workDir)
+	baseFS = newOverlayFS(baseFS, dir, buffers)
+	workDir = dir
+	return do(writer, flag.NewFlagSet("doc", flag.ContinueOnError), args)
+}
+
+// docBatch returns the documentation that doc would print for each of the
+// queries, which are lists of arguments, if run in dir with the buffers,
+// as for docBuffers. The queries are answered from a single snapshot of
+// the files, so that none changes between answers: an editor can fill
+// an outline and its hovers consistently. If a query fails, docBatch
+// returns the answers before it and the error.
+func docBatch(dir string, buffers map[string]string, queries [][]string) ([]string, error) {
+	defer func(fsys FileSystem, wd string) {
+		baseFS, workDir = fsys, wd
+	}(baseFS, workDir)
+	baseFS = newSnapshotFS(newOverlayFS(baseFS, dir, buffers))
+	workDir = dir
+	var answers []string
+	for _, args := range queries {
+		var b bytes.Buffer
+		if err := do(&b, flag.NewFlagSet("doc", flag.ContinueOnError), args); err != nil {
+			return answers, err
+		}
+		answers = append(answers, b.String())
+	}
+	return answers, nil
+}
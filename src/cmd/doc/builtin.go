@@ -0,0 +1,153 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// builtinTopics holds, for the functions of package builtin, what their
+// stubs there leave out: how they behave for each kind of argument, as
+// the language specification and the gc runtime define it. It is shown
+// after the stub's own doc comment, as a doc comment is.
+var builtinTopics = map[string]string{
+	"append": `Details
+
+If the capacity of the slice is large enough for the elements, append
+stores them in its underlying array and returns the slice resliced to
+include them; the original slice and others sharing the array see the
+change. Otherwise it allocates a new array, copies the elements to it
+and returns a slice of it, and the original slice is left as it was.
+When it grows a slice, the gc runtime doubles the capacity while it is
+below 1024 elements, then grows it by a quarter at a time, and rounds
+the size up to that of an allocation class, so the new capacity may
+exceed what was asked for. Appending to a nil slice allocates.
+
+As a special case, a string may be appended to a byte slice:
+
+	b = append(b, "text"...)
+
+Appending a slice to itself is allowed; the elements are copied as if
+the source were read in full first.
+`,
+	"cap": `Details
+
+For an array or a pointer to an array, cap is the length of the array,
+a constant if the expression contains no channel receives or function
+calls. For a slice, it is the number of elements of the underlying
+array from the start of the slice, so s[:cap(s)] is valid. For a
+channel, it is the size of the buffer. The capacity of a nil slice or
+channel is 0.
+`,
+	"close": `Details
+
+After the last value has been received from a closed channel, receives
+succeed at once with the zero value of the element type, and the second
+result of v, ok := <-c is false; a for range loop over the channel ends.
+Sending on a closed channel, closing it again and closing a nil channel
+panic. Closing a receive-only channel does not compile. Only the sender
+should close a channel, and only to tell receivers no more values are
+coming; channels need not be closed for their memory to be reclaimed.
+`,
+	"complex": `Details
+
+Both arguments must be of the same floating-point type, float32 for a
+complex64 result or float64 for a complex128. If both are untyped
+constants, the result is an untyped complex constant.
+`,
+	"copy": `Details
+
+Copy copies min(len(dst), len(src)) elements and returns that number.
+The slices may overlap; the result is as if src were first copied to
+a temporary. As a special case, the source may be a string when the
+destination is a byte slice:
+
+	n := copy(b, "text")
+`,
+	"delete": `Details
+
+Delete removes the element with the key from the map. If the map is nil
+or has no such element, it does nothing. It is safe to delete elements,
+including ones not yet reached, while ranging over the map; the deleted
+elements are not produced.
+`,
+	"imag": `Details
+
+For a complex64 argument the result is a float32, and for a complex128 a
+float64. If the argument is an untyped constant, so is the result.
+`,
+	"len": `Details
+
+For a string, len is the number of bytes, not of runes; use
+utf8.RuneCountInString for those. For an array or a pointer to an array,
+it is the length of the array, a constant if the expression contains no
+channel receives or function calls; for a constant string it is a
+constant too. For a slice or a map, it is the number of elements, and for
+a channel the number of elements queued in its buffer. The length of a
+nil slice, map or channel is 0.
+`,
+	"make": `Details
+
+The arguments for each kind of type are:
+
+	make([]T, n)     slice of length n and capacity n
+	make([]T, n, m)  slice of length n and capacity m
+	make(map[K]V)    map with room for a small number of elements
+	make(map[K]V, n) map with room for about n elements
+	make(chan T)     unbuffered channel
+	make(chan T, n)  channel buffered for n elements
+
+The size arguments must be of integer type or untyped constants. A
+constant size must not be negative, and a constant length no larger
+than the capacity; otherwise make panics at run time if n is negative
+or larger than m. For a map, the size is a hint: the map grows as
+elements are added whatever it is. The elements of a slice are set to
+their zero values.
+`,
+	"new": `Details
+
+New(T) allocates a zeroed variable of type T, and the result is its
+address; it is never nil. The composite literal &T{} does the same for
+struct, array, slice and map types, and can set the fields or elements.
+Whether the variable is placed on the heap or the stack is up to the
+compiler's escape analysis, not the use of new.
+`,
+	"panic": `Details
+
+Panic stops the normal execution of the goroutine and runs the functions
+deferred by the calling function, then those of its caller and so on up
+the stack. If a deferred function calls recover, the panicking sequence
+stops there and that function's caller returns normally; otherwise, when
+the top of the goroutine's stack is reached, the program crashes,
+printing the value passed to panic and the stack traces of the
+goroutines. Run-time errors such as indexing out of range panic with a
+runtime.Error. Calling panic(nil) panics too, but recover then returns
+nil, as though there were no panic.
+`,
+	"print": `Details
+
+Print writes to standard error, not standard output, and formats its
+arguments as the runtime sees fit; it is meant for bootstrapping and
+debugging the runtime, and may not remain in the language. Use the fmt
+package instead.
+`,
+	"println": `Details
+
+Println writes to standard error, not standard output, separating its
+arguments by spaces; it is meant for bootstrapping and debugging the
+runtime, and may not remain in the language. Use the fmt package
+instead.
+`,
+	"real": `Details
+
+For a complex64 argument the result is a float32, and for a complex128 a
+float64. If the argument is an untyped constant, so is the result.
+`,
+	"recover": `Details
+
+Recover stops a panicking sequence only when it is called directly by a
+deferred function, not by a function that one calls, and only in the
+goroutine that is panicking. Otherwise, and when the goroutine is not
+panicking, it returns nil and has no effect. A function whose deferred
+call recovered returns normally to its caller, with its named results
+as the deferred functions left them.
+`,
+}
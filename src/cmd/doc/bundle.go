@@ -0,0 +1,99 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeBundle writes, for the -bundle flag, the Go source files of the
+// package named by the first argument and of every package it imports,
+// directly or not, to the zip archive named by the second, each beneath
+// its import path, so the archive can be read with -zip. The packages are
+// those the go command would build with, as found in GOPATH and vendor
+// directories. Those of the standard library are left out, as -zip
+// reads them from GOROOT. Only the files go doc reads are included:
+// not tests, nor files excluded by build constraints. If the first
+// argument ends in .docz, it is instead a doc bundle to write the
+// documentation of the packages the others match to: see writeDocBundle.
+func writeBundle(args []string) error {
+	if len(args) > 0 && strings.HasSuffix(args[0], ".docz") {
+		return writeDocBundle(args[0], args[1:])
+	}
+	if len(args) != 2 {
+		usage()
+	}
+	root, err := buildCtx.Import(args[0], pwd(), build.ImportComment)
+	if err != nil {
+		return err
+	}
+	pkgs := make(map[string]*build.Package) // By the path in the archive.
+	var add func(p *build.Package)
+	add = func(p *build.Package) {
+		name := p.ImportPath
+		if build.IsLocalImport(name) || strings.HasPrefix(name, "_") {
+			// Not in GOPATH: use the directory's name.
+			name = filepath.Base(p.Dir)
+		}
+		if p.Goroot || pkgs[name] != nil {
+			return
+		}
+		pkgs[name] = p
+		for _, imp := range p.Imports {
+			if imp == "C" {
+				continue
+			}
+			dep, err := buildCtx.Import(imp, p.Dir, 0)
+			if err != nil {
+				warnf(warnSkip, "%v", err)
+				continue
+			}
+			add(dep)
+		}
+	}
+	add(root)
+
+	var names []string
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		p := pkgs[name]
+		for _, file := range append(p.GoFiles[:len(p.GoFiles):len(p.GoFiles)], p.CgoFiles...) {
+			filename := filepath.Join(p.Dir, file)
+			src, err := readFile(filename)
+			if err != nil {
+				return err
+			}
+			hdr := &zip.FileHeader{Name: path.Join(name, file), Method: zip.Deflate}
+			if fi, err := fileSystem.Stat(filename); err == nil {
+				hdr.SetModTime(fi.ModTime())
+			}
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(src); err != nil {
+				return err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,306 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// The documentation go doc prints is kept in a cache beneath $GOCACHE, so
+// that asking again, as shell completion and editors do, skips parsing the
+// package. The parsed package itself cannot be kept: its syntax trees
+// share comments and objects between nodes, which encoding would copy
+// apart. So the cache holds what was printed, with the warnings written,
+// keyed by everything that determines them: the arguments, the settings
+// in the environment, the program itself, the names and contents of the
+// package's files and the module path its go.mod file gives. The other
+// packages read to answer the query, such as those whose types a type is
+// declared as, are recorded in the entry with the contents of their
+// files, and the entry is used only if those are unchanged.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// A cacheEntry collects the output and warnings of a query to store them
+// in the cache once it is answered.
+type cacheEntry struct {
+	file     string          // The file the entry is stored in.
+	out      bytes.Buffer    // The output written so far.
+	warn     bytes.Buffer    // The warnings written so far.
+	warnings io.Writer       // Where warnings were written before.
+	dir      string          // The directory of the package queried.
+	deps     map[string]bool // The directories of the others read.
+}
+
+// A cachedDoc is the contents of a cache file.
+type cachedDoc struct {
+	Output   string
+	Warnings string
+	Deps     []cachedDep `json:",omitempty"`
+}
+
+// A cachedDep is another package read to answer a query: its directory
+// and the hash of its files, as filesHash gives it.
+type cachedDep struct {
+	Dir  string
+	Hash string
+}
+
+// collecting is the entry collecting the answer to the query being
+// answered, if any, to which noteCacheDep adds the packages read.
+var collecting *cacheEntry
+
+// cacheable reports whether the output for the flags set can be cached:
+// whether it depends only on the package's files and is all written to
+// the output. Those that read other packages, repositories or files,
+// write files of their own or trace the search are not.
+func cacheable() bool {
+	return !interactive && !trace && !follow && !showCalls && !inlineTypes && position == "" &&
+		!showLayout && !showLinks && srcURL == "" && platforms == "" &&
+		splitDir == "" && record == "" && baseline == ""
+}
+
+// docCacheDir returns the directory of go doc's cache: doc in $GOCACHE,
+// which is by default go-build in the user's cache directory, as for the
+// go command. It returns "" if $GOCACHE is off or there is no such
+// directory.
+func docCacheDir() string {
+	dir := goEnv("GOCACHE", "")
+	if dir == "off" {
+		return ""
+	}
+	if dir == "" {
+		dir = userCacheDir()
+		if dir == "" {
+			return ""
+		}
+		dir = filepath.Join(dir, "go-build")
+	}
+	return filepath.Join(dir, "doc")
+}
+
+// userCacheDir returns the directory for the user's cached data, or "" if
+// there is none.
+func userCacheDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("LocalAppData")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, "Library", "Caches")
+		}
+	case "plan9":
+		if home := os.Getenv("home"); home != "" {
+			return filepath.Join(home, "lib", "cache")
+		}
+	default:
+		if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+			return dir
+		}
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, ".cache")
+		}
+	}
+	return ""
+}
+
+// cacheFile returns the name of the file in the cache holding the output
+// for the arguments given the package, or "" if there is no cache or the
+// package's files cannot all be read, so the query must be answered
+// afresh. The settings that shape the output but are not arguments, such
+// as the width of the terminal, are part of the key.
+func cacheFile(pkg *build.Package, args []string) string {
+	dir := docCacheDir()
+	if dir == "" {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "go doc cache\nexe %s\n", executableID())
+	for _, arg := range args {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GO") || strings.HasPrefix(kv, "CGO_") {
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		fmt.Fprintf(h, "env %q\n", kv)
+	}
+	if name := goEnvFileName(); name != "" {
+		data, _ := ioutil.ReadFile(name)
+		fmt.Fprintf(h, "goenv %q\n", data)
+	}
+	fmt.Fprintf(h, "pwd %q\nstyled %v\nwidth %d\nindent %q\n", pwd(), styled, lineWidth, indent)
+	fmt.Fprintf(h, "dir %q\nimport %q\n", pkg.Dir, pkg.ImportPath)
+	// The go.mod file above the package decides the module warning.
+	modPath, gomod := modulePath(pkg.Dir)
+	fmt.Fprintf(h, "gomod %q %q\n", gomod, modPath)
+	files, ok := filesHash(pkg)
+	if !ok {
+		return ""
+	}
+	fmt.Fprintf(h, "files %s\n", files)
+	key := fmt.Sprintf("%x", h.Sum(nil))
+	return filepath.Join(dir, key[:2], key)
+}
+
+// filesHash returns a hash of the names and contents of the package's
+// files, or false if they cannot all be read.
+func filesHash(pkg *build.Package) (string, bool) {
+	h := sha256.New()
+	var names []string
+	for _, list := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.TestGoFiles, pkg.XTestGoFiles} {
+		names = append(names, list...)
+	}
+	for _, name := range names {
+		data, err := readFile(filepath.Join(pkg.Dir, name))
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(h, "file %q %x\n", name, sha256.Sum256(data))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), true
+}
+
+// dirHash returns the hash of the files of the package in the directory,
+// as filesHash gives it, or false if there is no package there.
+func dirHash(dir string) (string, bool) {
+	pkg, err := buildCtx.ImportDir(dir, 0)
+	if err != nil {
+		return "", false
+	}
+	return filesHash(pkg)
+}
+
+// noteCacheDep records, while the answer to a query is collected for the
+// cache, that the package, if it is not the one queried, was read to
+// answer it, so that the entry is checked against its files.
+func noteCacheDep(pkg *build.Package) {
+	if collecting != nil && pkg.Dir != "" && pkg.Dir != collecting.dir {
+		collecting.deps[pkg.Dir] = true
+	}
+}
+
+// A depImporter imports packages for the type checker as its Importer
+// does, noting each for the cache as noteCacheDep does, found from the
+// directory dir.
+type depImporter struct {
+	types.Importer
+	dir string
+}
+
+func (d depImporter) Import(path string) (*types.Package, error) {
+	if pkg, err := buildCtx.Import(path, d.dir, build.FindOnly); err == nil {
+		noteCacheDep(pkg)
+	}
+	return d.Importer.Import(path)
+}
+
+// executableID identifies the go doc program by its file's name, size and
+// modification time, so that what one build of it cached is not used by
+// another, which may print differently. It is "" if the file is unknown.
+func executableID() string {
+	exe, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %d %d", exe, fi.Size(), fi.ModTime().UnixNano())
+}
+
+// readCache writes the output and warnings stored in the cache file, if
+// there is one and the other packages it records are unchanged, and
+// reports whether it did.
+func readCache(writer io.Writer, file string) bool {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	var doc cachedDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	for _, dep := range doc.Deps {
+		if hash, ok := dirHash(dep.Dir); !ok || hash != dep.Hash {
+			return false
+		}
+	}
+	io.WriteString(warnings, doc.Warnings)
+	io.WriteString(writer, doc.Output)
+	return true
+}
+
+// beginCache starts collecting the output of the query, and the warnings
+// it writes, for the cache file. It returns the writer to write the output
+// to in place of writer.
+func beginCache(writer io.Writer, file string, pkg *build.Package) (*cacheEntry, io.Writer) {
+	c := &cacheEntry{file: file, warnings: warnings, dir: pkg.Dir, deps: make(map[string]bool)}
+	warnings = io.MultiWriter(warnings, &c.warn)
+	collecting = c
+	return c, io.MultiWriter(writer, &c.out)
+}
+
+// end stops collecting warnings and, if store is set, writes the entry to
+// its file, with the hashes of the other packages read. The file is
+// written whole and renamed into place, so that another go doc never
+// reads part of it. A cache that cannot be written is no worse than
+// none, so errors are ignored.
+func (c *cacheEntry) end(store bool) {
+	warnings, collecting = c.warnings, nil
+	if !store {
+		return
+	}
+	entry := cachedDoc{Output: c.out.String(), Warnings: c.warn.String()}
+	var dirs []string
+	for dir := range c.deps {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		hash, ok := dirHash(dir)
+		if !ok {
+			return
+		}
+		entry.Deps = append(entry.Deps, cachedDep{dir, hash})
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(c.file)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
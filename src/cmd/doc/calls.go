@@ -0,0 +1,230 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// callsDoc prints, for the -calls flag, a one-line summary of each
+// exported function and method that the function or method calls
+// directly, in the order of their first calls. Functions of other
+// packages of the same repository are qualified by their package's name;
+// those of other repositories and the standard library are omitted.
+// Without type information, a method call is attributed to the
+// receiver's type if made on the receiver, and otherwise to the only
+// type of the package with a method of that name, if there is one.
+func (pkg *Package) callsDoc(symbol, method string) error {
+	defer pkg.flush()
+	fun, file := pkg.funcSource(symbol, method)
+	if fun == nil {
+		if method != "" {
+			symbol += "." + method
+		}
+		pkg.failf(errSymbol, "no function or method %s in package %s", symbol, pkg.prettyPath())
+	}
+	if fun.Body == nil {
+		return nil
+	}
+	recvName, recvType := "", ""
+	if fun.Recv != nil && len(fun.Recv.List[0].Names) > 0 {
+		recvName = fun.Recv.List[0].Names[0].Name
+		recvType = typeName(fun.Recv.List[0].Type)
+	}
+	imports := make(map[string]string) // Name to path.
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			imports[imp.Name.Name] = path
+		} else if bpkg, err := buildCtx.Import(path, pkg.build.Dir, 0); err == nil {
+			imports[bpkg.Name] = path
+		}
+	}
+	others := make(map[string]*Package) // By import path.
+	seen := make(map[*doc.Func]bool)
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		callee, calleePkg := (*doc.Func)(nil), pkg
+		switch f := call.Fun.(type) {
+		case *ast.Ident:
+			callee = pkg.packageFunc(f.Name)
+		case *ast.SelectorExpr:
+			x := identName(f.X)
+			if path, ok := imports[x]; ok && x != recvName {
+				if calleePkg = pkg.sameRepoPackage(path, others); calleePkg != nil {
+					callee = calleePkg.packageFunc(f.Sel.Name)
+				}
+				break
+			}
+			typ := ""
+			if x == recvName && recvName != "" {
+				typ = recvType
+			}
+			callee = pkg.methodFunc(typ, f.Sel.Name)
+		}
+		if callee == nil || seen[callee] || !isExported(callee.Name) {
+			return true
+		}
+		seen[callee] = true
+		if calleePkg == pkg {
+			pkg.Printf("%s\n", pkg.summaryLine(callee.Decl))
+		} else {
+			pkg.Printf("%s\n", calleePkg.qualifiedFunc(callee.Decl))
+		}
+		return true
+	})
+	return nil
+}
+
+// funcSource returns the declaration of the function or method, with its
+// body, and the file that holds it, or nil if there is none.
+func (pkg *Package) funcSource(symbol, method string) (*ast.FuncDecl, *ast.File) {
+	for _, name := range append(pkg.build.GoFiles[:len(pkg.build.GoFiles):len(pkg.build.GoFiles)], pkg.build.CgoFiles...) {
+		// Parse the file again: go/doc has stripped the function bodies
+		// from the package's syntax trees.
+		filename := filepath.Join(pkg.build.Dir, name)
+		src, err := readFile(filename)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		file, err := parser.ParseFile(pkg.fs, filename, src, 0)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		for _, decl := range file.Decls {
+			fun, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			switch {
+			case method == "" && fun.Recv == nil && match(symbol, fun.Name.Name):
+				return fun, file
+			case method != "" && fun.Recv != nil &&
+				match(symbol, typeName(fun.Recv.List[0].Type)) && match(method, fun.Name.Name):
+				return fun, file
+			}
+		}
+	}
+	return nil, nil
+}
+
+// packageFunc returns the package's function with the given name, which
+// may be a constructor, or nil if there is none.
+func (pkg *Package) packageFunc(name string) *doc.Func {
+	for _, fun := range pkg.doc.Funcs {
+		if fun.Name == name {
+			return fun
+		}
+	}
+	for _, typ := range pkg.doc.Types {
+		for _, fun := range typ.Funcs {
+			if fun.Name == name {
+				return fun
+			}
+		}
+	}
+	return nil
+}
+
+// methodFunc returns the method with the given name of the package's type
+// typ or, if typ is "", of the only type that has one, or nil if there is
+// none.
+func (pkg *Package) methodFunc(typ, name string) *doc.Func {
+	var found *doc.Func
+	for _, t := range pkg.doc.Types {
+		if typ != "" && t.Name != typ {
+			continue
+		}
+		for _, fun := range t.Methods {
+			if fun.Name != name {
+				continue
+			}
+			if found != nil {
+				return nil // Ambiguous.
+			}
+			found = fun
+		}
+	}
+	return found
+}
+
+// sameRepoPackage returns the package with the import path if it is in
+// the same repository as pkg, loading it if it is not in others, or nil
+// otherwise.
+func (pkg *Package) sameRepoPackage(path string, others map[string]*Package) *Package {
+	if other, ok := others[path]; ok {
+		return other
+	}
+	var other *Package
+	bpkg, err := buildCtx.Import(path, pkg.build.Dir, build.ImportComment)
+	if err == nil && !bpkg.Goroot && !pkg.build.Goroot && repoRoot(bpkg.ImportPath) == repoRoot(pkg.build.ImportPath) {
+		other = parsePackage(pkg.writer, bpkg, path)
+	}
+	others[path] = other
+	return other
+}
+
+// repoRoot returns the prefix of the import path that names its
+// repository, as the go command guesses it for the common hosting sites:
+// the first three elements if the first looks like a domain, as in
+// github.com/user/repo, and otherwise the first.
+func repoRoot(path string) string {
+	elem := strings.Split(path, "/")
+	n := 1
+	if strings.Contains(elem[0], ".") {
+		n = 3
+	}
+	if n > len(elem) {
+		n = len(elem)
+	}
+	return strings.Join(elem[:n], "/")
+}
+
+// qualifiedFunc returns the one-line summary of the function or method,
+// with its name or receiver's type qualified by the package's name.
+func (pkg *Package) qualifiedFunc(fun *ast.FuncDecl) string {
+	// Qualify the name in place, then restore it, as iotaString does.
+	id := fun.Name
+	if fun.Recv != nil {
+		id = nil
+		ast.Inspect(fun.Recv.List[0].Type, func(n ast.Node) bool {
+			if i, ok := n.(*ast.Ident); ok && id == nil {
+				id = i
+			}
+			return true
+		})
+	}
+	name := id.Name
+	id.Name = pkg.name + "." + name
+	s := pkg.oneLineNode(fun)
+	id.Name = name
+	return s
+}
+
+// typeName returns the name of the type in a receiver, without a *.
+func typeName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	return identName(expr)
+}
+
+// identName returns the name of the expression if it is an identifier,
+// or "".
+func identName(expr ast.Expr) string {
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cTypes holds the names cgo gives the basic C types, as in C.int.
+var cTypes = map[string]bool{
+	"char": true, "schar": true, "uchar": true,
+	"short": true, "ushort": true, "int": true, "uint": true,
+	"long": true, "ulong": true, "longlong": true, "ulonglong": true,
+	"float": true, "double": true, "complexfloat": true, "complexdouble": true,
+	"size_t": true,
+}
+
+// cgoSummary prints, for a package that uses cgo, the Go functions its
+// cgo files export to C with //export directives and the C names (C.name)
+// its Go code refers to, with their kind when it can be told from the
+// name or use. Neither appear in the package's Go declarations.
+func (pkg *Package) cgoSummary() {
+	if len(pkg.build.CgoFiles) == 0 {
+		return
+	}
+	var exported []*ast.FuncDecl
+	kinds := make(map[string]string) // C name to kind, "" if unknown.
+	for _, name := range pkg.build.CgoFiles {
+		// Parse the file again: go/doc has stripped the function bodies
+		// from the package's syntax trees.
+		filename := filepath.Join(pkg.build.Dir, name)
+		src, err := readFile(filename)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		file, err := parser.ParseFile(pkg.fs, filename, src, parser.ParseComments)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		exports := make(map[string]bool)
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				if strings.HasPrefix(c.Text, "//export ") {
+					exports[strings.TrimSpace(c.Text[len("//export "):])] = true
+				}
+			}
+		}
+		for _, decl := range file.Decls {
+			if fun, ok := decl.(*ast.FuncDecl); ok && fun.Recv == nil && exports[fun.Name.Name] {
+				exported = append(exported, fun)
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if name, ok := cName(n.Fun); ok && kinds[name] == "" && !isCType(name) {
+					kinds[name] = "func"
+				}
+			case *ast.SelectorExpr:
+				if name, ok := cName(n); ok {
+					if _, seen := kinds[name]; !seen {
+						kinds[name] = ""
+					}
+					if isCType(name) {
+						kinds[name] = "type"
+					}
+				}
+			}
+			return true
+		})
+	}
+	if len(exported) > 0 {
+		sort.Slice(exported, func(i, j int) bool { return exported[i].Name.Name < exported[j].Name.Name })
+		pkg.newlines(2)
+		pkg.Printf("Exported to C:\n")
+		for _, fun := range exported {
+			pkg.Printf("%s\n", pkg.oneLineNode(fun))
+		}
+	}
+	if len(kinds) > 0 {
+		names := make([]string, 0, len(kinds))
+		for name := range kinds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pkg.newlines(2)
+		pkg.Printf("Used from C:\n")
+		for _, name := range names {
+			if kinds[name] == "" {
+				pkg.Printf("C.%s\n", name)
+			} else {
+				pkg.Printf("%s C.%s\n", kinds[name], name)
+			}
+		}
+	}
+}
+
+// cName reports whether expr is a reference to C.name and if so returns the name.
+func cName(expr ast.Expr) (string, bool) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || x.Name != "C" || x.Obj != nil {
+		return "", false
+	}
+	return sel.Sel.Name, true
+}
+
+// isCType reports whether the C name is evidently a type.
+func isCType(name string) bool {
+	return cTypes[name] || strings.HasPrefix(name, "struct_") ||
+		strings.HasPrefix(name, "union_") || strings.HasPrefix(name, "enum_")
+}
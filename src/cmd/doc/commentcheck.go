@@ -0,0 +1,234 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A commentLine is a line of a doc comment, without the comment markers,
+// and the line of the file on which it appears.
+type commentLine struct {
+	text string
+	line int
+}
+
+// listMarker matches the start of a line that looks like an item of a
+// list, which reflowing would join to the lines around it.
+var listMarker = regexp.MustCompile(`^([-*+•]|[0-9]+[.)])\s`)
+
+// proseLine matches a line of three or more words of prose.
+var proseLine = regexp.MustCompile(`^[\pL][\pL\pN'-]*([,;]? [\pL\pN'-]+){2,}[.,;:]?$`)
+
+// commentReport prints, for the -check-comments flag, each place in the
+// package's doc comments where rendering will not show the text as its
+// line breaks suggest it was meant: prose indented by accident, which
+// starts a preformatted block in the middle of a paragraph; lists and other short lines, which are
+// joined into a paragraph; and preformatted lines too long for the
+// output width, which are not wrapped. Each report suggests a fix.
+func (pkg *Package) commentReport() error {
+	defer pkg.flush()
+	problems := 0
+	for _, name := range append(pkg.build.GoFiles[:len(pkg.build.GoFiles):len(pkg.build.GoFiles)], pkg.build.CgoFiles...) {
+		// Parse the file again: go/doc has removed the doc comments
+		// from the declarations, with their positions.
+		filename := filepath.Join(pkg.build.Dir, name)
+		src, err := readFile(filename)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		file, err := parser.ParseFile(pkg.fs, filename, src, parser.ParseComments)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		for _, group := range docComments(file) {
+			for _, msg := range pkg.checkComment(group) {
+				pkg.Printf("%s\n", msg)
+				problems++
+			}
+		}
+	}
+	switch {
+	case problems == 1:
+		return fmt.Errorf("1 problem in doc comments of %s", pkg.prettyPath())
+	case problems > 1:
+		return fmt.Errorf("%d problems in doc comments of %s", problems, pkg.prettyPath())
+	}
+	return nil
+}
+
+// docComments returns the doc comments of the file's package clause and
+// of its exported declarations, including struct fields and interface
+// methods.
+func docComments(file *ast.File) []*ast.CommentGroup {
+	var groups []*ast.CommentGroup
+	add := func(doc *ast.CommentGroup, names ...*ast.Ident) {
+		if doc == nil {
+			return
+		}
+		for _, name := range names {
+			if isExported(name.Name) {
+				groups = append(groups, doc)
+				return
+			}
+		}
+	}
+	if file.Doc != nil {
+		groups = append(groups, file.Doc)
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			add(decl.Doc, decl.Name)
+		case *ast.GenDecl:
+			var names []*ast.Ident
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					add(spec.Doc, spec.Names...)
+					names = append(names, spec.Names...)
+				case *ast.TypeSpec:
+					add(spec.Doc, spec.Name)
+					names = append(names, spec.Name)
+					if !isExported(spec.Name.Name) {
+						continue
+					}
+					ast.Inspect(spec.Type, func(n ast.Node) bool {
+						if field, ok := n.(*ast.Field); ok {
+							add(field.Doc, field.Names...)
+						}
+						return true
+					})
+				}
+			}
+			add(decl.Doc, names...)
+		}
+	}
+	return groups
+}
+
+// commentLines returns the lines of the comment, with the indentation
+// common to them removed, as go/doc removes it.
+func commentLines(pkg *Package, group *ast.CommentGroup) []commentLine {
+	var lines []commentLine
+	for _, c := range group.List {
+		line := pkg.fs.Position(c.Pos()).Line
+		if strings.HasPrefix(c.Text, "//") {
+			lines = append(lines, commentLine{strings.TrimPrefix(c.Text[2:], " "), line})
+			continue
+		}
+		for i, text := range strings.Split(strings.TrimSuffix(c.Text[2:], "*/"), "\n") {
+			lines = append(lines, commentLine{text, line + i})
+		}
+	}
+	prefix, first := "", true
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) != "" {
+			prefix, first = commonIndent(prefix, l.text, first), false
+		}
+	}
+	for i := range lines {
+		lines[i].text = strings.TrimPrefix(lines[i].text, prefix)
+	}
+	return lines
+}
+
+// checkComment returns the reports for the doc comment.
+func (pkg *Package) checkComment(group *ast.CommentGroup) []string {
+	var msgs []string
+	report := func(l commentLine, format string, args ...interface{}) {
+		msgs = append(msgs, fmt.Sprintf("%s:%d: ", shortPath(pkg.fs.Position(group.Pos()).Filename), l.line)+fmt.Sprintf(format, args...))
+	}
+	lines := commentLines(pkg, group)
+	blank := func(i int) bool { return i < 0 || i >= len(lines) || strings.TrimSpace(lines[i].text) == "" }
+	indented := func(i int) bool { return !blank(i) && (lines[i].text[0] == ' ' || lines[i].text[0] == '\t') }
+	preIndent := "" // The indentation common to the lines of a preformatted block.
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if indented(i) && !indented(i-1) {
+			preIndent = ""
+			for j := i; j < len(lines) && (blank(j) || indented(j)); j++ {
+				if !blank(j) {
+					preIndent = commonIndent(preIndent, lines[j].text, j == i)
+				}
+			}
+		}
+		switch {
+		case indented(i):
+			// Code is commonly set into a sentence, indented by a tab;
+			// prose indented by spaces continues the paragraph by mistake.
+			if !blank(i-1) && !indented(i-1) && l.text[0] == ' ' && proseLine.MatchString(strings.TrimSpace(l.text)) {
+				report(l, "indented line starts a preformatted block within a paragraph; remove the indentation to continue the paragraph, or put a blank line before it if it is code")
+			}
+			if n := columns(strings.TrimPrefix(l.text, preIndent)); n > lineWidth {
+				report(l, "preformatted line is %d columns wide when shown, and is not wrapped to the output width of %d; break it or shorten it", n, lineWidth)
+			}
+		case !blank(i) && (blank(i-1) || indented(i-1)):
+			// The start of a paragraph. Look for two or more list
+			// items in it, or for a run of short lines, such as an
+			// address or a table, all but the last short.
+			end, items := i, 0
+			for ; !blank(end) && !indented(end); end++ {
+				if listMarker.MatchString(lines[end].text) {
+					items++
+				}
+			}
+			short := i
+			for short+1 < end && utf8.RuneCountInString(lines[short].text) <= lineWidth/3 {
+				short++
+			}
+			switch {
+			case items >= 2:
+				report(l, "list items will be joined into one paragraph; indent the list to keep its lines")
+			case short-i >= 2:
+				report(l, "%d short lines will be joined into one paragraph; indent them to keep their line breaks, or join them", short-i+1)
+			}
+			i = end - 1
+		}
+	}
+	return msgs
+}
+
+// commonIndent returns the indentation common to prefix and the line,
+// or the line's indentation if it is the first.
+func commonIndent(prefix, line string, first bool) string {
+	p := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	if first {
+		return p
+	}
+	for !strings.HasPrefix(p, prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// columns returns the number of columns a line of a preformatted block
+// takes when shown indented, with tab stops every 8 columns.
+func columns(line string) int {
+	col := indentColumns()
+	for _, r := range line {
+		if r == '\t' {
+			col = (col/8 + 1) * 8
+		} else {
+			col++
+		}
+	}
+	return col
+}
+
+// shortPath returns the file name relative to the current directory if
+// the file is beneath it.
+func shortPath(file string) string {
+	if rel, err := filepath.Rel(pwd(), file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
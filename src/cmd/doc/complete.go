@@ -0,0 +1,72 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/doc"
+	"sort"
+	"strings"
+)
+
+// A completion is a symbol offered by -complete-symbols.
+type completion struct {
+	kind      string // func, type, method, const or var.
+	name      string // For a method, Type.Method.
+	signature string // The declaration on one line.
+}
+
+// completeDoc prints, for the -complete-symbols flag, the exported symbols
+// of the package whose names begin with prefix or, if it is Type.prefix,
+// the methods of the type that do, one to a line as kind, name and
+// declaration separated by tabs, sorted by name, for editors' completion
+// and tools such as fzf. As with -prefix, a lower case letter matches
+// either case. Nothing is printed if none match.
+func (pkg *Package) completeDoc(prefix string) {
+	defer pkg.flush()
+	defer func(prefix bool) { matchPrefix = prefix }(matchPrefix)
+	matchPrefix = true
+	symbol, method := prefix, ""
+	dot := strings.Index(prefix, ".")
+	if dot >= 0 {
+		symbol, method = prefix[:dot], prefix[dot+1:]
+	}
+	var list []completion
+	if dot < 0 {
+		for _, fun := range pkg.findFuncs(symbol) {
+			list = append(list, completion{"func", fun.Name, pkg.oneLineNode(fun.Decl)})
+		}
+		for _, values := range [][]*doc.Value{pkg.doc.Consts, pkg.doc.Vars} {
+			for _, value := range values {
+				for _, name := range value.Names {
+					if match(symbol, name) {
+						node, _ := valueDecl([]*doc.Value{value}, name)
+						list = append(list, completion{value.Decl.Tok.String(), name, pkg.oneLineNode(node)})
+					}
+				}
+			}
+		}
+		for _, typ := range pkg.findTypes(symbol) {
+			list = append(list, completion{"type", typ.Name, pkg.oneLineNode(pkg.findTypeSpec(typ.Decl, typ.Name))})
+		}
+	} else {
+		matchPrefix = false // The type is named in full.
+		types := pkg.findTypes(symbol)
+		matchPrefix = true
+		for _, typ := range types {
+			for _, meth := range typ.Methods {
+				if match(method, meth.Name) {
+					list = append(list, completion{"method", typ.Name + "." + meth.Name, pkg.oneLineNode(meth.Decl)})
+				}
+			}
+			for _, meth := range pkg.interfaceMethodFuncs(typ, method) {
+				list = append(list, completion{"method", typ.Name + "." + meth.Name, pkg.oneLineNode(meth.Decl)})
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	for _, c := range list {
+		pkg.Printf("%s\t%s\t%s\n", c.kind, c.name, c.signature)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A methodSig is a method of a method set: its signature in the normal
+// form of typeString, without the func keyword, and whether it has a
+// pointer receiver, which puts it in the method set of *T but not T.
+type methodSig struct {
+	sig     string
+	pointer bool
+}
+
+// satisfiesConstraint reports, for -satisfies-constraint, whether the type
+// named by the first argument, perhaps with a leading * for its pointer
+// type, satisfies the interface named by the second, each given as
+// [<pkg>.]<sym> as for go doc. If it does not, the error explains why:
+// which methods are missing, have the wrong signature or are only in the
+// method set of the pointer type. The interfaces of this version of Go
+// are the only constraints there are, so their method sets are all that
+// is checked. Methods promoted from types of other packages are not
+// known, so are reported missing.
+func satisfiesConstraint(writer io.Writer, args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+	pointer := strings.HasPrefix(args[0], "*")
+	typePkg, typeSpec := lookupType(writer, strings.TrimPrefix(args[0], "*"))
+	ifacePkg, ifaceSpec := lookupType(writer, args[1])
+	iface, ok := ifaceSpec.Type.(*ast.InterfaceType)
+	if !ok {
+		return fmt.Errorf("%s.%s is not an interface type", ifacePkg.name, ifaceSpec.Name.Name)
+	}
+	typeName := typePkg.name + "." + typeSpec.Name.Name
+	if pointer {
+		typeName = "*" + typeName
+	}
+	ifaceName := ifacePkg.name + "." + ifaceSpec.Name.Name
+
+	// Signatures are written as in the type's package.
+	want := make(map[string]methodSig)
+	ifacePkg.interfaceMethods(writer, iface, typePkg, want)
+	have := make(map[string]methodSig)
+	if t, ok := typeSpec.Type.(*ast.InterfaceType); ok {
+		typePkg.interfaceMethods(writer, t, typePkg, have)
+	} else {
+		for _, typ := range typePkg.doc.Types {
+			if typ.Name == typeSpec.Name.Name {
+				have = typePkg.typeMethods(typ)
+			}
+		}
+	}
+
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		w := want[name]
+		h, ok := have[name]
+		switch {
+		case !ok:
+			problems = append(problems, "missing method "+name+w.sig)
+		case h.sig != w.sig:
+			problems = append(problems, "wrong signature for "+name+": have "+name+h.sig+", want "+name+w.sig)
+		case h.pointer && !pointer:
+			problems = append(problems, "method "+name+" has pointer receiver; *"+typeName+" satisfies "+ifaceName+" for it")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s does not satisfy %s:\n\t%s", typeName, ifaceName, strings.Join(problems, "\n\t"))
+	}
+	fmt.Fprintf(writer, "%s satisfies %s\n", typeName, ifaceName)
+	return nil
+}
+
+// typeMethods returns the method set of the pointer type of the package's
+// type, noting which methods are only in that of T. It includes the
+// methods promoted from the types of the package it embeds, which go/doc
+// leaves out if they are exported, a shallower one hiding a deeper one of
+// the same name. A method promoted through an embedded pointer is in the
+// method set of T.
+func (pkg *Package) typeMethods(typ *doc.Type) map[string]methodSig {
+	methods := make(map[string]methodSig)
+	type embedded struct {
+		typ     *doc.Type
+		pointer bool // Reached through a pointer.
+	}
+	seen := make(map[*doc.Type]bool)
+	for level := []embedded{{typ, false}}; len(level) > 0; {
+		var next []embedded
+		found := make(map[string]methodSig)
+		for _, e := range level {
+			if seen[e.typ] {
+				continue
+			}
+			seen[e.typ] = true
+			for _, fun := range e.typ.Methods {
+				if _, ok := methods[fun.Name]; !ok {
+					_, star := fun.Decl.Recv.List[0].Type.(*ast.StarExpr)
+					found[fun.Name] = methodSig{signatureString(fun.Decl.Type), star && !e.pointer}
+				}
+			}
+			st, ok := pkg.findTypeSpec(e.typ.Decl, e.typ.Name).Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				if len(field.Names) > 0 {
+					continue
+				}
+				_, star := field.Type.(*ast.StarExpr)
+				name := typeName(field.Type)
+				for _, t := range pkg.doc.Types {
+					if t.Name == name {
+						next = append(next, embedded{t, e.pointer || star})
+					}
+				}
+			}
+		}
+		for name, m := range found {
+			methods[name] = m
+		}
+		level = next
+	}
+	return methods
+}
+
+// implements reports whether the method set have, of a type T, holds the
+// methods in want, and whether that of *T does.
+func implements(have, want map[string]methodSig) (value, pointer bool) {
+	value, pointer = true, true
+	for name, w := range want {
+		h, ok := have[name]
+		if !ok || h.sig != w.sig {
+			return false, false
+		}
+		if h.pointer {
+			value = false
+		}
+	}
+	return value, pointer
+}
+
+// lookupType returns the package and declaration of the type named by
+// arg, [<pkg>.]<sym> as for go doc. As for -pos, unexported types of the
+// package in the current directory can be named.
+func lookupType(writer io.Writer, arg string) (*Package, *ast.TypeSpec) {
+	bpkg, userPath, symbol, _ := parseArgs([]string{arg})
+	if symbol == "" || strings.Contains(symbol, ".") {
+		failf(errSymbol, "%s does not name a type", arg)
+	}
+	pkg := parsePackage(writer, bpkg, userPath)
+	if userPath == "" {
+		unexported = true
+	}
+	for _, typ := range pkg.findTypes(symbol) {
+		if isExported(typ.Name) {
+			return pkg, pkg.findTypeSpec(typ.Decl, typ.Name)
+		}
+	}
+	failf(errSymbol, "no type %s in package %s", symbol, pkg.prettyPath())
+	return nil, nil
+}
+
+// interfaceMethods adds the methods of the interface, including those of
+// the interfaces it embeds, to methods. Their signatures are written as
+// they would be in the package in, with the names of types declared in
+// pkg qualified by its name if it is another package.
+func (pkg *Package) interfaceMethods(writer io.Writer, iface *ast.InterfaceType, in *Package, methods map[string]methodSig) {
+	for _, method := range iface.Methods.List {
+		if len(method.Names) > 0 {
+			sig := pkg.qualifiedSignature(method.Type.(*ast.FuncType), in)
+			for _, name := range method.Names {
+				methods[name.Name] = methodSig{sig: sig}
+			}
+			continue
+		}
+		// An embedded interface.
+		var embedded *Package
+		var name string
+		switch t := method.Type.(type) {
+		case *ast.Ident:
+			if t.Name == "error" && pkg.lookupTypeSpec(t.Name) == nil {
+				methods["Error"] = methodSig{sig: "() string"}
+				continue
+			}
+			embedded, name = pkg, t.Name
+		case *ast.SelectorExpr:
+			embedded, name = pkg.importedPackage(writer, t.X.(*ast.Ident).Name), t.Sel.Name
+		}
+		if spec := embedded.lookupTypeSpec(name); spec != nil {
+			if t, ok := spec.Type.(*ast.InterfaceType); ok {
+				embedded.interfaceMethods(writer, t, in, methods)
+			}
+		}
+	}
+}
+
+// lookupTypeSpec returns the declaration of the package's type with the
+// given name, or nil if there is none.
+func (pkg *Package) lookupTypeSpec(name string) *ast.TypeSpec {
+	for _, typ := range pkg.doc.Types {
+		if typ.Name == name {
+			return pkg.findTypeSpec(typ.Decl, name)
+		}
+	}
+	return nil
+}
+
+// importedPackage returns the package the package imports under name.
+func (pkg *Package) importedPackage(writer io.Writer, name string) *Package {
+	other := pkg.findImport(writer, name)
+	if other == nil {
+		failf(errPackage, "no import of %s in package %s", name, pkg.prettyPath())
+	}
+	return other
+}
+
+// findImport returns the package the package imports under name, or nil
+// if it cannot be found.
+func (pkg *Package) findImport(writer io.Writer, name string) *Package {
+	for _, imp := range pkg.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		bpkg, err := buildCtx.Import(path, pkg.build.Dir, build.ImportComment)
+		if err != nil {
+			continue
+		}
+		if imp.Name != nil && imp.Name.Name == name || imp.Name == nil && bpkg.Name == name {
+			return parsePackage(writer, bpkg, path)
+		}
+	}
+	// Not imported, as can happen in a file that does not compile;
+	// guess that the name is the path, as it is for io.
+	bpkg, err := buildCtx.Import(name, pkg.build.Dir, build.ImportComment)
+	if err != nil {
+		return nil
+	}
+	return parsePackage(writer, bpkg, name)
+}
+
+// qualifiedSignature returns the normal form of the signature, as
+// written in the package in: if that is not pkg, names of pkg's types
+// are qualified by pkg's name.
+func (pkg *Package) qualifiedSignature(f *ast.FuncType, in *Package) string {
+	return pkg.qualified(f, in, func() string { return signatureString(f) })
+}
+
+// qualified returns what str returns for the node, as written in the
+// package in: if that is not pkg, names of pkg's types in the node are
+// qualified by pkg's name while str runs. A nil package in stands for
+// the importers of pkg.
+func (pkg *Package) qualified(node ast.Node, in *Package, str func() string) string {
+	if in != nil && pkg.build.Dir == in.build.Dir {
+		return str()
+	}
+	// Qualify the names in place, then restore them, as iotaString does.
+	var idents []*ast.Ident
+	var qualify func(n ast.Node) bool
+	qualify = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			return false // Already qualified.
+		case *ast.Field:
+			ast.Inspect(n.Type, qualify) // Not the names.
+			return false
+		case *ast.Ident:
+			if pkg.lookupTypeSpec(n.Name) != nil {
+				idents = append(idents, n)
+			}
+		}
+		return true
+	}
+	ast.Inspect(node, qualify)
+	for _, id := range idents {
+		id.Name = pkg.name + "." + id.Name
+	}
+	s := str()
+	for _, id := range idents {
+		id.Name = strings.TrimPrefix(id.Name, pkg.name+".")
+	}
+	return s
+}
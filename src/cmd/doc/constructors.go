@@ -0,0 +1,82 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"sort"
+)
+
+// attachConstructors moves each function of the package that go/doc left
+// among the package's functions but that makes values of just one of its
+// types to that type's functions, so that it is shown beneath the type as
+// its constructors are. go/doc attaches a function only if its first
+// result is T or *T; here a result may also be a slice or array of them,
+// or an instantiation of a generic T, and may come anywhere in the list,
+// as in (int, *T, error), so long as the other results are of no other
+// type of the package.
+func attachConstructors(d *doc.Package) {
+	types := make(map[string]*doc.Type)
+	for _, typ := range d.Types {
+		types[typ.Name] = typ
+	}
+	var funcs []*doc.Func
+	for _, fun := range d.Funcs {
+		typ := constructedType(fun.Decl, types)
+		if typ == nil {
+			funcs = append(funcs, fun)
+			continue
+		}
+		tracef("showing %s with type %s", fun.Name, typ.Name)
+		typ.Funcs = append(typ.Funcs, fun)
+		sort.Slice(typ.Funcs, func(i, j int) bool { return typ.Funcs[i].Name < typ.Funcs[j].Name })
+	}
+	d.Funcs = funcs
+}
+
+// constructedType returns the type of the package, among types, of which
+// the function's results hold values, or nil if they hold values of none
+// or of more than one.
+func constructedType(decl *ast.FuncDecl, types map[string]*doc.Type) *doc.Type {
+	if decl.Recv != nil || decl.Type.Results == nil {
+		return nil
+	}
+	var found *doc.Type
+	for _, field := range decl.Type.Results.List {
+		typ := types[resultTypeName(field.Type)]
+		switch {
+		case typ == nil || typ == found:
+		case found != nil:
+			return nil
+		default:
+			found = typ
+		}
+	}
+	return found
+}
+
+// resultTypeName returns the name of the type of the package whose values
+// a result of type x holds, once pointers, slices, arrays and the type
+// arguments of an instantiation are stripped, or "" if x names no type of
+// the package.
+func resultTypeName(x ast.Expr) string {
+	for {
+		switch t := x.(type) {
+		case *ast.Ident:
+			return t.Name
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.ArrayType:
+			x = t.Elt
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.ParenExpr:
+			x = t.X
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "go/ast"
+
+// conventionsDoc prints, for the -conventions flag, a one-line summary of
+// each exported function and method of the package, noting whether it
+// takes a context.Context and returns an error where the conventions put
+// them, first and last, or elsewhere; then how many of them keep each
+// convention, as an aid to reviewing the design of an API.
+func (pkg *Package) conventionsDoc() {
+	defer pkg.flush()
+	pkg.packageClause(false)
+	funcs := pkg.doc.Funcs // Constructors included.
+	for _, typ := range pkg.doc.Types {
+		if isExported(typ.Name) {
+			funcs = append(funcs[:len(funcs):len(funcs)], typ.Methods...)
+		}
+	}
+	var total, ctxFirst, ctxElsewhere, errLast, errElsewhere int
+	for _, fun := range funcs {
+		if !isExported(fun.Name) {
+			continue
+		}
+		total++
+		var notes []string
+		switch pos := paramIndex(fun.Decl.Type.Params, pkg.isContext); {
+		case pos == 0:
+			notes = append(notes, "context first")
+			ctxFirst++
+		case pos > 0:
+			notes = append(notes, "context not first")
+			ctxElsewhere++
+		}
+		results := fun.Decl.Type.Results
+		switch pos := paramIndex(results, isError); {
+		case pos < 0:
+		case pos == fieldCount(results)-1:
+			notes = append(notes, "error last")
+			errLast++
+		default:
+			notes = append(notes, "error not last")
+			errElsewhere++
+		}
+		pkg.Printf("%s\n", pkg.summaryLine(fun.Decl, notes...))
+	}
+	pkg.Printf("\n%d functions and methods:\n", total)
+	pkg.Printf("%s%d take a context.Context first, %d elsewhere\n", indent, ctxFirst, ctxElsewhere)
+	pkg.Printf("%s%d return an error last, %d elsewhere\n", indent, errLast, errElsewhere)
+}
+
+// paramIndex returns the index of the first parameter or result in the
+// list whose type satisfies is, counting each name, or -1 if there is none.
+func paramIndex(list *ast.FieldList, is func(ast.Expr) bool) int {
+	if list == nil {
+		return -1
+	}
+	i := 0
+	for _, field := range list.List {
+		if is(field.Type) {
+			return i
+		}
+		if len(field.Names) == 0 {
+			i++
+		}
+		i += len(field.Names)
+	}
+	return -1
+}
+
+// fieldCount returns the number of parameters or results in the list.
+func fieldCount(list *ast.FieldList) int {
+	if list == nil {
+		return 0
+	}
+	return list.NumFields()
+}
+
+// isContext reports whether the type is context.Context, or Context
+// within package context.
+func (pkg *Package) isContext(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		return identName(t.X) == "context" && t.Sel.Name == "Context"
+	case *ast.Ident:
+		return pkg.build.ImportPath == "context" && t.Name == "Context"
+	}
+	return false
+}
+
+// isError reports whether the type is error.
+func isError(expr ast.Expr) bool {
+	return identName(expr) == "error"
+}
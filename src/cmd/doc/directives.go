@@ -0,0 +1,53 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// directiveLine matches a line of doc comment text, with its comment
+// markers removed, that is a compiler or tool directive such as
+// //go:noinline, //go:linkname or //line. Directives are not prose.
+var directiveLine = regexp.MustCompile(`^(go:[a-z][a-z0-9_]*(\s.*)?|line \S+:\d+(:\d+)?)$`)
+
+// splitDirectives separates the directives from the text of a doc
+// comment. It returns the text without them and the directives, each
+// with its leading //.
+func splitDirectives(comment string) (text string, directives []string) {
+	if !strings.Contains(comment, "go:") && !strings.Contains(comment, "line ") {
+		return comment, nil
+	}
+	lines := strings.SplitAfter(comment, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if directiveLine.MatchString(strings.TrimSuffix(line, "\n")) {
+			directives = append(directives, "//"+strings.TrimSpace(line))
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, ""), directives
+}
+
+// printDirectives prints the directives, as they appear in the source,
+// if the -directives flag is set. The //go:embed directives, which say
+// which files a variable holds, are always printed. (The printer prints
+// those of the specs in a parenthesized declaration along with their
+// doc comments.)
+func (pkg *Package) printDirectives(directives []string) {
+	for _, d := range directives {
+		if showDirectives || isEmbed(d) {
+			pkg.Printf("%s\n", d)
+		}
+	}
+}
+
+// isEmbed reports whether the directive, with its leading //, is a
+// //go:embed directive.
+func isEmbed(directive string) bool {
+	return strings.HasPrefix(directive, "//go:embed ")
+}
@@ -5,12 +5,14 @@
 package main
 
 import (
+	"fmt"
 	"go/build"
-	"log"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Dirs is a structure for scanning the directory tree.
@@ -18,33 +20,141 @@ import (
 // Although it can be used to scan the tree multiple times, it
 // only walks the tree once, caching the data it finds.
 type Dirs struct {
-	scan   chan string // directories generated by walk.
-	paths  []string    // Cache of known paths.
-	offset int         // Counter for Next.
+	scan     chan string      // directories generated by walk.
+	paths    []string         // Cache of known paths.
+	offset   int              // Counter for Next.
+	timeout  <-chan time.Time // Abandon the scan when this fires; nil means never.
+	timedOut bool             // The scan was abandoned.
+	follow   bool             // Follow symbolic links to directories.
+	ignore   []string         // Patterns for directories not to walk.
+	visited  map[string]bool  // Directories walked, by path with symbolic links resolved.
+	ctxt     build.Context    // Context whose trees are walked.
+	roots    []string         // Roots of the trees walked, in order.
+	fsys     FileSystem       // File system holding the trees; nil means the operating system's.
+	matches  []string         // Ranked matches of the partial path matching, not yet returned.
+	matching string           // Partial path being matched by findPackage.
+	stop     chan struct{}    // Closed when the walk is abandoned for another.
+
+	// The walk outlives the query that starts it, so it writes its
+	// warnings and trace where they went when it began, not to a query's
+	// cache entry, which collects only the query's own.
+	warnings     io.Writer
+	jsonWarnings bool
+	trace        bool
 }
 
 var dirs Dirs
 
-func init() {
-	dirs.paths = make([]string, 0, 1000)
-	dirs.scan = make(chan string)
-	go dirs.walk()
+// defaultIgnore holds the patterns for directories that never hold
+// packages worth documenting but can be huge. Directories whose names
+// begin with a period, such as .git, are always ignored too.
+var defaultIgnore = []string{"testdata", "node_modules", "bazel-*"}
+
+// Start begins the walk of the trees of buildCtx in the background,
+// those of the roots, in order. If follow is set,
+// symbolic links to directories are followed. Directories matching any of
+// the ignore patterns (see Dirs.ignored) are skipped, along with everything
+// beneath them. Start does nothing if a walk with the same settings, file
+// system and warnings has already begun. Otherwise any earlier walk is
+// abandoned and the trees are walked afresh, so that each query of -batch
+// and -repl searches the trees its own flags select.
+func (d *Dirs) Start(follow bool, ignore, roots []string) {
+	if d.scan != nil && d.follow == follow && sameStrings(d.ignore, ignore) &&
+		sameStrings(d.roots, roots) && d.fsys == fileSystem &&
+		d.warnings == warnings && d.jsonWarnings == jsonWarnings && d.trace == trace {
+		return
+	}
+	if d.stop != nil {
+		close(d.stop)
+	}
+	*d = Dirs{
+		paths:   make([]string, 0, 1000),
+		scan:    make(chan string),
+		follow:  follow,
+		ignore:  ignore,
+		visited: make(map[string]bool),
+		ctxt:    buildCtx,
+		roots:   roots,
+		fsys:    fileSystem,
+		stop:    make(chan struct{}),
+
+		warnings:     warnings,
+		jsonWarnings: jsonWarnings,
+		trace:        trace,
+	}
+	// The walk runs on a copy, which Start may replace meanwhile.
+	w := *d
+	go w.walk()
+}
+
+// sameStrings reports whether a and b hold the same strings in order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ignored reports whether the directory at path, which must be within
+// root, matches one of the ignore patterns. A pattern containing a slash
+// is matched against the slash-separated path relative to root; any
+// other pattern is matched against the directory's name.
+// Patterns use the syntax of filepath.Match.
+func (d *Dirs) ignored(root, path string) bool {
+	name := filepath.Base(path)
+	rel := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+	for _, pattern := range d.ignore {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Reset puts the scan back at the beginning.
 func (d *Dirs) Reset() {
 	d.offset = 0
+	d.matches, d.matching = nil, ""
+}
+
+// SetTimeout arranges for the scan to be abandoned once t has elapsed,
+// after which Next reports only the directories already found.
+// If t is zero, the scan runs to completion.
+func (d *Dirs) SetTimeout(t time.Duration) {
+	d.timeout = nil
+	d.timedOut = false
+	if t > 0 {
+		d.timeout = time.After(t)
+	}
 }
 
 // Next returns the next directory in the scan. The boolean
-// is false when the scan is done.
+// is false when the scan is done or has timed out.
 func (d *Dirs) Next() (string, bool) {
 	if d.offset < len(d.paths) {
 		path := d.paths[d.offset]
 		d.offset++
 		return path, true
 	}
-	path, ok := <-d.scan
+	if d.timedOut {
+		return "", false
+	}
+	var path string
+	var ok bool
+	select {
+	case path, ok = <-d.scan:
+	case <-d.timeout:
+		d.timedOut = true
+	}
 	if !ok {
 		return "", false
 	}
@@ -53,45 +163,108 @@ func (d *Dirs) Next() (string, bool) {
 	return path, ok
 }
 
-// walk walks the trees in GOROOT and GOPATH.
+// status describes how far the scan got if it timed out, for
+// appending to an error message. It is empty otherwise.
+func (d *Dirs) status() string {
+	if !d.timedOut {
+		return ""
+	}
+	last := "none"
+	if len(d.paths) > 0 {
+		last = d.paths[len(d.paths)-1]
+	}
+	return fmt.Sprintf(" (search timed out after scanning %d directories; last was %s)", len(d.paths), last)
+}
+
+// warnf and tracef write warnings and trace lines of the walk as the
+// functions of those names do.
+func (d *Dirs) warnf(kind, format string, args ...interface{}) {
+	writeWarning(d.warnings, d.jsonWarnings, kind, fmt.Sprintf(format, args...))
+}
+
+func (d *Dirs) tracef(format string, args ...interface{}) {
+	if d.trace {
+		writeTrace(d.warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// walk walks the trees of the roots, which are GOROOT and the GOPATH
+// entries unless -roots says otherwise.
 func (d *Dirs) walk() {
-	d.bfsWalkRoot(build.Default.GOROOT)
-	for _, root := range splitGopath() {
+	for _, root := range d.roots {
+		d.tracef("searching %s", filepath.Join(root, "src"))
 		d.bfsWalkRoot(root)
 	}
 	close(d.scan)
 }
 
+// fileSystem returns the file system holding the trees.
+func (d *Dirs) fileSystem() FileSystem {
+	if d.fsys == nil {
+		return osFS{}
+	}
+	return d.fsys
+}
+
+// A walkDir is a directory to be examined by bfsWalkRoot.
+type walkDir struct {
+	path string // Path by which the directory was reached.
+	real string // Path with symbolic links resolved, for detecting repeats.
+}
+
 // bfsWalkRoot walks a single directory hierarchy in breadth-first lexical order.
 // Each Go source directory it finds is delivered on d.scan.
+// A directory reachable by more than one path, including through a cycle
+// of symbolic links, is delivered only the first time it is found.
 func (d *Dirs) bfsWalkRoot(root string) {
 	root = path.Join(root, "src")
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		real = root // Not in the file system, or missing; see below.
+	}
 
 	// this is the queue of directories to examine in this pass.
-	this := []string{}
+	this := []walkDir{}
 	// next is the queue of directories to examine in the next pass.
-	next := []string{root}
+	next := []walkDir{{root, real}}
 
 	for len(next) > 0 {
 		this, next = next, this[0:0]
 		for _, dir := range this {
-			fd, err := os.Open(dir)
-			if err != nil {
-				log.Printf("error opening %s: %v", dir, err)
-				return // TODO? There may be entry before the error.
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+			if d.visited[dir.real] {
+				d.tracef("skipping %s: it was found by another path", dir.path)
+				continue
 			}
-			entries, err := fd.Readdir(0)
-			fd.Close()
+			d.visited[dir.real] = true
+			// The sorted listing keeps the walk in lexical order, so that
+			// the first of several links to a directory is the one kept.
+			entries, err := d.fileSystem().ReadDir(dir.path)
 			if err != nil {
-				log.Printf("error reading %s: %v", dir, err)
+				d.warnf(warnSkip, "error reading %s: %v", dir.path, err)
 				return // TODO? There may be entry before the error.
 			}
 			hasGoFiles := false
 			for _, entry := range entries {
 				name := entry.Name()
+				sub := walkDir{filepath.Join(dir.path, name), filepath.Join(dir.real, name)}
+				isDir := entry.IsDir()
+				if entry.Mode()&os.ModeSymlink != 0 && d.follow {
+					// Follow the link if it leads to a directory. A broken
+					// link or a link to a file is treated as a plain file.
+					if fi, err := d.fileSystem().Stat(sub.path); err == nil && fi.IsDir() {
+						if sub.real, err = filepath.EvalSymlinks(sub.path); err == nil {
+							isDir = true
+						}
+					}
+				}
 				// For plain files, remember if this directory contains any .go
 				// source files, but ignore them otherwise.
-				if !entry.IsDir() {
+				if !isDir {
 					if !hasGoFiles && strings.HasSuffix(name, ".go") {
 						hasGoFiles = true
 					}
@@ -102,12 +275,20 @@ func (d *Dirs) bfsWalkRoot(root string) {
 				if strings.HasPrefix(name, ".") {
 					continue
 				}
+				if d.ignored(root, sub.path) {
+					d.tracef("skipping %s: it matches an ignore pattern", sub.path)
+					continue
+				}
 				// Remember this (fully qualified) directory for the next pass.
-				next = append(next, filepath.Join(dir, name))
+				next = append(next, sub)
 			}
 			if hasGoFiles {
 				// It's a candidate.
-				d.scan <- dir
+				select {
+				case d.scan <- dir.path:
+				case <-d.stop:
+					return
+				}
 			}
 		}
 
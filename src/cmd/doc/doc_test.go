@@ -5,12 +5,28 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func maybeSkip(t *testing.T) {
@@ -22,6 +38,13 @@ func maybeSkip(t *testing.T) {
 	}
 }
 
+func TestMain(m *testing.M) {
+	// The tests answer the same queries of changing packages, so they
+	// do not use the cache, apart from TestCache.
+	os.Setenv("GOCACHE", "off")
+	os.Exit(m.Run())
+}
+
 type test struct {
 	name string
 	args []string // Arguments to "[go] doc".
@@ -361,6 +384,343 @@ var tests = []test{
 		nil,
 	},
 
+	// Build tags.
+	{
+		"build tags",
+		[]string{"-tags", "doctag otherdoctag", p, `TaggedFunc`},
+		[]string{
+			`Comment about function guarded by a build tag`,
+			`func TaggedFunc\(\) int`,
+		},
+		nil,
+	},
+	{
+		"no build tags",
+		[]string{p},
+		nil,
+		[]string{
+			`TaggedFunc`,
+		},
+	},
+
+	// Target platform.
+	{
+		"goos and goarch",
+		[]string{"-goos", "plan9", "-goarch", "arm", p, `Plan9ARMFunc`},
+		[]string{
+			`Comment about function only on plan9/arm`,
+		},
+		nil,
+	},
+	{
+		"goos only",
+		[]string{"-goos", "plan9", "-goarch", "386", p},
+		nil,
+		[]string{
+			`Plan9ARMFunc`,
+		},
+	},
+
+	// Merged platforms.
+	{
+		"merged platforms",
+		[]string{"-platforms", "plan9/arm plan9/386", p},
+		[]string{
+			`Package comment`,
+			`func Plan9ARMFunc\(\) int  // plan9/arm\n`,
+			`func ExportedFunc\(a int\) bool\n`,
+			`type ExportedType struct{ ... }\n    const ConstGroup4 ExportedType = ExportedType{}\n`,
+		},
+		[]string{
+			`ExportedFunc.*//`,
+		},
+	},
+
+	// Directives are not part of the doc comment's text.
+	{
+		"directive omitted",
+		[]string{p, `DirectiveFunc`},
+		[]string{
+			`func DirectiveFunc\(\)\n    DirectiveFunc is never inlined.\n`,
+		},
+		[]string{
+			`go:noinline`,
+		},
+	},
+	// With -directives, they are shown with the declaration.
+	{
+		"directive shown",
+		[]string{"-directives", p, `DirectiveFunc`},
+		[]string{
+			`//go:noinline\nfunc DirectiveFunc\(\)\n    DirectiveFunc is never inlined.\n`,
+		},
+		nil,
+	},
+	// Doc comments wrapped to the given width.
+	{
+		"width",
+		[]string{"-width", "30", p, `Derived`},
+		[]string{
+			`\n    Derived embeds\n    embeddedBase and overrides\n    one of its methods.\n`,
+		},
+		nil,
+	},
+	// Notes.
+	{
+		"notes",
+		[]string{"-notes", "todo", p},
+		[]string{
+			`\nTODO: Notes other than BUG are shown by -notes.\n`,
+		},
+		[]string{
+			`NOTE:`,
+		},
+	},
+	{
+		"all notes",
+		[]string{"-notes", "all", p},
+		[]string{
+			`\nNOTE: Notes are collected from comments anywhere in the package.\n(.|\n)*\nTODO: Notes`,
+		},
+		nil,
+	},
+	{
+		"custom notes",
+		[]string{"-notes=SECURITY,DEPRECATION", p},
+		[]string{
+			`\nSECURITY: Teams may use markers of their own.\n`,
+		},
+		[]string{
+			`TODO:`,
+		},
+	},
+	{
+		"no notes by default",
+		[]string{p},
+		nil,
+		[]string{
+			`NOTE:`,
+			`TODO:`,
+		},
+	},
+	// Types in signatures.
+	{
+		"inline types",
+		[]string{"-inline-types", p, `ReturnExported`},
+		[]string{
+			`func ReturnExported\(\) ExportedType\n\n    type ExportedType struct{ ... }\n`,
+		},
+		nil,
+	},
+	{
+		"inline types without receiver",
+		[]string{"-inline-types", p, `ExportedType.ExportedMethod`},
+		[]string{
+			`Comment about exported method.\n`,
+		},
+		[]string{
+			`type ExportedType`,
+		},
+	},
+	// Source order.
+	{
+		"sort by source",
+		[]string{"-sort", "source", p},
+		[]string{
+			`func ExportedFunc\(a int\) bool\nfunc ReturnUnexported\(\) unexportedType\nfunc MultiLineFunc`,
+			`type ExportedInterface interface{ ... }\ntype Derived struct{ ... }\n`,
+		},
+		nil,
+	},
+	// Values of iota blocks.
+	{
+		"iota values",
+		[]string{"-iota", p, `ConstLeft2`},
+		[]string{
+			`_, _ uint64 = 2 \* iota, 1 << iota // = 0, 1\n`,
+			`ConstLeft2, constRight2 // = 4, 4\n`,
+			`constLeft3, ConstRight3 // = 6, 8\n`,
+		},
+		[]string{
+			`constLeft1`,
+		},
+	},
+	// One-line answers.
+	{
+		"short function",
+		[]string{"-short", p, `ExportedFunc`},
+		[]string{
+			`^func ExportedFunc\(a int\) bool\n$`,
+		},
+		nil,
+	},
+	{
+		"short type",
+		[]string{"-short", p, `ExportedType`},
+		[]string{
+			`^type ExportedType struct{ ... }\n$`,
+		},
+		nil,
+	},
+	{
+		"short method",
+		[]string{"-short", p, `ExportedType.ExportedMethod`},
+		[]string{
+			`^func \(ExportedType\) ExportedMethod\(a int\) bool\n$`,
+		},
+		nil,
+	},
+	{
+		"short variable",
+		[]string{"-short", p, `ExportedVariable`},
+		[]string{
+			`^var ExportedVariable = 1\n$`,
+		},
+		nil,
+	},
+	// Completion.
+	{
+		"complete symbols",
+		[]string{"-complete-symbols", p, `exportedT`},
+		[]string{
+			`^type\tExportedType\ttype ExportedType struct{ ... }\nfunc\tExportedTypeConstructor\tfunc ExportedTypeConstructor\(\) \*ExportedType\nconst\tExportedTypedConstant\tconst ExportedTypedConstant ExportedType = iota\n`,
+		},
+		[]string{
+			`ExportedFunc`,
+			`ExportedMethod`,
+		},
+	},
+	{
+		"complete methods",
+		[]string{"-complete-symbols", p, `ExportedType.`},
+		[]string{
+			`^method\tExportedType.ExportedMethod\tfunc \(ExportedType\) ExportedMethod\(a int\) bool\n$`,
+		},
+		nil,
+	},
+	// Escaped output.
+	{
+		"escape html",
+		[]string{"-u", "-escape", "html", p, `ConstLeft2`},
+		[]string{
+			`_, _ uint64 = 2 \* iota, 1 &lt;&lt; iota\n`,
+		},
+		[]string{
+			`<<`,
+		},
+	},
+	{
+		"escape json",
+		[]string{"-escape", "json", p, `ExportedFunc`},
+		[]string{
+			`^"func ExportedFunc\(a int\) bool\\n    Comment about exported function.\\n\\n"\n$`,
+		},
+		nil,
+	},
+	// Indentation.
+	{
+		"indent",
+		[]string{"-indent", "2", p, `Derived`},
+		[]string{
+			`}\n  Derived embeds embeddedBase and overrides one of its methods.\n`,
+		},
+		[]string{
+			`   Derived`, // More than two spaces.
+		},
+	},
+	{
+		"indent by tab",
+		[]string{"-indent", `\t`, p},
+		[]string{
+			`\n\tfunc ReturnExported\(\) ExportedType\n`,
+		},
+		nil,
+	},
+	// Comments printed verbatim.
+	{
+		"raw comments",
+		[]string{"-raw", "-width", "30", p, `Derived`},
+		[]string{
+			`}\nDerived embeds embeddedBase and overrides one of its methods.\n`,
+		},
+		[]string{
+			`    Derived`, // No indentation.
+		},
+	},
+	// Locations of declarations.
+	{
+		"locations",
+		[]string{"-loc", p},
+		[]string{
+			`func ExportedFunc\(a int\) bool  // testdata/pkg.go:55\n`,
+			`type ExportedType struct{ ... }  // testdata/pkg.go:61\n`,
+		},
+		nil,
+	},
+	{
+		"location of type",
+		[]string{"-loc", p, `ExportedType`},
+		[]string{
+			`\n}  // testdata/pkg.go:61\n`,
+			`func \(ExportedType\) ExportedMethod\(a int\) bool  // overrides unexportedType.ExportedMethod; testdata/pkg.go:74\n`,
+		},
+		nil,
+	},
+	// Tree of symbols.
+	{
+		"tree",
+		[]string{"-tree", p},
+		[]string{
+			`├── functions\n│   ├── func DirectiveFunc\(\)\n`,
+			`└── types\n    ├── type Derived struct{ ... }\n`,
+			`    ├── type ExportedInterface interface{ ... }\n    │   └── methods\n    │       ├── ExportedMethod\(\)\n`,
+			`        ├── constructors\n        │   ├── func ExportedTypeConstructor\(\) \*ExportedType\n`,
+			`        └── methods\n            └── func \(ExportedType\) ExportedMethod\(a int\) bool\n`,
+		},
+		[]string{
+			`Package comment`,
+			`(?m)^│   ├── func ExportedTypeConstructor`, // Constructors are only with their types.
+		},
+	},
+	// Methods promoted from an embedded type, overrides of them, and
+	// the methods they shadow.
+	{
+		"promoted and overridden methods",
+		[]string{p, `Derived`},
+		[]string{
+			`func \(Derived\) Inherited\(\)  // promoted from \*embeddedBase\n`,
+			`func \(Derived\) Shared\(\)  // overrides embeddedBase.Shared\n`,
+			`func \(embeddedBase\) Shared\(\)  // shadowed by Derived.Shared\n`,
+		},
+		nil,
+	},
+	// Overrides are noted even when the embedded type is unexported.
+	{
+		"overridden method of unexported type",
+		[]string{p, `ExportedType`},
+		[]string{
+			`func \(ExportedType\) ExportedMethod\(a int\) bool  // overrides unexportedType.ExportedMethod\n`,
+		},
+		nil,
+	},
+	// Embed patterns are always shown.
+	{
+		"embed",
+		[]string{p, `EmbeddedText`},
+		[]string{
+			`//go:embed embed.txt\nvar EmbeddedText string\n    EmbeddedText holds the text of a file.\n`,
+		},
+		nil,
+	},
+	{
+		"embed in block",
+		[]string{p, `EmbeddedData`},
+		[]string{
+			`//go:embed data.bin\n\tEmbeddedData \[\]byte\n`,
+		},
+		nil,
+	},
+
 	// Case matching off.
 	{
 		"case matching off",
@@ -383,66 +743,219 @@ var tests = []test{
 			`CaseMatch`,
 		},
 	},
-}
-
-func TestDoc(t *testing.T) {
-	maybeSkip(t)
-	for _, test := range tests {
-		var b bytes.Buffer
-		var flagSet flag.FlagSet
-		err := do(&b, &flagSet, test.args)
-		if err != nil {
-			t.Fatalf("%s: %s\n", test.name, err)
-		}
-		output := b.Bytes()
-		failed := false
-		for j, yes := range test.yes {
-			re, err := regexp.Compile(yes)
-			if err != nil {
-				t.Fatalf("%s.%d: compiling %#q: %s", test.name, j, yes, err)
-			}
-			if !re.Match(output) {
-				t.Errorf("%s.%d: no match for %s %#q", test.name, j, test.args, yes)
-				failed = true
-			}
-		}
-		for j, no := range test.no {
-			re, err := regexp.Compile(no)
-			if err != nil {
-				t.Fatalf("%s.%d: compiling %#q: %s", test.name, j, no, err)
-			}
-			if re.Match(output) {
-				t.Errorf("%s.%d: incorrect match for %s %#q", test.name, j, test.args, no)
-				failed = true
-			}
-		}
-		if failed {
-			t.Logf("\n%s", output)
-		}
-	}
-}
 
-// Test the code to try multiple packages. Our test case is
-//	go doc rand.Float64
-// This needs to find math/rand.Float64; however crypto/rand, which doesn't
-// have the symbol, usually appears first in the directory listing.
-func TestMultiplePackages(t *testing.T) {
-	if testing.Short() {
-		t.Skip("scanning file system takes too long")
-	}
-	maybeSkip(t)
-	var b bytes.Buffer // We don't care about the output.
-	// Make sure crypto/rand does not have the symbol.
+	// Hooks.
 	{
-		var flagSet flag.FlagSet
-		err := do(&b, &flagSet, []string{"crypto/rand.float64"})
-		if err == nil {
-			t.Errorf("expected error from crypto/rand.float64")
-		} else if !strings.Contains(err.Error(), "no symbol float64") {
-			t.Errorf("unexpected error %q from crypto/rand.float64", err)
-		}
-	}
-	// Make sure math/rand does have the symbol.
+		"hooks",
+		[]string{"-hooks", p},
+		[]string{
+			`Variables you can override:\n` +
+				`    var Events chan string = make\(chan string, 1\)\n` +
+				`    var Filter func\(name string\) bool = func\(name string\) bool { ... }\n` +
+				`    var Handler EventHandler = defaultHandler\n` +
+				`    var OnEvent func\(name string\) error = nil\n`,
+		},
+		[]string{
+			`var EmbedCount`, // Not a hook.
+		},
+	},
+
+	// Builtin functions, with their details.
+	{
+		"builtin topic",
+		[]string{"make"},
+		[]string{
+			`func make\(Type, size IntegerType\) Type`,
+			`The make built-in function allocates`, // The stub's own doc.
+			`Details\n`,
+			`make\(chan T, n\)  channel buffered for n elements`,
+		},
+		nil,
+	},
+	{
+		"builtin topic qualified",
+		[]string{"builtin", "append"},
+		[]string{
+			`func append\(slice \[\]Type, elems \.\.\.Type\) \[\]Type`,
+			`doubles the capacity`,
+		},
+		[]string{
+			`(?s)Details.*Details`, // Once only.
+		},
+	},
+
+	// Symbols by prefix.
+	{
+		"prefix",
+		[]string{"-prefix", p, "exported"},
+		[]string{
+			`func ExportedFunc\(a int\) bool`,
+			`const ExportedConstant = 1`,
+			`type ExportedType struct`,
+		},
+		[]string{
+			`unexportedFunc`,
+		},
+	},
+
+	// Methods by name alone, of concrete types and interfaces.
+	{
+		"method search",
+		[]string{p, "exportedmethod"},
+		[]string{
+			`func \(ExportedType\) ExportedMethod\(a int\) bool\n    Comment about exported method.`,
+			`func \(ExportedInterface\) ExportedMethod\(\)\n    Comment before exported method.`,
+		},
+		[]string{
+			`unexportedType`,
+			`unexportedMethod`,
+		},
+	},
+
+	// Keywords.
+	{
+		"language topic",
+		[]string{"defer"},
+		[]string{
+			`^defer \(Defer statements\)\n`,
+			`    A defer statement schedules`,
+			`defer f.Close\(\)`,
+			`See https://golang.org/ref/spec#Defer_statements\n$`,
+		},
+		nil,
+	},
+
+	// Operators.
+	{
+		"operator topic",
+		[]string{"<-"},
+		[]string{
+			`^<- \(Receive operator\)\n`,
+			`See https://golang.org/ref/spec#Receive_operator\n$`,
+		},
+		nil,
+	},
+
+	// Annotations.
+	{
+		"annotate type",
+		[]string{"-annotate", p, `ExportedType`},
+		[]string{
+			`Has unexported fields`,
+			`About this output:\n    "// Has unexported fields." means the struct also has fields`,
+			`    The declarations after a type are those go doc lists with it`,
+		},
+		[]string{
+			`Has unexported methods." means`,
+			`lines indented beneath a type`,
+		},
+	},
+	{
+		"annotate package",
+		[]string{"-annotate", p},
+		[]string{
+			`About this output:\n    The comment // import "path" on the package clause`,
+			`    The lines indented beneath a type are constants`,
+		},
+		[]string{
+			`unexported fields." means`,
+			`WARNING`,
+		},
+	},
+
+	// Doc links.
+	{
+		"doc links",
+		[]string{p, `LinkedFunc`},
+		[]string{
+			`returns an ExportedType, read as an io.Reader is`,
+			`ExportedType.ExportedMethod and encoding/json.Marshal`,
+			`a\[0\], \[text\] and\s+\[Missing\] are not links`,
+		},
+		[]string{
+			`\[ExportedType`,
+			`\[io`,
+			`\[encoding`,
+		},
+	},
+
+	// Following doc links.
+	{
+		"follow doc links",
+		[]string{"-follow", p, `LinkedFunc`},
+		[]string{
+			`are not links.\n\n` +
+				`    type ExportedType struct{ ... }\n` +
+				`    type io.Reader interface{ ... }\n` +
+				`    func \(ExportedType\) ExportedMethod\(a int\) bool\n` +
+				`    func json.Marshal\(v interface{}\) \(\[\]byte, error\)\n`,
+		},
+		[]string{
+			`    \w+ Missing`, // Not a link.
+		},
+	},
+}
+
+func TestDoc(t *testing.T) {
+	maybeSkip(t)
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		err := do(&b, &flagSet, test.args)
+		if err != nil {
+			t.Fatalf("%s: %s\n", test.name, err)
+		}
+		output := b.Bytes()
+		failed := false
+		for j, yes := range test.yes {
+			re, err := regexp.Compile(yes)
+			if err != nil {
+				t.Fatalf("%s.%d: compiling %#q: %s", test.name, j, yes, err)
+			}
+			if !re.Match(output) {
+				t.Errorf("%s.%d: no match for %s %#q", test.name, j, test.args, yes)
+				failed = true
+			}
+		}
+		for j, no := range test.no {
+			re, err := regexp.Compile(no)
+			if err != nil {
+				t.Fatalf("%s.%d: compiling %#q: %s", test.name, j, no, err)
+			}
+			if re.Match(output) {
+				t.Errorf("%s.%d: incorrect match for %s %#q", test.name, j, test.args, no)
+				failed = true
+			}
+		}
+		if failed {
+			t.Logf("\n%s", output)
+		}
+	}
+}
+
+// Test the code to try multiple packages. Our test case is
+//
+//	go doc rand.Float64
+//
+// This needs to find math/rand.Float64; however crypto/rand, which doesn't
+// have the symbol, usually appears first in the directory listing.
+func TestMultiplePackages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scanning file system takes too long")
+	}
+	maybeSkip(t)
+	var b bytes.Buffer // We don't care about the output.
+	// Make sure crypto/rand does not have the symbol.
+	{
+		var flagSet flag.FlagSet
+		err := do(&b, &flagSet, []string{"crypto/rand.float64"})
+		if err == nil {
+			t.Errorf("expected error from crypto/rand.float64")
+		} else if !strings.Contains(err.Error(), "no symbol float64") {
+			t.Errorf("unexpected error %q from crypto/rand.float64", err)
+		}
+	}
+	// Make sure math/rand does have the symbol.
 	{
 		var flagSet flag.FlagSet
 		err := do(&b, &flagSet, []string{"math/rand.float64"})
@@ -479,31 +992,3478 @@ func TestMultiplePackages(t *testing.T) {
 	}
 }
 
-type trimTest struct {
-	path   string
-	prefix string
-	result string
-	ok     bool
+// Test that a failed lookup suggests the closest symbols.
+func TestCandidates(t *testing.T) {
+	maybeSkip(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{p, "ExportedFunk"}, "no symbol ExportedFunk in package cmd/doc/testdata\ndid you mean:\n\tcmd/doc/testdata.ExportedFunc\n"},
+		{[]string{p, "ExportedType.ExportedMethd"}, "did you mean:\n\tcmd/doc/testdata.ExportedType.ExportedMethod"},
+		{[]string{p, "ExportedTyp.ExportedMethod"}, "is not a type in package pkg installed in \"cmd/doc/testdata\"\ndid you mean:\n\tcmd/doc/testdata.ExportedType"},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		err := do(&b, &flagSet, test.args)
+		if err == nil {
+			t.Errorf("%s: expected error", test.args)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %q does not contain %q", test.args, err, test.want)
+		}
+	}
+	ranked := rankCandidates("decodr", []string{"Decoder", "Encoder", "Delim", "NewDecoder", "Marshal", "Decode"}, false)
+	want := []string{"Decode", "Decoder", "Encoder", "Delim", "NewDecoder"}
+	if !reflect.DeepEqual(ranked, want) {
+		t.Errorf("rankCandidates = %q, want %q", ranked, want)
+	}
 }
 
-var trimTests = []trimTest{
-	{"", "", "", true},
-	{"/usr/gopher", "/usr/gopher", "/usr/gopher", true},
-	{"/usr/gopher/bar", "/usr/gopher", "bar", true},
-	{"/usr/gopherflakes", "/usr/gopher", "/usr/gopherflakes", false},
-	{"/usr/gopher/bar", "/usr/zot", "/usr/gopher/bar", false},
+// Test that -pkg-index chooses among the packages a partial path matches.
+func TestPkgIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scanning file system takes too long")
+	}
+	maybeSkip(t)
+	// The two rank alike, so their order depends on when they were modified.
+	got := make(map[string]bool)
+	for _, index := range []string{"1", "2"} {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, []string{"-pkg-index", index, "rand"}); err != nil {
+			t.Fatal(err)
+		}
+		got[strings.SplitN(b.String(), "\n", 2)[0]] = true
+	}
+	for _, want := range []string{`package rand // import "crypto/rand"`, `package rand // import "math/rand"`} {
+		if !got[want] {
+			t.Errorf("-pkg-index 1 and 2 rand: got %v, want %q among them", got, want)
+		}
+	}
 }
 
-func TestTrim(t *testing.T) {
-	for _, test := range trimTests {
-		result, ok := trim(test.path, test.prefix)
-		if ok != test.ok {
-			t.Errorf("%s %s expected %t got %t", test.path, test.prefix, test.ok, ok)
+// Test the order in which the matches of a partial path are tried.
+func TestRankPackages(t *testing.T) {
+	defer func(ctxt build.Context) { buildCtx = ctxt }(buildCtx)
+	buildCtx.GOROOT = filepath.FromSlash("/goroot")
+	buildCtx.GOPATH = filepath.FromSlash("/gopath")
+	dirs := []string{
+		"/gopath/src/example.com/app/vendor/context",
+		"/gopath/src/example.com/x/net/context",
+		"/gopath/src/example.com/context",
+		"/goroot/src/vendor/golang_org/x/net/context",
+		"/goroot/src/context",
+	}
+	for i := range dirs {
+		dirs[i] = filepath.FromSlash(dirs[i])
+	}
+	rankPackages(dirs)
+	want := []string{
+		"/goroot/src/context",                         // Standard library.
+		"/gopath/src/example.com/context",             // Shorter.
+		"/gopath/src/example.com/x/net/context",       // Longer.
+		"/gopath/src/example.com/app/vendor/context",  // Vendored.
+		"/goroot/src/vendor/golang_org/x/net/context", // Vendored, and longer.
+	}
+	for i := range want {
+		want[i] = filepath.FromSlash(want[i])
+	}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("rankPackages:\ngot  %q\nwant %q", dirs, want)
+	}
+
+	// Preferred paths come first, in the order of their patterns.
+	defer func() { prefer = "" }()
+	prefer = "example.com/x/... example.com/app/*"
+	rankPackages(dirs)
+	want[0], want[1], want[2], want[3] = want[2], want[3], want[0], want[1]
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("rankPackages with -prefer %s:\ngot  %q\nwant %q", prefer, dirs, want)
+	}
+}
+
+// Test that -roots chooses the roots searched, in order, and ranks the
+// matches in earlier roots first.
+func TestRoots(t *testing.T) {
+	defer func(ctxt build.Context) { buildCtx = ctxt }(buildCtx)
+	buildCtx.GOROOT = filepath.FromSlash("/goroot")
+	buildCtx.GOPATH = strings.Join([]string{filepath.FromSlash("/gopath1"), filepath.FromSlash("/gopath2")}, string(filepath.ListSeparator))
+	defer func() { roots = "" }()
+	first := filepath.FromSlash("/gopath1/src/example.com/log")
+	second := filepath.FromSlash("/gopath2/src/example.com/x/log")
+
+	want := []string{buildCtx.GOROOT, filepath.FromSlash("/gopath1"), filepath.FromSlash("/gopath2")}
+	if got := searchRoots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("searchRoots() = %q; want %q", got, want)
+	}
+	if outranks(first, second) {
+		t.Errorf("without -roots, %s outranks %s", first, second)
+	}
+
+	roots = filepath.FromSlash("/gopath2/") + string(filepath.ListSeparator) + filepath.FromSlash("/gopath1")
+	want = []string{filepath.FromSlash("/gopath2"), filepath.FromSlash("/gopath1")}
+	if got := searchRoots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("-roots %s: searchRoots() = %q; want %q", roots, got, want)
+	}
+	dirs := []string{first, second}
+	rankPackages(dirs)
+	if dirs[0] != second {
+		t.Errorf("-roots %s: rankPackages = %q; want %s first", roots, dirs, second)
+	}
+	if !outranks(second, first) {
+		t.Errorf("-roots %s: %s does not outrank %s", roots, second, first)
+	}
+	if got := rootOf(second); got != filepath.FromSlash("/gopath2") {
+		t.Errorf("rootOf(%s) = %q; want /gopath2", second, got)
+	}
+}
+
+// Test that -split writes a page per symbol and an index referring to them.
+func TestSplit(t *testing.T) {
+	maybeSkip(t)
+	dir, err := ioutil.TempDir("", "doc-split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-split", dir, p}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("unexpected output on standard output:\n%s", b.Bytes())
+	}
+	pages := []struct {
+		file string
+		yes  []string
+		no   []string
+	}{
+		{"index.txt",
+			[]string{`Package comment`, `ExportedFunc.txt\tfunc ExportedFunc\(a int\) bool`, `ExportedType.txt\ttype ExportedType struct`},
+			[]string{`ExportedTypeConstructor.txt`, `internalFunc`}},
+		{"ExportedFunc.txt",
+			[]string{`Comment about exported function`},
+			[]string{`package pkg`, `ExportedType`}},
+		{"ExportedType.txt",
+			[]string{`Comment about exported type`, `Comment about exported method`, `Comment about constructor for exported type`},
+			[]string{`unexportedMethod`}},
+	}
+	for _, page := range pages {
+		data, err := ioutil.ReadFile(filepath.Join(dir, page.file))
+		if err != nil {
+			t.Error(err)
 			continue
 		}
-		if result != test.result {
-			t.Errorf("%s %s expected %q got %q", test.path, test.prefix, test.result, result)
+		for _, yes := range page.yes {
+			if !regexp.MustCompile(yes).Match(data) {
+				t.Errorf("%s: no match for %#q", page.file, yes)
+			}
+		}
+		for _, no := range page.no {
+			if regexp.MustCompile(no).Match(data) {
+				t.Errorf("%s: incorrect match for %#q", page.file, no)
+			}
+		}
+	}
+}
+
+// Test that the pages of symbols named index do not replace the index,
+// and that names differing only in case are told apart.
+func TestSplitIndexName(t *testing.T) {
+	defer withFiles(t, map[string]string{"idx/idx.go": "package idx\n\n// Index is exported.\nfunc Index() {}\n\n// index is not.\nfunc index() {}\n\n// Foo is exported.\nfunc Foo() {}\n\n// foo is not.\nfunc foo() {}\n"})()
+	dir, err := ioutil.TempDir("", "doc-split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { unexported = false }()
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"-u", "-split", dir, "doc.test/idx"}); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"index.txt":          "foo.2.txt\tfunc foo()",
+		"Index.symbol.txt":   "Index is exported.",
+		"index.symbol.2.txt": "index is not.",
+		"Foo.txt":            "Foo is exported.",
+		"foo.2.txt":          "foo is not.",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s: no %q in\n%s", file, want, data)
+		}
+	}
+}
+
+// Test that -pos documents the identifier at a position, wherever it is
+// declared.
+func TestPos(t *testing.T) {
+	maybeSkip(t)
+	const file = "testdata/pkg.go"
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string // Text of the line holding the identifier.
+		id   string // Text starting at the identifier.
+		yes  string // Regular expression that should match the output.
+	}{
+		{"func ExportedTypeConstructor() *ExportedType {", "ExportedType {", `Comment about exported type`},
+		{"\terror ", "error", `type error interface`},
+		{"\t*unexportedType ", "unexportedType", `Comment about unexported type`},
+	}
+	for _, test := range tests {
+		i := bytes.Index(src, []byte(test.line))
+		if i < 0 {
+			t.Fatalf("no line %q in %s", test.line, file)
+		}
+		line := bytes.Count(src[:i], []byte("\n")) + 1
+		col := strings.Index(test.line, test.id) + 1
+		pos := fmt.Sprintf("%s:%d:%d", file, line, col)
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, []string{"-pos", pos}); err != nil {
+			t.Errorf("%s: %s", pos, err)
 			continue
 		}
+		if !regexp.MustCompile(test.yes).Match(b.Bytes()) {
+			t.Errorf("%s: no match for %#q in\n%s", pos, test.yes, b.Bytes())
+		}
+	}
+}
+
+// Test that a scan that produces nothing in time is abandoned, and that
+// directories found before the timeout are still delivered.
+func TestDirsTimeout(t *testing.T) {
+	d := Dirs{
+		scan:  make(chan string),
+		paths: []string{"/gopath/src/found"},
+	}
+	d.SetTimeout(time.Millisecond)
+	if path, ok := d.Next(); !ok || path != "/gopath/src/found" {
+		t.Fatalf("Next() = %q, %t; want cached path", path, ok)
+	}
+	if path, ok := d.Next(); ok {
+		t.Fatalf("Next() = %q, %t; want timeout", path, ok)
+	}
+	if !strings.Contains(d.status(), "timed out after scanning 1 directories") {
+		t.Errorf("unexpected status %q", d.status())
+	}
+	d.Reset()
+	if _, ok := d.Next(); !ok {
+		t.Errorf("cached path not delivered after timeout")
+	}
+}
+
+// Test that Start walks the trees again when its settings change, as
+// they may between the queries of -batch and -repl.
+func TestDirsRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, pkg := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, "src", pkg), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "src", pkg, "x.go"), []byte("package x\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(fsys FileSystem) { fileSystem = fsys }(fileSystem)
+	fileSystem = osFS{}
+	var d Dirs
+	walk := func(ignore ...string) string {
+		d.Start(false, ignore, []string{dir})
+		d.Reset()
+		var found []string
+		for {
+			path, ok := d.Next()
+			if !ok {
+				break
+			}
+			found = append(found, filepath.Base(path))
+		}
+		return strings.Join(found, " ")
+	}
+	for _, test := range []struct {
+		ignore []string
+		want   string
+	}{
+		{nil, "a b"},
+		{[]string{"a"}, "b"},
+		{[]string{"a"}, "b"},
+		{nil, "a b"},
+	} {
+		if got := walk(test.ignore...); got != test.want {
+			t.Errorf("ignoring %q, found %q; want %q", test.ignore, got, test.want)
+		}
+	}
+}
+
+// Test that the walk follows symbolic links to directories only when
+// asked, and reports each directory once even if it is reachable by
+// several paths or through a cycle.
+func TestWalkSymlinks(t *testing.T) {
+	switch runtime.GOOS {
+	case "nacl", "plan9", "windows":
+		t.Skipf("symlinks not supported on %s", runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "doc-symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"root/src/a", "other/x"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, d, "x.go"), []byte("package x\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"root/src/b":      "a",                     // Duplicate of a.
+		"root/src/a/loop": "..",                    // Cycle.
+		"root/src/c":      "../../other/x",         // Outside the tree.
+		"root/src/d":      "missing",               // Broken.
+		"root/src/a/y.go": "../../../other/x/x.go", // Link to a file.
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk := func(follow bool) []string {
+		d := Dirs{
+			scan:    make(chan string),
+			follow:  follow,
+			visited: make(map[string]bool),
+		}
+		go func() {
+			d.bfsWalkRoot(filepath.Join(dir, "root"))
+			close(d.scan)
+		}()
+		var found []string
+		for path := range d.scan {
+			rel, _ := filepath.Rel(filepath.Join(dir, "root", "src"), path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return found
+	}
+	if got, want := strings.Join(walk(true), " "), "a c"; got != want {
+		t.Errorf("following links, found %q; want %q", got, want)
+	}
+	if got, want := strings.Join(walk(false), " "), "a"; got != want {
+		t.Errorf("not following links, found %q; want %q", got, want)
+	}
+}
+
+// memTree returns a tree holding the files, which map slash-separated
+// names to contents.
+func memTree(files map[string]string) *tree {
+	t := newTree()
+	for name, contents := range files {
+		name = path.Clean(name)
+		contents := contents
+		t.add(name, fileInfo{path.Base(name), int64(len(contents))}, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(contents)), nil
+		})
+	}
+	return t
+}
+
+// withFiles serves the files, which map slash-separated names to contents,
+// from memory in the directory doc.test of the first GOPATH entry, in
+// place of what is there, and returns a function that restores baseFS.
+// It skips the test if there is no GOPATH.
+func withFiles(t *testing.T, files map[string]string) func() {
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		t.Skip("no GOPATH")
+	}
+	baseFS = &mountFS{
+		root: filepath.Join(gopath[0], "src", "doc.test"),
+		tree: memTree(files),
+		base: osFS{},
+	}
+	return func() { baseFS = osFS{} }
+}
+
+// writeZip writes an archive holding the files, which map names to contents.
+func writeZip(t *testing.T, name string, files map[string]string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for name, contents := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(fw, contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test that -zip reads packages from an archive.
+func TestZip(t *testing.T) {
+	maybeSkip(t)
+	dir, err := ioutil.TempDir("", "doc-zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	project := filepath.Join(dir, "project.zip")
+	writeZip(t, project, map[string]string{
+		"root.go":                  "// Package root is at the root of an archive.\npackage root\n",
+		"example.com/proj/proj.go": "// Package proj is in an archive.\npackage proj\n\n// ArchivedFunc is in an archive.\nfunc ArchivedFunc() {}\n",
+	})
+	module := filepath.Join(dir, "module.zip")
+	writeZip(t, module, map[string]string{
+		"example.com/mod@v1.0.0/mod.go":     "// Package mod is in a module zip.\npackage mod\n",
+		"example.com/mod@v1.0.0/sub/sub.go": "// Package sub is in a module zip.\npackage sub\n",
+	})
+	tests := []struct {
+		args []string
+		yes  string
+	}{
+		{[]string{"-zip", project}, `Package root is at the root of an archive`},
+		{[]string{"-zip", project, "example.com/proj"}, `Package proj is in an archive`},
+		{[]string{"-zip", project, "example.com/proj", "archivedfunc"}, `ArchivedFunc is in an archive`},
+		{[]string{"-zip", module, "example.com/mod"}, `Package mod is in a module zip`},
+		{[]string{"-zip", module, "example.com/mod/sub"}, `Package sub is in a module zip`},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, test.args); err != nil {
+			t.Errorf("%s: %s", test.args, err)
+			continue
+		}
+		if !regexp.MustCompile(test.yes).Match(b.Bytes()) {
+			t.Errorf("%s: no match for %#q in\n%s", test.args, test.yes, b.Bytes())
+		}
+	}
+}
+
+// Test that packages are read through baseFS, here from memory.
+func TestFileSystem(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"mem/mem.go": "// Package mem is held in memory.\npackage mem\n\n// InMemory is held in memory.\nfunc InMemory() {}\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"doc.test/mem", "InMemory"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "InMemory is held in memory") {
+		t.Errorf("unexpected output:\n%s", b.Bytes())
+	}
+}
+
+// Test that -list lists the packages in a tree with their synopses.
+func TestList(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"list/list.go":          "// Package list is listed.\npackage list\n",
+		"list/a/a.go":           "// Package a is first. It has two sentences.\npackage a\n",
+		"list/a/doc.go":         "// Package a is documented in doc.go.\npackage a\n",
+		"list/b/nodoc.go":       "package b\n",
+		"list/empty/README":     "No Go files here.\n",
+		"list/empty/c/c.go":     "// Package c is beneath a directory without Go files.\npackage c\n",
+		"list/testdata/t/t.go":  "// Package t is ignored.\npackage t\n",
+		"list/.hidden/h/h.go":   "// Package h is hidden.\npackage h\n",
+		"list/_underscore/u.go": "// Package u is hidden.\npackage u\n",
+		"other/other.go":        "// Package other is not beneath list.\npackage other\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-list", "doc.test/list/..."}); err != nil {
+		t.Fatal(err)
+	}
+	want := "doc.test/list          Package list is listed.\n" +
+		"doc.test/list/a        Package a is documented in doc.go.\n" +
+		"doc.test/list/b        \n" +
+		"doc.test/list/empty/c  Package c is beneath a directory without Go files.\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.Bytes(), want)
+	}
+}
+
+// apiSource is the package used to test API baselines.
+const apiSource = `package api
+
+func F(a, b int) bool
+
+type T struct {
+	A int
+	b int
+}
+
+func (t *T) M(f func(x int) error) {}
+
+const (
+	C0 = 1 << iota
+	C1
+)
+`
+
+// apiFeatures is the API of apiSource.
+var apiFeatures = map[string]string{
+	"const C0":   "const C0 = 1 << 0",
+	"const C1":   "const C1 = 1 << 1",
+	"func F":     "func F(int, int) bool",
+	"type T":     "type T struct",
+	"field T.A":  "type T struct, A int",
+	"method T.M": "method (*T) M(func(int) error)",
+}
+
+var baselineTests = []struct {
+	name   string
+	edit   map[string]string // Changes to apiFeatures; "" deletes.
+	output string
+	status int // Exit status of the *apiDiffError; 0 if none.
+}{
+	{"same", nil, "", 0},
+	{
+		"added",
+		map[string]string{"const C1": ""},
+		"added: const C1 = 1 << 1\n",
+		3,
+	},
+	{
+		"incompatible",
+		map[string]string{"const C1": "", "func F": "func F(int) bool", "func G": "func G()"},
+		"added: const C1 = 1 << 1\n" +
+			"changed: func F(int, int) bool (was func F(int) bool)\n" +
+			"removed: func G()\n",
+		4,
+	},
+}
+
+// Test that the methods of embedded types that are not promoted are
+// listed with what shadows them.
+func TestShadowed(t *testing.T) {
+	defer withFiles(t, map[string]string{"shadow/shadow.go": `package shadow
+
+type Inner struct{}
+
+func (Inner) Name() string { return "" }
+func (Inner) Deep()        {}
+func (Inner) Kept()        {}
+
+type A struct{ Inner }
+
+func (A) Close() error { return nil }
+func (A) Name() string { return "" }
+func (A) Both()        {}
+
+type B struct{}
+
+func (*B) Close() error { return nil }
+func (B) Both()         {}
+
+type T struct {
+	A
+	*B
+	Deep int
+}
+
+func (T) Close() error { return nil }
+`})()
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"doc.test/shadow", "T"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `func (T) Close() error  // overrides A.Close
+func (A) Close() error  // shadowed by T.Close
+func (A) Both()  // not promoted: ambiguous with B.Both
+func (*B) Close() error  // shadowed by T.Close
+func (B) Both()  // not promoted: ambiguous with A.Both
+func (Inner) Name() string  // shadowed by A.Name
+func (Inner) Deep()  // shadowed by field T.Deep
+`
+	if !strings.HasSuffix(b.String(), want) {
+		t.Errorf("got\n%s\nwant it to end\n%s", &b, want)
+	}
+}
+
+// Test that functions making values of one type of the package, wherever
+// they come in the results and whether alone, in slices or in arrays,
+// are shown as that type's constructors.
+func TestConstructors(t *testing.T) {
+	defer withFiles(t, map[string]string{"ctor/ctor.go": `package ctor
+
+type T struct{}
+type U struct{}
+
+func New() (*T, error)            { return nil, nil }
+func Load(name string) (int, *T, error) { return 0, nil, nil }
+func All() []*T                   { return nil }
+func Grid() [2][]T                { return [2][]T{} }
+func Pair() (*T, *U)              { return nil, nil }
+func Count() int                  { return 0 }
+`})()
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"doc.test/ctor"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `func Count() int
+func Pair() (*T, *U)
+type T struct{}
+    func All() []*T
+    func Grid() [2][]T
+    func Load(name string) (int, *T, error)
+    func New() (*T, error)
+type U struct{}
+`
+	if !strings.HasSuffix(b.String(), want) {
+		t.Errorf("got\n%s\nwant it to end\n%s", &b, want)
+	}
+}
+
+// Test that -baseline reports how a package's API differs from a baseline.
+func TestBaseline(t *testing.T) {
+	defer withFiles(t, map[string]string{"api/api.go": apiSource})()
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, test := range baselineTests {
+		api := make(map[string]string)
+		for name, feature := range apiFeatures {
+			api[name] = feature
+		}
+		for name, feature := range test.edit {
+			if feature == "" {
+				delete(api, name)
+			} else {
+				api[name] = feature
+			}
+		}
+		data, err := json.Marshal(&apiBaseline{ImportPath: "doc.test/api", API: api})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, test.name+".json")
+		if err := ioutil.WriteFile(file, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		err = do(&b, &flagSet, []string{"-baseline", file, "doc.test/api"})
+		status := 0
+		if e, ok := err.(*apiDiffError); ok {
+			status = e.exitStatus()
+		} else if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if b.String() != test.output || status != test.status {
+			t.Errorf("%s: got status %d, output\n%s\nwant status %d, output\n%s", test.name, status, b.Bytes(), test.status, test.output)
+		}
+	}
+}
+
+// Test that -diff compares the APIs of two packages, the first the older.
+func TestDiff(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"diff/lib/lib.go":    "package lib\n\nfunc Same() {}\n\nfunc Gone() {}\n\nfunc Changed(int) {}\n",
+		"diff/lib/v2/lib.go": "package lib\n\nfunc Same() {}\n\nfunc Changed(string) {}\n\nfunc New() {}\n",
+		"diff/fork/lib.go":   "package lib\n\nfunc Same() {}\n\nfunc Gone() {}\n\nfunc Changed(int) {}\n\nfunc New() {}\n",
+	})()
+	tests := []struct {
+		args   []string
+		output string
+		status int
+	}{
+		{[]string{"doc.test/diff/lib", "doc.test/diff/lib/v2"}, "added: func New()\nchanged: func Changed(string) (was func Changed(int))\nremoved: func Gone()\n", 4},
+		{[]string{"doc.test/diff/lib", "doc.test/diff/fork"}, "added: func New()\n", 3},
+		{[]string{"doc.test/diff/fork", "doc.test/diff/fork"}, "", 0},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		err := do(&b, new(flag.FlagSet), append([]string{"-diff"}, test.args...))
+		status := 0
+		if e, ok := err.(*apiDiffError); ok {
+			status = e.exitStatus()
+		} else if err != nil {
+			t.Fatalf("%s: %v", test.args, err)
+		}
+		if b.String() != test.output || status != test.status {
+			t.Errorf("%s: got status %d, output\n%s\nwant status %d, output\n%s", test.args, status, b.Bytes(), test.status, test.output)
+		}
+	}
+}
+
+// Test that -record writes a canonical baseline that -baseline accepts.
+func TestRecord(t *testing.T) {
+	defer withFiles(t, map[string]string{"api/api.go": apiSource})()
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "api.json")
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-record", file, "doc.test/api"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"ImportPath": "doc.test/api",
+	"API": {
+		"const C0": "const C0 = 1 << 0",
+		"const C1": "const C1 = 1 << 1",
+		"field T.A": "type T struct, A int",
+		"func F": "func F(int, int) bool",
+		"method T.M": "method (*T) M(func(int) error)",
+		"type T": "type T struct"
+	}
+}
+`
+	if string(data) != want {
+		t.Errorf("recorded\n%s\nwant\n%s", data, want)
+	}
+	b.Reset()
+	flagSet = flag.FlagSet{}
+	if err := do(&b, &flagSet, []string{"-baseline", file, "doc.test/api"}); err != nil || b.Len() > 0 {
+		t.Errorf("-baseline of recorded API: %v\n%s", err, b.Bytes())
+	}
+}
+
+var matchPatternTests = []struct {
+	pattern string
+	path    string
+	match   bool
+}{
+	{"net/...", "net", true},
+	{"net/...", "net/http", true},
+	{"net/...", "netchan", false},
+	{"net/h...", "net/http/cgi", true},
+	{"net/h...", "net/url", false},
+	{"./...", ".", true},
+	{"./...", "./a/b", true},
+	{"...", "anything", true},
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range matchPatternTests {
+		if match := matchPattern(test.pattern)(test.path); match != test.match {
+			t.Errorf("matchPattern(%q)(%q) = %v; want %v", test.pattern, test.path, match, test.match)
+		}
+	}
+}
+
+var matchTests = []struct {
+	env           string
+	user, program string
+	match         bool
+}{
+	{"", "decoder", "Decoder", true},
+	{"", "Decoder", "Decoder", true},
+	{"", "decode", "Decoder", false},
+	{"case", "decoder", "Decoder", false},
+	{"case", "Decoder", "Decoder", true},
+	{"prefix", "decode", "Decoder", true},
+	{"prefix", "Dec", "Decoder", true},
+	{"prefix", "enc", "Decoder", false},
+	{"case prefix", "dec", "Decoder", false},
+	{"case prefix", "Dec", "Decoder", true},
+	{"prefix whole", "dec", "Decoder", false},
+	{"case fold", "decoder", "Decoder", true},
+}
+
+func TestMatch(t *testing.T) {
+	defer func() { matchCase, matchPrefix = false, false }()
+	for _, test := range matchTests {
+		matchCase, matchPrefix = matchDefaults(test.env)
+		if m := match(test.user, test.program); m != test.match {
+			t.Errorf("GODOCMATCH=%q: match(%q, %q) = %v; want %v", test.env, test.user, test.program, m, test.match)
+		}
+	}
+}
+
+// Test that a package pattern prints the docs of each package it matches.
+func TestPatternDoc(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"pat/a/a.go":          "// Package a is first.\npackage a\n\nfunc A() {}\n",
+		"pat/a/b/b.go":        "// Package b is beneath a.\npackage b\n\nfunc B() {}\n",
+		"pat/c/c.go":          "// Package c is last.\npackage c\n\nfunc C() {}\n",
+		"pat/c/testdata/t.go": "// Package t is ignored.\npackage t\n",
+	})()
+	rule := "\n" + strings.Repeat("-", punchedCardWidth) + "\n\n"
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"doc.test/pat/..."},
+			"package a // import \"doc.test/pat/a\"\n\nPackage a is first.\n\nfunc A()\n" + rule +
+				"package b // import \"doc.test/pat/a/b\"\n\nPackage b is beneath a.\n\nfunc B()\n" + rule +
+				"package c // import \"doc.test/pat/c\"\n\nPackage c is last.\n\nfunc C()\n",
+		},
+		{
+			[]string{"doc.test/pat/c/...", "doc.test/pat/a/b"},
+			"package c // import \"doc.test/pat/c\"\n\nPackage c is last.\n\nfunc C()\n" + rule +
+				"package b // import \"doc.test/pat/a/b\"\n\nPackage b is beneath a.\n\nfunc B()\n",
+		},
+	} {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.args, b.Bytes(), test.want)
+		}
+	}
+}
+
+// Test that unsaved buffers replace and add to the files on disk.
+func TestBuffers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	saved := "package buf\n\n// Saved is on disk.\nfunc Saved() {}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "saved.go"), []byte(saved), 0666); err != nil {
+		t.Fatal(err)
+	}
+	buffers := map[string]string{
+		"saved.go":                       "package buf\n\n// Saved is being edited.\nfunc Saved() {}\n",
+		filepath.Join(dir, "unsaved.go"): "package buf\n\n// Unsaved is only in a buffer.\nfunc Unsaved() {}\n",
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"Saved"}, "Saved is being edited."},
+		{[]string{"Unsaved"}, "Unsaved is only in a buffer."},
+	} {
+		var b bytes.Buffer
+		if err := docBuffers(&b, dir, buffers, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), test.want) {
+			t.Errorf("%s: no %q in\n%s", test.args, test.want, b.Bytes())
+		}
+	}
+	if workDir != "" {
+		t.Errorf("workDir = %q after docBuffers; want \"\"", workDir)
+	}
+}
+
+// Test that a snapshotFS keeps serving a file as first read.
+func TestSnapshotFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "x.go")
+	if err := ioutil.WriteFile(file, []byte("package x\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fsys := newSnapshotFS(osFS{})
+	read := func() string {
+		r, err := fsys.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	before := read()
+	if err := ioutil.WriteFile(file, []byte("package y\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if after := read(); after != before {
+		t.Errorf("snapshot changed from %q to %q", before, after)
+	}
+	if list, err := fsys.ReadDir(dir); err != nil || len(list) != 1 {
+		t.Errorf("ReadDir = %v, %v; want x.go", list, err)
+	}
+}
+
+// Test that an overlay listing a snapshot's directory leaves the
+// snapshot's listing as it was.
+func TestOverlaySnapshot(t *testing.T) {
+	snapshot := newSnapshotFS(memTree(map[string]string{"d/b.go": "", "d/c.go": "", "d/e.go": ""}))
+	fsys := newOverlayFS(snapshot, "d", map[string]string{"a0.go": ""})
+	names := func(list []os.FileInfo) []string {
+		var names []string
+		for _, info := range list {
+			names = append(names, info.Name())
+		}
+		return names
+	}
+	for i := 0; i < 2; i++ {
+		list, err := fsys.ReadDir("d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := names(list), []string{"a0.go", "b.go", "c.go", "e.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("overlay ReadDir #%d = %v; want %v", i+1, got, want)
+		}
+		list, err = snapshot.ReadDir("d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := names(list), []string{"b.go", "c.go", "e.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("snapshot ReadDir #%d = %v; want %v", i+1, got, want)
+		}
+	}
+}
+
+// Test that docBatch answers each query in turn.
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	buffers := map[string]string{
+		"b.go": "package b\n\n// One is first.\nfunc One() {}\n\n// Two is second.\nfunc Two() {}\n",
+	}
+	answers, err := docBatch(dir, buffers, [][]string{{"One"}, {"Two"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"func One()\n    One is first.\n\n", "func Two()\n    Two is second.\n\n"}
+	if len(answers) != len(want) {
+		t.Fatalf("got %d answers; want %d", len(answers), len(want))
+	}
+	for i := range want {
+		if answers[i] != want[i] {
+			t.Errorf("answer %d = %q; want %q", i, answers[i], want[i])
+		}
+	}
+}
+
+// Test that -batch answers each query in turn, going on after errors.
+func TestRunBatch(t *testing.T) {
+	maybeSkip(t)
+	in := strings.NewReader(p + " ExportedFunc\n\n" + p + " Nope\n" + p + ".ExportedType.ExportedMethod\n")
+	var b bytes.Buffer
+	if err := runBatch(&b, in); err != nil {
+		t.Fatal(err)
+	}
+	answers := strings.SplitAfter(b.String(), "\n"+batchEnd)
+	if len(answers) != 4 {
+		t.Fatalf("got %d answers, want 3:\n%s", len(answers)-1, b.String())
+	}
+	for i, want := range []string{
+		`^func ExportedFunc\(a int\) bool\n    Comment about exported function.\n\n\x1e$`,
+		`^ok\ndoc: no symbol Nope in package cmd/doc/testdata\n\x1e$`,
+		`^error\nfunc \(ExportedType\) ExportedMethod\(a int\) bool\n(.|\n)*\x1e$`,
+		`^ok\n$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(answers[i]) {
+			t.Errorf("answer %d = %q; want match for %q", i, answers[i], want)
+		}
+	}
+	if inBatch || packageCache != nil {
+		t.Error("batch state not reset")
+	}
+}
+
+// Test that an invalid flag in a query of -batch fails that query alone.
+func TestRunBatchInvalidFlags(t *testing.T) {
+	maybeSkip(t)
+	queries := []string{
+		"-sort size " + p,
+		"-width 3 " + p,
+		"-indent x " + p,
+		"-escape xml " + p,
+		"-satisfies-constraint int " + p + ".NoSuchInterface",
+		"-pos testdata/nosuch.go:1:1",
+		p + " ExportedFunc",
+	}
+	var b bytes.Buffer
+	if err := runBatch(&b, strings.NewReader(strings.Join(queries, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+	answers := strings.Split(b.String(), batchEnd)
+	if len(answers) != len(queries)+1 {
+		t.Fatalf("got %d answers, want %d:\n%s", len(answers)-1, len(queries), &b)
+	}
+	for i, answer := range answers[:len(queries)-1] {
+		if !strings.Contains(answer, "doc: ") {
+			t.Errorf("answer to %q has no error: %q", queries[i], answer)
+		}
+	}
+	if last := answers[len(queries)-1]; !strings.Contains(last, "func ExportedFunc(a int) bool") {
+		t.Errorf("answer to %q = %q", queries[len(queries)-1], last)
+	}
+}
+
+// Test that -batch answers its queries from a snapshot of the files.
+func TestRunBatchSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "s.go")
+	write := func(comment string) {
+		src := "package s\n\n// A is " + comment + ".\nconst A = 1\n"
+		if err := ioutil.WriteFile(file, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old")
+	workDir = dir
+	defer func() { workDir = "" }()
+	// The second query, without -u, parses the package again, after
+	// the file has changed.
+	changed := readerFunc(func(p []byte) (int, error) {
+		write("new")
+		return 0, io.EOF
+	})
+	in := io.MultiReader(strings.NewReader("-u A\n"), changed, strings.NewReader("A\n"))
+	var b bytes.Buffer
+	if err := runBatch(&b, in); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(b.String(), "A is old."); got != 2 || strings.Contains(b.String(), "A is new.") {
+		t.Errorf("answers not from a snapshot:\n%s", &b)
+	}
+	if _, ok := baseFS.(*snapshotFS); ok {
+		t.Error("snapshot not removed after the batch")
+	}
+}
+
+// A readerFunc is an io.Reader that calls itself to read.
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+// Test that a -record query in a batch leaves the parameter names of the
+// package's declarations for the queries after it.
+func TestRunBatchRecord(t *testing.T) {
+	maybeSkip(t)
+	dir, err := ioutil.TempDir("", "doc-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "api.txt")
+	in := strings.NewReader("-record " + file + " " + p + "\n" + p + " ExportedFunc\n" + p + ".ExportedType.ExportedMethod\n")
+	var b bytes.Buffer
+	if err := runBatch(&b, in); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"func ExportedFunc(a int) bool\n", "func (ExportedType) ExportedMethod(a int) bool\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("no %q in output:\n%s", want, &b)
+		}
+	}
+}
+
+// Test the interactive browser's search, navigation and rendering.
+func TestBrowser(t *testing.T) {
+	maybeSkip(t)
+	buildCtx = build.Default
+	bpkg, err := buildCtx.Import(p, "", build.ImportComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	b := newBrowser(parsePackage(&out, bpkg, p), 120, 10)
+	listed := make(map[string]bool)
+	for _, item := range b.items {
+		if listed[item.label] {
+			t.Errorf("%s listed twice", item.label)
+		}
+		listed[item.label] = true
+	}
+	for _, key := range "/exportedfunc" {
+		b.handleKey(key)
+	}
+	b.handleKey(keyEnter)
+	if item := b.items[b.shown[b.selected]]; item.symbol != "ExportedFunc" {
+		t.Fatalf("selected %q after search; want ExportedFunc", item.label)
+	}
+	var screen bytes.Buffer
+	b.render(&screen)
+	if !strings.Contains(screen.String(), "│func ExportedFunc(a int) bool") ||
+		!strings.Contains(screen.String(), "Comment about exported function.") {
+		t.Errorf("no preview of ExportedFunc in\n%s", screen.Bytes())
+	}
+	b.handleKey(keyEscape)
+	if len(b.shown) != len(b.items) {
+		t.Errorf("escape left %d of %d items shown", len(b.shown), len(b.items))
+	}
+	b.selectSymbol("ExportedType.ExportedMethod")
+	b.handleKey(keyDown)
+	b.handleKey(keyUp)
+	if item := b.items[b.shown[b.selected]]; item.symbol != "ExportedType.ExportedMethod" {
+		t.Errorf("selected %q after down and up; want ExportedType.ExportedMethod", item.label)
+	}
+	if out.Len() > 0 {
+		t.Errorf("browser wrote to the package's writer:\n%s", out.Bytes())
+	}
+	if !b.handleKey('q') {
+		t.Error("q did not quit")
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[A\x1b[Bx\r\x7f\x03"))
+	want := []rune{keyUp, keyDown, 'x', keyEnter, keyBackspace, keyQuit}
+	for _, w := range want {
+		if key, err := readKey(r); key != w || err != nil {
+			t.Errorf("readKey = %d, %v; want %d", key, err, w)
+		}
+	}
+}
+
+// Test that -outline nests members beneath their types, with positions.
+func TestOutline(t *testing.T) {
+	maybeSkip(t)
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-outline", p}); err != nil {
+		t.Fatal(err)
+	}
+	var o outline
+	if err := json.Unmarshal(b.Bytes(), &o); err != nil {
+		t.Fatalf("%v in\n%s", err, b.Bytes())
+	}
+	find := func(items []*outlineItem, name string) *outlineItem {
+		for _, item := range items {
+			if item.Name == name {
+				return item
+			}
+		}
+		t.Fatalf("no %s in outline", name)
+		return nil
+	}
+	typ := find(o.Items, "ExportedType")
+	if typ.Kind != "type" || filepath.Base(typ.File) != "pkg.go" || typ.Line != 61 || typ.Column != 6 {
+		t.Errorf("ExportedType is %+v; want type at pkg.go:61:6", typ)
+	}
+	for _, want := range []struct{ name, kind string }{
+		{"ExportedField", "field"},
+		{"ExportedTypedConstant", "const"},
+		{"ExportedTypeConstructor", "func"},
+		{"ExportedMethod", "method"},
+	} {
+		if item := find(typ.Children, want.name); item.Kind != want.kind {
+			t.Errorf("%s is a %s; want %s", want.name, item.Kind, want.kind)
+		}
+	}
+	if item := find(find(o.Items, "ExportedInterface").Children, "Reader"); item.Kind != "embedded" {
+		t.Errorf("io.Reader in ExportedInterface is a %s; want embedded", item.Kind)
+	}
+	// Typed values and constructors are only beneath their types.
+	for _, item := range o.Items {
+		for _, child := range typ.Children {
+			if item.Name == child.Name {
+				t.Errorf("%s is in the outline and beneath ExportedType", item.Name)
+			}
+		}
+	}
+}
+
+// Test that -proto writes the outline, with signatures and docs, in the
+// wire format of doc.proto.
+func TestProto(t *testing.T) {
+	maybeSkip(t)
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-proto", p}); err != nil {
+		t.Fatal(err)
+	}
+	pkg := decodeProto(t, b.Bytes())
+	if name := string(pkg.bytes[protoPackageName][0]); name != "pkg" {
+		t.Errorf("name = %q; want pkg", name)
+	}
+	var typ *protoFields
+	for _, sym := range pkg.bytes[protoPackageSymbols] {
+		if f := decodeProto(t, sym); string(f.bytes[protoSymbolName][0]) == "ExportedType" {
+			typ = f
+		}
+	}
+	if typ == nil {
+		t.Fatal("no ExportedType in message")
+	}
+	if kind := typ.varints[protoSymbolKind]; kind != protoKinds["type"] {
+		t.Errorf("ExportedType has kind %d; want %d", kind, protoKinds["type"])
+	}
+	if sig := string(typ.bytes[protoSymbolSignature][0]); sig != "type ExportedType struct{ ... }" {
+		t.Errorf("ExportedType has signature %q", sig)
+	}
+	if doc := string(typ.bytes[protoSymbolDoc][0]); !strings.HasPrefix(doc, "Comment about exported type.") {
+		t.Errorf("ExportedType has doc %q", doc)
+	}
+	pos := decodeProto(t, typ.bytes[protoSymbolPosition][0])
+	if line, col := pos.varints[protoPositionLine], pos.varints[protoPositionColumn]; line != 61 || col != 6 {
+		t.Errorf("ExportedType is at %d:%d; want 61:6", line, col)
+	}
+	found := false
+	for _, child := range typ.bytes[protoSymbolChildren] {
+		f := decodeProto(t, child)
+		if string(f.bytes[protoSymbolName][0]) == "ExportedMethod" {
+			found = true
+			if want := "func (ExportedType) ExportedMethod(a int) bool"; string(f.bytes[protoSymbolSignature][0]) != want {
+				t.Errorf("ExportedMethod has signature %q; want %q", f.bytes[protoSymbolSignature][0], want)
+			}
+		}
+	}
+	if !found {
+		t.Error("no ExportedMethod beneath ExportedType")
+	}
+}
+
+// Test that -dump writes the model with typed constants and constructors
+// both beneath their types and at the top level.
+func TestDump(t *testing.T) {
+	maybeSkip(t)
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-dump", p}); err != nil {
+		t.Fatal(err)
+	}
+	var d dumpPackage
+	if err := json.Unmarshal(b.Bytes(), &d); err != nil {
+		t.Fatalf("%v in\n%s", err, b.Bytes())
+	}
+	hasConst := func(list []*dumpValue, name string) bool {
+		for _, v := range list {
+			for _, n := range v.Names {
+				if n == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	hasFunc := func(list []*dumpFunc, name string) bool {
+		for _, f := range list {
+			if f.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasConst(d.Consts, "ExportedTypedConstant") {
+		t.Error("ExportedTypedConstant missing from package constants")
+	}
+	if !hasFunc(d.Funcs, "ExportedTypeConstructor") {
+		t.Error("ExportedTypeConstructor missing from package functions")
+	}
+	for _, typ := range d.Types {
+		if typ.Name != "ExportedType" {
+			continue
+		}
+		if !hasConst(typ.Consts, "ExportedTypedConstant") || !hasFunc(typ.Funcs, "ExportedTypeConstructor") {
+			t.Errorf("ExportedType lacks its constant or constructor: %+v", typ)
+		}
+		if !strings.HasPrefix(typ.Decl, "type ExportedType struct {") || !strings.HasSuffix(typ.Pos, "pkg.go:61:1") {
+			t.Errorf("ExportedType is %q at %s", typ.Decl, typ.Pos)
+		}
+		return
+	}
+	t.Error("no ExportedType in dump")
+}
+
+// protoFields holds the fields of a decoded message by number.
+type protoFields struct {
+	varints map[int]uint64
+	bytes   map[int][][]byte
+}
+
+// decodeProto decodes a message of varint and length-delimited fields.
+func decodeProto(t *testing.T, b []byte) *protoFields {
+	f := &protoFields{varints: make(map[int]uint64), bytes: make(map[int][][]byte)}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad key in %q", b)
+		}
+		b = b[n:]
+		num := int(key >> 3)
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad varint in %q", b)
+		}
+		b = b[n:]
+		switch key & 7 {
+		case wireVarint:
+			f.varints[num] = x
+		case wireBytes:
+			if uint64(len(b)) < x {
+				t.Fatalf("field %d overruns message", num)
+			}
+			f.bytes[num] = append(f.bytes[num], b[:x])
+			b = b[x:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return f
+}
+
+// Test that -repl carries the current place between queries and does not
+// parse a package again.
+func TestREPL(t *testing.T) {
+	maybeSkip(t)
+	buildCtx = build.Default
+	unexported = false
+	var b bytes.Buffer
+	in := strings.NewReader(".ExportedType\n.ExportedMethod\n..\n.ExportedTypeConstructor\n..\n.Nope\n..\n")
+	if err := runREPL(&b, in, []string{p}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package pkg // import \"cmd/doc/testdata\"\n",
+		"pkg> type ExportedType struct {",
+		"pkg.ExportedType> func (ExportedType) ExportedMethod(a int) bool\n",
+		"pkg.ExportedType.ExportedMethod> pkg.ExportedType> func ExportedTypeConstructor() *ExportedType\n",
+		"pkg.ExportedTypeConstructor> pkg> no symbol Nope in package cmd/doc/testdata\n",
+		"pkg> > \n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("no %q in\n%s", want, b.Bytes())
+		}
+	}
+	r := &replState{writer: &b, packages: make(map[string]*Package)}
+	first, err := r.load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := r.load(p); again != first {
+		t.Error("package parsed again")
+	}
+}
+
+// Test that a package that does not parse fails its query of -repl,
+// and the session goes on.
+func TestREPLParseError(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"repl/bad/bad.go":   "package bad\n\nfunc {\n",
+		"repl/good/good.go": "package good\n\n// Sym is good.\nconst Sym = 1\n",
+	})()
+	buildCtx, fileSystem = build.Default, baseFS
+	useFileSystem(&buildCtx, fileSystem)
+	defer func() { buildCtx, fileSystem = build.Default, osFS{} }()
+	var b bytes.Buffer
+	in := strings.NewReader("doc.test/repl/bad\ndoc.test/repl/good.Sym\n")
+	if err := runREPL(&b, in, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"bad.go:3:6: expected", "> const Sym = 1\n", "good.Sym> \n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("no %q in\n%s", want, &b)
+		}
+	}
+	if inREPL {
+		t.Error("-repl state not reset")
+	}
+}
+
+const satisfySource = `package sat
+
+import "io"
+
+type NamedCloser interface {
+	io.Closer
+	Name() string
+}
+
+type File struct{}
+
+func (*File) Close() error  { return nil }
+func (File) Name() string { return "" }
+
+type Number struct{}
+
+func (Number) Close() error { return nil }
+func (Number) Name() int    { return 0 }
+`
+
+var satisfyTests = []struct {
+	typ  string
+	want string // The output, or the error.
+}{
+	{"*doc.test/sat.File", "*sat.File satisfies sat.NamedCloser\n"},
+	{"doc.test/sat.File", "sat.File does not satisfy sat.NamedCloser:\n\tmethod Close has pointer receiver; *sat.File satisfies sat.NamedCloser for it"},
+	{"doc.test/sat.Number", "sat.Number does not satisfy sat.NamedCloser:\n\twrong signature for Name: have Name() int, want Name() string"},
+	{"doc.test/sat.NamedCloser", "sat.NamedCloser satisfies sat.NamedCloser\n"},
+	{"doc.test/sat.NamedCloser io.Reader", "sat.NamedCloser does not satisfy io.Reader:\n\tmissing method Read([]byte) (int, error)"},
+}
+
+// Test that -satisfies-constraint compares method sets and explains
+// the differences.
+func TestSatisfiesConstraint(t *testing.T) {
+	defer withFiles(t, map[string]string{"sat/sat.go": satisfySource})()
+	for _, test := range satisfyTests {
+		args := strings.Fields(test.typ)
+		if len(args) == 1 {
+			args = append(args, "doc.test/sat.NamedCloser")
+		}
+		var b bytes.Buffer
+		err := do(&b, new(flag.FlagSet), append([]string{"-satisfies-constraint"}, args...))
+		got := b.String()
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q; want %q", test.typ, got, test.want)
+		}
+	}
+}
+
+// Test that -calls lists the exported functions and methods called, of
+// the package and others in its repository.
+func TestCalls(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"repo/calls/calls.go": `package calls
+
+import (
+	"strings"
+
+	"doc.test/repo/calls/sub"
+)
+
+type T struct{}
+
+func NewT() *T { return &T{} }
+
+func (t *T) Run(s string) int { t.step(); return sub.Count(strings.TrimSpace(s)) }
+
+func (*T) step() { Helper(); helper() }
+
+func Helper() { NewT().Run("") }
+
+func helper() {}
+
+type U struct{}
+
+func (U) Close() {}
+
+func Entry() {
+	t := NewT()
+	t.Run("x")
+	Helper()
+	var u U
+	u.Close()
+	new(sub.V).Do()
+}
+`,
+		"repo/calls/sub/sub.go": "package sub\n\nfunc Count(s string) int { return len(s) }\n\ntype V struct{}\n\nfunc (*V) Do() {}\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-calls", "doc.test/repo/calls.Entry"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "func NewT() *T\n" +
+		"func (t *T) Run(s string) int\n" +
+		"func Helper()\n" +
+		"func (U) Close()\n"
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+	b.Reset()
+	if err := do(&b, new(flag.FlagSet), []string{"-calls", "doc.test/repo/calls.T.Run"}); err != nil {
+		t.Fatal(err)
+	}
+	want = "func sub.Count(s string) int\n"
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+	b.Reset()
+	if err := do(&b, new(flag.FlagSet), []string{"-u", "-calls", "doc.test/repo/calls.T.step"}); err != nil {
+		t.Fatal(err)
+	}
+	want = "func Helper()\nfunc helper()\n"
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+const commentSource = `// Package comments has doc comments that render badly.
+package comments
+
+// Steps are:
+// - the first,
+// - the second.
+func Steps() {}
+
+// Address is
+// 1 Main St
+// Springfield
+// USA.
+const Address = 1
+
+// Accident continues this sentence
+//  on a line indented by mistake.
+func Accident() {}
+
+// Code is fine, indented by a tab:
+//	if Code() {
+//		return 'this line is too long to be shown in eighty columns without overflowing'
+//	}
+func Code() bool { return false }
+
+// The list of unexported declarations is not checked:
+// - one
+// - two
+func unexported() {}
+`
+
+// Test that -check-comments reports doc comments that render badly.
+func TestCheckComments(t *testing.T) {
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		t.Skip("no GOPATH")
+	}
+	root := filepath.Join(gopath[0], "src", "doc.test")
+	defer withFiles(t, map[string]string{"comments/comments.go": commentSource})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	err := do(&b, &flagSet, []string{"-check-comments", "doc.test/comments"})
+	if err == nil || err.Error() != "4 problems in doc comments of doc.test/comments" {
+		t.Errorf("got error %v; want 4 problems", err)
+	}
+	file := shortPath(filepath.Join(root, "comments", "comments.go"))
+	want := file + ":4: list items will be joined into one paragraph; indent the list to keep its lines\n" +
+		file + ":9: 4 short lines will be joined into one paragraph; indent them to keep their line breaks, or join them\n" +
+		file + ":16: indented line starts a preformatted block within a paragraph; remove the indentation to continue the paragraph, or put a blank line before it if it is code\n" +
+		file + ":21: preformatted line is 88 columns wide when shown, and is not wrapped to the output width of 80; break it or shorten it\n"
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+// Test that -check-import-comments reports the packages installed at
+// paths other than those of their import comments.
+func TestCheckImportComments(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"imp/a/a.go":     "package a // import \"doc.test/imp/a\"\n",
+		"imp/b/b.go":     "package b // import \"example.com/b\"\n",
+		"imp/c/c.go":     "package c\n",
+		"imp/c/d/d.go":   "package d // import \"example.com/d\"\n",
+		"other/e/e.go":   "package e // import \"example.com/e\"\n",
+		"imp/f/f.go":     "package f // import \"doc.test/imp/f\"\n",
+		"imp/f/f_doc.go": "// Package f is documented here.\npackage f\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	err := do(&b, &flagSet, []string{"-check-import-comments", "doc.test/imp"})
+	if err == nil || err.Error() != "2 packages are not installed at their import paths" {
+		t.Errorf("got error %v; want 2 packages", err)
+	}
+	want := "doc.test/imp/b: import comment \"example.com/b\" differs from install path \"doc.test/imp/b\"\n" +
+		"doc.test/imp/c/d: import comment \"example.com/d\" differs from install path \"doc.test/imp/c/d\"\n"
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+// Test that -cgo shows what a cgo package exports to and uses from C.
+func TestOutput(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"out/a/a.go":   "// Package a is first.\npackage a\n",
+		"out/a/b/b.go": "// Package b is in a.\npackage b\n",
+	})()
+	dir, err := ioutil.TempDir("", "doc-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	file := filepath.Join(dir, "a.txt")
+	if err := do(&b, &flagSet, []string{"-o", file, "doc.test/out/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() > 0 {
+		t.Errorf("-o printed %q", b.Bytes())
+	}
+	if data, err := ioutil.ReadFile(file); err != nil || !strings.Contains(string(data), "Package a is first.") {
+		t.Errorf("%s: got %q, %v; want doc of package a", file, data, err)
+	}
+
+	flagSet = flag.FlagSet{}
+	if err := do(&b, &flagSet, []string{"-o", filepath.Join(dir, "all"), "doc.test/out/..."}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"all/doc.test/out/a.txt":   "Package a is first.",
+		"all/doc.test/out/a/b.txt": "Package b is in a.",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s: got %q, %v; want %q", name, data, err, want)
+		}
+	}
+
+	// In -batch, a query that fails writes nothing.
+	oldFatalf := fatalf
+	inBatch, fatalf = true, panicf
+	defer func() { inBatch, fatalf = false, oldFatalf }()
+	failed := filepath.Join(dir, "failed.txt")
+	if err := batchQuery(&b, []string{"-o", failed, "-escape", "xml", "doc.test/out/a"}); err == nil {
+		t.Error("-escape xml: no error")
+	}
+	if _, err := os.Stat(failed); err == nil {
+		t.Errorf("%s written for a failed query", failed)
+	}
+}
+
+func TestImports(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"imports/a.go": "// Package imports imports.\npackage imports\n\nimport (\n\t\"io\"\n\n\t\"example.com/x\"\n)\n",
+		"imports/b.go": "package imports\n\nimport (\n\t\"fmt\"\n\t\"io\"\n)\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-imports", "doc.test/imports"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Package imports imports.\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\n\t\"example.com/x\"\n)\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+const hierarchySource = `package h
+
+import "io"
+
+type Base interface{ Base() }
+
+type Middle interface {
+	Base
+	Middle()
+}
+
+type Top interface {
+	Middle
+	io.Closer
+}
+
+type Value struct{}
+
+func (Value) Base() {}
+
+type Pointer struct{}
+
+func (*Pointer) Base()   {}
+func (*Pointer) Middle() {}
+
+// Embedding promotes Pointer's methods to the value.
+type Embed struct{ *Pointer }
+
+func (Embed) Close() error { return nil }
+`
+
+func TestHierarchy(t *testing.T) {
+	defer withFiles(t, map[string]string{"h/h.go": hierarchySource})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-hierarchy", "doc.test/h.Middle"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `h.Middle
+├── embeds
+│   └── h.Base
+│       └── implemented by
+│           ├── h.Embed
+│           ├── *h.Pointer
+│           └── h.Value
+├── embedded by
+│   └── h.Top
+│       └── implemented by
+│           └── h.Embed
+└── implemented by
+    ├── h.Embed
+    └── *h.Pointer
+`
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+func TestConventions(t *testing.T) {
+	defer withFiles(t, map[string]string{"conv/conv.go": `package conv
+
+import "context"
+
+func Good(ctx context.Context, a, b int) (int, error) { return 0, nil }
+func Late(a, b int, ctx context.Context)                {}
+func Early() (error, int)                               { return nil, 0 }
+
+type T struct{}
+
+func (T) Plain(s string) string { return s }
+`})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-conventions", "doc.test/conv"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `package conv // import "doc.test/conv"
+
+func Early() (error, int)  // error not last
+func Good(ctx context.Context, a, b int) (int, error)  // context first; error last
+func Late(a, b int, ctx context.Context)  // context not first
+func (T) Plain(s string) string
+
+4 functions and methods:
+    1 take a context.Context first, 1 elsewhere
+    1 return an error last, 1 elsewhere
+`
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+// Test that -returns lists the functions and methods with a result of a
+// type, or of a pointer to it.
+func TestReturns(t *testing.T) {
+	defer withFiles(t, map[string]string{"ret/ret.go": `package ret
+
+import "io"
+
+type T struct{}
+
+func New() *T                         { return nil }
+func Open(name string) (io.Reader, error) { return nil, nil }
+func Value() T                        { return T{} }
+func hidden() T                       { return T{} }
+
+func (t *T) Clone() (*T, error) { return t, nil }
+func (T) Reader() io.Reader    { return nil }
+`})()
+	for _, test := range []struct {
+		typ, want string
+	}{
+		{"T", "func New() *T\nfunc Value() T\nfunc (t *T) Clone() (*T, error)\n"},
+		{"ret.T", "func New() *T\nfunc Value() T\nfunc (t *T) Clone() (*T, error)\n"},
+		{"*T", "func New() *T\nfunc (t *T) Clone() (*T, error)\n"},
+		{"io.Reader", "func Open(name string) (io.Reader, error)\nfunc (T) Reader() io.Reader\n"},
+	} {
+		var b bytes.Buffer
+		if err := do(&b, new(flag.FlagSet), []string{"-returns", test.typ, "doc.test/ret"}); err != nil {
+			t.Errorf("-returns %s: %v", test.typ, err)
+			continue
+		}
+		want := "package ret // import \"doc.test/ret\"\n\n" + test.want
+		if b.String() != want {
+			t.Errorf("-returns %s: got\n%swant\n%s", test.typ, &b, want)
+		}
+	}
+	inBatch = true
+	defer func() { inBatch = false }()
+	var b bytes.Buffer
+	err := batchQuery(&b, []string{"-returns", "int", "doc.test/ret"})
+	if e, ok := err.(PackageError); !ok || e.kind != errSymbol {
+		t.Errorf("-returns int: got error %#v; want a PackageError of kind %q", err, errSymbol)
+	}
+}
+
+// Test that -accepts lists the functions and methods with a parameter of
+// a type, or of a pointer to it, including a variadic one.
+func TestAccepts(t *testing.T) {
+	defer withFiles(t, map[string]string{"acc/acc.go": `package acc
+
+import "io"
+
+type T struct{}
+
+func Copy(dst io.Writer, src io.Reader) error { return nil }
+func Merge(ts ...*T) *T                    { return nil }
+func Use(t T)                              {}
+func use(t T)                              {}
+
+func (t *T) ReadFrom(r io.Reader) (int64, error) { return 0, nil }
+func (T) Close() error                           { return nil }
+`})()
+	for _, test := range []struct {
+		typ, want string
+	}{
+		{"T", "func Merge(ts ...*T) *T\nfunc Use(t T)\n"},
+		{"*acc.T", "func Merge(ts ...*T) *T\n"},
+		{"io.Reader", "func Copy(dst io.Writer, src io.Reader) error\nfunc (t *T) ReadFrom(r io.Reader) (int64, error)\n"},
+	} {
+		var b bytes.Buffer
+		if err := do(&b, new(flag.FlagSet), []string{"-accepts", test.typ, "doc.test/acc"}); err != nil {
+			t.Errorf("-accepts %s: %v", test.typ, err)
+			continue
+		}
+		want := "package acc // import \"doc.test/acc\"\n\n" + test.want
+		if b.String() != want {
+			t.Errorf("-accepts %s: got\n%swant\n%s", test.typ, &b, want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	defer withFiles(t, map[string]string{"stats/stats.go": `package stats
+
+// Sizes.
+const (
+	Small = 1
+	Large = 2
+)
+
+// T is a type.
+type T int
+
+// NewT returns a T.
+//
+// Deprecated: Use a literal.
+func NewT() T { return 0 }
+
+func (T) Method() {}
+
+// I is an interface.
+type I interface {
+	// Do does.
+	Do()
+	Undone()
+}
+
+var V, W int
+
+func unexported() {}
+`})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-stats", "doc.test/stats"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `package stats // import "doc.test/stats"
+
+types       2
+functions   1
+methods     3
+constants   2
+variables   2
+deprecated  1
+documented  6 of 10 (60%)
+`
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+func TestStub(t *testing.T) {
+	defer withFiles(t, map[string]string{"stub/stub.go": `// Package stub is stubbed.
+package stub
+
+import (
+	"io"
+	"strings"
+)
+
+// Kinds.
+const (
+	A kind = iota // The first.
+	b
+	C
+)
+
+type kind int
+
+func (k kind) String() string { return strings.Repeat("k", int(k)) }
+
+var (
+	// R reads.
+	R io.Reader = newReader()
+	w io.Writer
+)
+
+func newReader() io.Reader { return nil }
+
+// Do does.
+func Do(k kind) {
+	println(k)
+}
+
+type T struct {
+	X int // The X.
+	y int
+}
+
+func (T) hidden() {}
+`})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-stub", "doc.test/stub"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Package stub is stubbed.
+package stub
+
+import (
+	"io"
+)
+
+// Kinds.
+const (
+	A kind = iota // The first.
+	b
+	C
+)
+
+type kind int
+
+func (k kind) String() string { panic("stub") }
+
+var (
+	// R reads.
+	R io.Reader = newReader()
+)
+
+func newReader() io.Reader { panic("stub") }
+
+// Do does.
+func Do(k kind) {}
+
+type T struct {
+	X int // The X.
+	y int
+}
+`
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	defer withFiles(t, map[string]string{"tool/main.go": `// Tool does things.
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var verbose = flag.Bool("v", false, "print more")
+
+var wait time.Duration
+
+func main() {
+	flag.DurationVar(&wait, "wait", time.Second, "wait for ` + "`d`" + `")
+	fs := flag.NewFlagSet("sub", flag.ExitOnError)
+	fs.String("out", "a.out", "write to "+"file")
+	var n int
+	fs.IntVar(&n, "n", 0, "count")
+	fs.Parse(nil)
+}
+`})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"doc.test/tool"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `Tool does things.
+
+Flags:
+    -n int
+        count
+    -out string
+        write to file (default "a.out")
+    -v
+        print more
+    -wait d
+        wait for d (default time.Second)
+`
+	if b.String() != want {
+		t.Errorf("got\n%swant\n%s", b.Bytes(), want)
+	}
+}
+
+func TestSynopsis(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"fromfunc/main.go": `// Fromfunc does things.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [-v] file...\n", os.Args[0])
+	os.Exit(2)
+}
+`,
+		"fromconst/main.go": `// Fromconst does things.
+package main
+
+const usageMessage = "Usage of fromconst:\n\tfromconst -a\n\tfromconst -b\nMore.\n"
+`,
+		"fromdoc/main.go": `// Fromdoc does things.
+//
+// Usage:
+//
+//	go tool fromdoc [flags]
+//
+// More.
+package main
+`,
+		"none/main.go": `// None does things.
+package main
+`,
+	})()
+	for _, test := range []struct {
+		cmd, want string
+	}{
+		{"fromfunc", "Usage:\n    fromfunc [-v] file...\n\nFromfunc does things.\n"},
+		{"fromconst", "Usage:\n    fromconst -a\n    fromconst -b\n\nFromconst does things.\n"},
+		{"fromdoc", "Usage:\n    go tool fromdoc [flags]\n\nFromdoc does things.\n"},
+		{"none", "None does things.\n"},
+	} {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, []string{"doc.test/" + test.cmd}); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: got\n%swant prefix\n%s", test.cmd, got, test.want)
+		}
+	}
+}
+
+// Test that $GOFLAGS, from the environment or the go env file, sets -tags
+// unless the command line does.
+func TestGoFlags(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"gf/gf.go":     "// Package gf is tagged.\npackage gf\n\n// Plain is always there.\nconst Plain = 1\n",
+		"gf/tagged.go": "// +build gfone,gftwo\n\npackage gf\n\n// Tagged needs tags.\nconst Tagged = 2\n",
+	})()
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	envFile := filepath.Join(dir, "env")
+	if err := ioutil.WriteFile(envFile, []byte("GOFLAGS=-mod=vendor -tags=gfone,gftwo\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GOENV", os.Getenv("GOENV"))
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Unsetenv("GOFLAGS")
+	for _, test := range []struct {
+		goenv, goflags string
+		args           []string
+		tagged         bool
+	}{
+		{goenv: "off"},
+		{goenv: envFile, tagged: true},
+		{goenv: "off", goflags: "-tags=gfone,gftwo", tagged: true},
+		{goenv: envFile, args: []string{"-tags=gfone"}},
+		{goenv: envFile, goflags: "-tags=gfone"},
+	} {
+		os.Setenv("GOENV", test.goenv)
+		if test.goflags != "" {
+			os.Setenv("GOFLAGS", test.goflags)
+		} else {
+			os.Unsetenv("GOFLAGS")
+		}
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, append(test.args, "doc.test/gf")); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(b.String(), "Tagged"); got != test.tagged {
+			t.Errorf("GOENV=%s GOFLAGS=%q %v: Tagged shown is %v; want %v\n%s", test.goenv, test.goflags, test.args, got, test.tagged, b.Bytes())
+		}
+	}
+}
+
+// Test that the external test package of a package is documented with
+// -xtest, or given a path ending in _test.
+func TestXTest(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"helper/helper.go": `package helper
+
+// Do does.
+func Do() {}
+`,
+		"helper/helper_test.go": `package helper
+
+func TestDo(t *testing.T) {}
+`,
+		"helper/x_test.go": `package helper_test
+
+// Fixture returns a value for tests of helper.
+func Fixture() int { return 1 }
+
+func ExampleDo() {}
+`,
+	})()
+	tests := []struct {
+		args []string
+		want []string
+		no   []string
+	}{
+		{
+			[]string{"doc.test/helper_test"},
+			[]string{"package helper_test", "func ExampleDo()", "func Fixture() int"},
+			[]string{"func Do()", "TestDo"},
+		},
+		{
+			[]string{"-xtest", "doc.test/helper", "Fixture"},
+			[]string{"func Fixture() int", "Fixture returns a value for tests of helper."},
+			nil,
+		},
+		{
+			[]string{"doc.test/helper_test.Fixture"},
+			[]string{"func Fixture() int"},
+			nil,
+		},
+		{
+			[]string{"doc.test/helper"},
+			[]string{"func Do()"},
+			[]string{"Fixture"},
+		},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, test.args); err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%q: no %q in\n%s", test.args, want, &b)
+			}
+		}
+		for _, no := range test.no {
+			if strings.Contains(b.String(), no) {
+				t.Errorf("%q: unexpected %q in\n%s", test.args, no, &b)
+			}
+		}
+	}
+}
+
+func TestExamples(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"ex/ex.go": `package ex
+
+// Do does.
+func Do() {}
+
+type T int
+
+// M is a method.
+func (T) M() {}
+`,
+		"ex/ex_test.go": `package ex_test
+
+import "doc.test/ex"
+
+func ExampleDo() {
+	// Do it.
+	ex.Do()
+	// Output:
+	// done
+}
+
+func ExampleDo_twice() {
+	ex.Do()
+	ex.Do()
+}
+
+func ExampleT_M() {
+	var t ex.T
+	t.M()
+}
+`,
+	})()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-ex", "doc.test/ex", "Do"}, `func Do()
+    Do does.
+
+Example:
+    // Do it.
+    ex.Do()
+
+    Output:
+        done
+
+Example (twice):
+    ex.Do()
+    ex.Do()
+
+`},
+		{[]string{"-ex", "doc.test/ex", "T.M"}, `func (T) M()
+    M is a method.
+
+Example:
+    var t ex.T
+    t.M()
+
+`},
+		{[]string{"doc.test/ex", "Do"}, `func Do()
+    Do does.
+
+`},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, test.args); err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%q: got\n%swant\n%s", test.args, got, test.want)
+		}
+	}
+}
+
+func TestSignatureHelp(t *testing.T) {
+	defer withFiles(t, map[string]string{"sig/sig.go": `package sig
+
+// Pad returns s padded to width w. It's done with the rune r,
+// or with spaces if r is 0. E.g. Pad("x", 3, 0) is "x  ".
+func Pad(s string, w int, r rune, _ ...bool) string { return s }
+`})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-sighelp", "doc.test/sig", "Pad"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []signatureHelp
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("%v in\n%s", err, &b)
+	}
+	want := []signatureHelp{{
+		Label: "func Pad(s string, w int, r rune, _ ...bool) string",
+		Doc:   "Pad returns s padded to width w.",
+		Parameters: []signatureParam{
+			{"s", "string", "Pad returns s padded to width w."},
+			{"w", "int", "Pad returns s padded to width w."},
+			{"r", "rune", "It's done with the rune r, or with spaces if r is 0."},
+			{"_", "...bool", ""},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// Test that errors are of the kinds that decide the exit status.
+func TestErrorKinds(t *testing.T) {
+	defer withFiles(t, map[string]string{"broken/broken.go": "package broken\n\nfunc {\n"})()
+	// As for -batch, failf panics rather than exits.
+	defer func() { inBatch = false }()
+	inBatch = true
+	tests := []struct {
+		args []string
+		kind string
+	}{
+		{[]string{p, "NoSuchSymbol"}, errSymbol},
+		{[]string{p, "ExportedType.NoSuchMethod"}, errSymbol},
+		{[]string{"-literal", p, "NoSuchType"}, errSymbol},
+		{[]string{"doc.test/nosuch/pkg.Symbol"}, errPackage},
+		{[]string{"doc.test/broken"}, errParse},
+		{[]string{"-literal", p, "ExportedType.ExportedMethod"}, errOther},
+		{[]string{"-satisfies-constraint", p + ".NoSuchType", "io.Reader"}, errSymbol},
+		{[]string{"-pos", "testdata/pkg.go:1:1"}, errSymbol},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		err := batchQuery(&b, test.args)
+		e, ok := err.(PackageError)
+		if !ok {
+			t.Errorf("%q: got error %#v; want a PackageError", test.args, err)
+			continue
+		}
+		if e.kind != test.kind {
+			t.Errorf("%q: got error %q of kind %q; want kind %q", test.args, e, e.kind, test.kind)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		t.Skip("no GOPATH")
+	}
+	defer withFiles(t, map[string]string{
+		"tr/tr.go":  "package tr\n\n// Sym is traced.\nconst Sym = 1\n",
+		"tr/gen.go": "// +build ignore\n\npackage main\n",
+	})()
+	var trace bytes.Buffer
+	defer func() { warnings = os.Stderr }()
+	warnings = &trace
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-x", "doc.test/tr.Sym"}); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(gopath[0], "src", "doc.test", "tr")
+	for _, want := range []string{
+		"doc: x: doc.test/tr.Sym is not an import path: ",
+		"doc: x: not parsing " + filepath.Join(dir, "gen.go") + ": it is excluded by build constraints\n",
+		"doc: x: parsing " + filepath.Join(dir, "tr.go") + "\n",
+	} {
+		if !strings.Contains(trace.String(), want) {
+			t.Errorf("no %q in trace:\n%s", want, &trace)
+		}
+	}
+	if !strings.Contains(b.String(), "const Sym = 1") {
+		t.Errorf("unexpected output:\n%s", &b)
+	}
+
+	// Without -x, nothing is traced.
+	trace.Reset()
+	b.Reset()
+	if err := do(&b, new(flag.FlagSet), []string{"doc.test/tr.Sym"}); err != nil {
+		t.Fatal(err)
+	}
+	if trace.Len() > 0 {
+		t.Errorf("traced without -x:\n%s", &trace)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOCACHE", "off")
+	os.Setenv("GOCACHE", dir)
+	files := map[string]string{"ca/ca.go": "package ca\n\n// Sym is cached.\nconst Sym = 1\n"}
+	query := func(arg string) string {
+		defer withFiles(t, files)()
+		var b bytes.Buffer
+		if err := do(&b, new(flag.FlagSet), []string{arg}); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+	first := query("doc.test/ca.Sym")
+	if !strings.Contains(first, "const Sym = 1") {
+		t.Fatalf("unexpected output:\n%s", first)
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "doc", "*", "*"))
+	if len(entries) != 1 {
+		t.Fatalf("cache holds %q, want one entry", entries)
+	}
+
+	// The second time, the output comes from the cache.
+	data, err := json.Marshal(cachedDoc{Output: "from the cache\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(entries[0], data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if out := query("doc.test/ca.Sym"); out != "from the cache\n" {
+		t.Errorf("cache not used; got:\n%s", out)
+	}
+
+	// A change to the package's files misses it.
+	files["ca/ca.go"] = "package ca\n\n// Sym is changed.\nconst Sym = 2\n"
+	if out := query("doc.test/ca.Sym"); !strings.Contains(out, "const Sym = 2") {
+		t.Errorf("stale output after change:\n%s", out)
+	}
+
+	// So does a change to another package read to answer the query.
+	files["ca/ca.go"] = "package ca\n\nimport \"doc.test/cb\"\n\n// Sym is cached.\nconst Sym = 1\n\n// T is another package's type.\ntype T cb.U\n"
+	files["cb/cb.go"] = "package cb\n\ntype U map[string]int\n"
+	if out := query("doc.test/ca.T"); !strings.Contains(out, "underlying: map[string]int (map)") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	files["cb/cb.go"] = "package cb\n\ntype U []string\n"
+	if out := query("doc.test/ca.T"); !strings.Contains(out, "underlying: []string (slice)") {
+		t.Errorf("stale output after change to another package:\n%s", out)
+	}
+
+	// So does a go.mod file added above it, which changes the warnings.
+	var w bytes.Buffer
+	defer func() { warnings = os.Stderr }()
+	warnings = &w
+	query("doc.test/ca")
+	files["ca/go.mod"] = "module example.com/ca\n"
+	w.Reset()
+	query("doc.test/ca")
+	if !strings.Contains(w.String(), "warning: module:") {
+		t.Errorf("no module warning after adding go.mod; got:\n%s", &w)
+	}
+	warnings = os.Stderr
+
+	// GOCACHE=off turns it off.
+	os.Setenv("GOCACHE", "off")
+	files["ca/ca.go"] = "package ca\n\n// Sym is not cached.\nconst Sym = 3\n"
+	query("doc.test/ca.Sym")
+	if entries, _ := filepath.Glob(filepath.Join(dir, "doc", "*", "*")); len(entries) != 5 {
+		t.Errorf("cache holds %q, want five entries", entries)
+	}
+}
+
+func TestStdArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("documenting the standard library takes too long")
+	}
+	maybeSkip(t)
+	dir, err := ioutil.TempDir("", "doc-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOCACHE", "off")
+	os.Setenv("GOCACHE", dir)
+	// Documenting the standard library draws a few warnings.
+	defer func() { warnings = os.Stderr }()
+	warnings = ioutil.Discard
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"-std-archive"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "wrote the documentation of ") {
+		t.Fatalf("unexpected output:\n%s", &b)
+	}
+	for _, test := range []struct {
+		args             []string
+		symbol, method   string
+		archived, clause bool
+	}{
+		{[]string{"fmt"}, "", "", true, true},
+		{[]string{"fmt.Println"}, "Println", "", true, false},
+		{[]string{"json.decoder"}, "decoder", "", true, true},
+		{[]string{"io.Reader.Read"}, "Reader", "Read", true, false},
+		{[]string{"os.O_RDONLY"}, "O_RDONLY", "", true, false},
+		{[]string{"json.Un"}, "Un", "", false, false}, // Unmarshal, Unmarshaler and so on.
+	} {
+		os.Setenv("GOCACHE", "off")
+		var want bytes.Buffer
+		if err := do(&want, new(flag.FlagSet), test.args); err != nil && test.archived {
+			t.Fatal(err)
+		}
+		os.Setenv("GOCACHE", dir)
+		dirs.Reset()
+		bpkg, userPath, _, _ := parseArgs(test.args)
+		b.Reset()
+		if archived := archivedDoc(&b, bpkg, userPath, test.symbol, test.method); archived != test.archived {
+			t.Errorf("%s: archived = %v, want %v", test.args, archived, test.archived)
+			continue
+		}
+		if test.archived && b.String() != want.String() {
+			t.Errorf("%s: archived doc:\n%s\nwant:\n%s", test.args, &b, &want)
+		}
+		if test.archived && strings.HasPrefix(b.String(), "package ") != test.clause {
+			t.Errorf("%s: package clause shown = %v, want %v", test.args, !test.clause, test.clause)
+		}
+	}
+}
+
+func TestDocBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "out.docz")
+	defer withFiles(t, map[string]string{
+		"bun/a/a.go":     "// Package a is bundled.\npackage a\n\n// F is a function.\nfunc F() {}\n\n// T is a type.\ntype T int\n\n// M is a method.\nfunc (T) M() {}\n",
+		"bun/x/x.go":     "package x\n",
+		"bun/sub/x/x.go": "package x\n",
+	})()
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"-bundle", file, "doc.test/bun/..."}); err != nil {
+		t.Fatal(err)
+	}
+	// The bundle is read without the source.
+	baseFS = osFS{}
+	for _, test := range []struct {
+		args []string
+		yes  []string
+		no   []string
+	}{
+		{[]string{"bun/a"}, []string{`package a // import "doc.test/bun/a"`, "Package a is bundled.", "func F\\(\\)", "type T int"}, nil},
+		{[]string{"a.F"}, []string{`^package a // import "doc.test/bun/a"`, "F is a function."}, []string{"T is a type."}},
+		{[]string{"doc.test/bun/a.t"}, []string{`^type T int`, "T is a type.", "func \\(T\\) M\\(\\)"}, []string{"package a"}},
+		{[]string{"a", "T.M"}, []string{`^func \(T\) M\(\)`, "M is a method."}, []string{"T is a type."}},
+	} {
+		b.Reset()
+		if err := do(&b, new(flag.FlagSet), append([]string{"-frombundle", file}, test.args...)); err != nil {
+			t.Errorf("%s: %v", test.args, err)
+			continue
+		}
+		for _, yes := range test.yes {
+			if !regexp.MustCompile("(?m)" + yes).Match(b.Bytes()) {
+				t.Errorf("%s: no match for %s in:\n%s", test.args, yes, &b)
+			}
+		}
+		for _, no := range test.no {
+			if regexp.MustCompile("(?m)" + no).Match(b.Bytes()) {
+				t.Errorf("%s: unexpected match for %s in:\n%s", test.args, no, &b)
+			}
+		}
+	}
+
+	// As for -batch, failf panics rather than exits.
+	defer func() { inBatch = false }()
+	inBatch = true
+	for _, test := range []struct {
+		arg, kind string
+	}{
+		{"x", errAmbiguous},
+		{"nosuch.F", errPackage},
+		{"a.NoSuch", errSymbol},
+	} {
+		err := batchQuery(&b, []string{"-frombundle", file, test.arg})
+		if e, ok := err.(PackageError); !ok || e.kind != test.kind {
+			t.Errorf("%s: got error %v; want one of kind %s", test.arg, err, test.kind)
+		}
+	}
+}
+
+func TestManPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doc-man")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer withFiles(t, map[string]string{
+		"man/lib/lib.go":       "// Package lib has a man page.\n//\n// .netrc files are read.\n//\n//\t`\\n` is not an escape\npackage lib\n\n// C is a constant.\nconst C = 1\n\n// F is a function.\nfunc F() {}\n\n// T is a type.\ntype T int\n\n// M is a method.\nfunc (T) M() {}\n",
+		"man/cmd/tool/main.go": "// Tool does things.\n//\n// Usage:\n//\n//\ttool [-v] file\npackage main\n\nimport \"flag\"\n\nvar v = flag.Bool(\"v\", false, \"be verbose\")\n\n// Exported is not shown.\nfunc Exported() {}\n\nfunc main() {}\n",
+	})()
+	var b bytes.Buffer
+	if err := do(&b, new(flag.FlagSet), []string{"-man-all", dir, "doc.test/man/..."}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "wrote 2 man pages to " + dir + "\n"; b.String() != want {
+		t.Errorf("got %q; want %q", &b, want)
+	}
+	for _, test := range []struct {
+		file string
+		yes  []string
+		no   []string
+	}{
+		{
+			"doc.test.man.lib.3go",
+			[]string{
+				`^\.TH "doc\.test\.man\.lib" 3go`,
+				`^\.SH NAME\ndoc\.test/man/lib \\- Package lib has a man page\.\n`,
+				`^\.SH SYNOPSIS\n\.nf\nimport "doc\.test/man/lib"\n\.fi\n`,
+				`^\.SH DESCRIPTION\n\.nf\nPackage lib has a man page\.\n`,
+				`^\\&\.netrc files are read\.$`,
+				"^    `\\\\en` is not an escape$",
+				`^\.SH CONSTANTS\n\.SS C\n\.nf\nconst C = 1\n    C is a constant\.\n\.fi\n`,
+				`^\.SH FUNCTIONS\n\.SS F\n\.nf\nfunc F\(\)\n`,
+				`^\.SH TYPES\n\.SS T\n\.nf\ntype T int\n    T is a type\.\n\n+func \(T\) M\(\)\n\nfunc \(T\) M\(\)\n    M is a method\.\n\.fi\n`,
+			},
+			[]string{`package lib`, `VARIABLES`},
+		},
+		{
+			"tool.1",
+			[]string{
+				`^\.TH "tool" 1`,
+				`^\.SH NAME\ntool \\- Tool does things\.\n`,
+				`^\.SH SYNOPSIS\n\.nf\ntool \[-v\] file\n\.fi\n`,
+				`^    -v\n        be verbose`,
+			},
+			[]string{`Exported`, `FUNCTIONS`},
+		},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, test.file))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, yes := range test.yes {
+			if !regexp.MustCompile("(?m)" + yes).Match(data) {
+				t.Errorf("%s: no match for %s in:\n%s", test.file, yes, data)
+			}
+		}
+		for _, no := range test.no {
+			if regexp.MustCompile("(?m)" + no).Match(data) {
+				t.Errorf("%s: unexpected match for %s in:\n%s", test.file, no, data)
+			}
+		}
+	}
+}
+
+// Test that -remote documents a module downloaded from a proxy, here one
+// in a directory, into its own cache, and later reads it offline, along
+// with those the go command has verified in the module cache.
+func TestRemote(t *testing.T) {
+	maybeSkip(t)
+	dir, err := ioutil.TempDir("", "doc-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	proxy := filepath.Join(dir, "proxy", "example.com", "!mod")
+	if err := os.MkdirAll(filepath.Join(proxy, "@v"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	info := `{"Version":"v1.1.0"}`
+	for name, contents := range map[string]string{
+		"@latest":        info,
+		"@v/v1.1.0.info": info,
+		"@v/v1.0.0.info": `{"Version":"v1.0.0"}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(proxy, filepath.FromSlash(name)), []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeZip(t, filepath.Join(proxy, "@v", "v1.1.0.zip"), map[string]string{
+		"example.com/Mod@v1.1.0/mod.go":     "// Package mod is in a module.\npackage mod\n\n// F is new in v1.1.0.\nfunc F() {}\n",
+		"example.com/Mod@v1.1.0/sub/sub.go": "// Package sub is in a module too.\npackage sub\n",
+	})
+	writeZip(t, filepath.Join(proxy, "@v", "v1.0.0.zip"), map[string]string{
+		"example.com/Mod@v1.0.0/mod.go": "// Package mod is in a module.\npackage mod\n\n// F is old in v1.0.0.\nfunc F() {}\n",
+	})
+	// The module cache holds a version the go command has verified, with
+	// its hash, and one it has not.
+	modCache := filepath.Join(dir, "mod", "cache", "download", "example.com", "!mod", "@v")
+	if err := os.MkdirAll(modCache, 0777); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(modCache, "v0.9.0.zip"), map[string]string{
+		"example.com/Mod@v0.9.0/mod.go": "// Package mod is in a module.\npackage mod\n\n// F is verified in v0.9.0.\nfunc F() {}\n",
+	})
+	if err := ioutil.WriteFile(filepath.Join(modCache, "v0.9.0.ziphash"), []byte("h1:\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(modCache, "v0.8.0.zip"), map[string]string{
+		"example.com/Mod@v0.8.0/mod.go": "package mod\n",
+	})
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	defer os.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	defer os.Setenv("GOCACHE", "off")
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(filepath.Join(dir, "proxy")))
+	os.Setenv("GOMODCACHE", filepath.Join(dir, "mod"))
+	os.Setenv("GOCACHE", filepath.Join(dir, "cache"))
+	tests := []struct {
+		args []string
+		yes  string
+	}{
+		{[]string{"example.com/Mod"}, `Package mod is in a module`},
+		{[]string{"example.com/Mod.F"}, `F is new in v1.1.0`},
+		{[]string{"example.com/Mod/sub"}, `Package sub is in a module too`},
+		{[]string{"example.com/Mod@v1.0.0", "F"}, `F is old in v1.0.0`},
+		{[]string{"example.com/Mod@latest", "F"}, `F is new in v1.1.0`},
+	}
+	run := func() {
+		for _, test := range tests {
+			var b bytes.Buffer
+			if err := do(&b, new(flag.FlagSet), append([]string{"-remote"}, test.args...)); err != nil {
+				t.Errorf("%s: %s", test.args, err)
+				continue
+			}
+			if !regexp.MustCompile(test.yes).Match(b.Bytes()) {
+				t.Errorf("%s: no match for %#q in\n%s", test.args, test.yes, b.Bytes())
+			}
+		}
+	}
+	run()
+	if _, err := os.Stat(filepath.Join(dir, "cache", "doc", "download", "example.com", "!mod", "@v", "v1.0.0.zip")); err != nil {
+		t.Errorf("module not in the cache: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(modCache, "v1.0.0.zip")); err == nil {
+		t.Errorf("module downloaded into the module cache")
+	}
+
+	// Versions given exactly are read from the caches.
+	os.Setenv("GOPROXY", "off")
+	tests = append(tests[3:4], struct {
+		args []string
+		yes  string
+	}{[]string{"example.com/Mod@v0.9.0", "F"}, `F is verified in v0.9.0`})
+	run()
+
+	// As for -batch, failf panics rather than exits.
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(filepath.Join(dir, "proxy")))
+	defer func() { inBatch = false }()
+	inBatch = true
+	var b bytes.Buffer
+	for _, arg := range []string{"example.com/nosuch", "example.com/Mod@v0.8.0"} {
+		err = batchQuery(&b, []string{"-remote", arg})
+		if e, ok := err.(PackageError); !ok || e.kind != errPackage {
+			t.Errorf("%s: got error %v; want one of kind %s", arg, err, errPackage)
+		}
+	}
+}
+
+func TestModulePaths(t *testing.T) {
+	for _, test := range []struct {
+		arg  string
+		want string
+	}{
+		{"rsc.io/quote", "rsc.io/quote"},
+		{"rsc.io/quote.Hello", "rsc.io/quote.Hello rsc.io/quote"},
+		{"golang.org/x/text/language.Tag.String", "golang.org/x/text/language.Tag.String golang.org/x/text/language.Tag golang.org/x/text/language golang.org/x/text golang.org/x"},
+		{"json", "json"},
+		{"local/pkg", "local/pkg"},
+	} {
+		if got := strings.Join(modulePaths(test.arg), " "); got != test.want {
+			t.Errorf("modulePaths(%q) = %q; want %q", test.arg, got, test.want)
+		}
+	}
+}
+
+func TestSkipBodies(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			"package p\nfunc F() int {\n\treturn 1\n}\n",
+			"package p\nfunc F() int {\n         \n}\n",
+		},
+		{
+			// Braces of types in the signature are not the body.
+			"package p\nfunc (t *T) M(s struct{ x int }) interface{ N() } { return t }\n",
+			"package p\nfunc (t *T) M(s struct{ x int }) interface{ N() } {          }\n",
+		},
+		{
+			// Functions without bodies and function literals are kept.
+			"package p\nfunc Asm(x int)\nvar V = func() { g() }\n",
+			"package p\nfunc Asm(x int)\nvar V = func() { g() }\n",
+		},
+		{
+			// Comments, which may be notes, are kept, in their groups.
+			"package p\nfunc F() {\n\tx := 1 // TODO(r): x.\n\t// BUG(r): y.\n\tif x { y() }\n}\n",
+			"package p\nfunc F() {\n      ; // TODO(r): x.\n // BUG(r): y.\n             \n}\n",
+		},
+	}
+	for _, test := range tests {
+		if got := string(skipBodies([]byte(test.src))); got != test.want {
+			t.Errorf("skipBodies(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+// BenchmarkOneLineNode measures summarizing the declarations of a large
+// generated package, as its package doc does.
+func BenchmarkOneLineNode(b *testing.B) {
+	var src bytes.Buffer
+	src.WriteString("package gen\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&src, "type T%d struct{ x int }\n", i)
+		fmt.Fprintf(&src, "func (t *T%d) M(a, b int, m map[string][]*T%d) (n int, err error) { return }\n", i, i)
+		fmt.Fprintf(&src, "func F%d(f func(int) bool, c ...interface{}) *T%d { return nil }\n", i, i)
+		fmt.Fprintf(&src, "var V%d = make(map[string]int)\n", i)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "gen.go", src.Bytes(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pkg := &Package{fs: fset}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, decl := range file.Decls {
+			if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.TYPE {
+				pkg.oneLineNode(d.Specs[0])
+				continue
+			}
+			pkg.oneLineNode(decl)
+		}
+	}
+}
+
+const leaksSource = `package api
+
+import (
+	"io"
+
+	x "doc.test/leaks/internal/impl"
+)
+
+// Safe uses no internal types.
+func Safe(r io.Reader) error { return nil }
+
+// Open returns an internal connection.
+func Open(name string) (*x.Conn, error) { return nil, nil }
+
+// Client wraps internal types.
+type Client struct {
+	Conn   x.Conn
+	hidden x.Conn
+	x.Options
+}
+
+// Do takes an internal request.
+func (c *Client) Do(r x.Request) {}
+
+func (c *Client) do(r x.Request) {}
+
+// Default is an internal configuration.
+var Default x.Options
+`
+
+func TestLeaks(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"leaks/api/api.go":             leaksSource,
+		"leaks/internal/impl/impl.go":  "package impl\n\ntype Conn struct{}\n\ntype Options struct{}\n\ntype Request struct{}\n",
+		"leaks/internal/impl/other.go": "package impl\n\n// Internal packages are not checked.\nfunc New() Conn { return Conn{} }\n",
+	})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	err := do(&b, &flagSet, []string{"-leaks", "doc.test/leaks/..."})
+	want := "doc.test/leaks/api.Default: doc.test/leaks/internal/impl.Options\n" +
+		"doc.test/leaks/api.Open: doc.test/leaks/internal/impl.Conn\n" +
+		"doc.test/leaks/api.Client: doc.test/leaks/internal/impl.Conn, doc.test/leaks/internal/impl.Options\n" +
+		"doc.test/leaks/api.Client.Do: doc.test/leaks/internal/impl.Request\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if err == nil || err.Error() != "4 exported symbols use internal types" {
+		t.Errorf("got error %v, want 4 exported symbols", err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		t.Skip("no GOPATH")
+	}
+	defer withFiles(t, map[string]string{
+		"warn/moved/moved.go":            "// Package moved moved.\npackage moved // import \"example.com/moved\"\n\n// X is exported.\nconst X = 1\n",
+		"warn/lib/internal/impl/impl.go": "// Package impl is internal.\npackage impl\n\n// X is exported.\nconst X = 1\n",
+		"warn/fork/go.mod":               "module \"example.com/orig\" // The original.\n",
+		"warn/fork/sub/sub.go":           "// Package sub is forked.\npackage sub\n\n// X is exported.\nconst X = 1\n",
+	})()
+	defer func() { warnings = os.Stderr }()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"doc.test/warn/moved"},
+			`doc: warning: install: package example.com/moved: package source is installed in "doc.test/warn/moved"` + "\n",
+		},
+		{
+			[]string{"-json", "doc.test/warn/moved"},
+			`{"kind":"install","message":"package example.com/moved: package source is installed in \"doc.test/warn/moved\""}` + "\n",
+		},
+		{
+			[]string{"doc.test/warn/lib/internal/impl"},
+			"doc: warning: internal: package doc.test/warn/lib/internal/impl is internal: not importable from here, only from beneath " +
+				filepath.Join(gopath[0], "src", "doc.test", "warn", "lib") + "\n",
+		},
+		{
+			[]string{"doc.test/warn/fork/sub"},
+			`doc: warning: module: package doc.test/warn/fork/sub: ` + filepath.Join(gopath[0], "src", "doc.test", "warn", "fork", "go.mod") +
+				` implies import path "example.com/orig/sub"` + "\n",
+		},
+	}
+	for _, test := range tests {
+		var b, w bytes.Buffer
+		warnings = &w
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.String(); got != test.want {
+			t.Errorf("%v: got warnings\n%s\nwant\n%s", test.args, got, test.want)
+		}
+		if out := b.String(); strings.Contains(out, "installed") || !strings.Contains(out, "const X = 1") {
+			t.Errorf("%v: unexpected output\n%s", test.args, out)
+		}
+	}
+
+	// An internal package is importable from beneath its parent.
+	var b, w bytes.Buffer
+	warnings = &w
+	workDir = filepath.Join(gopath[0], "src", "doc.test", "warn", "lib")
+	defer func() { workDir = "" }()
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"doc.test/warn/lib/internal/impl"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() > 0 {
+		t.Errorf("got warnings from beneath the parent:\n%s", w.Bytes())
+	}
+}
+
+const underlyingSource = `package under
+
+import "net/url"
+
+// Header maps keys to values.
+type Header map[string][]string
+
+// Values is a Header.
+type Values Header
+
+// Query is a url.Values.
+type Query url.Values
+
+// Node is a pointer to a Header.
+type Node *Header
+
+// Ref is a Node.
+type Ref Node
+
+// Count is an int.
+type Count int
+`
+
+func TestUnderlying(t *testing.T) {
+	defer withFiles(t, map[string]string{"under/under.go": underlyingSource})()
+	tests := []struct {
+		sym  string
+		want string // "" for none.
+	}{
+		{"Header", ""},
+		{"Values", "underlying: map[string][]string (map)"},
+		{"Query", "underlying: map[string][]string (map)"},
+		{"Ref", "underlying: *Header (pointer)"},
+		{"Count", ""},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, []string{"doc.test/under", test.sym}); err != nil {
+			t.Fatal(err)
+		}
+		out := b.String()
+		if test.want == "" {
+			if strings.Contains(out, "underlying:") {
+				t.Errorf("%s: unexpected underlying type in\n%s", test.sym, out)
+			}
+		} else if !strings.Contains(out, "\n    "+test.want+"\n") {
+			t.Errorf("%s: no %q in\n%s", test.sym, test.want, out)
+		}
+	}
+}
+
+const layoutSource = `package layout
+
+import "time"
+
+const n = 4
+
+// Record has padding.
+type Record struct {
+	Flag  bool
+	Count int64
+	Name  string
+	Small, Other int16
+	Buf   [n]byte
+	When  time.Duration
+	Empty struct{}
+}
+
+// Count is an int.
+type Count int
+`
+
+func TestLayout(t *testing.T) {
+	defer withFiles(t, map[string]string{"layout/layout.go": layoutSource})()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"-goarch", "amd64", "doc.test/layout", "Record"},
+			"    offset  size align  field\n" +
+				"         0     1     1  Flag bool\n" +
+				"         8     8     8  Count int64\n" +
+				"        16    16     8  Name string\n" +
+				"        32     2     2  Small int16\n" +
+				"        34     2     2  Other int16\n" +
+				"        36     4     1  Buf [n]byte\n" +
+				"        40     8     8  When time.Duration\n" +
+				"        48     0     1  Empty struct{}\n" +
+				"    size 56, align 8, padding 15 on amd64\n",
+		},
+		{
+			[]string{"-goarch", "386", "doc.test/layout", "Record"},
+			"    offset  size align  field\n" +
+				"         0     1     1  Flag bool\n" +
+				"         4     8     4  Count int64\n" +
+				"        12     8     4  Name string\n" +
+				"        20     2     2  Small int16\n" +
+				"        22     2     2  Other int16\n" +
+				"        24     4     1  Buf [n]byte\n" +
+				"        28     8     4  When time.Duration\n" +
+				"        36     0     1  Empty struct{}\n" +
+				"    size 40, align 4, padding 7 on 386\n",
+		},
+		{
+			[]string{"-goarch", "386", "doc.test/layout", "Count"},
+			"    size 4, align 4 on 386\n",
+		},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, append([]string{"-layout"}, test.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if out := b.String(); !strings.Contains(out, "\n\n"+test.want) {
+			t.Errorf("%v: no\n%s\nin\n%s", test.args, test.want, out)
+		}
+	}
+}
+
+const literalSource = `package lit
+
+import (
+	"io"
+	"time"
+)
+
+// Options configures.
+type Options struct {
+	Name    string
+	Verbose bool
+	Timeout time.Duration
+	Out     io.Writer
+	Tags    []string
+	Limits  Limits
+	Key     [4]byte
+	Next    *Options
+	Hidden  Missing
+	Handler
+	private int
+}
+
+// Limits limits.
+type Limits struct{ Max int }
+
+// Handler handles.
+type Handler func()
+
+// Count is not a struct.
+type Count int
+`
+
+func TestLiteral(t *testing.T) {
+	defer withFiles(t, map[string]string{"lit/lit.go": literalSource})()
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-literal", "doc.test/lit", "Options"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `&lit.Options{
+	Name:    "",
+	Verbose: false,
+	Timeout: 0,
+	Out:     nil,
+	Tags:    nil,
+	Limits:  lit.Limits{},
+	Key:     [4]byte{},
+	Next:    nil,
+	Hidden:  *new(lit.Missing),
+	Handler: nil,
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestClassifyArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		kind int
+	}{
+		{"select", argTopic},
+		{":=", argTopic},
+		{"<-", argTopic},
+		{"iota", argTopic},
+		{"++", argTopic},
+		{"(", argOperator},
+		{"...", argPackage},
+		{".", argPackage},
+		{"..", argPackage},
+		{"json", argPackage},
+		{"json.Decoder", argPackage},
+		{"net/http", argPackage},
+		{"<-c", argPackage},
+	}
+	for _, test := range tests {
+		if kind := classifyArg(test.arg); kind != test.kind {
+			t.Errorf("classifyArg(%q) = %d, want %d", test.arg, kind, test.kind)
+		}
+	}
+}
+
+func TestLang(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"lang/lang.go":   "package lang\n\n// Old is in every version.\nconst Old = 1\n",
+		"lang/go17.go":   "// +build go1.7\n\npackage lang\n\n// Go17 needs Go 1.7.\nconst Go17 = 1\n",
+		"lang/future.go": "// +build go1.99\n\npackage lang\n\n// Future needs Go 1.99.\nconst Future = 1\n",
+		"lang/exp.go":    "// +build goexperiment.doctest\n\npackage lang\n\n// Exp needs an experiment.\nconst Exp = 1\n",
+	})()
+	defer os.Setenv("GOEXPERIMENT", os.Getenv("GOEXPERIMENT"))
+	tests := []struct {
+		args         []string
+		goexperiment string
+		yes, no      []string
+	}{
+		{nil, "", []string{"Old", "Go17"}, []string{"Future", "Exp"}},
+		{[]string{"-lang", "go1.6"}, "", []string{"Old"}, []string{"Go17", "Future"}},
+		{[]string{"-lang", "go1.99"}, "", []string{"Old", "Go17", "Future"}, nil},
+		{nil, "nodoctest,doctest", []string{"Exp"}, nil},
+		{nil, "nodoctest", nil, []string{"Exp"}},
+	}
+	for _, test := range tests {
+		os.Setenv("GOEXPERIMENT", test.goexperiment)
+		var b bytes.Buffer
+		var flagSet flag.FlagSet
+		if err := do(&b, &flagSet, append(test.args, "doc.test/lang")); err != nil {
+			t.Fatal(err)
+		}
+		out := b.String()
+		for _, name := range test.yes {
+			if !strings.Contains(out, "const "+name) {
+				t.Errorf("%v GOEXPERIMENT=%s: no %s in\n%s", test.args, test.goexperiment, name, out)
+			}
+		}
+		for _, name := range test.no {
+			if strings.Contains(out, "const "+name) {
+				t.Errorf("%v GOEXPERIMENT=%s: unexpected %s in\n%s", test.args, test.goexperiment, name, out)
+			}
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"bundle/cmd/server/main.go":      "// Server serves.\npackage main\n\nimport _ \"doc.test/bundle/lib\"\n",
+		"bundle/cmd/server/main_test.go": "package main\n",
+		"bundle/lib/lib.go":              "// Package lib is used by the server.\npackage lib\n\nimport (\n\t\"fmt\"\n\n\t\"doc.test/bundle/lib/inner\"\n)\n\nvar _ = fmt.Sprint\nvar _ = inner.X\n",
+		"bundle/lib/inner/inner.go":      "package inner\n\n// X is used by lib.\nconst X = 1\n",
+		"bundle/other/other.go":          "package other\n",
+	})()
+	dir, err := ioutil.TempDir("", "doc-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "server.docz")
+
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-bundle", "doc.test/bundle/cmd/server", file}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	r.Close()
+	want := []string{
+		"doc.test/bundle/cmd/server/main.go",
+		"doc.test/bundle/lib/lib.go",
+		"doc.test/bundle/lib/inner/inner.go",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("bundle holds %q; want %q", names, want)
+	}
+
+	// The bundle can be read with -zip.
+	b.Reset()
+	flagSet = flag.FlagSet{}
+	if err := do(&b, &flagSet, []string{"-zip", file, "doc.test/bundle/lib/inner", "X"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "X is used by lib.") {
+		t.Errorf("-zip of bundle printed\n%s", b.Bytes())
+	}
+}
+
+func TestCgo(t *testing.T) {
+	maybeSkip(t)
+	if !build.Default.CgoEnabled {
+		t.Skip("cgo not enabled")
+	}
+	var b bytes.Buffer
+	var flagSet flag.FlagSet
+	if err := do(&b, &flagSet, []string{"-cgo", p}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Exported to C:\n" +
+		"func cgoCallback(p *C.struct_point) C.int\n" +
+		"\n" +
+		"Used from C:\n" +
+		"type C.double\n" +
+		"type C.int\n" +
+		"func C.sqrt\n" +
+		"type C.struct_point\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("no cgo summary %q in\n%s", want, b.Bytes())
+	}
+	b.Reset()
+	if err := do(&b, new(flag.FlagSet), []string{p}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "cgoCallback") {
+		t.Errorf("cgo summary without -cgo:\n%s", b.Bytes())
+	}
+}
+
+type ignoreTest struct {
+	path    string
+	ignored bool
+}
+
+var ignoreTests = []ignoreTest{
+	{"/go/src/github.com/user/proj", false},
+	{"/go/src/github.com/user/proj/testdata", true},
+	{"/go/src/github.com/user/proj/web/node_modules", true},
+	{"/go/src/github.com/user/proj/bazel-out", true},
+	{"/go/src/github.com/user/proj/generated", true},
+	{"/go/src/github.com/user/proj/web/generated", false},
+	{"/go/src/github.com/user/proj/tmp", true},
+	{"/go/src/tmp", true},
+}
+
+func TestIgnored(t *testing.T) {
+	d := Dirs{
+		ignore: append(defaultIgnore, "github.com/*/proj/generated", "tmp"),
+	}
+	for _, test := range ignoreTests {
+		path := filepath.FromSlash(test.path)
+		if ignored := d.ignored(filepath.FromSlash("/go/src"), path); ignored != test.ignored {
+			t.Errorf("ignored(%q) = %t; want %t", test.path, ignored, test.ignored)
+		}
+	}
+}
+
+type trimTest struct {
+	path   string
+	prefix string
+	result string
+	ok     bool
+}
+
+var trimTests = []trimTest{
+	{"", "", "", true},
+	{"/usr/gopher", "/usr/gopher", "/usr/gopher", true},
+	{"/usr/gopher/bar", "/usr/gopher", "bar", true},
+	{"/usr/gopherflakes", "/usr/gopher", "/usr/gopherflakes", false},
+	{"/usr/gopher/bar", "/usr/zot", "/usr/gopher/bar", false},
+}
+
+func TestTrim(t *testing.T) {
+	for _, test := range trimTests {
+		result, ok := trim(test.path, test.prefix)
+		if ok != test.ok {
+			t.Errorf("%s %s expected %t got %t", test.path, test.prefix, test.ok, ok)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("%s %s expected %q got %q", test.path, test.prefix, test.result, result)
+			continue
+		}
+	}
+}
+
+// Test the terminal typography of doc comments.
+func TestTypography(t *testing.T) {
+	styled = true
+	defer func() { styled = false }()
+	comment := "Text.\n\nA Heading\n\nMore text.\n  - one\n  - two\n    continued\nCode:\n\tcode\n"
+	want := "Text.\n\n" +
+		bold + "A Heading" + reset + "\n\n" +
+		"More text.\n\n" +
+		"  • one\n" +
+		"  • two continued\n\n" +
+		"Code:\n\n" +
+		indent + shade + "code" + reset + "\n"
+	var pkg Package
+	pkg.toText(comment, "")
+	if got := pkg.buf.String(); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCommentSyntax(t *testing.T) {
+	defer func() { styled = false }()
+	comment := "Text.\n\n# Usage\n\nSteps:\n  1. one\n  2. two\n\nSee [Go].\n\n[Go]: https://golang.org\n[Blog]: https://blog.golang.org\n"
+	tests := []struct {
+		styled bool
+		want   string
+	}{
+		{
+			false,
+			"Text.\n\n" +
+				"# Usage\n\n" +
+				"Steps:\n\n" +
+				indent + "1. one\n" +
+				indent + "2. two\n\n" +
+				"See [Go].\n\n" +
+				"[Go]: https://golang.org\n" +
+				"[Blog]: https://blog.golang.org\n",
+		},
+		{
+			true,
+			"Text.\n\n" +
+				bold + "Usage" + reset + "\n\n" +
+				"Steps:\n\n" +
+				"  1. one\n" +
+				"  2. two\n\n" +
+				"See [Go].\n\n" +
+				"[Go]: https://golang.org\n" +
+				"[Blog]: https://blog.golang.org\n",
+		},
+	}
+	for _, test := range tests {
+		styled = test.styled
+		var pkg Package
+		pkg.toText(comment, "")
+		if got := pkg.buf.String(); got != test.want {
+			t.Errorf("styled=%v: got\n%q\nwant\n%q", test.styled, got, test.want)
+		}
+	}
+}
+
+func TestDocLinkHyperlinks(t *testing.T) {
+	styled = true
+	defer func() { styled = false }()
+	comment := "See [encoding/json.Marshal], not [text].\n\n\t[code.Block]\n"
+	want := "See " + hyperlink("https://godoc.org/encoding/json#Marshal", "encoding/json.Marshal") +
+		", not [text].\n\n" +
+		indent + shade + "[code.Block]" + reset + "\n"
+	var pkg Package
+	pkg.toText(comment, "")
+	if got := pkg.buf.String(); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+var webURLTests = []struct {
+	remote, want string
+}{
+	{"git@github.com:org/repo.git", "https://github.com/org/repo"},
+	{"https://github.com/org/repo", "https://github.com/org/repo"},
+	{"https://user@gitlab.com/group/sub/repo.git/", "https://gitlab.com/group/sub/repo"},
+	{"ssh://git@bitbucket.org:7999/org/repo.git", "https://bitbucket.org/org/repo"},
+	{"https://go.googlesource.com/go", "https://go.googlesource.com/go"},
+	{"/home/user/repo.git", ""},
+	{"file:///home/user/repo.git", ""},
+}
+
+func TestWebURL(t *testing.T) {
+	for _, test := range webURLTests {
+		if got := webURL(test.remote); got != test.want {
+			t.Errorf("webURL(%q) = %q; want %q", test.remote, got, test.want)
+		}
+	}
+}
+
+var fileURLTests = []struct {
+	base, want string
+}{
+	{"https://github.com/org/repo", "https://github.com/org/repo/blob/abc/dir/file.go#L12"},
+	{"https://bitbucket.org/org/repo", "https://bitbucket.org/org/repo/src/abc/dir/file.go#lines-12"},
+	{"https://go.googlesource.com/go", "https://go.googlesource.com/go/+/abc/dir/file.go#12"},
+}
+
+func TestFileURL(t *testing.T) {
+	for _, test := range fileURLTests {
+		if got := fileURL(test.base, "abc", "dir/file.go", 12); got != test.want {
+			t.Errorf("fileURL(%q) = %q; want %q", test.base, got, test.want)
+		}
+	}
+}
+
+var escapeTests = []struct {
+	mode, in, want string
+}{
+	{"html", "a < b && \"c\"\n", "a &lt; b &amp;&amp; &#34;c&#34;\n"},
+	{"json", "func F() <-chan int\n\tdoc\n", `"func F() \u003c-chan int\n\tdoc\n"` + "\n"},
+	{"shell", "it's\n", `'it'\''s` + "\n'\n"},
+	{"html", "ab\xe2\x82", "ab��"}, // Invalid UTF-8.
+	{"shell", "€\xff", "'€�'\n"},
+}
+
+func TestEscape(t *testing.T) {
+	for _, test := range escapeTests {
+		if got := escaper(test.mode)(test.in); got != test.want {
+			t.Errorf("-escape %s of %q = %q; want %q", test.mode, test.in, got, test.want)
+		}
+	}
+}
+
+var fitTests = []struct {
+	in    string
+	width int
+	want  string
+}{
+	{"abc", 5, "abc  "},
+	{"abcdef", 3, "abc"},
+	{"héllo, 世界", 8, "héllo, 世"},
+	{"世界", 1, "世"},
+}
+
+// TestFit checks that truncating a line for the terminal never splits a
+// multibyte rune.
+func TestFit(t *testing.T) {
+	for _, test := range fitTests {
+		if got := fit(test.in, test.width); got != test.want {
+			t.Errorf("fit(%q, %d) = %q; want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
+
+var templateURLTests = []struct {
+	base, importPath, want string
+}{
+	{"https://github.com/org/repo", "example.com/anything", "https://src.example.com/org/repo/blob/abc/dir/file.go#L12"},
+	{"", "example.com/org/repo/dir", "https://src.example.com/org/repo/blob/abc/dir/file.go#L12"},
+	{"", "corp/dir", "https://src.example.com/corp/blob/abc/dir/file.go#L12"},
+	{"", ".", "https://src.example.com/checkout/blob/abc/dir/file.go#L12"},
+}
+
+func TestTemplateURL(t *testing.T) {
+	// Fill the cache rather than run git.
+	root := filepath.Join(os.TempDir(), "checkout")
+	dir := filepath.Join(root, "dir")
+	defer delete(repos, dir)
+	for _, test := range templateURLTests {
+		repos[dir] = &repoInfo{root: root, base: test.base, rev: "abc"}
+		got := templateURL("https://src.example.com/{repo}/blob/{rev}/{path}#L{line}", filepath.Join(dir, "file.go"), 12, test.importPath)
+		if got != test.want {
+			t.Errorf("templateURL with remote %q, import path %q = %q; want %q", test.base, test.importPath, got, test.want)
+		}
 	}
 }
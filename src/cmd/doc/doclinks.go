@@ -0,0 +1,248 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// docLinkRE matches what may be a doc link: [Name], [Name.Method],
+// [pkg.Name], [pkg.Name.Method], [import/path.Name], [import/path] or any
+// of those after a star, as in [*bytes.Buffer]. The characters around it,
+// and its parts, are checked by docLinks.
+var docLinkRE = regexp.MustCompile(`\[\*?[\pL\pN_./-]+\]`)
+
+// Marks around the text of a doc link when styled, so that it can be
+// made a hyperlink once its paragraph has been filled: the escape
+// sequences of a hyperlink would count toward the width of its line.
+const (
+	linkStart = "\x01"
+	linkEnd   = "\x02"
+)
+
+// A docLink is the target of a doc link: a package, or one of its
+// symbols given as a name or as a type's name and a method's.
+type docLink struct {
+	importPath string // "" if not known, as for a local package.
+	local      bool   // Whether the target is in this package.
+	syms       []string
+}
+
+// url returns the address of the target's documentation on godoc.org,
+// or "" if its import path is not known.
+func (l docLink) url() string {
+	if l.importPath == "" {
+		return ""
+	}
+	url := "https://godoc.org/" + l.importPath
+	if len(l.syms) > 0 {
+		url += "#" + strings.Join(l.syms, ".")
+	}
+	return url
+}
+
+// docLinks rewrites the doc links of the comment, which name a package
+// or one of its symbols in square brackets, as the text they link: the
+// brackets are dropped. Brackets that are not doc links, such as a[i],
+// [text] or the names of symbols the package does not declare, are left
+// alone, as are indented, preformatted lines. If mark is set, the text of
+// each link is set between linkStart and linkEnd and its target appended
+// to the returned list, to be made a hyperlink by hyperlinks.
+func (pkg *Package) docLinks(comment string, mark bool) (string, []string) {
+	var urls []string
+	comment = pkg.scanDocLinks(comment, func(text string, link docLink) string {
+		if url := link.url(); mark && url != "" {
+			urls = append(urls, url)
+			return linkStart + text + linkEnd
+		}
+		return text
+	})
+	return comment, urls
+}
+
+// scanDocLinks calls f for each doc link of the comment, in order, with
+// its text, without the brackets, and its target, and returns the comment
+// with each link replaced by what f returns.
+func (pkg *Package) scanDocLinks(comment string, f func(text string, link docLink) string) string {
+	lines := strings.SplitAfter(comment, "\n")
+	for i, line := range lines {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue // Preformatted.
+		}
+		var buf bytes.Buffer
+		last := 0
+		for _, m := range docLinkRE.FindAllStringIndex(line, -1) {
+			before, _ := utf8.DecodeLastRuneInString(line[:m[0]])
+			after, _ := utf8.DecodeRuneInString(line[m[1]:])
+			if m[0] > 0 && !isLinkDelim(before) || m[1] < len(line) && !isLinkDelim(after) {
+				continue
+			}
+			if m[0] == 0 && after == ':' {
+				continue // A link definition, [text]: URL.
+			}
+			text := line[m[0]+1 : m[1]-1]
+			link, ok := pkg.parseDocLink(text)
+			if !ok {
+				continue
+			}
+			buf.WriteString(line[last:m[0]])
+			buf.WriteString(f(text, link))
+			last = m[1]
+		}
+		if last > 0 {
+			buf.WriteString(line[last:])
+			lines[i] = buf.String()
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// isLinkDelim reports whether the rune may precede or follow a doc link.
+func isLinkDelim(r rune) bool {
+	switch r {
+	case '_', '[', ']', '(':
+		return false
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// parseDocLink reports whether the text of a doc link, without its
+// brackets, names a package or a symbol, and returns its target. A
+// symbol named without a package must be declared by this package; one
+// named with a package must be exported; a package named alone must
+// be found by its import path.
+func (pkg *Package) parseDocLink(text string) (docLink, bool) {
+	name := strings.TrimPrefix(text, "*")
+	dir := ""
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		dir, name = name[:i+1], name[i+1:]
+	}
+	parts := strings.Split(name, ".")
+	for _, part := range parts {
+		if !isIdent(part) {
+			return docLink{}, false
+		}
+	}
+	var link docLink
+	switch {
+	case dir != "":
+		link.importPath, link.syms = dir+parts[0], parts[1:]
+		if len(link.syms) > 2 || len(link.syms) > 0 && !isUpper(link.syms[0]) {
+			return docLink{}, false
+		}
+		if len(link.syms) == 0 {
+			if strings.HasPrefix(text, "*") {
+				return docLink{}, false
+			}
+			if _, err := buildCtx.Import(link.importPath, pwd(), build.FindOnly); err != nil {
+				return docLink{}, false
+			}
+		}
+	case pkg.declares(parts):
+		if pkg.build != nil && !build.IsLocalImport(pkg.build.ImportPath) {
+			link.importPath = pkg.build.ImportPath
+		}
+		link.local, link.syms = true, parts
+	case len(parts) == 2 || len(parts) == 3:
+		if !isUpper(parts[1]) {
+			return docLink{}, false
+		}
+		link.importPath, link.syms = pkg.importPathOf(parts[0]), parts[1:]
+	default:
+		return docLink{}, false
+	}
+	return link, true
+}
+
+// isIdent reports whether the name is a Go identifier.
+func isIdent(name string) bool {
+	for i, ch := range name {
+		if !unicode.IsLetter(ch) && ch != '_' && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// declares reports whether the package declares the symbol, given as a
+// name or as a type's name and a method's.
+func (pkg *Package) declares(sym []string) bool {
+	if pkg.file == nil || len(sym) > 2 {
+		return false
+	}
+	for _, decl := range pkg.file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			recv := ""
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv = typeName(decl.Recv.List[0].Type)
+			}
+			if len(sym) == 1 && recv == "" && decl.Name.Name == sym[0] ||
+				len(sym) == 2 && recv == sym[0] && decl.Name.Name == sym[1] {
+				return true
+			}
+		case *ast.GenDecl:
+			if len(sym) > 1 {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == sym[0] {
+						return true
+					}
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						if ident.Name == sym[0] {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// importPathOf returns the import path of the package the package's files
+// import under name. If none does, it returns name, as the path of a
+// package of the standard library such as io.
+func (pkg *Package) importPathOf(name string) string {
+	if pkg.file != nil {
+		for _, imp := range pkg.file.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			if imp.Name != nil && imp.Name.Name == name || imp.Name == nil && path.Base(p) == name {
+				return p
+			}
+		}
+	}
+	return name
+}
+
+// hyperlinks replaces the marked link texts in the text, in order, with
+// hyperlinks to the targets docLinks returned.
+func hyperlinks(text string, urls []string) string {
+	var buf bytes.Buffer
+	for _, url := range urls {
+		i := strings.Index(text, linkStart)
+		j := strings.Index(text, linkEnd)
+		if i < 0 || j < i {
+			break
+		}
+		buf.WriteString(text[:i])
+		buf.WriteString(hyperlink(url, text[i+len(linkStart):j]))
+		text = text[j+len(linkEnd):]
+	}
+	buf.WriteString(text)
+	return buf.String()
+}
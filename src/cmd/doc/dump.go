@@ -0,0 +1,134 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/doc"
+	"go/format"
+)
+
+// The types of the output of -dump mirror those of go/doc, with each
+// declaration printed as source and its position given as file:line:column.
+type (
+	dumpPackage struct {
+		Name       string
+		ImportPath string
+		Doc        string
+		Filenames  []string
+		Bugs       []string `json:",omitempty"`
+		Consts     []*dumpValue
+		Vars       []*dumpValue
+		Funcs      []*dumpFunc
+		Types      []*dumpType
+	}
+
+	dumpValue struct {
+		Doc   string
+		Names []string
+		Decl  string
+		Pos   string
+	}
+
+	dumpFunc struct {
+		Doc   string
+		Name  string
+		Recv  string `json:",omitempty"`
+		Orig  string `json:",omitempty"`
+		Level int    `json:",omitempty"`
+		Decl  string
+		Pos   string
+	}
+
+	dumpType struct {
+		Doc     string
+		Name    string
+		Decl    string
+		Pos     string
+		Consts  []*dumpValue
+		Vars    []*dumpValue
+		Funcs   []*dumpFunc
+		Methods []*dumpFunc
+	}
+)
+
+// dumpDoc prints as JSON the package's doc.Package as go doc uses it:
+// after parsePackage has added the typed constants, variables and
+// constructors of each type to those of the package, so that they are
+// listed both beneath the type and at the top level, and sorted them as
+// -sort says. Unexported declarations are included.
+func (pkg *Package) dumpDoc() {
+	defer pkg.flush()
+	d := pkg.doc
+	out := &dumpPackage{
+		Name:       d.Name,
+		ImportPath: d.ImportPath,
+		Doc:        d.Doc,
+		Filenames:  d.Filenames,
+		Bugs:       d.Bugs,
+		Consts:     pkg.dumpValues(d.Consts),
+		Vars:       pkg.dumpValues(d.Vars),
+		Funcs:      pkg.dumpFuncs(d.Funcs),
+	}
+	for _, typ := range d.Types {
+		out.Types = append(out.Types, &dumpType{
+			Doc:     typ.Doc,
+			Name:    typ.Name,
+			Decl:    pkg.source(typ.Decl),
+			Pos:     pkg.fs.Position(typ.Decl.Pos()).String(),
+			Consts:  pkg.dumpValues(typ.Consts),
+			Vars:    pkg.dumpValues(typ.Vars),
+			Funcs:   pkg.dumpFuncs(typ.Funcs),
+			Methods: pkg.dumpFuncs(typ.Methods),
+		})
+	}
+	data, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	pkg.buf.Write(data)
+	pkg.buf.WriteString("\n")
+}
+
+func (pkg *Package) dumpValues(values []*doc.Value) []*dumpValue {
+	var list []*dumpValue
+	for _, value := range values {
+		list = append(list, &dumpValue{
+			Doc:   value.Doc,
+			Names: value.Names,
+			Decl:  pkg.source(value.Decl),
+			Pos:   pkg.fs.Position(value.Decl.Pos()).String(),
+		})
+	}
+	return list
+}
+
+func (pkg *Package) dumpFuncs(funcs []*doc.Func) []*dumpFunc {
+	var list []*dumpFunc
+	for _, fun := range funcs {
+		list = append(list, &dumpFunc{
+			Doc:   fun.Doc,
+			Name:  fun.Name,
+			Recv:  fun.Recv,
+			Orig:  fun.Orig,
+			Level: fun.Level,
+			Decl:  pkg.source(fun.Decl),
+			Pos:   pkg.fs.Position(fun.Decl.Pos()).String(),
+		})
+	}
+	return list
+}
+
+// source returns the declaration formatted as Go source, without its doc
+// comment.
+func (pkg *Package) source(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pkg.fs, node); err != nil {
+		fatalf("%v", err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// goFlags are the flags of go doc that $GOFLAGS may set, as it does those
+// of go build. Other flags in $GOFLAGS, such as -mod, are for other
+// commands and are ignored, as the go command ignores flags a command does
+// not define.
+var goFlags = map[string]bool{
+	"tags": true,
+}
+
+// goEnv returns the setting of the variable as the go command sees it:
+// from the environment, or failing that from the file go env -w writes,
+// or failing that def.
+func goEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	if v, ok := readGoEnvFile()[key]; ok {
+		return v
+	}
+	return def
+}
+
+// goEnvFileName returns the name of the file in which go env -w records
+// settings: $GOENV, or go/env in the user's configuration directory. It
+// returns "" if $GOENV is off or there is no such directory.
+func goEnvFileName() string {
+	if file := os.Getenv("GOENV"); file != "" {
+		if file == "off" {
+			return ""
+		}
+		return file
+	}
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("AppData")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Library", "Application Support")
+		}
+	case "plan9":
+		if home := os.Getenv("home"); home != "" {
+			dir = filepath.Join(home, "lib")
+		}
+	default:
+		dir = os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" && os.Getenv("HOME") != "" {
+			dir = filepath.Join(os.Getenv("HOME"), ".config")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "go", "env")
+}
+
+// readGoEnvFile returns the settings in the go env file, each a line
+// KEY=VALUE. A file that cannot be read holds none.
+func readGoEnvFile() map[string]string {
+	settings := make(map[string]string)
+	name := goEnvFileName()
+	if name == "" {
+		return settings
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return settings
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "="); i > 0 && !strings.HasPrefix(line, "#") {
+			settings[strings.TrimSpace(line[:i])] = line[i+1:]
+		}
+	}
+	return settings
+}
+
+// applyGoFlags sets the flags of go doc that $GOFLAGS, a space-separated
+// list of -flag=value settings, gives and the command line does not. As
+// for the go command, a setting that is not a flag is an error.
+func applyGoFlags(flagSet *flag.FlagSet) {
+	set := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, arg := range strings.Fields(goEnv("GOFLAGS", "")) {
+		if !strings.HasPrefix(arg, "-") {
+			fatalf("parsing $GOFLAGS: non-flag %q", arg)
+		}
+		name, value := strings.TrimLeft(arg, "-"), "true"
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		}
+		if !goFlags[name] || set[name] {
+			continue
+		}
+		if name == "tags" {
+			// Spaces separate the settings, so commas separate tags.
+			value = strings.Replace(value, ",", " ", -1)
+		}
+		if err := flagSet.Set(name, value); err != nil {
+			fatalf("parsing $GOFLAGS: invalid value %q for flag -%s: %v", value, name, err)
+		}
+	}
+}
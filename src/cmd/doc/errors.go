@@ -0,0 +1,83 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Kinds of error, which wrappers can tell apart by the status with which
+// doc exits, or for the -json flag by the kind in the JSON object written
+// for the error, rather than by the message.
+const (
+	errOther     = "other"     // Any other error: exit status 1.
+	errPackage   = "package"   // No package matches: exit status 5.
+	errSymbol    = "symbol"    // No symbol or method matches: exit status 6.
+	errAmbiguous = "ambiguous" // A partial path matches several packages: exit status 7.
+	errParse     = "parse"     // A package's files cannot be read or parsed: exit status 8.
+	errAPI       = "api"       // The API differs from -baseline: exit status 3 or 4.
+)
+
+// exitStatuses maps the kinds of error, other than errOther and errAPI,
+// to the statuses with which doc exits for them. Status 2 is for invalid
+// usage, as for package flag.
+var exitStatuses = map[string]int{
+	errPackage:   5,
+	errSymbol:    6,
+	errAmbiguous: 7,
+	errParse:     8,
+}
+
+// An errorObject is what exit writes for the -json flag.
+type errorObject struct {
+	Error   string `json:"error"` // The kind of error.
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+// failf is fatalf for an error of a known kind: it reports the error and
+// exits with the kind's status, or for -batch and -repl panics with a
+// PackageError of the kind.
+func failf(kind, format string, args ...interface{}) {
+	err := PackageError{kind: kind, msg: fmt.Sprintf(format, args...)}
+	if inBatch || inREPL {
+		panic(err)
+	}
+	exit(err)
+}
+
+// panicf is fatalf for -batch and -repl: it panics with a PackageError,
+// which is recovered to report the error of the query alone.
+func panicf(format string, args ...interface{}) {
+	panic(PackageError{kind: errOther, msg: fmt.Sprintf(format, args...)})
+}
+
+// exit reports the error on standard error, as a line beginning "doc: ",
+// or for the -json flag as a JSON object on a line of its own, and exits
+// with the status for its kind.
+func exit(err error) {
+	kind, status := errOther, 1
+	switch e := err.(type) {
+	case *apiDiffError:
+		kind, status = errAPI, e.exitStatus()
+	case PackageError:
+		if s, ok := exitStatuses[e.kind]; ok {
+			kind, status = e.kind, s
+		}
+	}
+	if jsonWarnings {
+		b, jsonErr := json.Marshal(errorObject{kind, err.Error(), status})
+		if jsonErr != nil {
+			panic(jsonErr) // Cannot happen: strings always marshal.
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", b)
+	} else {
+		log.Print(err)
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// escaper returns the function that escapes the whole output for the
+// -escape mode, so that it can be embedded in an HTML page, a JSON
+// document or a shell command as it is. Invalid UTF-8 is replaced by
+// U+FFFD first, so that the result is valid UTF-8 in every mode.
+func escaper(mode string) func(string) string {
+	switch mode {
+	case "html":
+		return func(s string) string {
+			return html.EscapeString(validUTF8(s))
+		}
+	case "json":
+		// A JSON string, with <, > and & escaped as well, so that it
+		// can also be put in a <script> element.
+		return func(s string) string {
+			data, err := json.Marshal(validUTF8(s))
+			if err != nil {
+				fatalf("%v", err)
+			}
+			return string(data) + "\n"
+		}
+	case "shell":
+		// A single word, quoted for POSIX shells.
+		return func(s string) string {
+			return "'" + strings.Replace(validUTF8(s), "'", `'\''`, -1) + "'\n"
+		}
+	}
+	fatalf("invalid -escape %q; want html, json or shell", mode)
+	return nil
+}
+
+// validUTF8 returns s with each invalid UTF-8 sequence replaced by U+FFFD.
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	b := make([]byte, 0, len(s)+utf8.UTFMax)
+	for _, r := range s {
+		// Ranging over the string yields RuneError for invalid bytes.
+		b = append(b, string(r)...)
+	}
+	return string(b)
+}
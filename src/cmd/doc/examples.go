@@ -0,0 +1,110 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// outputPrefix matches the comment holding an example's expected output,
+// which is shown apart from its code.
+var outputPrefix = regexp.MustCompile(`(?i)//[[:space:]]*(unordered )?output:`)
+
+// examples returns the examples in the package's _test.go files, those of
+// its external test package too, sorted by name. Files that cannot be read
+// or parsed are skipped, as tests are not the subject of the documentation.
+func (pkg *Package) examples() (*token.FileSet, []*doc.Example) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	names := append(pkg.build.TestGoFiles[:len(pkg.build.TestGoFiles):len(pkg.build.TestGoFiles)], pkg.build.XTestGoFiles...)
+	for _, name := range names {
+		filename := filepath.Join(pkg.build.Dir, name)
+		src, err := readFile(filename)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	examples := doc.Examples(files...)
+	sort.Slice(examples, func(i, j int) bool { return examples[i].Name < examples[j].Name })
+	return fset, examples
+}
+
+// splitExampleName splits the name of an example, as doc.Example holds it,
+// into the symbol it is for and its suffix, which begins with a lower case
+// letter, as Decoder_Decode_stream is split into Decoder_Decode and stream.
+func splitExampleName(name string) (symbol, suffix string) {
+	i := strings.LastIndex(name, "_")
+	if i < 0 || i+1 == len(name) || isUpper(name[i+1:]) {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// exampleDoc prints, for the -ex flag, the examples of the symbol, which is
+// a function or type name or, for a method, Type_Method as in the names
+// of example functions: the code of each, and the output it expects.
+func (pkg *Package) exampleDoc(symbol string) {
+	fset, examples := pkg.examples()
+	for _, ex := range examples {
+		name, suffix := splitExampleName(ex.Name)
+		if name != symbol {
+			continue
+		}
+		pkg.newlines(2)
+		if suffix != "" {
+			pkg.Printf("Example (%s):\n", suffix)
+		} else {
+			pkg.Printf("Example:\n")
+		}
+		for _, line := range strings.Split(exampleCode(fset, ex), "\n") {
+			pkg.Printf("%s\n", strings.TrimRight(indent+line, " \t"))
+		}
+		if ex.Output != "" || ex.EmptyOutput {
+			pkg.Printf("\n%sOutput:\n", indent)
+			for _, line := range strings.Split(strings.TrimSuffix(ex.Output, "\n"), "\n") {
+				pkg.Printf("%s\n", strings.TrimRight(indent+indent+line, " \t"))
+			}
+		}
+		pkg.newlines(2)
+	}
+}
+
+// exampleCode returns the source of the example: the statements of its
+// body, or for a whole-file example the file, without the comment giving
+// its output.
+func exampleCode(fset *token.FileSet, ex *doc.Example) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, &printer.CommentedNode{Node: ex.Code, Comments: ex.Comments}); err != nil {
+		return ""
+	}
+	code := buf.String()
+	if _, ok := ex.Code.(*ast.BlockStmt); ok {
+		code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+		lines := strings.Split(strings.Trim(code, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimPrefix(line, "\t")
+		}
+		code = strings.Join(lines, "\n")
+	}
+	if loc := outputPrefix.FindAllStringIndex(code, -1); loc != nil {
+		code = code[:loc[len(loc)-1][0]]
+	}
+	return strings.TrimSpace(code)
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A cmdFlag is a flag a command registers with package flag.
+type cmdFlag struct {
+	name  string
+	typ   string // As flag.PrintDefaults names it, or "" for bool.
+	def   string // The default, as flag.PrintDefaults shows it, or "".
+	usage string
+}
+
+// flagTypes maps the functions and methods of package flag that register
+// flags, with those ending in Var taking a pointer first, to the types of
+// the flags.
+var flagTypes = map[string]string{
+	"Bool":     "bool",
+	"Duration": "duration",
+	"Float64":  "float",
+	"Int":      "int",
+	"Int64":    "int",
+	"String":   "string",
+	"Uint":     "uint",
+	"Uint64":   "uint",
+	"Var":      "value",
+}
+
+// flagsSummary prints, for a command, the flags it registers, as
+// flag.PrintDefaults would. They are found by reading the source: calls of
+// the functions of package flag, such as flag.String, and of the same
+// methods of the variables and parameters of type flag.FlagSet or
+// *flag.FlagSet and those set by flag.NewFlagSet, whose names are string
+// literals. Defaults and usage messages that are not constants are shown
+// as they are written.
+func (pkg *Package) flagsSummary() {
+	flags := pkg.cmdFlags()
+	if len(flags) == 0 {
+		return
+	}
+	pkg.newlines(2)
+	pkg.Printf("Flags:\n")
+	for _, f := range flags {
+		pkg.Printf("%s-%s", indent, f.name)
+		if f.typ != "" {
+			pkg.Printf(" %s", f.typ)
+		}
+		pkg.Printf("\n%s%s%s", indent, indent, f.usage)
+		if f.def != "" {
+			pkg.Printf(" (default %s)", f.def)
+		}
+		pkg.Printf("\n")
+	}
+}
+
+// cmdFlags returns the flags the command registers, sorted by name.
+func (pkg *Package) cmdFlags() []cmdFlag {
+	_, files := pkg.sourceFiles()
+	var flags []cmdFlag
+	seen := make(map[string]bool)
+	for _, f := range files {
+		flagPkg := importName(f.file, "flag")
+		if flagPkg == "" {
+			continue
+		}
+		sets := flagSets(f.file, flagPkg)
+		ast.Inspect(f.file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if x := identName(sel.X); x == "" || x != flagPkg && !sets[x] {
+				return true
+			}
+			if fl, ok := parseFlagCall(sel.Sel.Name, call.Args); ok && !seen[fl.name] {
+				seen[fl.name] = true
+				flags = append(flags, fl)
+			}
+			return true
+		})
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// importName returns the name under which the file imports the path, or
+// "" if it does not.
+func importName(file *ast.File, path string) string {
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			if imp.Name != nil {
+				return imp.Name.Name
+			}
+			return path[strings.LastIndex(path, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// flagSets returns the names of the variables and parameters in the file
+// that hold flag sets: those declared as flag.FlagSet or *flag.FlagSet,
+// and those set by flag.NewFlagSet. Scopes are not considered.
+func flagSets(file *ast.File, flagPkg string) map[string]bool {
+	isFlagSet := func(expr ast.Expr) bool {
+		if star, ok := expr.(*ast.StarExpr); ok {
+			expr = star.X
+		}
+		if call, ok := expr.(*ast.CallExpr); ok {
+			expr = call.Fun
+			if sel, ok := expr.(*ast.SelectorExpr); !ok || sel.Sel.Name != "NewFlagSet" {
+				return false
+			}
+		} else if sel, ok := expr.(*ast.SelectorExpr); !ok || sel.Sel.Name != "FlagSet" {
+			return false
+		}
+		return identName(expr.(*ast.SelectorExpr).X) == flagPkg
+	}
+	sets := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			if isFlagSet(n.Type) {
+				for _, name := range n.Names {
+					sets[name.Name] = true
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if n.Type != nil && isFlagSet(n.Type) || i < len(n.Values) && isFlagSet(n.Values[i]) {
+					sets[name.Name] = true
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				if name := identName(lhs); name != "" && i < len(n.Rhs) && isFlagSet(n.Rhs[i]) {
+					sets[name] = true
+				}
+			}
+		}
+		return true
+	})
+	return sets
+}
+
+// parseFlagCall returns the flag registered by a call of the function or
+// method of package flag with the arguments, if it registers one.
+func parseFlagCall(fun string, args []ast.Expr) (cmdFlag, bool) {
+	base := fun
+	if fun != "Var" {
+		base = strings.TrimSuffix(fun, "Var")
+	}
+	typ, ok := flagTypes[base]
+	if !ok {
+		return cmdFlag{}, false
+	}
+	want := 3 // The name, default and usage.
+	if fun == "Var" {
+		want = 2 // No default.
+	}
+	if strings.HasSuffix(fun, "Var") {
+		// The pointer or Value comes first.
+		if len(args) != want+1 {
+			return cmdFlag{}, false
+		}
+		args = args[1:]
+	}
+	if len(args) != want {
+		return cmdFlag{}, false
+	}
+	name, ok := stringValue(args[0])
+	if !ok {
+		return cmdFlag{}, false
+	}
+	f := cmdFlag{name: name, typ: typ}
+	usage := args[len(args)-1]
+	if s, ok := stringValue(usage); ok {
+		// As flag.UnquoteUsage, a name in back quotes names the value.
+		f.usage = s
+		if i := strings.Index(s, "`"); i >= 0 {
+			if j := strings.Index(s[i+1:], "`"); j >= 0 {
+				f.typ = s[i+1 : i+1+j]
+				f.usage = s[:i] + f.typ + s[i+1+j+1:]
+			}
+		}
+	} else {
+		f.usage = types.ExprString(usage)
+	}
+	if f.typ == "bool" {
+		f.typ = ""
+	}
+	if fun != "Var" {
+		f.def = flagDefault(args[1], typ)
+	}
+	return f, true
+}
+
+// flagDefault returns the default as flag.PrintDefaults shows it: a
+// string quoted, and "" for the zero value.
+func flagDefault(expr ast.Expr, typ string) string {
+	if s, ok := stringValue(expr); ok {
+		if s == "" {
+			return ""
+		}
+		return fmt.Sprintf("%q", s)
+	}
+	def := types.ExprString(expr)
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind != token.STRING {
+		if v, err := strconv.ParseFloat(lit.Value, 64); err == nil && v == 0 {
+			return ""
+		}
+	}
+	switch {
+	case typ == "bool" && def == "false", typ == "duration" && def == "0":
+		return ""
+	}
+	return def
+}
+
+// stringValue returns the value of a string literal, or of a sum of them.
+func stringValue(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind == token.STRING {
+			s, err := strconv.Unquote(expr.Value)
+			return s, err == nil
+		}
+	case *ast.BinaryExpr:
+		if expr.Op == token.ADD {
+			x, ok1 := stringValue(expr.X)
+			y, ok2 := stringValue(expr.Y)
+			return x + y, ok1 && ok2
+		}
+	case *ast.ParenExpr:
+		return stringValue(expr.X)
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"strings"
+)
+
+// followLinks prints, for the -follow flag, beneath the documentation of
+// a symbol, a one-line summary of each symbol or package its doc comment
+// links to, as [pkg.Name] does, in the order they appear. Symbols of
+// other packages are qualified by the package's name; links whose
+// targets cannot be found are left out.
+func (pkg *Package) followLinks(comment string) {
+	seen := make(map[string]bool)
+	pkgs := make(map[string]*Package) // By import path, nil if not found.
+	var lines []string
+	pkg.scanDocLinks(comment, func(text string, link docLink) string {
+		key := link.importPath + "#" + text
+		if link.local {
+			key = text
+		}
+		if seen[key] {
+			return text
+		}
+		seen[key] = true
+		p := pkg
+		if !link.local {
+			var ok bool
+			if p, ok = pkgs[link.importPath]; !ok {
+				p = nil
+				bpkg, err := buildCtx.Import(link.importPath, pkg.build.Dir, build.ImportComment)
+				if err == nil {
+					p = parsePackage(pkg.writer, bpkg, link.importPath)
+				}
+				pkgs[link.importPath] = p
+			}
+			if p == nil {
+				return text
+			}
+		}
+		if len(link.syms) == 0 {
+			lines = append(lines, "package "+p.name+" // import \""+link.importPath+"\"")
+			return text
+		}
+		node, ident := p.linkedDecl(link.syms)
+		if node == nil {
+			return text
+		}
+		if decl, ok := node.(*ast.GenDecl); ok && p != pkg {
+			// The summary of a value begins "var Name"; the name cannot
+			// be qualified in place, as a qualified name is not exported.
+			tok := decl.Tok.String() + " "
+			lines = append(lines, tok+p.name+"."+strings.TrimPrefix(p.oneLineNode(node), tok))
+			return text
+		}
+		if p != pkg {
+			// Qualify the name in place, then restore it, as iotaString does.
+			name := ident.Name
+			ident.Name = p.name + "." + name
+			defer func() { ident.Name = name }()
+		}
+		lines = append(lines, p.oneLineNode(node))
+		return text
+	})
+	if len(lines) == 0 {
+		return
+	}
+	pkg.newlines(2)
+	for _, line := range lines {
+		pkg.Printf("%s%s\n", indent, line)
+	}
+	pkg.newlines(2)
+}
+
+// linkedDecl returns the declaration of the package's symbol, given as a
+// name or as a type's name and a method's, to be summarized, and the
+// identifier by which a method's receiver type or another symbol is named
+// in it, or nil if there is none. A single value of a group is returned
+// in a declaration of its own.
+func (pkg *Package) linkedDecl(syms []string) (ast.Node, *ast.Ident) {
+	if len(syms) == 2 {
+		for _, typ := range pkg.doc.Types {
+			if typ.Name != syms[0] {
+				continue
+			}
+			for _, meth := range typ.Methods {
+				if meth.Name == syms[1] && meth.Decl.Recv != nil {
+					recv := meth.Decl.Recv.List[0].Type
+					if star, ok := recv.(*ast.StarExpr); ok {
+						recv = star.X
+					}
+					if ident, ok := recv.(*ast.Ident); ok {
+						return meth.Decl, ident
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+	name := syms[0]
+	funcs := pkg.doc.Funcs
+	values := append(pkg.doc.Consts[:len(pkg.doc.Consts):len(pkg.doc.Consts)], pkg.doc.Vars...)
+	for _, typ := range pkg.doc.Types {
+		if typ.Name == name {
+			spec := pkg.findTypeSpec(typ.Decl, name)
+			return spec, spec.Name
+		}
+		funcs = append(funcs[:len(funcs):len(funcs)], typ.Funcs...)
+		values = append(values, typ.Consts...)
+		values = append(values, typ.Vars...)
+	}
+	for _, fun := range funcs {
+		if fun.Name == name {
+			return fun.Decl, fun.Decl.Name
+		}
+	}
+	return valueDecl(values, name)
+}
+
+// valueDecl returns a declaration of the constant or variable alone, and
+// its name, or nil if none of the values declares it.
+func valueDecl(values []*doc.Value, name string) (ast.Node, *ast.Ident) {
+	for _, value := range values {
+		var typ ast.Expr // Carried over from a previous spec, as for iota.
+		for _, spec := range value.Decl.Specs {
+			vspec := spec.(*ast.ValueSpec)
+			if vspec.Type != nil || len(vspec.Values) > 0 {
+				typ = vspec.Type
+			}
+			for i, ident := range vspec.Names {
+				if ident.Name != name {
+					continue
+				}
+				single := &ast.ValueSpec{Names: []*ast.Ident{ident}, Type: typ}
+				if i < len(vspec.Values) {
+					single.Values = []ast.Expr{vspec.Values[i]}
+				}
+				return &ast.GenDecl{Tok: value.Decl.Tok, Specs: []ast.Spec{single}}, ident
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A FileSystem provides the files from which packages are read.
+// All of doc's file access goes through one, so that packages may come
+// from archives or memory as well as from the operating system.
+type FileSystem interface {
+	// Open opens the named file (not a directory) for reading.
+	Open(name string) (io.ReadCloser, error)
+	// ReadDir returns the entries of the named directory, sorted by name.
+	ReadDir(dir string) ([]os.FileInfo, error)
+	// Stat returns information about the named file or directory.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// osFS is the FileSystem of the operating system.
+type osFS struct{}
+
+func (osFS) Open(name string) (io.ReadCloser, error)   { return os.Open(name) }
+func (osFS) ReadDir(dir string) ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }
+func (osFS) Stat(name string) (os.FileInfo, error)     { return os.Stat(name) }
+
+// baseFS is the file system from which packages are read, before any
+// archive is mounted on it. Tests replace it.
+var baseFS FileSystem = osFS{}
+
+// fileSystem is the file system from which packages are read.
+// It is baseFS with any archive named by -zip mounted on it.
+var fileSystem FileSystem = baseFS
+
+// A mountFS presents a FileSystem whose names are slash-separated and
+// relative, like those in an archive, as the tree beneath root in base.
+// The tree hides anything beneath root in base.
+type mountFS struct {
+	root string
+	tree FileSystem
+	base FileSystem
+}
+
+// rel reports whether name is in the mounted tree and if so returns its
+// name within the tree.
+func (m *mountFS) rel(name string) (string, bool) {
+	if name == m.root {
+		return ".", true
+	}
+	if !strings.HasPrefix(name, m.root+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(name[len(m.root)+1:]), true
+}
+
+func (m *mountFS) Open(name string) (io.ReadCloser, error) {
+	if rel, ok := m.rel(name); ok {
+		return m.tree.Open(rel)
+	}
+	return m.base.Open(name)
+}
+
+func (m *mountFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	if rel, ok := m.rel(dir); ok {
+		return m.tree.ReadDir(rel)
+	}
+	return m.base.ReadDir(dir)
+}
+
+func (m *mountFS) Stat(name string) (os.FileInfo, error) {
+	if rel, ok := m.rel(name); ok {
+		return m.tree.Stat(rel)
+	}
+	return m.base.Stat(name)
+}
+
+// An overlayFS is a FileSystem whose files, held in memory, replace or
+// add to those of base. It presents the unsaved contents of an editor's
+// buffers, for example.
+type overlayFS struct {
+	base  FileSystem
+	files map[string]string // Contents by absolute, clean file name.
+}
+
+// newOverlayFS returns an overlayFS holding the files, which map names to
+// contents. Relative names are relative to dir.
+func newOverlayFS(base FileSystem, dir string, files map[string]string) *overlayFS {
+	o := &overlayFS{base: base, files: make(map[string]string)}
+	for name, contents := range files {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		o.files[filepath.Clean(name)] = contents
+	}
+	return o
+}
+
+func (o *overlayFS) Open(name string) (io.ReadCloser, error) {
+	if contents, ok := o.files[name]; ok {
+		return ioutil.NopCloser(strings.NewReader(contents)), nil
+	}
+	return o.base.Open(name)
+}
+
+// ReadDir merges the overlay's files in dir with the directory's entries
+// in base. The directory need not exist in base if it holds overlay files.
+func (o *overlayFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	list, err := o.base.ReadDir(dir)
+	byName := make(map[string]os.FileInfo)
+	for _, info := range list {
+		byName[info.Name()] = info
+	}
+	added := false
+	for name, contents := range o.files {
+		if filepath.Dir(name) == dir {
+			byName[filepath.Base(name)] = fileInfo{filepath.Base(name), int64(len(contents))}
+			added = true
+		}
+	}
+	if !added {
+		return list, err
+	}
+	list = make([]os.FileInfo, 0, len(byName))
+	for _, info := range byName {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (o *overlayFS) Stat(name string) (os.FileInfo, error) {
+	if contents, ok := o.files[name]; ok {
+		return fileInfo{filepath.Base(name), int64(len(contents))}, nil
+	}
+	info, err := o.base.Stat(name)
+	if err != nil {
+		for file := range o.files {
+			if strings.HasPrefix(file, name+string(filepath.Separator)) {
+				return dirInfo(filepath.Base(name)), nil
+			}
+		}
+	}
+	return info, err
+}
+
+// A snapshotFS is a FileSystem that reads each file and directory of base
+// at most once, so that a series of reads sees them as they were when
+// first read, even if they change meanwhile.
+type snapshotFS struct {
+	base  FileSystem
+	mu    sync.Mutex // Guards the maps; the search for packages runs concurrently.
+	files map[string]snapshotFile
+	dirs  map[string]snapshotDir
+	stats map[string]snapshotStat
+}
+
+type snapshotFile struct {
+	data []byte
+	err  error
+}
+
+type snapshotDir struct {
+	list []os.FileInfo
+	err  error
+}
+
+type snapshotStat struct {
+	info os.FileInfo
+	err  error
+}
+
+func newSnapshotFS(base FileSystem) *snapshotFS {
+	return &snapshotFS{
+		base:  base,
+		files: make(map[string]snapshotFile),
+		dirs:  make(map[string]snapshotDir),
+		stats: make(map[string]snapshotStat),
+	}
+}
+
+func (s *snapshotFS) Open(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		var r io.ReadCloser
+		r, f.err = s.base.Open(name)
+		if f.err == nil {
+			f.data, f.err = ioutil.ReadAll(r)
+			r.Close()
+		}
+		s.files[name] = f
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+func (s *snapshotFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.dirs[dir]
+	if !ok {
+		d.list, d.err = s.base.ReadDir(dir)
+		s.dirs[dir] = d
+	}
+	return d.list, d.err
+}
+
+func (s *snapshotFS) Stat(name string) (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[name]
+	if !ok {
+		st.info, st.err = s.base.Stat(name)
+		s.stats[name] = st
+	}
+	return st.info, st.err
+}
+
+// useFileSystem sets the hooks of ctxt so that it reads from fsys.
+func useFileSystem(ctxt *build.Context, fsys FileSystem) {
+	ctxt.IsDir = func(name string) bool {
+		fi, err := fsys.Stat(name)
+		return err == nil && fi.IsDir()
+	}
+	ctxt.ReadDir = fsys.ReadDir
+	ctxt.OpenFile = fsys.Open
+}
+
+// readDir and readFile read from fileSystem.
+func readDir(dir string) ([]os.FileInfo, error) {
+	return fileSystem.ReadDir(dir)
+}
+
+func readFile(name string) ([]byte, error) {
+	f, err := fileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// A tree is a FileSystem built from a list of files, with names that
+// are slash-separated and relative to its root, ".". Its directories
+// are implied by the names of the files.
+type tree struct {
+	files map[string]func() (io.ReadCloser, error) // Opener for each file.
+	info  map[string]os.FileInfo                   // Information about each file and directory.
+	dirs  map[string][]os.FileInfo                 // Directory contents, sorted by name.
+}
+
+func newTree() *tree {
+	return &tree{
+		files: make(map[string]func() (io.ReadCloser, error)),
+		info:  map[string]os.FileInfo{".": dirInfo(".")},
+		dirs:  map[string][]os.FileInfo{".": nil},
+	}
+}
+
+// add adds the named file to the tree, along with any directories
+// needed to hold it. The name must be clean.
+func (t *tree) add(name string, info os.FileInfo, open func() (io.ReadCloser, error)) {
+	if _, ok := t.info[name]; ok {
+		return
+	}
+	t.info[name] = info
+	if info.IsDir() {
+		t.dirs[name] = nil
+	} else {
+		t.files[name] = open
+	}
+	dir := path.Dir(name)
+	t.add(dir, dirInfo(path.Base(dir)), nil)
+	list := t.dirs[dir]
+	i := sort.Search(len(list), func(i int) bool { return list[i].Name() >= info.Name() })
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = info
+	t.dirs[dir] = list
+}
+
+func (t *tree) Open(name string) (io.ReadCloser, error) {
+	open := t.files[name]
+	if open == nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return open()
+}
+
+func (t *tree) ReadDir(dir string) ([]os.FileInfo, error) {
+	list, ok := t.dirs[dir]
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: os.ErrNotExist}
+	}
+	return list, nil
+}
+
+func (t *tree) Stat(name string) (os.FileInfo, error) {
+	info, ok := t.info[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return info, nil
+}
+
+// dirInfo is the os.FileInfo for a directory in a tree.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
+
+// fileInfo is the os.FileInfo for a file in a tree held in memory.
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) Mode() os.FileMode  { return 0444 }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,196 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"io"
+	"strconv"
+)
+
+// An ifaceRef is an interface type and the package that declares it.
+type ifaceRef struct {
+	pkg  *Package
+	spec *ast.TypeSpec
+}
+
+// name returns the interface's name, qualified by its package's name.
+func (r ifaceRef) name() string {
+	return r.pkg.name + "." + r.spec.Name.Name
+}
+
+// A hierarchy is the state of -hierarchy: the packages searched for
+// interfaces and implementations, and the directories of the packages
+// each imports, by name, as they are needed.
+type hierarchy struct {
+	writer  io.Writer
+	pkgs    []*Package
+	imports map[*Package]map[string]string
+}
+
+// hierarchyDoc prints, for the -hierarchy flag, a tree of the interface
+// named by the first argument, [<pkg>.]<sym> as for go doc: the
+// interfaces it embeds, and the ones they embed; the interfaces that
+// embed it, and the ones that embed them; and beneath each, the concrete
+// types that implement it. Interfaces and implementations are searched
+// for in the interface's package and in the packages given by the other
+// arguments, which may be patterns such as ./...; embedded interfaces
+// are found wherever they are declared. As for -satisfies-constraint,
+// methods promoted from types of other packages are not known.
+func hierarchyDoc(writer io.Writer, args []string) error {
+	if len(args) == 0 {
+		usage()
+	}
+	pkg, spec := lookupType(writer, args[0])
+	if _, ok := spec.Type.(*ast.InterfaceType); !ok {
+		return fmt.Errorf("%s.%s is not an interface type", pkg.name, spec.Name.Name)
+	}
+	h := &hierarchy{writer: writer, pkgs: []*Package{pkg}, imports: make(map[*Package]map[string]string)}
+	if len(args) > 1 {
+		for _, p := range matchPackages(args[1:]) {
+			if p.pkg.Dir != pkg.build.Dir {
+				h.pkgs = append(h.pkgs, parsePackage(writer, p.pkg, p.path))
+			}
+		}
+	}
+	defer pkg.flush()
+	root := ifaceRef{pkg, spec}
+	pkg.Printf("%s\n", root.name())
+	pkg.printTree(nonEmpty(
+		&treeNode{"embeds", h.embeds(root)},
+		&treeNode{"embedded by", h.embeddedBy(root)},
+		&treeNode{"implemented by", h.implementers(root)},
+	), "")
+	return nil
+}
+
+// embeds returns a node for each interface the interface embeds, with
+// those they embed and their implementations beneath them.
+func (h *hierarchy) embeds(r ifaceRef) []*treeNode {
+	var nodes []*treeNode
+	for _, field := range r.spec.Type.(*ast.InterfaceType).Methods.List {
+		if len(field.Names) > 0 {
+			continue
+		}
+		var embedded ifaceRef
+		switch t := field.Type.(type) {
+		case *ast.Ident:
+			if t.Name == "error" && r.pkg.lookupTypeSpec(t.Name) == nil {
+				nodes = append(nodes, &treeNode{text: "error"})
+				continue
+			}
+			embedded = ifaceRef{r.pkg, r.pkg.lookupTypeSpec(t.Name)}
+		case *ast.SelectorExpr:
+			if other := r.pkg.findImport(h.writer, identName(t.X)); other != nil {
+				embedded = ifaceRef{other, other.lookupTypeSpec(t.Sel.Name)}
+			}
+		}
+		if embedded.spec == nil {
+			nodes = append(nodes, &treeNode{text: r.pkg.oneLineNode(field.Type)}) // Not found.
+			continue
+		}
+		if _, ok := embedded.spec.Type.(*ast.InterfaceType); !ok {
+			continue
+		}
+		nodes = append(nodes, &treeNode{embedded.name(), nonEmpty(
+			&treeNode{"embeds", h.embeds(embedded)},
+			&treeNode{"implemented by", h.implementers(embedded)},
+		)})
+	}
+	return nodes
+}
+
+// embeddedBy returns a node for each interface of the packages searched
+// that embeds the interface, with those that embed them and their
+// implementations beneath them.
+func (h *hierarchy) embeddedBy(r ifaceRef) []*treeNode {
+	var nodes []*treeNode
+	for _, p := range h.pkgs {
+		for _, typ := range p.doc.Types {
+			if !isExported(typ.Name) {
+				continue
+			}
+			spec := p.findTypeSpec(typ.Decl, typ.Name)
+			iface, ok := spec.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			for _, field := range iface.Methods.List {
+				if len(field.Names) == 0 && h.refersTo(p, field.Type, r) {
+					embedder := ifaceRef{p, spec}
+					nodes = append(nodes, &treeNode{embedder.name(), nonEmpty(
+						&treeNode{"embedded by", h.embeddedBy(embedder)},
+						&treeNode{"implemented by", h.implementers(embedder)},
+					)})
+					break
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// refersTo reports whether the type expression, in package p, names the
+// interface.
+func (h *hierarchy) refersTo(p *Package, expr ast.Expr, r ifaceRef) bool {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return p == r.pkg && t.Name == r.spec.Name.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name == r.spec.Name.Name && h.importDir(p, identName(t.X)) == r.pkg.build.Dir
+	}
+	return false
+}
+
+// importDir returns the directory of the package p imports under name,
+// or "" if there is none.
+func (h *hierarchy) importDir(p *Package, name string) string {
+	dirs, ok := h.imports[p]
+	if !ok {
+		dirs = make(map[string]string)
+		for _, imp := range p.file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			bpkg, err := buildCtx.Import(path, p.build.Dir, 0)
+			if err != nil {
+				continue
+			}
+			if imp.Name != nil {
+				dirs[imp.Name.Name] = bpkg.Dir
+			} else {
+				dirs[bpkg.Name] = bpkg.Dir
+			}
+		}
+		h.imports[p] = dirs
+	}
+	return dirs[name]
+}
+
+// implementers returns a node for each concrete type of the packages
+// searched that implements the interface, or whose pointer type does.
+func (h *hierarchy) implementers(r ifaceRef) []*treeNode {
+	var nodes []*treeNode
+	for _, p := range h.pkgs {
+		// The signatures of the interface's methods, as written in p.
+		want := make(map[string]methodSig)
+		r.pkg.interfaceMethods(h.writer, r.spec.Type.(*ast.InterfaceType), p, want)
+		for _, typ := range p.doc.Types {
+			if !isExported(typ.Name) {
+				continue
+			}
+			if _, ok := p.findTypeSpec(typ.Decl, typ.Name).Type.(*ast.InterfaceType); ok {
+				continue
+			}
+			value, pointer := implements(p.typeMethods(typ), want)
+			switch {
+			case value:
+				nodes = append(nodes, &treeNode{text: p.name + "." + typ.Name})
+			case pointer:
+				nodes = append(nodes, &treeNode{text: "*" + p.name + "." + typ.Name})
+			}
+		}
+	}
+	return nodes
+}
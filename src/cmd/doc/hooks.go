@@ -0,0 +1,84 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"sort"
+)
+
+// hooksSummary prints, for the -hooks flag, the package's exported
+// variables whose types are channels or functions, which are most often
+// hooks for users to replace, each with its type and its initial value:
+// nil if it has none.
+func (pkg *Package) hooksSummary() {
+	vars := pkg.doc.Vars
+	for _, typ := range pkg.doc.Types {
+		vars = append(vars[:len(vars):len(vars)], typ.Vars...)
+	}
+	var lines []string
+	for _, value := range vars {
+		for _, spec := range value.Decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, name := range spec.Names {
+				if !isExported(name.Name) {
+					continue
+				}
+				var val ast.Expr
+				if i < len(spec.Values) {
+					val = spec.Values[i]
+				}
+				typ := pkg.hookType(spec.Type, val)
+				if typ == nil {
+					continue
+				}
+				init := "nil"
+				if val != nil {
+					init = pkg.oneLineNode(val)
+				}
+				lines = append(lines, "var "+name.Name+" "+pkg.oneLineNode(typ)+" = "+init)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	pkg.newlines(2)
+	pkg.Printf("Variables you can override:\n")
+	for _, line := range lines {
+		pkg.Printf("%s%s\n", indent, line)
+	}
+}
+
+// hookType returns the type of a variable declared with the type and
+// initial value, either of which may be nil, if it is a channel or
+// function type, or a type of the package defined as one; otherwise nil.
+// Without a type, it is known only from a function literal or a call of
+// make.
+func (pkg *Package) hookType(typ, val ast.Expr) ast.Expr {
+	if typ == nil {
+		switch v := val.(type) {
+		case *ast.FuncLit:
+			typ = v.Type
+		case *ast.CallExpr:
+			if identName(v.Fun) == "make" && len(v.Args) > 0 {
+				typ = v.Args[0]
+			}
+		}
+	}
+	switch t := typ.(type) {
+	case *ast.ChanType, *ast.FuncType:
+		return typ
+	case *ast.Ident:
+		if spec := pkg.lookupTypeSpec(t.Name); spec != nil {
+			switch spec.Type.(type) {
+			case *ast.ChanType, *ast.FuncType:
+				return typ
+			}
+		}
+	}
+	return nil
+}
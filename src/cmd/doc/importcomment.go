@@ -0,0 +1,34 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// checkImportComments prints, for the -check-import-comments flag, a line
+// for each package in the trees rooted at the arguments, as for -list,
+// whose import comment differs from the path at which it is installed,
+// and so cannot be built there. It returns an error if there are any.
+// Packages outside GOROOT and GOPATH have no such path and are skipped.
+func checkImportComments(writer io.Writer, args []string) error {
+	bad := 0
+	for _, p := range matchPackages(treePatterns(args)) {
+		comment, path := p.pkg.ImportComment, p.pkg.ImportPath
+		if comment == "" || comment == path || path == "." {
+			continue
+		}
+		fmt.Fprintf(writer, "%s: import comment %q differs from install path %q\n", p.path, comment, path)
+		bad++
+	}
+	switch {
+	case bad == 1:
+		return fmt.Errorf("1 package is not installed at its import path")
+	case bad > 1:
+		return fmt.Errorf("%d packages are not installed at their import paths", bad)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"strings"
+)
+
+// importsSummary prints, for the -imports flag, the packages the package
+// imports as an import block, the standard library first, then the
+// others after a blank line, as goimports groups them.
+func (pkg *Package) importsSummary() {
+	var std, other []string
+	for _, path := range pkg.build.Imports {
+		if isStandard(path, pkg.build.Dir) {
+			std = append(std, path)
+		} else {
+			other = append(other, path)
+		}
+	}
+	if len(std)+len(other) == 0 {
+		return
+	}
+	pkg.Printf("import (\n")
+	for _, path := range std {
+		pkg.Printf("\t%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		pkg.Printf("\n")
+	}
+	for _, path := range other {
+		pkg.Printf("\t%q\n", path)
+	}
+	pkg.Printf(")\n\n")
+}
+
+// isStandard reports whether the import path, imported from dir, is of a
+// package of the standard library. If the package cannot be found, it
+// guesses as the go command does: paths whose first element has no dot,
+// such as C, are standard.
+func isStandard(path, dir string) bool {
+	if bpkg, err := buildCtx.Import(path, dir, build.FindOnly); err == nil {
+		return bpkg.Goroot
+	}
+	elem := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		elem = path[:i]
+	}
+	return !strings.Contains(elem, ".")
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+)
+
+// inlineTypes prints, for the -inline-types flag, beneath the documentation
+// of the function or method, a one-line summary of each named type in its
+// signature other than its receiver's, in the order they appear, so that
+// what they are can be seen without asking for them. Types of imported
+// packages are qualified by the package's name; those that cannot be
+// found, and the predeclared types, are left out.
+func (pkg *Package) inlineTypes(fun *ast.FuncDecl) {
+	recv := ""
+	if fun.Recv != nil {
+		recv = typeName(fun.Recv.List[0].Type)
+	}
+	seen := map[string]bool{recv: true}
+	imported := make(map[string]*Package) // By name, nil if not found.
+	var lines []string
+	ast.Inspect(fun.Type, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			ast.Inspect(n.Type, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					x := identName(n.X)
+					if x == "" || seen[x+"."+n.Sel.Name] {
+						return false
+					}
+					seen[x+"."+n.Sel.Name] = true
+					other, ok := imported[x]
+					if !ok {
+						other = pkg.findImport(pkg.writer, x)
+						imported[x] = other
+					}
+					if other == nil {
+						return false
+					}
+					if spec := other.lookupTypeSpec(n.Sel.Name); spec != nil {
+						lines = append(lines, other.qualifiedType(spec))
+					}
+					return false
+				case *ast.Ident:
+					if seen[n.Name] {
+						return false
+					}
+					seen[n.Name] = true
+					if spec := pkg.lookupTypeSpec(n.Name); spec != nil {
+						lines = append(lines, pkg.oneLineNode(spec))
+					}
+				}
+				return true
+			})
+			return false // Not the names.
+		}
+		return true
+	})
+	if len(lines) == 0 {
+		return
+	}
+	pkg.newlines(2)
+	for _, line := range lines {
+		pkg.Printf("%s%s\n", indent, line)
+	}
+	pkg.newlines(2)
+}
+
+// qualifiedType returns the one-line summary of the type, with its name
+// qualified by the package's name.
+func (pkg *Package) qualifiedType(spec *ast.TypeSpec) string {
+	// Qualify the name in place, then restore it, as iotaString does.
+	name := spec.Name.Name
+	spec.Name.Name = pkg.name + "." + name
+	s := pkg.oneLineNode(spec)
+	spec.Name.Name = name
+	return s
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"strings"
+)
+
+// iotaComments returns, for the -iota flag, the line comment that gives
+// the values of the constants of each specification of the const
+// declaration, or nil if the declaration does not use iota. The values
+// are worked out from the declaration alone, as untyped constants: a
+// value that depends on anything else, such as a constant declared
+// elsewhere, is shown as ?.
+func iotaComments(decl *ast.GenDecl) map[*ast.ValueSpec]*ast.CommentGroup {
+	if decl.Tok != token.CONST || !usesIota(decl) {
+		return nil
+	}
+	comments := make(map[*ast.ValueSpec]*ast.CommentGroup)
+	known := make(map[string]constant.Value) // The block's constants so far.
+	var values []ast.Expr
+	for iota, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		if spec.Type != nil || spec.Values != nil {
+			values = spec.Values
+		}
+		var text []string
+		for i, name := range spec.Names {
+			v := constant.MakeUnknown()
+			if i < len(values) {
+				v = evalConst(values[i], iota, known)
+			}
+			if name.Name != "_" {
+				known[name.Name] = v
+			}
+			if v.Kind() == constant.Unknown {
+				text = append(text, "?")
+			} else {
+				text = append(text, v.String())
+			}
+		}
+		// Keep any comment already there, after the values.
+		c := &ast.Comment{Slash: spec.End(), Text: "// = " + strings.Join(text, ", ")}
+		if spec.Comment != nil {
+			c.Text += "; " + strings.TrimSpace(spec.Comment.Text())
+		}
+		comments[spec] = &ast.CommentGroup{List: []*ast.Comment{c}}
+	}
+	return comments
+}
+
+// usesIota reports whether a value of the declaration refers to iota.
+func usesIota(decl *ast.GenDecl) bool {
+	found := false
+	for _, spec := range decl.Specs {
+		for _, v := range spec.(*ast.ValueSpec).Values {
+			ast.Inspect(v, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+					found = true
+				}
+				return !found
+			})
+		}
+	}
+	return found
+}
+
+// evalConst returns the value of the constant expression, with iota
+// having the given value and the constants in known theirs, or an
+// unknown value if it cannot be worked out. A call with one argument
+// is taken to be a conversion, which leaves the value as it is.
+func evalConst(expr ast.Expr, iota int, known map[string]constant.Value) constant.Value {
+	unknown := constant.MakeUnknown()
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(e.Value, e.Kind, 0)
+	case *ast.Ident:
+		switch e.Name {
+		case "iota":
+			return constant.MakeInt64(int64(iota))
+		case "true", "false":
+			return constant.MakeBool(e.Name == "true")
+		}
+		if v, ok := known[e.Name]; ok {
+			return v
+		}
+	case *ast.ParenExpr:
+		return evalConst(e.X, iota, known)
+	case *ast.CallExpr:
+		if len(e.Args) == 1 {
+			return evalConst(e.Args[0], iota, known)
+		}
+	case *ast.UnaryExpr:
+		x := evalConst(e.X, iota, known)
+		if x.Kind() != constant.Unknown {
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+	case *ast.BinaryExpr:
+		x, y := evalConst(e.X, iota, known), evalConst(e.Y, iota, known)
+		if x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+			return unknown
+		}
+		if x.Kind() != y.Kind() && (!isNumeric(x) || !isNumeric(y)) && e.Op != token.SHL && e.Op != token.SHR {
+			return unknown // Not valid Go.
+		}
+		switch e.Op {
+		case token.SHL, token.SHR:
+			if s, ok := constant.Uint64Val(y); ok && x.Kind() == constant.Int && s < 1<<16 {
+				return constant.Shift(x, e.Op, uint(s))
+			}
+			return unknown
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, e.Op, y))
+		case token.QUO, token.REM:
+			if constant.Sign(y) == 0 {
+				return unknown
+			}
+			if e.Op == token.QUO && x.Kind() == constant.Int && y.Kind() == constant.Int {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y) // Integer division.
+			}
+		}
+		return constant.BinaryOp(x, e.Op, y)
+	}
+	return unknown
+}
+
+// isNumeric reports whether the value is a number.
+func isNumeric(v constant.Value) bool {
+	k := v.Kind()
+	return k == constant.Int || k == constant.Float || k == constant.Complex
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// releaseTags returns the release tags satisfied by the language version
+// given by the -lang flag, such as go1.7: go1.1 through that version. The
+// files of a package are then selected by their build constraints as that
+// version of Go would select them.
+func releaseTags(lang string) []string {
+	minor := 0
+	if lang != "go1" {
+		var err error
+		minor, err = strconv.Atoi(strings.TrimPrefix(lang, "go1."))
+		if !strings.HasPrefix(lang, "go1.") || err != nil || minor < 0 {
+			fatalf("invalid -lang %q; want a version such as go1.8", lang)
+		}
+	}
+	var tags []string
+	for i := 1; i <= minor; i++ {
+		tags = append(tags, "go1."+strconv.Itoa(i))
+	}
+	return tags
+}
+
+// experimentTags returns the build tags, such as goexperiment.fieldtrack,
+// of the experiments the GOEXPERIMENT setting, a comma-separated list,
+// enables. Experiments disabled with a "no" prefix have none.
+func experimentTags(goexperiment string) []string {
+	var tags []string
+	for _, name := range strings.Split(goexperiment, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.HasPrefix(name, "no") {
+			continue
+		}
+		tags = append(tags, "goexperiment."+name)
+	}
+	return tags
+}
@@ -0,0 +1,255 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+)
+
+// archSizes gives the word size and the maximum alignment of each
+// architecture, as the gc compiler lays out data.
+var archSizes = map[string]struct{ word, maxAlign int64 }{
+	"386":      {4, 4},
+	"arm":      {4, 4},
+	"armbe":    {4, 4},
+	"amd64":    {8, 8},
+	"amd64p32": {4, 8},
+	"arm64":    {8, 8},
+	"arm64be":  {8, 8},
+	"mips":     {4, 4},
+	"mipsle":   {4, 4},
+	"mips64":   {8, 8},
+	"mips64le": {8, 8},
+	"ppc64":    {8, 8},
+	"ppc64le":  {8, 8},
+	"s390x":    {8, 8},
+}
+
+// A layout is the size and alignment of a type, in bytes.
+type layout struct {
+	size, align int64
+}
+
+// A layoutCalc works out the layouts of types for an architecture.
+type layoutCalc struct {
+	word, maxAlign int64
+	consts         map[*Package]map[string]constant.Value // For array lengths.
+}
+
+// layoutSummary prints, for the -layout flag, beneath the documentation
+// of the type, its size and alignment for the architecture selected by
+// GOARCH or -goarch, and for a struct the offset, size and alignment of
+// each field and the bytes of padding between and after them, as the gc
+// compiler lays them out. Types whose layout cannot be worked out from
+// their declarations, as when a package cannot be found, are noted.
+func (pkg *Package) layoutSummary(spec *ast.TypeSpec) {
+	arch, ok := archSizes[buildCtx.GOARCH]
+	if !ok {
+		fatalf("-layout: unknown architecture %s", buildCtx.GOARCH)
+	}
+	c := &layoutCalc{word: arch.word, maxAlign: arch.maxAlign, consts: make(map[*Package]map[string]constant.Value)}
+	pkg.newlines(2)
+	defer pkg.newlines(2)
+	in, typ := pkg.underlying(spec.Type, 10)
+	st, isStruct := typ.(*ast.StructType)
+	if !isStruct {
+		l, ok := c.layout(pkg, spec.Type, 10)
+		if !ok {
+			pkg.Printf("%slayout unknown on %s\n", indent, buildCtx.GOARCH)
+			return
+		}
+		pkg.Printf("%ssize %d, align %d on %s\n", indent, l.size, l.align, buildCtx.GOARCH)
+		return
+	}
+	pkg.Printf("%s%6s %5s %5s  %s\n", indent, "offset", "size", "align", "field")
+	var offset, align, used int64 = 0, 1, 0
+	for _, field := range st.Fields.List {
+		l, ok := c.layout(in, field.Type, 10)
+		if !ok {
+			pkg.Printf("%slayout unknown on %s: %s\n", indent, buildCtx.GOARCH, in.oneLineNode(field.Type))
+			return
+		}
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: typeName(field.Type)}} // Embedded.
+		}
+		for _, name := range names {
+			offset = alignTo(offset, l.align)
+			pkg.Printf("%s%6d %5d %5d  %s %s\n", indent, offset, l.size, l.align, name.Name, in.oneLineNode(field.Type))
+			offset += l.size
+			used += l.size
+		}
+		if l.align > align {
+			align = l.align
+		}
+	}
+	size := c.structSize(in, offset, align, st)
+	pkg.Printf("%ssize %d, align %d, padding %d on %s\n", indent, size, align, size-used, buildCtx.GOARCH)
+}
+
+// alignTo returns x rounded up to a multiple of align.
+func alignTo(x, align int64) int64 {
+	return (x + align - 1) / align * align
+}
+
+// structSize returns the size of the struct, as written in the package,
+// whose fields end at offset, with the given alignment. As the gc
+// compiler does, a struct whose last field has size zero is padded, so
+// that the address of that field does not point past the struct.
+func (c *layoutCalc) structSize(pkg *Package, offset, align int64, st *ast.StructType) int64 {
+	if n := len(st.Fields.List); n > 0 && offset > 0 {
+		last := st.Fields.List[n-1]
+		if l, ok := c.layout(pkg, last.Type, 10); ok && l.size == 0 {
+			offset++
+		}
+	}
+	return alignTo(offset, align)
+}
+
+// basicSizes gives the sizes of the predeclared types whose size does not
+// depend on the architecture; int, uint and uintptr are a word.
+var basicSizes = map[string]int64{
+	"bool": 1, "int8": 1, "uint8": 1, "byte": 1,
+	"int16": 2, "uint16": 2,
+	"int32": 4, "uint32": 4, "rune": 4, "float32": 4,
+	"int64": 8, "uint64": 8, "float64": 8, "complex64": 8,
+	"complex128": 16,
+}
+
+// layout returns the layout of the type expression, as written in the
+// package, following the declarations of named types to at most the given
+// depth. It reports whether the layout could be worked out.
+func (c *layoutCalc) layout(pkg *Package, expr ast.Expr, depth int) (layout, bool) {
+	word := layout{c.word, c.word}
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return c.layout(pkg, t.X, depth)
+	case *ast.Ident:
+		switch t.Name {
+		case "int", "uint", "uintptr":
+			return word, true
+		case "string":
+			return layout{2 * c.word, c.word}, true
+		case "error":
+			if pkg.lookupTypeSpec(t.Name) == nil {
+				return layout{2 * c.word, c.word}, true
+			}
+		}
+		if size, ok := basicSizes[t.Name]; ok && pkg.lookupTypeSpec(t.Name) == nil {
+			align := size
+			if t.Name == "complex64" || t.Name == "complex128" {
+				align = size / 2
+			}
+			if align > c.maxAlign {
+				align = c.maxAlign
+			}
+			return layout{size, align}, true
+		}
+		if depth == 0 {
+			return layout{}, false
+		}
+		spec := pkg.lookupTypeSpec(t.Name)
+		if spec == nil {
+			return layout{}, false
+		}
+		return c.layout(pkg, spec.Type, depth-1)
+	case *ast.SelectorExpr:
+		if identName(t.X) == "unsafe" && t.Sel.Name == "Pointer" {
+			return word, true
+		}
+		if depth == 0 {
+			return layout{}, false
+		}
+		other := pkg.findImport(pkg.writer, identName(t.X))
+		if other == nil {
+			return layout{}, false
+		}
+		spec := other.lookupTypeSpec(t.Sel.Name)
+		if spec == nil {
+			return layout{}, false
+		}
+		return c.layout(other, spec.Type, depth-1)
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType:
+		return word, true
+	case *ast.InterfaceType:
+		return layout{2 * c.word, c.word}, true
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return layout{3 * c.word, c.word}, true // A slice.
+		}
+		n, ok := c.arrayLen(pkg, t.Len)
+		if !ok {
+			return layout{}, false
+		}
+		elem, ok := c.layout(pkg, t.Elt, depth)
+		if !ok {
+			return layout{}, false
+		}
+		return layout{n * elem.size, elem.align}, true
+	case *ast.StructType:
+		var offset, align int64 = 0, 1
+		for _, field := range t.Fields.List {
+			l, ok := c.layout(pkg, field.Type, depth)
+			if !ok {
+				return layout{}, false
+			}
+			n := len(field.Names)
+			if n == 0 {
+				n = 1 // Embedded.
+			}
+			for i := 0; i < n; i++ {
+				offset = alignTo(offset, l.align) + l.size
+			}
+			if l.align > align {
+				align = l.align
+			}
+		}
+		return layout{c.structSize(pkg, offset, align, t), align}, true
+	}
+	return layout{}, false
+}
+
+// arrayLen returns the length of an array type, which may use the
+// constants of the package.
+func (c *layoutCalc) arrayLen(pkg *Package, expr ast.Expr) (int64, bool) {
+	known := c.consts[pkg]
+	if known == nil {
+		known = pkg.constValues()
+		c.consts[pkg] = known
+	}
+	v := constant.ToInt(evalConst(expr, 0, known))
+	if v.Kind() != constant.Int {
+		return 0, false
+	}
+	n, ok := constant.Int64Val(v)
+	return n, ok && n >= 0
+}
+
+// constValues returns the values of the package's constants that can be
+// worked out from their declarations, as for -iota.
+func (pkg *Package) constValues() map[string]constant.Value {
+	known := make(map[string]constant.Value)
+	for _, decl := range pkg.file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			continue
+		}
+		var values []ast.Expr
+		for iota, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			if spec.Type != nil || spec.Values != nil {
+				values = spec.Values
+			}
+			for i, name := range spec.Names {
+				if i < len(values) && name.Name != "_" {
+					known[name.Name] = evalConst(values[i], iota, known)
+				}
+			}
+		}
+	}
+	return known
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// reportLeaks prints, for the -leaks flag, a line for each exported
+// symbol of the packages in the trees rooted at the arguments, as for
+// -list, whose declaration uses types of internal packages: functions and
+// methods in their signatures, types in their exported fields, methods
+// or underlying types, and values in their types. Packages importing
+// them cannot name those types. Internal packages and commands are not
+// checked. It returns an error if there are any.
+func reportLeaks(writer io.Writer, args []string) error {
+	bad := 0
+	for _, p := range matchPackages(treePatterns(args)) {
+		if p.pkg.IsCommand() || isInternal(p.pkg.ImportPath) {
+			continue
+		}
+		pkg := parsePackage(writer, p.pkg, p.path)
+		internal := pkg.internalImports()
+		if len(internal) == 0 {
+			continue
+		}
+		for _, leak := range pkg.leaks(internal) {
+			fmt.Fprintf(writer, "%s.%s: %s\n", p.path, leak.sym, strings.Join(leak.types, ", "))
+			bad++
+		}
+	}
+	switch {
+	case bad == 1:
+		return fmt.Errorf("1 exported symbol uses internal types")
+	case bad > 1:
+		return fmt.Errorf("%d exported symbols use internal types", bad)
+	}
+	return nil
+}
+
+// isInternal reports whether the import path has an element internal.
+func isInternal(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// internalImports returns the import paths of the internal packages the
+// package imports, by the names under which they are imported.
+func (pkg *Package) internalImports() map[string]string {
+	paths := make(map[string]string)
+	for _, imp := range pkg.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if !isInternal(path) {
+			continue
+		}
+		if imp.Name != nil {
+			paths[imp.Name.Name] = path
+			continue
+		}
+		bpkg, err := buildCtx.Import(path, pkg.build.Dir, 0)
+		if err != nil {
+			continue
+		}
+		paths[bpkg.Name] = path
+	}
+	return paths
+}
+
+// A leak is an exported symbol, Name or Type.Method, and the internal
+// types, path.Name, its declaration uses.
+type leak struct {
+	sym   string
+	types []string
+}
+
+// leaks returns the exported symbols of the package whose declarations
+// use types of the internal packages it imports under the given names,
+// in the order go doc prints them.
+func (pkg *Package) leaks(internal map[string]string) []leak {
+	var leaks []leak
+	check := func(sym string, nodes ...ast.Node) {
+		var types []string
+		seen := make(map[string]bool)
+		for _, node := range nodes {
+			ast.Inspect(node, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if path, ok := internal[identName(sel.X)]; ok && !seen[path+"."+sel.Sel.Name] {
+					seen[path+"."+sel.Sel.Name] = true
+					types = append(types, path+"."+sel.Sel.Name)
+				}
+				return false
+			})
+		}
+		if len(types) > 0 {
+			leaks = append(leaks, leak{sym, types})
+		}
+	}
+	checkValues := func(values []*doc.Value) {
+		for _, value := range values {
+			for _, spec := range value.Decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for _, name := range spec.Names {
+					if isExported(name.Name) && spec.Type != nil {
+						check(name.Name, spec.Type)
+					}
+				}
+			}
+		}
+	}
+	checkFuncs := func(prefix string, funcs []*doc.Func) {
+		for _, fun := range funcs {
+			if isExported(fun.Name) {
+				check(prefix+fun.Name, fun.Decl.Type)
+			}
+		}
+	}
+	checkValues(pkg.doc.Consts)
+	checkValues(pkg.doc.Vars)
+	checkFuncs("", pkg.doc.Funcs)
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		check(typ.Name, exportedParts(pkg.findTypeSpec(typ.Decl, typ.Name))...)
+		checkValues(typ.Consts)
+		checkValues(typ.Vars)
+		checkFuncs("", typ.Funcs)
+		checkFuncs(typ.Name+".", typ.Methods)
+	}
+	return leaks
+}
+
+// exportedParts returns the parts of the type's declaration that those
+// of other packages can see: the exported fields and embedded types of a
+// struct, the exported methods and embedded interfaces of an interface,
+// and otherwise the whole type.
+func exportedParts(spec *ast.TypeSpec) []ast.Node {
+	var list *ast.FieldList
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		list = t.Fields
+	case *ast.InterfaceType:
+		list = t.Methods
+	default:
+		return []ast.Node{spec.Type}
+	}
+	var nodes []ast.Node
+	for _, field := range list.List {
+		if len(field.Names) == 0 {
+			nodes = append(nodes, field.Type)
+			continue
+		}
+		for _, name := range field.Names {
+			if isExported(name.Name) {
+				nodes = append(nodes, field.Type)
+				break
+			}
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// listPackages prints, for the -list flag, the import path and synopsis
+// of each package in the trees rooted at the directories of the
+// arguments, which are package paths or relative directories, optionally
+// followed by /..., or other package patterns. With no arguments it lists
+// the tree rooted at the current directory.
+func listPackages(writer io.Writer, args []string) error {
+	tw := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+	for _, p := range matchPackages(treePatterns(args)) {
+		fmt.Fprintf(tw, "%s\t%s\n", p.path, packageSynopsis(p.pkg))
+	}
+	return nil
+}
+
+// treePatterns returns the patterns matching the trees rooted at the
+// directories of the arguments, as for -list: each argument that is not
+// already a pattern is followed by /..., and no arguments means the
+// tree rooted at the current directory.
+func treePatterns(args []string) []string {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var patterns []string
+	for _, arg := range args {
+		if !isPattern(arg) {
+			arg = strings.TrimSuffix(arg, "/") + "/..."
+		}
+		patterns = append(patterns, arg)
+	}
+	return patterns
+}
+
+// packageSynopsis returns the synopsis of the package, the first
+// sentence of its doc comment.
+func packageSynopsis(pkg *build.Package) string {
+	// The doc comment is conventionally in doc.go, if there is one.
+	names := append(pkg.GoFiles, pkg.CgoFiles...)
+	if contains(names, "doc.go") {
+		names = append([]string{"doc.go"}, names...)
+	}
+	fset := token.NewFileSet()
+	for _, name := range names {
+		filename := filepath.Join(pkg.Dir, name)
+		src, err := readFile(filename)
+		if err != nil {
+			warnf(warnSkip, "%v", err)
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, src, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || f.Doc == nil {
+			continue
+		}
+		text, _ := splitDirectives(f.Doc.Text())
+		if synopsis := doc.Synopsis(text); synopsis != "" {
+			return synopsis
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+)
+
+// literalDoc prints, for the -literal flag, a composite literal of each
+// struct type matching the symbol, as the address of a value, with each
+// exported field set to its zero value, to be pasted into a program and
+// filled in. Names are qualified by their package's name, as they are
+// written by the package's importers.
+func (pkg *Package) literalDoc(symbol, method string) error {
+	defer pkg.flush()
+	if method != "" {
+		pkg.Fatalf("-literal shows a struct type, not a method: %s.%s", symbol, method)
+	}
+	types := pkg.findTypes(symbol)
+	if len(types) == 0 {
+		pkg.failf(errSymbol, "no type %s in package %s", symbol, pkg.prettyPath())
+	}
+	for i, typ := range types {
+		spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+		in, under := pkg.underlying(spec.Type, 10)
+		st, ok := under.(*ast.StructType)
+		if !ok {
+			pkg.Fatalf("%s.%s is not a struct type", pkg.name, typ.Name)
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "&%s.%s{\n", pkg.name, typ.Name)
+		for _, field := range st.Fields.List {
+			names := field.Names
+			if len(names) == 0 {
+				embedded := field.Type
+				if star, ok := embedded.(*ast.StarExpr); ok {
+					embedded = star.X
+				}
+				if sel, ok := embedded.(*ast.SelectorExpr); ok {
+					embedded = sel.Sel
+				}
+				names = []*ast.Ident{embedded.(*ast.Ident)}
+			}
+			for _, name := range names {
+				if isExported(name.Name) {
+					fmt.Fprintf(&buf, "%s: %s,\n", name.Name, in.zeroValue(field.Type))
+				}
+			}
+		}
+		buf.WriteString("}\n")
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			fatalf("formatting literal of %s: %v", typ.Name, err)
+		}
+		if i > 0 {
+			pkg.Printf("\n")
+		}
+		pkg.buf.Write(src)
+	}
+	return nil
+}
+
+// zeroValue returns the zero value of the type, as written in the
+// package, as the package's importers would write it. The zero value of
+// a type that cannot be found is written *new(T).
+func (pkg *Package) zeroValue(expr ast.Expr) string {
+	unknown := "*new(" + pkg.qualifiedExpr(expr, nil) + ")"
+	if id, ok := expr.(*ast.Ident); ok && pkg.lookupTypeSpec(id.Name) == nil {
+		unknown = "*new(" + pkg.name + "." + id.Name + ")" // Not predeclared, so the package's.
+	}
+	_, under := pkg.underlying(expr, 10)
+	switch t := under.(type) {
+	case nil:
+		return unknown
+	case *ast.Ident:
+		switch t.Name {
+		case "bool":
+			return "false"
+		case "string":
+			return `""`
+		case "error":
+			return "nil"
+		}
+		if _, ok := basicSizes[t.Name]; ok || t.Name == "int" || t.Name == "uint" || t.Name == "uintptr" {
+			return "0"
+		}
+		return unknown
+	case *ast.StructType:
+		return pkg.qualifiedExpr(expr, nil) + "{}"
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return pkg.qualifiedExpr(expr, nil) + "{}"
+		}
+	}
+	return "nil"
+}
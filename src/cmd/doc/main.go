@@ -41,18 +41,112 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
 
 var (
-	unexported bool // -u flag
-	matchCase  bool // -c flag
-	showCmd    bool // -cmd flag
+	unexported     bool          // -u flag
+	matchCase      bool          // -c flag
+	matchPrefix    bool          // -prefix flag
+	showCmd        bool          // -cmd flag
+	splitDir       string        // -split flag
+	splitFmt       string        // -splitfmt flag
+	position       string        // -pos flag
+	timeout        time.Duration // -timeout flag
+	buildTags      string        // -tags flag
+	symlinks       bool          // -symlinks flag
+	goos           string        // -goos flag
+	goarch         string        // -goarch flag
+	ignore         string        // -ignore flag
+	zipFile        string        // -zip flag
+	platforms      string        // -platforms flag
+	showCgo        bool          // -cgo flag
+	overlay        string        // -overlay flag
+	showDirectives bool          // -directives flag
+	color          string        // -color flag
+	list           bool          // -list flag
+	baseline       string        // -baseline flag
+	record         string        // -record flag
+	showTree       bool          // -tree flag
+	interactive    bool          // -i flag
+	showOutline    bool          // -outline flag
+	repl           bool          // -repl flag
+	satisfies      bool          // -satisfies-constraint flag
+	showLoc        bool          // -loc flag
+	showCalls      bool          // -calls flag
+	showLinks      bool          // -links flag
+	width          int           // -width flag
+	indentFlag     string        // -indent flag
+	checkComments  bool          // -check-comments flag
+	checkImports   bool          // -check-import-comments flag
+	rawComments    bool          // -raw flag
+	escape         string        // -escape flag
+	showIota       bool          // -iota flag
+	output         string        // -o flag
+	srcURL         string        // -srcurl flag
+	sortOrder      string        // -sort flag
+	showImports    bool          // -imports flag
+	inlineTypes    bool          // -inline-types flag
+	showHierarchy  bool          // -hierarchy flag
+	conventions    bool          // -conventions flag
+	notes          string        // -notes flag
+	bundle         bool          // -bundle flag
+	showHooks      bool          // -hooks flag
+	follow         bool          // -follow flag
+	lang           string        // -lang flag
+	showLeaks      bool          // -leaks flag
+	explain        bool          // -annotate flag
+	jsonWarnings   bool          // -json flag
+	showLayout     bool          // -layout flag
+	literal        bool          // -literal flag
+	pkgIndex       int           // -pkg-index flag
+	prefer         string        // -prefer flag
+	batch          bool          // -batch flag
+	showProto      bool          // -proto flag
+	dump           bool          // -dump flag
+	showStats      bool          // -stats flag
+	stub           bool          // -stub flag
+	roots          string        // -roots flag
+	xtest          bool          // -xtest flag
+	showExamples   bool          // -ex flag
+	short          bool          // -short flag
+	sigHelp        bool          // -sighelp flag
+	complete       bool          // -complete-symbols flag
+	trace          bool          // -x flag
+	stdArchive     bool          // -std-archive flag
+	fromBundle     string        // -frombundle flag
+	remote         bool          // -remote flag
+	manAll         string        // -man-all flag
+	diff           bool          // -diff flag
+	returns        string        // -returns flag
+	accepts        string        // -accepts flag
 )
 
+// buildCtx is the context used to locate packages and select their files.
+// It is build.Default adjusted by the flags.
+var buildCtx build.Context
+
+// archiveRoot, if not empty, is where the zip archive named by -zip is
+// mounted. It replaces GOPATH's src directory and the current directory.
+var archiveRoot string
+
+// workDir, if not empty, is the directory in which to run in place of
+// the current directory. It is set by docBuffers.
+var workDir string
+
+// fatalf reports an error in a query, such as a package that cannot be
+// found or parsed, and exits. For -batch, it panics with a PackageError
+// instead, so that the next query can be answered.
+var fatalf = log.Fatalf
+
 // usage is a replacement usage function for the flags package.
+// For -batch, it reports an invalid query with fatalf instead.
 func usage() {
+	if inBatch {
+		fatalf("invalid query; run go help doc for usage")
+	}
 	fmt.Fprintf(os.Stderr, "Usage of [go] doc:\n")
 	fmt.Fprintf(os.Stderr, "\tgo doc\n")
 	fmt.Fprintf(os.Stderr, "\tgo doc <pkg>\n")
@@ -69,9 +163,8 @@ func usage() {
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("doc: ")
-	err := do(os.Stdout, flag.CommandLine, os.Args[1:])
-	if err != nil {
-		log.Fatal(err)
+	if err := do(os.Stdout, flag.CommandLine, os.Args[1:]); err != nil {
+		exit(err)
 	}
 }
 
@@ -80,22 +173,276 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 	flagSet.Usage = usage
 	unexported = false
 	matchCase = false
+	caseDefault, prefixDefault := matchDefaults(os.Getenv("GODOCMATCH"))
 	flagSet.BoolVar(&unexported, "u", false, "show unexported symbols as well as exported")
-	flagSet.BoolVar(&matchCase, "c", false, "symbol matching honors case (paths not affected)")
+	flagSet.BoolVar(&matchCase, "c", caseDefault, "symbol matching honors case (paths not affected)")
+	flagSet.BoolVar(&matchPrefix, "prefix", prefixDefault, "symbols match if they begin with the name given, rather than only if they are the whole name")
+	flagSet.StringVar(&accepts, "accepts", "", "list the exported functions and methods of the package with a parameter of the named `type`, such as io.Reader, or a pointer to it")
+	flagSet.StringVar(&returns, "returns", "", "list the exported functions and methods of the package with a result of the named `type`, such as http.Handler, or a pointer to it")
+	flagSet.BoolVar(&diff, "diff", false, "compare the exported API of the package named by the second argument with that of the first, listing the features added, removed and changed, as -baseline does")
+	flagSet.StringVar(&manAll, "man-all", "", "write a man page for each package the arguments, packages or patterns such as std, match to the directory `dir`, named as net.http.3go, or for a command as gofmt.1")
+	flagSet.BoolVar(&remote, "remote", false, "document the package named by the first argument, as path[@version], from its module, downloaded through $GOPROXY into the module cache, without requiring it in go.mod")
+	flagSet.StringVar(&fromBundle, "frombundle", "", "print the documentation the arguments ask for from the doc bundle `file` that -bundle wrote, rather than from source")
+	flagSet.BoolVar(&stdArchive, "std-archive", false, "write the documentation of the standard library, as printed with the settings in effect, to an archive in $GOCACHE, from which later queries without flags about it are answered without parsing")
+	flagSet.BoolVar(&trace, "x", false, "trace on standard error how the package and symbol are found: the import paths tried, the directories searched, skipped and matched, and the files parsed")
+	flagSet.BoolVar(&complete, "complete-symbols", false, "list the exported symbols whose names begin with the symbol given, or all of them, with their kinds and one-line declarations, tab-separated, for completion")
+	flagSet.BoolVar(&sigHelp, "sighelp", false, "print, for editors' signature help, the signature of each function or method matched as JSON, with the doc sentence naming each parameter")
+	flagSet.BoolVar(&short, "short", false, "print only the one-line summary of each declaration a symbol or method matches, without its doc or the package clause")
+	flagSet.BoolVar(&showExamples, "ex", false, "show the examples of a symbol in the package's tests, with the output they expect, beneath its doc")
+	flagSet.BoolVar(&xtest, "xtest", false, "document the package's external test package, package p_test, with its exported helpers and examples, as a path such as encoding/json_test does")
+	flagSet.StringVar(&roots, "roots", "", "search only the GOROOT and GOPATH entries in the `list`, separated as in $GOPATH, in its order, for the packages partial paths match, ranking those in earlier entries first")
+	flagSet.BoolVar(&stub, "stub", false, "print a Go file declaring the package's exported API, with doc comments, and function bodies stubbed out")
+	flagSet.BoolVar(&showStats, "stats", false, "count the package's exported types, functions, methods, constants and variables, and those deprecated and documented")
+	flagSet.BoolVar(&dump, "dump", false, "print the package's go/doc model, as go doc has adjusted it, as JSON")
+	flagSet.BoolVar(&showProto, "proto", false, "print the package's symbols, with their signatures, docs and positions, as a protocol buffer of the message Package in $GOROOT/src/cmd/doc/doc.proto")
+	flagSet.BoolVar(&batch, "batch", false, "read queries, each the arguments to go doc, from standard input, one to a line, and write their answers, each ended by a line beginning \\x1e, parsing each package once")
+	flagSet.StringVar(&prefer, "prefer", "", "rank the packages a partial path matches whose import paths match the `patterns`, such as github.com/myorg/..., first, in addition to $GODOCPREFER")
+	flagSet.IntVar(&pkgIndex, "pkg-index", 0, "use the `n`th of the packages a partial path matches, as listed when it is ambiguous")
+	flagSet.BoolVar(&literal, "literal", false, "print a composite literal of the struct type, with each exported field set to its zero value, to fill in")
+	flagSet.BoolVar(&showLayout, "layout", false, "show the size and alignment of a type, and the offset of each field of a struct, for the selected GOARCH")
+	flagSet.BoolVar(&jsonWarnings, "json", false, "write warnings to standard error as JSON objects, one to a line, rather than as text")
+	flagSet.BoolVar(&explain, "annotate", false, "explain features of the output, such as \"// Has unexported fields.\", after it, for those learning to read it")
+	flagSet.BoolVar(&showLeaks, "leaks", false, "report the exported symbols of the packages in the trees rooted at the arguments whose declarations use types of internal packages")
+	flagSet.StringVar(&lang, "lang", "", "select files by their build constraints as the language `version`, such as go1.7, would")
+	flagSet.BoolVar(&follow, "follow", false, "show a one-line summary of each symbol a symbol's doc comment links to, as [pkg.Name] does, beneath its doc")
+	flagSet.BoolVar(&showHooks, "hooks", false, "list the exported variables of channel and function types, with their initial values, in the package doc")
+	flagSet.BoolVar(&bundle, "bundle", false, "write the sources of the package named by the first argument and its dependencies to the zip archive named by the second, for -zip, or given a first argument ending in .docz, the documentation of the packages the others match to that doc bundle, for -frombundle")
+	flagSet.StringVar(&notes, "notes", "", "show the notes with the `markers`, such as TODO,NOTE or all, after the BUGs in the package doc")
+	flagSet.BoolVar(&conventions, "conventions", false, "note which functions take a context.Context first and return an error last, with totals for the package")
+	flagSet.BoolVar(&showHierarchy, "hierarchy", false, "show the interfaces the interface named by the first argument embeds and is embedded by, and their implementations in it and the packages named by the others")
+	flagSet.BoolVar(&inlineTypes, "inline-types", false, "show a one-line summary of each named type in a function's signature beneath its doc")
+	flagSet.BoolVar(&showImports, "imports", false, "list the packages the package imports, standard library first, in the package doc")
+	flagSet.BoolVar(&showIota, "iota", false, "annotate the constants of blocks using iota with their values, showing skipped values too")
+	flagSet.BoolVar(&rawComments, "raw", false, "print doc comments verbatim, without reflowing or indenting them")
+	flagSet.BoolVar(&showCalls, "calls", false, "list the exported functions and methods of the same repository that the function or method calls directly")
+	flagSet.BoolVar(&checkComments, "check-comments", false, "report doc comments whose line breaks rendering will not keep, such as accidentally indented lines and lists")
+	flagSet.BoolVar(&checkImports, "check-import-comments", false, "report the packages in the trees rooted at the arguments whose import comments differ from their install paths")
 	flagSet.BoolVar(&showCmd, "cmd", false, "show symbols with package docs even if package is a command")
+	flagSet.BoolVar(&showCgo, "cgo", false, "show functions exported to C and C names used by a cgo package")
+	flagSet.StringVar(&color, "color", "auto", "render doc comments with terminal typography: `when` is auto, always or never")
+	flagSet.BoolVar(&showDirectives, "directives", false, "show compiler and tool directives, such as //go:noinline, with declarations")
+	flagSet.StringVar(&splitDir, "split", "", "write package docs to `dir`, one file per symbol plus an index")
+	flagSet.StringVar(&splitFmt, "splitfmt", "text", "`format` of -split files: text or markdown")
+	flagSet.BoolVar(&showLinks, "links", false, "append a comment giving the web address, in the file's git repository, of each declaration printed")
+	flagSet.BoolVar(&showLoc, "loc", false, "append a comment giving the file and line of each declaration printed")
+	flagSet.StringVar(&position, "pos", "", "show documentation for the identifier at `file:line:column`")
+	flagSet.DurationVar(&timeout, "timeout", 0, "give up searching for a partial package path after `duration` (0 means no limit)")
+	flagSet.StringVar(&buildTags, "tags", "", "consider `tag list` satisfied when selecting files, as in go build")
+	flagSet.BoolVar(&symlinks, "symlinks", true, "follow symbolic links to directories when searching GOROOT and GOPATH")
+	flagSet.StringVar(&goos, "goos", goEnv("GOOS", build.Default.GOOS), "select files for target operating `system`")
+	flagSet.StringVar(&goarch, "goarch", goEnv("GOARCH", build.Default.GOARCH), "select files for target `architecture`")
+	flagSet.StringVar(&platforms, "platforms", "", "merge package docs for the `goos/goarch list`, noting where symbols exist")
+	flagSet.StringVar(&zipFile, "zip", "", "read packages from the zip archive `file` instead of GOPATH")
+	flagSet.StringVar(&ignore, "ignore", "", "skip directories matching `patterns` when searching, in addition to $GODOCIGNORE and the defaults")
+	flagSet.StringVar(&baseline, "baseline", "", "compare the package's exported API with the one recorded in `file`")
+	flagSet.StringVar(&record, "record", "", "write the package's exported API to `file`, as a baseline for -baseline")
+	flagSet.BoolVar(&showTree, "tree", false, "show the package's symbols as a tree, with each type's constants, constructors and methods beneath it")
+	flagSet.BoolVar(&interactive, "i", false, "browse the package's symbols and imports interactively in the terminal")
+	flagSet.BoolVar(&list, "list", false, "list the packages in the trees rooted at the arguments, with their synopses")
+	flagSet.BoolVar(&repl, "repl", false, "read queries such as json, .Decoder and .. from standard input, one to a line, keeping loaded packages between them")
+	flagSet.BoolVar(&satisfies, "satisfies-constraint", false, "report whether the type named by the first argument satisfies the interface named by the second, and if not, why")
+	flagSet.BoolVar(&showOutline, "outline", false, "print the package's symbols, nested by type, with their positions as JSON")
+	flagSet.StringVar(&overlay, "overlay", "", "read a JSON object mapping file names to contents that replace or add to the files on disk from `file` (- for standard input)")
+	flagSet.IntVar(&width, "width", 0, "wrap doc comments to lines of `n` columns (0 means the terminal's width, or 80)")
+	flagSet.StringVar(&indentFlag, "indent", "4", "indent doc comments and nested lines by `n` spaces, or by a string of spaces and tabs (\\t)")
+	flagSet.StringVar(&escape, "escape", "", "escape the output as a whole for embedding, in `mode` html, json or shell")
+	flagSet.StringVar(&output, "o", "", "write the output to `file`, or for a pattern, each package's to a file beneath the directory")
+	flagSet.StringVar(&srcURL, "srcurl", "", "link each declaration to the address given by the `template`, with fields {repo}, {rev}, {path} and {line}")
+	flagSet.StringVar(&sortOrder, "sort", "name", "list symbols in `order` name, alphabetically, or source, as declared")
 	flagSet.Parse(args)
-	var paths []string
+	applyGoFlags(flagSet)
+	checkTemplate(srcURL)
+	if sortOrder != "name" && sortOrder != "source" {
+		fatalf("invalid -sort %q; want name or source", sortOrder)
+	}
+	// Patterns are written to a directory by patternDoc.
+	toDir := output != "" && !list && !checkImports && flagSet.NArg() > 0 && isPattern(flagSet.Arg(0))
+	if output != "" && !toDir {
+		if interactive || repl {
+			fatalf("-o cannot be used with -i or -repl")
+		}
+		if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+			fatalf("-o %s is a directory; only the output for a pattern is written to a directory", output)
+		}
+		// Write the file once the output is complete, and only if
+		// there is no error. The PackageError that fatalf and failf
+		// panic with in -batch is returned as the error, so that
+		// nothing is written for it.
+		buf := new(bytes.Buffer)
+		writer = buf
+		defer func() {
+			if err == nil {
+				writeOutput(output, buf.Bytes())
+			}
+		}()
+		defer func() {
+			if e := recover(); e != nil {
+				pkgError, ok := e.(PackageError)
+				if !ok {
+					panic(e)
+				}
+				err = pkgError
+			}
+		}()
+	}
+	if escape != "" && !toDir {
+		if interactive || repl {
+			fatalf("-escape cannot be used with -i or -repl")
+		}
+		// Escape the output once it is all written, after the
+		// packages are flushed, even if there is an error.
+		esc, out, buf := escaper(escape), writer, new(bytes.Buffer)
+		writer = buf
+		defer func() {
+			io.WriteString(out, esc(buf.String()))
+		}()
+	}
+	styled = colorEnabled(color, writer)
+	indent = indentation(indentFlag)
+	lineWidth = outputWidth(width, writer)
+	buildCtx = build.Default
+	buildCtx.GOPATH = goEnv("GOPATH", build.Default.GOPATH)
+	buildCtx.BuildTags = strings.Fields(buildTags)
+	buildCtx.BuildTags = append(buildCtx.BuildTags, experimentTags(goEnv("GOEXPERIMENT", ""))...)
+	if lang != "" {
+		buildCtx.ReleaseTags = releaseTags(lang)
+	}
+	buildCtx = platformContext(goos, goarch)
+	fileSystem, archiveRoot = baseFS, ""
+	if remote {
+		if zipFile != "" {
+			fatalf("-remote cannot be used with -zip")
+		}
+		// Read the module's zip file as -zip does, with the arguments
+		// parsed again without the @version.
+		var args []string
+		zipFile, args = remoteArgs(flagSet.Args())
+		flagSet.Parse(append([]string{"--"}, args...))
+	}
+	if zipFile != "" {
+		// Mount the archive beneath its own name, which cannot also be
+		// a directory, so it serves as a GOPATH entry that hides nothing.
+		file, err := filepath.Abs(zipFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		t, err := openZip(file)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		archiveRoot = filepath.Join(file, "src")
+		fileSystem = &mountFS{root: archiveRoot, tree: t, base: baseFS}
+		buildCtx.GOPATH = file
+	}
+	if overlay != "" {
+		fileSystem = newOverlayFS(fileSystem, pwd(), readOverlay(overlay))
+	}
+	useFileSystem(&buildCtx, fileSystem)
+	if list {
+		return listPackages(writer, flagSet.Args())
+	}
+	if checkImports {
+		return checkImportComments(writer, flagSet.Args())
+	}
+	if bundle {
+		return writeBundle(flagSet.Args())
+	}
+	if stdArchive {
+		return writeStdArchive(writer)
+	}
+	if manAll != "" {
+		return writeManPages(writer, manAll, flagSet.Args())
+	}
+	if fromBundle != "" {
+		return bundleDoc(writer, fromBundle, flagSet.Args())
+	}
+	if showLeaks {
+		return reportLeaks(writer, flagSet.Args())
+	}
+	if flagSet.NArg() == 1 {
+		switch classifyArg(flagSet.Arg(0)) {
+		case argTopic:
+			return topicDoc(writer, flagSet.Arg(0))
+		case argOperator:
+			return fmt.Errorf("no documentation for operator %s", flagSet.Arg(0))
+		}
+	}
+	if flagSet.NArg() > 0 && isPattern(flagSet.Arg(0)) {
+		return patternDoc(writer, flagSet.Args())
+	}
+	var pkgs []*Package
 	var symbol, method string
 	// Loop until something is printed.
+	dirs.Start(symlinks, ignorePatterns(), searchRoots())
 	dirs.Reset()
+	dirs.SetTimeout(timeout)
+	if batch {
+		return runBatch(writer, os.Stdin)
+	}
+	if repl {
+		return runREPL(writer, os.Stdin, flagSet.Args())
+	}
+	if satisfies {
+		return satisfiesConstraint(writer, flagSet.Args())
+	}
+	if showHierarchy {
+		return hierarchyDoc(writer, flagSet.Args())
+	}
+	if diff {
+		return diffPackages(writer, flagSet.Args())
+	}
+	// Store the output, with its warnings, in the cache once it is all
+	// written, if the first package answered the query.
+	var cache *cacheEntry
+	defer func() {
+		if cache != nil {
+			cache.end(err == nil && len(pkgs) == 1)
+		}
+	}()
+	// Warn of an internal package once its documentation is shown.
+	defer func() {
+		if err == nil && len(pkgs) > 0 {
+			pkgs[len(pkgs)-1].checkInternal()
+		}
+	}()
 	for i := 0; ; i++ {
-		buildPackage, userPath, sym, more := parseArgs(flagSet.Args())
+		var buildPackage *build.Package
+		var userPath, sym string
+		var more bool
+		if position != "" {
+			buildPackage, userPath, sym = parsePos(position)
+			// Unexported identifiers are visible within their own package.
+			if userPath == "" {
+				unexported = true
+			}
+		} else {
+			buildPackage, userPath, sym, more = parseArgs(flagSet.Args())
+		}
+		if xtest {
+			buildPackage = xtestPackage(buildPackage)
+		}
 		if i > 0 && !more { // Ignore the "more" bit on the first iteration.
-			return failMessage(paths, symbol, method)
+			return failMessage(pkgs, symbol, method)
+		}
+		if complete {
+			// A prefix such as Header. is incomplete, not invalid.
+			symbol, method = sym, ""
+		} else {
+			symbol, method = parseSymbol(sym)
+		}
+		if i == 0 && flagSet.NFlag() == 0 && archivedDoc(writer, buildPackage, userPath, symbol, method) {
+			return nil
+		}
+		if i == 0 && cacheable() {
+			if file := cacheFile(buildPackage, args); file != "" {
+				if readCache(writer, file) {
+					return nil
+				}
+				cache, writer = beginCache(writer, file, buildPackage)
+			}
 		}
-		symbol, method = parseSymbol(sym)
 		pkg := parsePackage(writer, buildPackage, userPath)
-		paths = append(paths, pkg.prettyPath())
+		pkgs = append(pkgs, pkg)
 
 		defer func() {
 			pkg.flush()
@@ -118,6 +465,63 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 		}
 
 		switch {
+		case interactive:
+			if method != "" {
+				symbol += "." + method
+			}
+			return pkg.browse(symbol)
+		case complete:
+			pkg.completeDoc(symbol)
+			return
+		case symbol != "" && sigHelp:
+			if pkg.signatureDoc(symbol, method) {
+				return
+			}
+		case symbol != "" && short:
+			if pkg.shortDoc(symbol, method) {
+				return
+			}
+		case symbol != "" && showCalls:
+			return pkg.callsDoc(symbol, method)
+		case symbol != "" && literal:
+			return pkg.literalDoc(symbol, method)
+		case symbol == "" && returns != "":
+			return pkg.returnsDoc(returns)
+		case symbol == "" && accepts != "":
+			return pkg.acceptsDoc(accepts)
+		case symbol == "" && conventions:
+			pkg.conventionsDoc()
+			return
+		case symbol == "" && checkComments:
+			return pkg.commentReport()
+		case symbol == "" && record != "":
+			return pkg.recordBaseline(record)
+		case symbol == "" && baseline != "":
+			return pkg.checkBaseline(baseline)
+		case symbol == "" && stub:
+			pkg.stubDoc()
+			return
+		case symbol == "" && showStats:
+			pkg.statsDoc()
+			return
+		case symbol == "" && dump:
+			pkg.dumpDoc()
+			return
+		case symbol == "" && showProto:
+			pkg.protoDoc()
+			return
+		case symbol == "" && showOutline:
+			pkg.outlineDoc()
+			return
+		case symbol == "" && showTree:
+			pkg.treeDoc()
+			return
+		case symbol == "" && platforms != "":
+			pkg.platformDoc(strings.Fields(platforms))
+			return
+		case symbol == "" && splitDir != "":
+			pkg.splitDoc(splitDir, splitFmt)
+			return
 		case symbol == "":
 			pkg.packageDoc() // The package exists, so we got some output.
 			return
@@ -130,26 +534,67 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 				return
 			}
 		}
+		tracef("rejecting %s: it has no %s", pkg.build.Dir, sym)
 	}
 }
 
+// ignorePatterns returns the patterns for directories to skip when
+// searching: the defaults, those in $GODOCIGNORE, and those given by
+// -ignore. The lists are space-separated.
+func ignorePatterns() []string {
+	patterns := append([]string(nil), defaultIgnore...)
+	patterns = append(patterns, strings.Fields(os.Getenv("GODOCIGNORE"))...)
+	patterns = append(patterns, strings.Fields(ignore)...)
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fatalf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	return patterns
+}
+
+// matchDefaults returns the defaults for the -c and -prefix flags set by
+// the space-separated words of $GODOCMATCH: case or fold, to honor case
+// in matching symbols or not, and prefix or whole, to match symbols by
+// a prefix or only whole. Later words override earlier ones.
+func matchDefaults(env string) (matchCase, matchPrefix bool) {
+	for _, word := range strings.Fields(env) {
+		switch word {
+		case "case", "fold":
+			matchCase = word == "case"
+		case "prefix", "whole":
+			matchPrefix = word == "prefix"
+		default:
+			fatalf("invalid word %q in $GODOCMATCH; want case, fold, prefix or whole", word)
+		}
+	}
+	return
+}
+
 // failMessage creates a nicely formatted error message when there is no result to show.
-func failMessage(paths []string, symbol, method string) error {
+// It lists the closest symbols of the packages, for the user to try instead.
+func failMessage(pkgs []*Package, symbol, method string) error {
 	var b bytes.Buffer
-	if len(paths) > 1 {
+	if len(pkgs) > 1 {
 		b.WriteString("s")
 	}
 	b.WriteString(" ")
-	for i, path := range paths {
+	var candidates []string
+	for i, pkg := range pkgs {
 		if i > 0 {
 			b.WriteString(", ")
 		}
-		b.WriteString(path)
+		b.WriteString(pkg.prettyPath())
+		candidates = append(candidates, pkg.candidates(symbol, method, false)...)
+	}
+	b.WriteString(dirs.status())
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
 	}
 	if method == "" {
-		return fmt.Errorf("no symbol %s in package%s", symbol, &b)
+		return PackageError{errSymbol, fmt.Sprintf("no symbol %s in package%s%s", symbol, &b, suggestion(candidates))}
 	}
-	return fmt.Errorf("no method %s.%s in package%s", symbol, method, &b)
+	return PackageError{errSymbol, fmt.Sprintf("no method %s.%s in package%s%s", symbol, method, &b, suggestion(candidates))}
 }
 
 // parseArgs analyzes the arguments (if any) and returns the package
@@ -174,9 +619,12 @@ func parseArgs(args []string) (pkg *build.Package, path, symbol string, more boo
 		// Done below.
 	case 2:
 		// Package must be importable.
-		pkg, err := build.Import(args[0], "", build.ImportComment)
+		if pkg, ok := importXTest(args[0]); ok {
+			return pkg, args[0], args[1], false
+		}
+		pkg, err := buildCtx.Import(args[0], "", build.ImportComment)
 		if err != nil {
-			log.Fatalf("%s", err)
+			failf(errPackage, "%s", err)
 		}
 		return pkg, args[0], args[1], false
 	}
@@ -186,16 +634,21 @@ func parseArgs(args []string) (pkg *build.Package, path, symbol string, more boo
 	// First, is it a complete package path as it is? If so, we are done.
 	// This avoids confusion over package paths that have other
 	// package paths as their prefix.
-	pkg, err := build.Import(arg, "", build.ImportComment)
+	pkg, err := buildCtx.Import(arg, "", build.ImportComment)
 	if err == nil {
 		return pkg, arg, "", false
 	}
+	tracef("%s is not an import path: %v", arg, err)
+	// A path ending in _test may name an external test package.
+	if pkg, ok := importXTest(arg); ok {
+		return pkg, arg, "", false
+	}
 	// Another disambiguator: If the symbol starts with an upper
 	// case letter, it can only be a symbol in the current directory.
 	// Kills the problem caused by case-insensitive file systems
 	// matching an upper case name as a package name.
 	if isUpper(arg) {
-		pkg, err := build.ImportDir(".", build.ImportComment)
+		pkg, err := buildCtx.ImportDir(pwd(), build.ImportComment)
 		if err == nil {
 			return pkg, "", arg, false
 		}
@@ -221,32 +674,63 @@ func parseArgs(args []string) (pkg *build.Package, path, symbol string, more boo
 			symbol = arg[period+1:]
 		}
 		// Have we identified a package already?
-		pkg, err := build.Import(arg[0:period], "", build.ImportComment)
+		pkg, err := buildCtx.Import(arg[0:period], "", build.ImportComment)
 		if err == nil {
 			return pkg, arg[0:period], symbol, false
 		}
+		if period < len(arg) {
+			tracef("%s is not an import path: %v", arg[0:period], err)
+		}
+		if pkg, ok := importXTest(arg[0:period]); ok {
+			return pkg, arg[0:period], symbol, false
+		}
 		// See if we have the basename or tail of a package, as in json for encoding/json
 		// or ivy/value for robpike.io/ivy/value.
 		// Launch findPackage as a goroutine so it can return multiple paths if required.
 		path, ok := findPackage(arg[0:period])
 		if ok {
+			// Without a symbol to look for in each, an ambiguous
+			// path must be settled by the user.
+			if symbol == "" || pkgIndex > 0 {
+				return importDir(choosePackage(arg[0:period], path)), arg[0:period], symbol, false
+			}
 			return importDir(path), arg[0:period], symbol, true
 		}
 		dirs.Reset() // Next iteration of for loop must scan all the directories again.
 	}
 	// If it has a slash, we've failed.
 	if slash >= 0 {
-		log.Fatalf("no such package %s", arg[0:period])
+		failf(errPackage, "no such package %s%s", arg[0:period], dirs.status())
+	}
+	// The functions of package builtin, such as make, are documented there.
+	if _, ok := builtinTopics[arg]; ok {
+		pkg, err := buildCtx.Import("builtin", "", build.ImportComment)
+		if err == nil {
+			return pkg, "builtin", arg, false
+		}
 	}
 	// Guess it's a symbol in the current directory.
 	return importDir(pwd()), "", arg, false
 }
 
-// importDir is just an error-catching wrapper for build.ImportDir.
+// platformContext returns buildCtx adjusted to select files for the
+// platform. As when cross-compiling, cgo is disabled for platforms other
+// than the current one unless CGO_ENABLED says otherwise.
+func platformContext(goos, goarch string) build.Context {
+	ctxt := buildCtx
+	ctxt.GOOS, ctxt.GOARCH = goos, goarch
+	ctxt.CgoEnabled = build.Default.CgoEnabled
+	if goos != build.Default.GOOS || goarch != build.Default.GOARCH {
+		ctxt.CgoEnabled = goEnv("CGO_ENABLED", "") == "1"
+	}
+	return ctxt
+}
+
+// importDir is just an error-catching wrapper for buildCtx.ImportDir.
 func importDir(dir string) *build.Package {
-	pkg, err := build.ImportDir(dir, build.ImportComment)
+	pkg, err := buildCtx.ImportDir(dir, build.ImportComment)
 	if err != nil {
-		log.Fatal(err)
+		failf(errPackage, "%s", err)
 	}
 	return pkg
 }
@@ -277,13 +761,13 @@ func parseSymbol(str string) (symbol, method string) {
 // logs and exits if it is not.
 func isIdentifier(name string) {
 	if len(name) == 0 {
-		log.Fatal("empty symbol")
+		fatalf("empty symbol")
 	}
 	for i, ch := range name {
 		if unicode.IsLetter(ch) || ch == '_' || i > 0 && unicode.IsDigit(ch) {
 			continue
 		}
-		log.Fatalf("invalid identifier %q", name)
+		fatalf("invalid identifier %q", name)
 	}
 }
 
@@ -300,34 +784,112 @@ func isUpper(name string) bool {
 	return unicode.IsUpper(ch)
 }
 
-// findPackage returns the full file name path that first matches the
-// (perhaps partial) package path pkg. The boolean reports if any match was found.
+// findPackage returns the full file name path that best matches the
+// (perhaps partial) package path pkg, as ranked by rankPackages, and on
+// later calls the next best, until dirs is reset. The boolean reports if
+// any match was found.
 func findPackage(pkg string) (string, bool) {
 	if pkg == "" || isUpper(pkg) { // Upper case symbol cannot be a package name.
 		return "", false
 	}
-	pkgString := filepath.Clean(string(filepath.Separator) + pkg)
+	if dirs.matching != pkg {
+		pkgString := filepath.Clean(string(filepath.Separator) + pkg)
+		var matches []string
+		n := 0
+		for {
+			path, ok := dirs.Next()
+			if !ok {
+				break
+			}
+			n++
+			if strings.HasSuffix(path, pkgString) {
+				matches = append(matches, path)
+			}
+		}
+		rankPackages(matches)
+		tracef("%s matches %d of %d directories%s", pkg, len(matches), n, dirs.status())
+		for i, path := range matches {
+			tracef("%s: candidate %d: %s", pkg, i+1, path)
+		}
+		dirs.matches, dirs.matching = matches, pkg
+	}
+	if len(dirs.matches) == 0 {
+		return "", false
+	}
+	path := dirs.matches[0]
+	dirs.matches = dirs.matches[1:]
+	return path, true
+}
+
+// choosePackage returns the directory of the package the partial package
+// path matches, given the best match found. If it matches more than one,
+// the one chosen by -pkg-index is returned; without it, the best is
+// returned if it outranks the rest, as the standard library's does, and
+// otherwise the import paths of the matches are listed, numbered for
+// -pkg-index, with their roots, and doc exits. With -roots, the package
+// chosen and its root are reported on standard error.
+func choosePackage(pkg, first string) string {
+	matches := []string{first}
 	for {
-		path, ok := dirs.Next()
+		path, ok := findPackage(pkg)
 		if !ok {
-			return "", false
+			break
 		}
-		if strings.HasSuffix(path, pkgString) {
-			return path, true
+		matches = append(matches, path)
+	}
+	if pkgIndex > len(matches) {
+		fatalf("-pkg-index %d: %s matches only %d package(s)", pkgIndex, pkg, len(matches))
+	}
+	chosen := ""
+	switch {
+	case pkgIndex > 0:
+		chosen = matches[pkgIndex-1]
+	case len(matches) == 1:
+		chosen = first
+	case outranks(matches[0], matches[1]):
+		tracef("%s: using %s, which outranks the other candidates", pkg, first)
+		chosen = first
+	}
+	if chosen != "" {
+		if roots != "" {
+			log.Printf("%s: using %s (in %s)", pkg, dirImportPath(chosen), rootOf(chosen))
 		}
+		return chosen
 	}
+	var b bytes.Buffer
+	for i, dir := range matches {
+		fmt.Fprintf(&b, "\n\t%d. %s (in %s)", i+1, dirImportPath(dir), rootOf(dir))
+	}
+	failf(errAmbiguous, "%s matches %d packages; give more of its path, or -pkg-index to choose one:%s", pkg, len(matches), &b)
+	return ""
+}
+
+// dirImportPath returns the import path of the package in the directory,
+// or the directory if it has none.
+func dirImportPath(dir string) string {
+	if p, err := buildCtx.ImportDir(dir, build.FindOnly); err == nil && p.ImportPath != "." {
+		return p.ImportPath
+	}
+	return dir
 }
 
 // splitGopath splits $GOPATH into a list of roots.
 func splitGopath() []string {
-	return filepath.SplitList(build.Default.GOPATH)
+	return filepath.SplitList(buildCtx.GOPATH)
 }
 
-// pwd returns the current directory.
+// pwd returns the current directory, which is the root of the archive
+// if there is one.
 func pwd() string {
+	if archiveRoot != "" {
+		return archiveRoot
+	}
+	if workDir != "" {
+		return workDir
+	}
 	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 	return wd
 }
@@ -0,0 +1,180 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/doc"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// manWidth is the width to which man pages are printed unless -width is
+// given, so that a page fits a terminal of punchedCardWidth columns
+// beneath the indentation man gives its sections.
+const manWidth = punchedCardWidth - 8
+
+// manSections are the sections of a man page that hold the symbols of
+// each kind, as pages reports it, in the order they come in.
+var manSections = []struct {
+	kind, title string
+}{
+	{"const", "CONSTANTS"},
+	{"var", "VARIABLES"},
+	{"func", "FUNCTIONS"},
+	{"type", "TYPES"},
+}
+
+// writeManPages writes, for the -man-all flag, a man page for each package
+// the patterns match to dir, as manPage prints it, in a file named as
+// manName gives, for packagers to install. It is printed without terminal
+// typography, at the width given by -width or manWidth columns.
+func writeManPages(writer io.Writer, dir string, patterns []string) error {
+	if len(patterns) == 0 {
+		usage()
+	}
+	pkgs := matchPackages(patterns)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+	styled, lineWidth = false, manWidth
+	if width != 0 {
+		lineWidth = outputWidth(width, ioutil.Discard)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	saveUnexported := unexported
+	defer func() { unexported = saveUnexported }()
+	for _, p := range pkgs {
+		// As in do, the lower case symbols of builtin are shown.
+		unexported = saveUnexported || p.pkg.ImportPath == "builtin"
+		var buf bytes.Buffer
+		pkg := parsePackage(&buf, p.pkg, p.path)
+		name, section := manName(p)
+		pkg.manPage(name, section)
+		writeOutput(filepath.Join(dir, name+"."+section), buf.Bytes())
+	}
+	fmt.Fprintf(writer, "wrote %d man pages to %s\n", len(pkgs), dir)
+	return nil
+}
+
+// manName returns the name and section of the man page of the package.
+// A command's page, in section 1, is named by its last element, as the
+// command is. Any other package's, in section 3go, beside the C library's
+// and others' in section 3, is named by the path by which it is known,
+// with each slash replaced by a period, such as net.http.
+func manName(p pkgDir) (name, section string) {
+	file := bundlePath(p)
+	if p.pkg.Name == "main" {
+		return path.Base(file), "1"
+	}
+	return strings.Replace(file, "/", ".", -1), "3go"
+}
+
+// manPage prints the package's documentation as a man page, in the man
+// macros of troff. The NAME section gives its path and the first sentence
+// of its doc comment, and SYNOPSIS its import declaration or, for a
+// command, its usage. The doc comment follows in DESCRIPTION, with the
+// flags of a command, and then the docs of the exported symbols, as
+// -split pages print them, each in its own subsection of CONSTANTS,
+// VARIABLES, FUNCTIONS or TYPES. BUGS holds the package's bugs. The text
+// is printed as go doc prints it, so it is kept as it is, not filled.
+func (pkg *Package) manPage(name, section string) {
+	// Symbols are named exactly, and the package clause is not printed.
+	saveMatchCase, saveMatchPrefix, saveUserPath, saveWriter := matchCase, matchPrefix, pkg.userPath, pkg.writer
+	matchCase, matchPrefix, pkg.userPath = true, false, ""
+	defer func() {
+		matchCase, matchPrefix, pkg.userPath, pkg.writer = saveMatchCase, saveMatchPrefix, saveUserPath, saveWriter
+	}()
+	render := func(print func()) string {
+		var buf bytes.Buffer
+		pkg.writer = &buf
+		print()
+		pkg.flush()
+		return strings.Trim(buf.String(), "\n")
+	}
+	w := saveWriter
+	fmt.Fprintf(w, ".TH %s %s \"\" \"\" \"Go Packages\"\n", manQuote(name), section)
+	title := manEscape(pkg.prettyPath())
+	if section == "1" {
+		title = manEscape(name)
+	}
+	if synopsis := doc.Synopsis(pkg.doc.Doc); synopsis != "" {
+		title += ` \- ` + manEscape(synopsis)
+	}
+	fmt.Fprintf(w, ".SH NAME\n%s\n", title)
+	if pkg.pkg.Name == "main" {
+		if lines := pkg.usageLines(); len(lines) > 0 {
+			manBlock(w, "SYNOPSIS", strings.Join(lines, "\n"))
+		}
+	} else {
+		manBlock(w, "SYNOPSIS", fmt.Sprintf("import %q", pkg.prettyPath()))
+	}
+	manBlock(w, "DESCRIPTION", render(func() {
+		comment, _ := splitDirectives(pkg.doc.Doc)
+		pkg.toText(comment, "")
+		if !pkg.showInternals() {
+			pkg.flagsSummary()
+		}
+	}))
+	if pkg.showInternals() {
+		pages := pkg.pages()
+		for _, s := range manSections {
+			wrote := false
+			for _, p := range pages {
+				if p.kind != s.kind {
+					continue
+				}
+				if !wrote {
+					fmt.Fprintf(w, ".SH %s\n", s.title)
+					wrote = true
+				}
+				fmt.Fprintf(w, ".SS %s\n", manEscape(p.name))
+				manBlock(w, "", render(func() {
+					pkg.symbolDoc(p.name)
+					pkg.typeMembersDoc(p.name)
+				}))
+			}
+		}
+	}
+	manBlock(w, "BUGS", render(pkg.bugs))
+}
+
+// manBlock prints the text, if there is any, in a section with the title,
+// unless it is "", as lines kept as they are.
+func manBlock(w io.Writer, title, text string) {
+	if text == "" {
+		return
+	}
+	if title != "" {
+		fmt.Fprintf(w, ".SH %s\n", title)
+	}
+	fmt.Fprintf(w, ".nf\n%s\n.fi\n", manEscape(text))
+}
+
+// manEscape escapes the text for troff: its backslashes, and the periods
+// and apostrophes that would begin requests at the start of its lines.
+func manEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manQuote returns the argument of a request quoted for troff.
+func manQuote(arg string) string {
+	arg = strings.Replace(arg, `\`, `\e`, -1)
+	return `"` + strings.Replace(arg, `"`, `\(dq`, -1) + `"`
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/doc"
+)
+
+// An outline is the output of -outline: the package's symbols with their
+// positions, nested as in the documentation, for an editor's symbol list.
+type outline struct {
+	Name       string
+	ImportPath string
+	Items      []*outlineItem
+}
+
+// An outlineItem is a symbol in an outline.
+type outlineItem struct {
+	Name     string
+	Kind     string // const, var, func, type, method, field or embedded.
+	File     string
+	Line     int
+	Column   int
+	Children []*outlineItem `json:",omitempty"`
+
+	// For -proto only.
+	signature string // The declaration on one line.
+	doc       string
+}
+
+// outlineDoc prints the package's outline as JSON: its constants,
+// variables, functions and types, and beneath each type its fields or
+// interface methods, constants, variables, constructors and methods.
+func (pkg *Package) outlineDoc() {
+	defer pkg.flush()
+	data, err := json.MarshalIndent(pkg.outline(), "", "\t")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	pkg.buf.Write(data)
+	pkg.buf.WriteString("\n")
+}
+
+// outline returns the package's outline.
+func (pkg *Package) outline() *outline {
+	o := &outline{Name: pkg.name, ImportPath: pkg.prettyPath()}
+
+	// Constructors and typed values are shown with their types.
+	grouped := make(map[interface{}]bool)
+	var types []*outlineItem
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		for _, v := range typ.Consts {
+			grouped[v] = true
+		}
+		for _, v := range typ.Vars {
+			grouped[v] = true
+		}
+		for _, f := range typ.Funcs {
+			grouped[f] = true
+		}
+		spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+		item := pkg.outlineItem(spec.Name, "type")
+		item.signature, item.doc = pkg.oneLineNode(spec), typ.Doc
+		item.Children = append(item.Children, pkg.memberItems(spec)...)
+		item.Children = append(item.Children, pkg.valueItems(typ.Consts, nil)...)
+		item.Children = append(item.Children, pkg.valueItems(typ.Vars, nil)...)
+		item.Children = append(item.Children, pkg.funcItems(typ.Funcs, "func", nil)...)
+		item.Children = append(item.Children, pkg.funcItems(typ.Methods, "method", nil)...)
+		types = append(types, item)
+	}
+	o.Items = append(o.Items, pkg.valueItems(pkg.doc.Consts, grouped)...)
+	o.Items = append(o.Items, pkg.valueItems(pkg.doc.Vars, grouped)...)
+	o.Items = append(o.Items, pkg.funcItems(pkg.doc.Funcs, "func", grouped)...)
+	o.Items = append(o.Items, types...)
+	return o
+}
+
+// outlineItem returns the item for the symbol declared by id.
+func (pkg *Package) outlineItem(id *ast.Ident, kind string) *outlineItem {
+	pos := pkg.fs.Position(id.Pos())
+	return &outlineItem{
+		Name:   id.Name,
+		Kind:   kind,
+		File:   pos.Filename,
+		Line:   pos.Line,
+		Column: pos.Column,
+	}
+}
+
+// valueItems returns the items for the exported constants or variables
+// not in skip.
+func (pkg *Package) valueItems(values []*doc.Value, skip map[interface{}]bool) []*outlineItem {
+	var items []*outlineItem
+	for _, value := range values {
+		if skip[value] {
+			continue
+		}
+		for _, spec := range value.Decl.Specs {
+			for _, id := range spec.(*ast.ValueSpec).Names {
+				if isExported(id.Name) {
+					item := pkg.outlineItem(id, value.Decl.Tok.String())
+					decl, _ := valueDecl([]*doc.Value{value}, id.Name)
+					item.signature, item.doc = pkg.oneLineNode(decl), value.Doc
+					items = append(items, item)
+				}
+			}
+		}
+	}
+	return items
+}
+
+// funcItems returns the items, of the given kind, for the exported
+// functions not in skip.
+func (pkg *Package) funcItems(funcs []*doc.Func, kind string, skip map[interface{}]bool) []*outlineItem {
+	var items []*outlineItem
+	for _, fun := range funcs {
+		if isExported(fun.Name) && !skip[fun] {
+			item := pkg.outlineItem(fun.Decl.Name, kind)
+			item.signature, item.doc = pkg.oneLineNode(fun.Decl), fun.Doc
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// memberItems returns the items for the exported fields of a struct
+// type or the methods of an interface type.
+func (pkg *Package) memberItems(spec *ast.TypeSpec) []*outlineItem {
+	var list *ast.FieldList
+	kind := "field"
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		list = t.Fields
+	case *ast.InterfaceType:
+		list, kind = t.Methods, "method"
+	default:
+		return nil
+	}
+	var items []*outlineItem
+	for _, field := range list.List {
+		names, kind := field.Names, kind
+		if len(names) == 0 {
+			kind = "embedded"
+			// Embedded; named by its type.
+			typ := field.Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			if sel, ok := typ.(*ast.SelectorExpr); ok {
+				typ = sel.Sel
+			}
+			if id, ok := typ.(*ast.Ident); ok {
+				names = []*ast.Ident{id}
+			}
+		}
+		for _, id := range names {
+			if isExported(id.Name) {
+				item := pkg.outlineItem(id, kind)
+				item.signature, item.doc = pkg.oneLineField(field, 10), field.Doc.Text()
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
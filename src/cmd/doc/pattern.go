@@ -0,0 +1,210 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A pkgDir is a package matched by a pattern, with the path by which it
+// is known: its import path, or its directory if the pattern was relative.
+type pkgDir struct {
+	path string
+	pkg  *build.Package
+}
+
+// isPattern reports whether the argument is a package pattern rather
+// than a single package: std, or a path containing "...".
+func isPattern(arg string) bool {
+	return arg == "std" || strings.Contains(arg, "...")
+}
+
+// matchPattern returns a function reporting whether a package path
+// matches the pattern, in which ... matches any string, as in the go
+// command. A pattern ending in /... also matches the path without it.
+func matchPattern(pattern string) func(path string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	if strings.HasSuffix(re, `/.*`) {
+		re = re[:len(re)-len(`/.*`)] + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + re + `$`).MatchString
+}
+
+// matchPackages returns the packages matched by the patterns, in
+// lexical order within each pattern and each only once. The pattern std
+// matches the packages of the standard library, and a path without
+// wildcards matches just that package. Directories are skipped as when
+// searching for a package: see Dirs.
+func matchPackages(patterns []string) []pkgDir {
+	var pkgs []pkgDir
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		d := &Dirs{follow: symlinks, ignore: ignorePatterns(), visited: make(map[string]bool)}
+		add := func(p pkgDir) {
+			if !seen[p.pkg.Dir] {
+				seen[p.pkg.Dir] = true
+				pkgs = append(pkgs, p)
+			}
+		}
+		if !isPattern(pattern) {
+			pkg, err := buildCtx.Import(pattern, pwd(), build.ImportComment)
+			if err != nil {
+				warnf(warnSkip, "%v", err)
+				continue
+			}
+			add(pkgDir{pattern, pkg})
+			continue
+		}
+		if pattern == "std" {
+			root := filepath.Join(buildCtx.GOROOT, "src")
+			d.walkPackages(root, root, "", func(path string) bool {
+				return path != "cmd" && !strings.HasPrefix(path, "cmd/") &&
+					!strings.Contains("/"+path+"/", "/vendor/")
+			}, add)
+			continue
+		}
+		match := matchPattern(pattern)
+		// Walk from the directory named by the pattern up to its first
+		// wildcard, or from every root if there is none.
+		base := pattern
+		if i := strings.Index(pattern, "..."); i >= 0 {
+			base = pattern[:i]
+		}
+		if i := strings.LastIndex(base, "/"); i >= 0 {
+			base = base[:i]
+		} else {
+			base = ""
+		}
+		switch {
+		case base == "":
+			for _, root := range append([]string{buildCtx.GOROOT}, filepath.SplitList(buildCtx.GOPATH)...) {
+				root = filepath.Join(root, "src")
+				d.walkPackages(root, root, "", match, add)
+			}
+		case build.IsLocalImport(base):
+			dir := filepath.Join(pwd(), base)
+			d.walkPackages(dir, dir, base, match, add)
+		default:
+			pkg, err := buildCtx.Import(base, pwd(), build.FindOnly)
+			if err != nil {
+				warnf(warnSkip, "%v", err)
+				continue
+			}
+			d.walkPackages(pkg.Dir, pkg.Dir, base, match, add)
+		}
+	}
+	return pkgs
+}
+
+// walkPackages calls add for the package in dir, which is known as path,
+// if path matches, and then walks the subdirectories of dir in lexical
+// order. Root is the directory at which the walk began; the empty path
+// is that of a root of GOROOT or GOPATH, which holds no package.
+func (d *Dirs) walkPackages(root, dir, path string, match func(string) bool, add func(pkgDir)) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		real = dir // Not in the file system; see fs.go.
+	}
+	if d.visited[real] {
+		return
+	}
+	d.visited[real] = true
+	if path != "" && match(path) {
+		pkg, err := buildCtx.ImportDir(dir, build.ImportComment)
+		if err == nil {
+			add(pkgDir{path, pkg})
+		} else if _, ok := err.(*build.NoGoError); !ok {
+			warnf(warnSkip, "%v", err)
+		}
+	}
+	entries, err := fileSystem.ReadDir(dir)
+	if err != nil {
+		warnf(warnSkip, "%v", err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		sub := filepath.Join(dir, name)
+		isDir := entry.IsDir()
+		if entry.Mode()&os.ModeSymlink != 0 && d.follow {
+			fi, err := fileSystem.Stat(sub)
+			isDir = err == nil && fi.IsDir()
+		}
+		if !isDir || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || d.ignored(root, sub) {
+			continue
+		}
+		subPath := name
+		if path != "" {
+			subPath = path + "/" + name
+		}
+		d.walkPackages(root, sub, subPath, match, add)
+	}
+}
+
+// patternDoc prints the package docs for each package matched by the
+// patterns, as packageDoc does, separated by rules. With -o, each is
+// written to its own file beneath the directory instead: see outputFile.
+func patternDoc(writer io.Writer, patterns []string) error {
+	pkgs := matchPackages(patterns)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+	if output != "" {
+		esc := func(s string) string { return s }
+		if escape != "" {
+			esc = escaper(escape)
+		}
+		for _, p := range pkgs {
+			var buf bytes.Buffer
+			pkg := parsePackage(&buf, p.pkg, p.path)
+			pkg.packageDoc()
+			writeOutput(outputFile(output, p), []byte(esc(buf.String())))
+		}
+		return nil
+	}
+	for i, p := range pkgs {
+		if i > 0 {
+			fmt.Fprintf(writer, "\n%s\n\n", strings.Repeat("-", lineWidth))
+		}
+		pkg := parsePackage(writer, p.pkg, p.path)
+		pkg.packageDoc()
+	}
+	return nil
+}
+
+// outputFile returns the name of the file beneath dir to which -o writes
+// the docs of the package: the path by which it is known, without any
+// leading ./ or ../ elements, with .txt appended. The package in the
+// directory of a relative pattern is named by its package name.
+func outputFile(dir string, p pkgDir) string {
+	path := filepath.ToSlash(filepath.Clean(p.path))
+	for strings.HasPrefix(path, "../") {
+		path = path[len("../"):]
+	}
+	if path == "." || path == ".." {
+		path = p.pkg.Name
+	}
+	return filepath.Join(dir, filepath.FromSlash(path)+".txt")
+}
+
+// writeOutput writes the output to the file for -o, creating its
+// directory if need be.
+func writeOutput(file string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		fatalf("%v", err)
+	}
+	if err := ioutil.WriteFile(file, data, 0666); err != nil {
+		fatalf("%v", err)
+	}
+}
@@ -12,21 +12,44 @@ import (
 	"go/doc"
 	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io"
-	"log"
-	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
 
-const (
-	punchedCardWidth = 80 // These things just won't leave us alone.
-	indentedWidth    = punchedCardWidth - len(indent)
-	indent           = "    "
-)
+const punchedCardWidth = 80 // These things just won't leave us alone.
+
+// indent is the indentation of doc comments beneath their declarations,
+// of preformatted blocks within them and of constructors beneath their
+// types. It is set from the -indent flag.
+var indent = "    "
+
+// indentedWidth returns the width to which indented doc comments are
+// wrapped.
+func indentedWidth() int {
+	return lineWidth - indentColumns()
+}
+
+// indentColumns returns the number of columns the indentation takes,
+// with tab stops every 8 columns.
+func indentColumns() int {
+	n := 0
+	for _, r := range indent {
+		if r == '\t' {
+			n = (n/8 + 1) * 8
+		} else {
+			n++
+		}
+	}
+	return n
+}
 
 type Package struct {
 	writer   io.Writer    // Destination for output.
@@ -38,12 +61,19 @@ type Package struct {
 	build    *build.Package
 	fs       *token.FileSet // Needed for printing.
 	buf      bytes.Buffer
+
+	annotated []int // Features of the output to explain, for -annotate.
 }
 
-type PackageError string // type returned by pkg.Fatalf.
+// A PackageError is the error pkg.Fatalf panics with, and fatalf for
+// -batch, of one of the kinds of error.
+type PackageError struct {
+	kind string
+	msg  string
+}
 
 func (p PackageError) Error() string {
-	return string(p)
+	return p.msg
 }
 
 // prettyPath returns a version of the package path that is suitable for an
@@ -62,7 +92,7 @@ func (pkg *Package) prettyPath() string {
 	// Also convert everything to slash-separated paths for uniform handling.
 	path = filepath.Clean(filepath.ToSlash(pkg.build.Dir))
 	// Can we find a decent prefix?
-	goroot := filepath.Join(build.Default.GOROOT, "src")
+	goroot := filepath.Join(buildCtx.GOROOT, "src")
 	if p, ok := trim(path, filepath.ToSlash(goroot)); ok {
 		return p
 	}
@@ -96,36 +126,58 @@ func trim(path, prefix string) (string, bool) {
 // without running a subprocess. The log prefix will be added when
 // logged in main; it is not added here.
 func (pkg *Package) Fatalf(format string, args ...interface{}) {
-	panic(PackageError(fmt.Sprintf(format, args...)))
+	pkg.failf(errOther, format, args...)
+}
+
+// pkg.failf is pkg.Fatalf for an error of a known kind.
+func (pkg *Package) failf(kind, format string, args ...interface{}) {
+	panic(PackageError{kind: kind, msg: fmt.Sprintf(format, args...)})
 }
 
 // parsePackage turns the build package we found into a parsed package
 // we can then use to generate documentation.
 func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Package {
+	noteCacheDep(pkg)
+	var key string
+	if packageCache != nil {
+		key = cacheKey(pkg)
+		if p := packageCache[key]; p != nil {
+			p.writer, p.userPath = writer, userPath
+			return p
+		}
+	}
+	for _, name := range pkg.IgnoredGoFiles {
+		tracef("not parsing %s: it is excluded by build constraints", filepath.Join(pkg.Dir, name))
+	}
 	fs := token.NewFileSet()
-	// include tells parser.ParseDir which files to include.
-	// That means the file must be in the build package's GoFiles or CgoFiles
+	// Parse the files in the build package's GoFiles or CgoFiles
 	// list only (no tag-ignored files, tests, swig or other non-Go files).
-	include := func(info os.FileInfo) bool {
-		for _, name := range pkg.GoFiles {
-			if name == info.Name() {
-				return true
-			}
+	pkgs := make(map[string]*ast.Package)
+	for _, name := range append(pkg.GoFiles[:len(pkg.GoFiles):len(pkg.GoFiles)], pkg.CgoFiles...) {
+		filename := filepath.Join(pkg.Dir, name)
+		tracef("parsing %s", filename)
+		src, err := readFile(filename)
+		if err != nil {
+			failf(errParse, "%s", err)
+		}
+		// go/doc discards function bodies, so do not build them.
+		file, err := parser.ParseFile(fs, filename, skipBodies(src), parser.ParseComments)
+		if err != nil {
+			failf(errParse, "%s", err)
 		}
-		for _, name := range pkg.CgoFiles {
-			if name == info.Name() {
-				return true
+		astPkg := pkgs[file.Name.Name]
+		if astPkg == nil {
+			astPkg = &ast.Package{
+				Name:  file.Name.Name,
+				Files: make(map[string]*ast.File),
 			}
+			pkgs[astPkg.Name] = astPkg
 		}
-		return false
-	}
-	pkgs, err := parser.ParseDir(fs, pkg.Dir, include, parser.ParseComments)
-	if err != nil {
-		log.Fatal(err)
+		astPkg.Files[filename] = file
 	}
 	// Make sure they are all in one package.
 	if len(pkgs) != 1 {
-		log.Fatalf("multiple packages in directory %s", pkg.Dir)
+		failf(errParse, "multiple packages in directory %s", pkg.Dir)
 	}
 	astPkg := pkgs[pkg.Name]
 
@@ -136,15 +188,20 @@ func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Packag
 	//	go doc time.Sunday
 	// from finding the symbol. Work around this for now, but we
 	// should fix it in go/doc.
-	// A similar story applies to factory functions.
+	// A similar story applies to factory functions, more of which
+	// attachConstructors finds first.
 	docPkg := doc.New(astPkg, pkg.ImportPath, doc.AllDecls)
+	attachConstructors(docPkg)
 	for _, typ := range docPkg.Types {
 		docPkg.Consts = append(docPkg.Consts, typ.Consts...)
 		docPkg.Vars = append(docPkg.Vars, typ.Vars...)
 		docPkg.Funcs = append(docPkg.Funcs, typ.Funcs...)
 	}
+	if sortOrder == "source" {
+		sortBySource(docPkg)
+	}
 
-	return &Package{
+	p := &Package{
 		writer:   writer,
 		name:     pkg.Name,
 		userPath: userPath,
@@ -154,6 +211,164 @@ func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Packag
 		build:    pkg,
 		fs:       fs,
 	}
+	if packageCache != nil {
+		packageCache[key] = p
+	}
+	return p
+}
+
+// skipBodies returns a copy of the source with the bodies of its function
+// declarations blanked out, apart from their comments, which may hold
+// notes such as BUG(who). Every other byte of a body becomes a space and
+// its newlines are kept, so the positions of what remains are unchanged,
+// but the parser has only an empty block to build. Function literals,
+// which may be the values of variables, are left alone.
+func skipBodies(src []byte) []byte {
+	out := append([]byte(nil), src...)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+	prev := token.SEMICOLON // The package clause ends with one.
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF {
+			return out
+		}
+		if tok == token.COMMENT {
+			continue
+		}
+		start := tok == token.FUNC && prev == token.SEMICOLON
+		prev = tok
+		if !start {
+			continue
+		}
+		// Find the body, if there is one, after the signature, whose
+		// types may have braces of their own.
+		parens, braces := 0, 0
+	signature:
+		for {
+			pos, tok, _ = s.Scan()
+			switch tok {
+			case token.EOF:
+				return out
+			case token.LPAREN, token.LBRACK:
+				parens++
+			case token.RPAREN, token.RBRACK:
+				parens--
+			case token.LBRACE:
+				if braces == 0 && parens == 0 && prev != token.STRUCT && prev != token.INTERFACE {
+					break signature
+				}
+				braces++
+			case token.RBRACE:
+				braces--
+			case token.SEMICOLON:
+				if parens == 0 && braces == 0 {
+					break signature // No body.
+				}
+			}
+			if tok != token.COMMENT {
+				prev = tok
+			}
+		}
+		prev = tok
+		if tok != token.LBRACE {
+			continue
+		}
+		from, depth := file.Offset(pos)+1, 1
+		for depth > 0 {
+			pos, tok, lit := s.Scan()
+			switch tok {
+			case token.EOF:
+				blank(out[from:])
+				return out
+			case token.COMMENT:
+				code := out[from:file.Offset(pos)]
+				end := len(bytes.TrimRight(code, " \t\r\n"))
+				blank(code)
+				if end > 0 {
+					// The comment follows code, which keeps it in a
+					// group of its own, so leave an empty statement
+					// where the code ended.
+					code[end-1] = ';'
+				}
+				from = file.Offset(pos) + len(lit)
+			case token.LBRACE:
+				depth++
+			case token.RBRACE:
+				depth--
+				if depth == 0 {
+					blank(out[from:file.Offset(pos)])
+				}
+			}
+		}
+		prev = token.RBRACE
+	}
+}
+
+// blank replaces the bytes of b, apart from newlines, with spaces.
+func blank(b []byte) {
+	for i, c := range b {
+		if c != '\n' {
+			b[i] = ' '
+		}
+	}
+}
+
+// A sourceFile is a file of a package as parsed by sourceFiles.
+type sourceFile struct {
+	name string
+	file *ast.File
+	src  []byte
+}
+
+// sourceFiles parses the package's files again, in the order of their
+// names, for those features that need what go/doc removes from the
+// syntax trees it is given: doc comments and function bodies.
+func (pkg *Package) sourceFiles() (*token.FileSet, []sourceFile) {
+	var names []string
+	for name := range pkg.pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fset := token.NewFileSet()
+	var files []sourceFile
+	for _, name := range names {
+		src, err := readFile(name)
+		if err != nil {
+			failf(errParse, "%s", err)
+		}
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			failf(errParse, "%s", err)
+		}
+		files = append(files, sourceFile{name, file, src})
+	}
+	return fset, files
+}
+
+// sortBySource puts the declarations of the package, and those
+// associated with each type, in the order of the source, for -sort
+// source, rather than the alphabetical order of go/doc. The files are
+// parsed in the order of their names, so later files have later positions.
+func sortBySource(d *doc.Package) {
+	values := func(list []*doc.Value) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Decl.Pos() < list[j].Decl.Pos() })
+	}
+	funcs := func(list []*doc.Func) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Decl.Pos() < list[j].Decl.Pos() })
+	}
+	values(d.Consts)
+	values(d.Vars)
+	funcs(d.Funcs)
+	sort.Slice(d.Types, func(i, j int) bool { return d.Types[i].Decl.Pos() < d.Types[j].Decl.Pos() })
+	for _, typ := range d.Types {
+		values(typ.Consts)
+		values(typ.Vars)
+		funcs(typ.Funcs)
+		funcs(typ.Methods)
+	}
 }
 
 func (pkg *Package) Printf(format string, args ...interface{}) {
@@ -163,7 +378,7 @@ func (pkg *Package) Printf(format string, args ...interface{}) {
 func (pkg *Package) flush() {
 	_, err := pkg.writer.Write(pkg.buf.Bytes())
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 	pkg.buf.Reset() // Not needed, but it's a flush.
 }
@@ -180,13 +395,18 @@ func (pkg *Package) newlines(n int) {
 // emit prints the node.
 func (pkg *Package) emit(comment string, node ast.Node) {
 	if node != nil {
+		comment, directives := splitDirectives(comment)
+		pkg.printDirectives(directives)
 		err := format.Node(&pkg.buf, pkg.fs, node)
 		if err != nil {
-			log.Fatal(err)
+			fatalf("%v", err)
+		}
+		if loc := pkg.location(node); loc != "" {
+			pkg.Printf("  // %s", loc)
 		}
 		if comment != "" {
 			pkg.newlines(1)
-			doc.ToText(&pkg.buf, comment, "    ", indent, indentedWidth)
+			pkg.toText(comment, indent)
 			pkg.newlines(2) // Blank line after comment to separate from next item.
 		} else {
 			pkg.newlines(1)
@@ -194,6 +414,54 @@ func (pkg *Package) emit(comment string, node ast.Node) {
 	}
 }
 
+// location returns where node is declared, for the -loc and -links flags,
+// or "" if none is set. It is file:line, with the file relative to the
+// current directory if it is beneath it, or for -links the address of the
+// line on the web site of the file's git repository, if it has one, and
+// for -srcurl the address given by the template. When styled, the address
+// is a hyperlink on file:line.
+func (pkg *Package) location(node ast.Node) string {
+	if !showLoc && !showLinks && srcURL == "" {
+		return ""
+	}
+	pos := pkg.fs.Position(node.Pos())
+	loc := fmt.Sprintf("%s:%d", shortPath(pos.Filename), pos.Line)
+	if showLinks || srcURL != "" {
+		var url string
+		if srcURL != "" {
+			url = templateURL(srcURL, pos.Filename, pos.Line, pkg.build.ImportPath)
+		} else {
+			url = sourceURL(pos.Filename, pos.Line)
+		}
+		if url != "" {
+			if styled {
+				return hyperlink(url, loc)
+			}
+			return url
+		}
+	}
+	return loc
+}
+
+// summaryLine returns the one-line summary of node, followed by a comment
+// holding the notes and its location, if there are any.
+func (pkg *Package) summaryLine(node ast.Node, notes ...string) string {
+	line := pkg.oneLineNode(node)
+	if loc := pkg.location(node); loc != "" {
+		notes = append(notes, loc)
+	}
+	if line != "" && len(notes) > 0 {
+		line += "  // " + strings.Join(notes, "; ")
+	}
+	return line
+}
+
+// oneLineBuffers holds the buffers in which one-line summaries are built,
+// reused because summarizing a large package builds thousands.
+var oneLineBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 // oneLineNode returns a one-line summary of the given input node.
 func (pkg *Package) oneLineNode(node ast.Node) string {
 	const maxDepth = 10
@@ -203,167 +471,219 @@ func (pkg *Package) oneLineNode(node ast.Node) string {
 // oneLineNodeDepth returns a one-line summary of the given input node.
 // The depth specifies the maximum depth when traversing the AST.
 func (pkg *Package) oneLineNodeDepth(node ast.Node, depth int) string {
+	buf := oneLineBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	pkg.writeOneLine(buf, node, depth)
+	s := buf.String()
+	oneLineBuffers.Put(buf)
+	return s
+}
+
+// writeOneLine writes the one-line summary of the node that
+// oneLineNodeDepth returns to buf.
+func (pkg *Package) writeOneLine(buf *bytes.Buffer, node ast.Node, depth int) {
 	const dotDotDot = "..."
 	if depth == 0 {
-		return dotDotDot
+		buf.WriteString(dotDotDot)
+		return
 	}
 	depth--
 
 	switch n := node.(type) {
 	case nil:
-		return ""
 
 	case *ast.GenDecl:
 		// Formats const and var declarations.
-		trailer := ""
-		if len(n.Specs) > 1 {
-			trailer = " " + dotDotDot
-		}
-
 		// Find the first relevant spec.
-		typ := ""
+		var typ ast.Expr
 		for i, spec := range n.Specs {
 			valueSpec := spec.(*ast.ValueSpec) // Must succeed; we can't mix types in one GenDecl.
 
 			// The type name may carry over from a previous specification in the
 			// case of constants and iota.
 			if valueSpec.Type != nil {
-				typ = fmt.Sprintf(" %s", pkg.oneLineNodeDepth(valueSpec.Type, depth))
+				typ = valueSpec.Type
 			} else if len(valueSpec.Values) > 0 {
-				typ = ""
+				typ = nil
 			}
 
 			if !isExported(valueSpec.Names[0].Name) {
 				continue
 			}
-			val := ""
+			buf.WriteString(n.Tok.String())
+			buf.WriteByte(' ')
+			buf.WriteString(valueSpec.Names[0].Name)
+			if typ != nil {
+				buf.WriteByte(' ')
+				pkg.writeOneLine(buf, typ, depth)
+			}
 			if i < len(valueSpec.Values) && valueSpec.Values[i] != nil {
-				val = fmt.Sprintf(" = %s", pkg.oneLineNodeDepth(valueSpec.Values[i], depth))
+				buf.WriteString(" = ")
+				pkg.writeOneLine(buf, valueSpec.Values[i], depth)
+			}
+			if len(n.Specs) > 1 {
+				buf.WriteString(" " + dotDotDot)
 			}
-			return fmt.Sprintf("%s %s%s%s%s", n.Tok, valueSpec.Names[0], typ, val, trailer)
+			return
 		}
-		return ""
 
 	case *ast.FuncDecl:
 		// Formats func declarations.
-		name := n.Name.Name
-		recv := pkg.oneLineNodeDepth(n.Recv, depth)
-		if len(recv) > 0 {
-			recv = "(" + recv + ") "
+		buf.WriteString("func ")
+		start := buf.Len()
+		buf.WriteByte('(')
+		pkg.writeOneLine(buf, n.Recv, depth)
+		if buf.Len() == start+1 {
+			buf.Truncate(start) // No receiver.
+		} else {
+			buf.WriteString(") ")
 		}
-		fnc := pkg.oneLineNodeDepth(n.Type, depth)
-		if strings.Index(fnc, "func") == 0 {
-			fnc = fnc[4:]
+		buf.WriteString(n.Name.Name)
+		start = buf.Len()
+		pkg.writeOneLine(buf, n.Type, depth)
+		if b := buf.Bytes()[start:]; bytes.HasPrefix(b, []byte("func")) {
+			copy(b, b[4:])
+			buf.Truncate(buf.Len() - 4)
 		}
-		return fmt.Sprintf("func %s%s%s", recv, name, fnc)
 
 	case *ast.TypeSpec:
-		return fmt.Sprintf("type %s %s", n.Name.Name, pkg.oneLineNodeDepth(n.Type, depth))
+		buf.WriteString("type ")
+		buf.WriteString(n.Name.Name)
+		buf.WriteByte(' ')
+		pkg.writeOneLine(buf, n.Type, depth)
 
 	case *ast.FuncType:
-		var params []string
+		buf.WriteString("func(")
 		if n.Params != nil {
-			for _, field := range n.Params.List {
-				params = append(params, pkg.oneLineField(field, depth))
+			for i, field := range n.Params.List {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				pkg.writeOneLineField(buf, field, depth)
 			}
 		}
-		needParens := false
-		var results []string
-		if n.Results != nil {
-			needParens = needParens || len(n.Results.List) > 1
-			for _, field := range n.Results.List {
-				needParens = needParens || len(field.Names) > 0
-				results = append(results, pkg.oneLineField(field, depth))
-			}
+		buf.WriteByte(')')
+		if n.Results == nil || len(n.Results.List) == 0 {
+			return
 		}
-
-		param := strings.Join(params, ", ")
-		if len(results) == 0 {
-			return fmt.Sprintf("func(%s)", param)
+		needParens := len(n.Results.List) > 1
+		for _, field := range n.Results.List {
+			needParens = needParens || len(field.Names) > 0
+		}
+		buf.WriteByte(' ')
+		if needParens {
+			buf.WriteByte('(')
+		}
+		for i, field := range n.Results.List {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			pkg.writeOneLineField(buf, field, depth)
 		}
-		result := strings.Join(results, ", ")
-		if !needParens {
-			return fmt.Sprintf("func(%s) %s", param, result)
+		if needParens {
+			buf.WriteByte(')')
 		}
-		return fmt.Sprintf("func(%s) (%s)", param, result)
 
 	case *ast.StructType:
 		if n.Fields == nil || len(n.Fields.List) == 0 {
-			return "struct{}"
+			buf.WriteString("struct{}")
+			return
 		}
-		return "struct{ ... }"
+		buf.WriteString("struct{ ... }")
 
 	case *ast.InterfaceType:
 		if n.Methods == nil || len(n.Methods.List) == 0 {
-			return "interface{}"
+			buf.WriteString("interface{}")
+			return
 		}
-		return "interface{ ... }"
+		buf.WriteString("interface{ ... }")
 
 	case *ast.FieldList:
 		if n == nil || len(n.List) == 0 {
-			return ""
+			return
 		}
 		if len(n.List) == 1 {
-			return pkg.oneLineField(n.List[0], depth)
+			pkg.writeOneLineField(buf, n.List[0], depth)
+			return
 		}
-		return dotDotDot
+		buf.WriteString(dotDotDot)
 
 	case *ast.FuncLit:
-		return pkg.oneLineNodeDepth(n.Type, depth) + " { ... }"
+		pkg.writeOneLine(buf, n.Type, depth)
+		buf.WriteString(" { ... }")
 
 	case *ast.CompositeLit:
-		typ := pkg.oneLineNodeDepth(n.Type, depth)
+		pkg.writeOneLine(buf, n.Type, depth)
 		if len(n.Elts) == 0 {
-			return fmt.Sprintf("%s{}", typ)
+			buf.WriteString("{}")
+			return
 		}
-		return fmt.Sprintf("%s{ %s }", typ, dotDotDot)
+		buf.WriteString("{ " + dotDotDot + " }")
 
 	case *ast.ArrayType:
-		length := pkg.oneLineNodeDepth(n.Len, depth)
-		element := pkg.oneLineNodeDepth(n.Elt, depth)
-		return fmt.Sprintf("[%s]%s", length, element)
+		buf.WriteByte('[')
+		pkg.writeOneLine(buf, n.Len, depth)
+		buf.WriteByte(']')
+		pkg.writeOneLine(buf, n.Elt, depth)
 
 	case *ast.MapType:
-		key := pkg.oneLineNodeDepth(n.Key, depth)
-		value := pkg.oneLineNodeDepth(n.Value, depth)
-		return fmt.Sprintf("map[%s]%s", key, value)
+		buf.WriteString("map[")
+		pkg.writeOneLine(buf, n.Key, depth)
+		buf.WriteByte(']')
+		pkg.writeOneLine(buf, n.Value, depth)
 
 	case *ast.CallExpr:
-		fnc := pkg.oneLineNodeDepth(n.Fun, depth)
-		var args []string
-		for _, arg := range n.Args {
-			args = append(args, pkg.oneLineNodeDepth(arg, depth))
+		pkg.writeOneLine(buf, n.Fun, depth)
+		buf.WriteByte('(')
+		for i, arg := range n.Args {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			pkg.writeOneLine(buf, arg, depth)
 		}
-		return fmt.Sprintf("%s(%s)", fnc, strings.Join(args, ", "))
+		buf.WriteByte(')')
 
 	case *ast.UnaryExpr:
-		return fmt.Sprintf("%s%s", n.Op, pkg.oneLineNodeDepth(n.X, depth))
+		buf.WriteString(n.Op.String())
+		pkg.writeOneLine(buf, n.X, depth)
 
 	case *ast.Ident:
-		return n.Name
+		buf.WriteString(n.Name)
 
 	default:
 		// As a fallback, use default formatter for all unknown node types.
-		buf := new(bytes.Buffer)
+		start := buf.Len()
 		format.Node(buf, pkg.fs, node)
-		s := buf.String()
-		if strings.Contains(s, "\n") {
-			return dotDotDot
+		if bytes.IndexByte(buf.Bytes()[start:], '\n') >= 0 {
+			buf.Truncate(start)
+			buf.WriteString(dotDotDot)
 		}
-		return s
 	}
 }
 
 // oneLineField returns a one-line summary of the field.
 func (pkg *Package) oneLineField(field *ast.Field, depth int) string {
-	var names []string
-	for _, name := range field.Names {
-		names = append(names, name.Name)
+	buf := oneLineBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	pkg.writeOneLineField(buf, field, depth)
+	s := buf.String()
+	oneLineBuffers.Put(buf)
+	return s
+}
+
+// writeOneLineField writes the one-line summary of the field that
+// oneLineField returns to buf.
+func (pkg *Package) writeOneLineField(buf *bytes.Buffer, field *ast.Field, depth int) {
+	for i, name := range field.Names {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(name.Name)
 	}
-	if len(names) == 0 {
-		return pkg.oneLineNodeDepth(field.Type, depth)
+	if len(field.Names) > 0 {
+		buf.WriteByte(' ')
 	}
-	return strings.Join(names, ", ") + " " + pkg.oneLineNodeDepth(field.Type, depth)
+	pkg.writeOneLine(buf, field.Type, depth)
 }
 
 // packageDoc prints the docs for the package (package doc plus one-liners of the rest).
@@ -373,20 +693,39 @@ func (pkg *Package) packageDoc() {
 		pkg.packageClause(false)
 	}
 
-	doc.ToText(&pkg.buf, pkg.doc.Doc, "", indent, indentedWidth)
+	if pkg.pkg.Name == "main" {
+		pkg.synopsis()
+	}
+	comment, directives := splitDirectives(pkg.doc.Doc)
+	pkg.printDirectives(directives)
+	pkg.toText(comment, "")
 	pkg.newlines(1)
 
 	if !pkg.showInternals() {
-		// Show only package docs for commands.
+		// Show only package docs for commands, and their flags.
+		pkg.flagsSummary()
 		return
 	}
 
 	pkg.newlines(2) // Guarantee blank line before the components.
+	if showImports {
+		pkg.importsSummary()
+	}
 	pkg.valueSummary(pkg.doc.Consts, false)
 	pkg.valueSummary(pkg.doc.Vars, false)
 	pkg.funcSummary(pkg.doc.Funcs, false)
 	pkg.typeSummary()
+	if showCgo {
+		pkg.cgoSummary()
+	}
+	if showHooks {
+		pkg.hooksSummary()
+	}
+	if pkg.pkg.Name == "main" {
+		pkg.flagsSummary()
+	}
 	pkg.bugs()
+	pkg.printAnnotations()
 }
 
 // showInternals reports whether we should show the internals
@@ -412,8 +751,99 @@ func (pkg *Package) packageClause(checkUserPath bool) {
 		importPath = pkg.build.ImportPath
 	}
 	pkg.Printf("package %s // import %q\n\n", pkg.name, importPath)
+	pkg.annotate(annotateImport)
 	if importPath != pkg.build.ImportPath {
-		pkg.Printf("WARNING: package source is installed in %q\n", pkg.build.ImportPath)
+		warnf(warnInstall, "package %s: package source is installed in %q", importPath, pkg.build.ImportPath)
+		pkg.annotate(annotateInstalled)
+	}
+	if modPath, gomod := modulePath(pkg.build.Dir); modPath != "" && modPath != pkg.build.ImportPath && !isLocalImport(pkg.build.ImportPath) {
+		warnf(warnModule, "package %s: %s implies import path %q", pkg.build.ImportPath, gomod, modPath)
+	}
+}
+
+// modulePath returns the import path that the nearest go.mod file in the
+// directory or those above it, up to the src directory of GOROOT or the
+// GOPATH entry holding it, implies for a package in the directory: its
+// module path and the directory's path beneath the file's. It returns
+// the name of the file too, or "" and "" if there is none.
+func modulePath(dir string) (path, gomod string) {
+	root := ""
+	for _, r := range append([]string{buildCtx.GOROOT}, splitGopath()...) {
+		if src := filepath.Join(r, "src"); inDir(dir, src) {
+			root = src
+		}
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		gomod = filepath.Join(d, "go.mod")
+		if data, err := readFile(gomod); err == nil {
+			mod := moduleDirective(data)
+			if mod == "" {
+				return "", ""
+			}
+			rel, err := filepath.Rel(d, dir)
+			if err != nil {
+				return "", ""
+			}
+			if rel == "." {
+				return mod, gomod
+			}
+			return mod + "/" + filepath.ToSlash(rel), gomod
+		}
+		if d == root || d == filepath.Dir(d) {
+			return "", ""
+		}
+	}
+}
+
+// moduleDirective returns the module path given by the module directive
+// of the go.mod file's contents, or "".
+func moduleDirective(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 || f[0] != "module" {
+			continue
+		}
+		if path, err := strconv.Unquote(f[1]); err == nil {
+			return path
+		}
+		return f[1]
+	}
+	return ""
+}
+
+// isLocalImport reports whether the import path is that go/build gives a
+// directory outside GOROOT and GOPATH: ".", or beginning "_/".
+func isLocalImport(path string) bool {
+	return path == "." || strings.HasPrefix(path, "_/")
+}
+
+// checkInternal warns if the package is internal and the package in the
+// current directory cannot import it: if the directory is not beneath the
+// one holding the package's last path element internal, as the go command
+// requires. Its documentation is shown all the same.
+func (pkg *Package) checkInternal() {
+	rel, ok := srcRelative(pkg.build.Dir)
+	if !ok {
+		return
+	}
+	var i int
+	switch {
+	case strings.HasSuffix(rel, "/internal"):
+		i = len(rel) - len("internal")
+	case strings.Contains(rel, "/internal/"):
+		i = strings.LastIndex(rel, "/internal/") + 1
+	case rel == "internal" || strings.HasPrefix(rel, "internal/"):
+		i = 0
+	default:
+		return
+	}
+	parent := strings.TrimSuffix(pkg.build.Dir, filepath.FromSlash(rel[i:]))
+	parent = strings.TrimSuffix(parent, string(filepath.Separator))
+	if !inDir(pwd(), parent) {
+		warnf(warnInternal, "package %s is internal: not importable from here, only from beneath %s", pkg.build.ImportPath, parent)
 	}
 }
 
@@ -439,7 +869,7 @@ func (pkg *Package) valueSummary(values []*doc.Value, showGrouped bool) {
 
 	for _, value := range values {
 		if !isGrouped[value] {
-			if decl := pkg.oneLineNode(value.Decl); decl != "" {
+			if decl := pkg.summaryLine(value.Decl); decl != "" {
 				pkg.Printf("%s\n", decl)
 			}
 		}
@@ -465,7 +895,7 @@ func (pkg *Package) funcSummary(funcs []*doc.Func, showConstructors bool) {
 		// Exported functions only. The go/doc package does not include methods here.
 		if isExported(fun.Name) {
 			if !isConstructor[fun] {
-				pkg.Printf("%s\n", pkg.oneLineNode(fun.Decl))
+				pkg.Printf("%s\n", pkg.summaryLine(fun.Decl))
 			}
 		}
 	}
@@ -477,21 +907,24 @@ func (pkg *Package) typeSummary() {
 		for _, spec := range typ.Decl.Specs {
 			typeSpec := spec.(*ast.TypeSpec) // Must succeed.
 			if isExported(typeSpec.Name.Name) {
-				pkg.Printf("%s\n", pkg.oneLineNode(typeSpec))
+				pkg.Printf("%s\n", pkg.summaryLine(typeSpec))
+				if len(typ.Consts) > 0 || len(typ.Vars) > 0 || len(typ.Funcs) > 0 {
+					pkg.annotate(annotateSummaryGroup)
+				}
 				// Now print the consts, vars, and constructors.
 				for _, c := range typ.Consts {
-					if decl := pkg.oneLineNode(c.Decl); decl != "" {
+					if decl := pkg.summaryLine(c.Decl); decl != "" {
 						pkg.Printf(indent+"%s\n", decl)
 					}
 				}
 				for _, v := range typ.Vars {
-					if decl := pkg.oneLineNode(v.Decl); decl != "" {
+					if decl := pkg.summaryLine(v.Decl); decl != "" {
 						pkg.Printf(indent+"%s\n", decl)
 					}
 				}
 				for _, constructor := range typ.Funcs {
 					if isExported(constructor.Name) {
-						pkg.Printf(indent+"%s\n", pkg.oneLineNode(constructor.Decl))
+						pkg.Printf(indent+"%s\n", pkg.summaryLine(constructor.Decl))
 					}
 				}
 			}
@@ -499,16 +932,45 @@ func (pkg *Package) typeSummary() {
 	}
 }
 
-// bugs prints the BUGS information for the package.
-// TODO: Provide access to TODOs and NOTEs as well (very noisy so off by default)?
+// bugs prints the BUGS information for the package, followed by the
+// notes with the other markers asked for by -notes, which are very noisy
+// so off by default.
 func (pkg *Package) bugs() {
-	if pkg.doc.Notes["BUG"] == nil {
-		return
+	for _, marker := range pkg.noteMarkers() {
+		if pkg.doc.Notes[marker] == nil {
+			continue
+		}
+		pkg.Printf("\n")
+		for _, note := range pkg.doc.Notes[marker] {
+			pkg.Printf("%s: %v\n", marker, note.Body)
+		}
 	}
-	pkg.Printf("\n")
-	for _, note := range pkg.doc.Notes["BUG"] {
-		pkg.Printf("%s: %v\n", "BUG", note.Body)
+}
+
+// noteMarkers returns the markers of the notes to print: BUG, then those
+// in the -notes list, in its order, where all stands for every other
+// marker in the package, in alphabetical order.
+func (pkg *Package) noteMarkers() []string {
+	markers := []string{"BUG"}
+	seen := map[string]bool{"BUG": true}
+	for _, m := range strings.FieldsFunc(notes, func(r rune) bool { return r == ',' || r == ' ' }) {
+		var list []string
+		if m == "all" {
+			for marker := range pkg.doc.Notes {
+				list = append(list, marker)
+			}
+			sort.Strings(list)
+		} else {
+			list = []string{strings.ToUpper(m)}
+		}
+		for _, marker := range list {
+			if !seen[marker] {
+				seen[marker] = true
+				markers = append(markers, marker)
+			}
+		}
 	}
+	return markers
 }
 
 // findValues finds the doc.Values that describe the symbol.
@@ -561,6 +1023,7 @@ func (pkg *Package) findTypeSpec(decl *ast.GenDecl, symbol string) *ast.TypeSpec
 // If there is no top-level symbol, symbolDoc looks for methods that match.
 func (pkg *Package) symbolDoc(symbol string) bool {
 	defer pkg.flush()
+	defer pkg.printAnnotations()
 	found := false
 	// Functions.
 	for _, fun := range pkg.findFuncs(symbol) {
@@ -570,7 +1033,20 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 		// Symbol is a function.
 		decl := fun.Decl
 		decl.Body = nil
-		pkg.emit(fun.Doc, decl)
+		comment := fun.Doc
+		if topic, ok := builtinTopics[fun.Name]; ok && pkg.build.ImportPath == "builtin" {
+			comment += "\n" + topic
+		}
+		pkg.emit(comment, decl)
+		if inlineTypes {
+			pkg.inlineTypes(decl)
+		}
+		if follow {
+			pkg.followLinks(fun.Doc)
+		}
+		if showExamples {
+			pkg.exampleDoc(fun.Name)
+		}
 		found = true
 	}
 	// Constants and variables behave the same.
@@ -584,6 +1060,10 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 		// TODO: Would be nice if go/doc did this for us.
 		specs := make([]ast.Spec, 0, len(value.Decl.Specs))
 		var typ ast.Expr
+		var comments map[*ast.ValueSpec]*ast.CommentGroup
+		if showIota {
+			comments = iotaComments(value.Decl)
+		}
 		for _, spec := range value.Decl.Specs {
 			vspec := spec.(*ast.ValueSpec)
 
@@ -594,7 +1074,8 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 			}
 
 			for _, ident := range vspec.Names {
-				if isExported(ident.Name) {
+				// With -iota, the skipped values of a block are shown too.
+				if isExported(ident.Name) || comments != nil && ident.Name == "_" {
 					if vspec.Type == nil && vspec.Values == nil && typ != nil {
 						// This a standalone identifier, as in the case of iota usage.
 						// Thus, assume the type comes from the previous type.
@@ -604,6 +1085,13 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 						}
 					}
 
+					if c := comments[vspec]; c != nil {
+						// Annotate a copy, so the values are not shown twice
+						// if the symbol is asked for again (by -repl).
+						s := *vspec
+						s.Comment = c
+						vspec = &s
+					}
 					specs = append(specs, vspec)
 					typ = nil // Only inject type on first exported identifier
 					break
@@ -618,6 +1106,9 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 			pkg.packageClause(true)
 		}
 		pkg.emit(value.Doc, value.Decl)
+		if follow {
+			pkg.followLinks(value.Doc)
+		}
 		found = true
 	}
 	// Types.
@@ -627,12 +1118,21 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 		}
 		decl := typ.Decl
 		spec := pkg.findTypeSpec(decl, typ.Name)
+		embedded := embeddedTypes(spec)
 		trimUnexportedElems(spec)
 		// If there are multiple types defined, reduce to just this one.
+		// Reduce a copy, as the others may be asked for later (by -repl).
 		if len(decl.Specs) > 1 {
-			decl.Specs = []ast.Spec{spec}
+			d := *decl
+			d.Specs = []ast.Spec{spec}
+			decl = &d
 		}
 		pkg.emit(typ.Doc, decl)
+		pkg.underlyingSummary(spec)
+		if showLayout {
+			pkg.layoutSummary(spec)
+		}
+		pkg.annotateType(typ, spec)
 		// Show associated methods, constants, etc.
 		if len(typ.Consts) > 0 || len(typ.Vars) > 0 || len(typ.Funcs) > 0 || len(typ.Methods) > 0 {
 			pkg.Printf("\n")
@@ -640,7 +1140,13 @@ func (pkg *Package) symbolDoc(symbol string) bool {
 		pkg.valueSummary(typ.Consts, true)
 		pkg.valueSummary(typ.Vars, true)
 		pkg.funcSummary(typ.Funcs, true)
-		pkg.funcSummary(typ.Methods, true)
+		pkg.methodSummary(typ, embedded)
+		if follow {
+			pkg.followLinks(typ.Doc)
+		}
+		if showExamples {
+			pkg.exampleDoc(typ.Name)
+		}
 		found = true
 	}
 	if !found {
@@ -702,7 +1208,7 @@ func trimUnexportedFields(fields *ast.FieldList, isInterface bool) *ast.FieldLis
 			}
 			if names == nil {
 				// Can only happen if AST is incorrect. Safe to continue with a nil list.
-				log.Print("invalid program: unexpected type for embedded field")
+				warnf(warnInvalid, "unexpected type for embedded field")
 			}
 		}
 		// Trims if any is unexported. Good enough in practice.
@@ -751,7 +1257,8 @@ func (pkg *Package) printMethodDoc(symbol, method string) bool {
 		if symbol == "" {
 			return false
 		}
-		pkg.Fatalf("symbol %s is not a type in package %s installed in %q", symbol, pkg.name, pkg.build.ImportPath)
+		pkg.failf(errSymbol, "symbol %s is not a type in package %s installed in %q%s", symbol, pkg.name, pkg.build.ImportPath,
+			suggestion(pkg.candidates(symbol, "", true)))
 	}
 	found := false
 	for _, typ := range types {
@@ -760,13 +1267,68 @@ func (pkg *Package) printMethodDoc(symbol, method string) bool {
 				decl := meth.Decl
 				decl.Body = nil
 				pkg.emit(meth.Doc, decl)
+				if inlineTypes {
+					pkg.inlineTypes(decl)
+				}
+				if follow {
+					pkg.followLinks(meth.Doc)
+				}
+				if showExamples {
+					pkg.exampleDoc(typ.Name + "_" + meth.Name)
+				}
 				found = true
 			}
 		}
+		if pkg.printInterfaceMethods(typ, method) {
+			found = true
+		}
 	}
 	return found
 }
 
+// printInterfaceMethods prints the docs for the methods of the type,
+// if it is an interface, that match method, and reports whether there
+// were any. Each is shown as if declared with the interface as its
+// receiver, as func (Writer) Write(p []byte) (n int, err error).
+func (pkg *Package) printInterfaceMethods(typ *doc.Type, method string) bool {
+	meths := pkg.interfaceMethodFuncs(typ, method)
+	for _, meth := range meths {
+		pkg.emit(meth.Doc, meth.Decl)
+	}
+	return len(meths) > 0
+}
+
+// interfaceMethodFuncs returns the methods of the type, if it is an interface,
+// that match method, each declared with the interface as its receiver.
+func (pkg *Package) interfaceMethodFuncs(typ *doc.Type, method string) []*doc.Func {
+	spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+	var meths []*doc.Func
+	for _, field := range iface.Methods.List {
+		if len(field.Names) == 0 {
+			continue // Embedded.
+		}
+		name := field.Names[0].Name
+		if !match(method, name) {
+			continue
+		}
+		meths = append(meths, &doc.Func{
+			Doc:  field.Doc.Text(),
+			Name: name,
+			Decl: &ast.FuncDecl{
+				Recv: &ast.FieldList{List: []*ast.Field{{Type: &ast.Ident{Name: typ.Name}}}},
+				Name: field.Names[0],
+				Type: field.Type.(*ast.FuncType),
+			},
+			Recv: typ.Name,
+		})
+	}
+	return meths
+}
+
 // methodDoc prints the docs for matches of symbol.method.
 func (pkg *Package) methodDoc(symbol, method string) bool {
 	defer pkg.flush()
@@ -775,12 +1337,16 @@ func (pkg *Package) methodDoc(symbol, method string) bool {
 
 // match reports whether the user's symbol matches the program's.
 // A lower-case character in the user's string matches either case in the program's.
+// With -prefix, the user's string need only match the start of the program's.
 // The program string must be exported.
 func match(user, program string) bool {
 	if !isExported(program) {
 		return false
 	}
 	if matchCase {
+		if matchPrefix {
+			return strings.HasPrefix(program, user)
+		}
 		return user == program
 	}
 	for _, u := range user {
@@ -794,7 +1360,7 @@ func match(user, program string) bool {
 		}
 		return false
 	}
-	return program == ""
+	return program == "" || matchPrefix
 }
 
 // simpleFold returns the minimum rune equivalent to r
@@ -0,0 +1,109 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"strings"
+)
+
+// A platformLine is a line of the merged summary printed by platformDoc,
+// with the platforms on which it appears.
+type platformLine struct {
+	key       string // Line text, qualified by its parent's if indented.
+	text      string
+	platforms []string
+}
+
+// platformDoc prints the package docs as packageDoc does, but merged from
+// the package's files as selected for each of the platforms, which have the
+// form goos/goarch. Each summary line that is not present on all the
+// platforms on which the package exists is annotated with those where it is.
+func (pkg *Package) platformDoc(platforms []string) {
+	defer pkg.flush()
+	var lines []*platformLine
+	var present, absent []string
+	for _, platform := range platforms {
+		slash := strings.Index(platform, "/")
+		if slash <= 0 || slash == len(platform)-1 {
+			fatalf("invalid platform %q; want goos/goarch", platform)
+		}
+		ctxt := platformContext(platform[:slash], platform[slash+1:])
+		bpkg, err := ctxt.ImportDir(pkg.build.Dir, build.ImportComment)
+		if err != nil {
+			if _, ok := err.(*build.NoGoError); ok {
+				absent = append(absent, platform)
+				continue
+			}
+			fatalf("%v", err)
+		}
+		present = append(present, platform)
+		p := parsePackage(pkg.writer, bpkg, pkg.userPath)
+		if len(present) == 1 {
+			pkg.packageClause(false)
+			pkg.toText(p.doc.Doc, "")
+			pkg.newlines(1)
+			if !pkg.showInternals() {
+				return
+			}
+		}
+		p.valueSummary(p.doc.Consts, false)
+		p.valueSummary(p.doc.Vars, false)
+		p.funcSummary(p.doc.Funcs, false)
+		p.typeSummary()
+		lines = mergeLines(lines, strings.Split(strings.TrimSuffix(p.buf.String(), "\n"), "\n"), platform)
+	}
+	if len(present) == 0 {
+		pkg.Fatalf("package %s has no Go files for any of %s", pkg.prettyPath(), strings.Join(platforms, ", "))
+	}
+
+	pkg.newlines(2)
+	if len(absent) > 0 {
+		pkg.Printf("// No Go files for %s.\n\n", strings.Join(absent, ", "))
+	}
+	for _, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		if len(line.platforms) == len(present) {
+			pkg.Printf("%s\n", line.text)
+		} else {
+			pkg.Printf("%s  // %s\n", line.text, strings.Join(line.platforms, ", "))
+		}
+	}
+}
+
+// mergeLines merges the summary lines for a platform into the merged list.
+// Indented lines belong to the preceding unindented line, and a line not
+// already present is inserted after its predecessor for the platform, so
+// lines stay with their parents.
+func mergeLines(merged []*platformLine, lines []string, platform string) []*platformLine {
+	parent := ""
+	prev := -1 // Index in merged of the previous line for this platform.
+	for _, text := range lines {
+		key := text
+		if strings.HasPrefix(text, indent) {
+			key = parent + "\n" + text
+		} else {
+			parent = text
+		}
+		found := -1
+		for i, line := range merged {
+			if line.key == key {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			found = prev + 1
+			merged = append(merged, nil)
+			copy(merged[found+1:], merged[found:])
+			merged[found] = &platformLine{key: key, text: text}
+		}
+		merged[found].platforms = append(merged[found].platforms, platform)
+		prev = found
+	}
+	return merged
+}
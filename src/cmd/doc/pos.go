@@ -0,0 +1,214 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parsePos analyzes the argument of the -pos flag, of the form
+// file:line:column, and returns the package and symbol (possibly with a
+// .method) that the identifier at that position refers to. As in
+// parseArgs, the path is "" if the package is the one in the file's
+// directory. Columns count bytes, starting at 1, as in compiler errors.
+func parsePos(arg string) (pkg *build.Package, path, symbol string) {
+	file, line, col := splitPos(arg)
+	file, err := filepath.Abs(file)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	dir := filepath.Dir(file)
+	bpkg := importDir(dir)
+
+	// Type check the files of the package that holds the file,
+	// so that identifiers can be resolved wherever they are declared.
+	base := filepath.Base(file)
+	names := append(bpkg.GoFiles, bpkg.CgoFiles...)
+	switch {
+	case contains(bpkg.TestGoFiles, base):
+		names = append(names, bpkg.TestGoFiles...)
+	case contains(bpkg.XTestGoFiles, base):
+		names = bpkg.XTestGoFiles
+	case !contains(names, base):
+		failf(errPackage, "%s is not part of package %s", arg, bpkg.Name)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var target *ast.File
+	var src []byte
+	for _, name := range names {
+		data, err := readFile(filepath.Join(dir, name))
+		if err != nil {
+			failf(errParse, "%v", err)
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), data, 0)
+		if err != nil {
+			failf(errParse, "%v", err)
+		}
+		if name == base {
+			target, src = f, data
+		}
+		files = append(files, f)
+	}
+	id := identAt(fset, target, src, line, col)
+	if id == nil {
+		failf(errSymbol, "no identifier at %s", arg)
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer:    importer.Default(),
+		FakeImportC: true,
+		Error:       func(error) {}, // Do the best we can with what type checks.
+	}
+	conf.Check(bpkg.ImportPath, fset, files, info)
+	obj := info.Uses[id]
+	if obj == nil {
+		obj = info.Defs[id]
+	}
+	if obj == nil {
+		failf(errSymbol, "cannot resolve %s at %s", id.Name, arg)
+	}
+
+	importPath, symbol := objectSymbol(obj)
+	if importPath == "" {
+		failf(errSymbol, "no documentation for %s at %s", id.Name, arg)
+	}
+	if importPath == bpkg.ImportPath {
+		return bpkg, "", symbol
+	}
+	pkg, err = buildCtx.Import(importPath, dir, build.ImportComment)
+	if err != nil {
+		failf(errPackage, "%v", err)
+	}
+	return pkg, importPath, symbol
+}
+
+// splitPos splits file:line:column into its parts.
+func splitPos(arg string) (file string, line, col int) {
+	i := strings.LastIndex(arg, ":")
+	j := -1
+	if i > 0 {
+		j = strings.LastIndex(arg[:i], ":")
+	}
+	if j <= 0 {
+		fatalf("invalid position %q; want file:line:column", arg)
+	}
+	line, err1 := strconv.Atoi(arg[j+1 : i])
+	col, err2 := strconv.Atoi(arg[i+1:])
+	if err1 != nil || err2 != nil || line < 1 || col < 1 {
+		fatalf("invalid position %q; want file:line:column", arg)
+	}
+	return arg[:j], line, col
+}
+
+// identAt returns the identifier in f, whose source is src, that covers
+// the given line and column.
+func identAt(fset *token.FileSet, f *ast.File, src []byte, line, col int) *ast.Ident {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return nil
+		}
+		offset += i + 1
+	}
+	offset += col - 1
+	if offset > len(src) {
+		return nil
+	}
+	pos := token.Pos(fset.File(f.Pos()).Base() + offset)
+	var id *ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || id != nil || pos < n.Pos() || n.End() <= pos {
+			return false
+		}
+		if x, ok := n.(*ast.Ident); ok {
+			id = x
+		}
+		return true
+	})
+	return id
+}
+
+// objectSymbol returns the import path of the package that documents obj
+// and the symbol (possibly with a .method) to look up there. An imported
+// package name yields just the path. Fields and local variables are
+// documented by their (named) type. The path is "" if there is nothing
+// to document.
+func objectSymbol(obj types.Object) (path, symbol string) {
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return obj.Imported().Path(), ""
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.Recv() == nil {
+			break
+		}
+		recv := namedType(sig.Recv().Type())
+		if recv == nil {
+			return "", "" // A method of an unnamed interface.
+		}
+		if types.IsInterface(recv) {
+			// Interface methods are documented with their type.
+			return packagePath(recv.Obj()), recv.Obj().Name()
+		}
+		return packagePath(recv.Obj()), recv.Obj().Name() + "." + obj.Name()
+	case *types.Var:
+		if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+			break
+		}
+		named := namedType(obj.Type())
+		if named == nil {
+			return "", ""
+		}
+		return packagePath(named.Obj()), named.Obj().Name()
+	case *types.Label:
+		return "", ""
+	}
+	if obj.Pkg() != nil && obj.Parent() != obj.Pkg().Scope() {
+		return "", "" // Declared inside a function.
+	}
+	return packagePath(obj), obj.Name()
+}
+
+// namedType returns the named type of t, looking through a pointer.
+func namedType(t types.Type) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// packagePath returns the import path of the package declaring obj.
+// Predeclared objects are documented by package builtin.
+func packagePath(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return "builtin"
+	}
+	return obj.Pkg().Path()
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+)
+
+// embeddedTypes returns the names of the types of the package that are
+// embedded in the struct type declared by spec. It must be called before
+// the unexported fields are trimmed from spec.
+func embeddedTypes(spec *ast.TypeSpec) []string {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, field := range st.Fields.List {
+		if len(field.Names) > 0 {
+			continue
+		}
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		// Types of other packages are not in pkg.doc.Types; skip them.
+		if id, ok := typ.(*ast.Ident); ok {
+			names = append(names, id.Name)
+		}
+	}
+	return names
+}
+
+// methodSummary prints a one-line summary for each exported method of
+// the type. If the type embeds others, the summary of each method that is
+// promoted from an embedded type, or that overrides a method of one, says
+// so, to show which behavior the type customizes. The methods of embedded
+// types that are not promoted follow: see shadowedSummary.
+func (pkg *Package) methodSummary(typ *doc.Type, embedded []string) {
+	for _, fun := range typ.Methods {
+		if !isExported(fun.Name) {
+			continue
+		}
+		var notes []string
+		switch {
+		case fun.Level > 0:
+			notes = append(notes, "promoted from "+fun.Orig)
+			pkg.annotate(annotatePromoted)
+		case len(embedded) > 0:
+			if base := pkg.overridden(embedded, fun.Name); base != "" {
+				notes = append(notes, "overrides "+base+"."+fun.Name)
+				pkg.annotate(annotateOverrides)
+			}
+		}
+		pkg.Printf("%s\n", pkg.summaryLine(fun.Decl, notes...))
+	}
+	pkg.shadowedSummary(typ)
+}
+
+// overridden returns the name of the first of the embedded types that has
+// a method with the given name, or "" if none does. The methods of a type
+// include those promoted to it, so embedding at any depth is found.
+func (pkg *Package) overridden(embedded []string, method string) string {
+	for _, name := range embedded {
+		for _, typ := range pkg.doc.Types {
+			if typ.Name != name {
+				continue
+			}
+			for _, fun := range typ.Methods {
+				if fun.Name == method {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "encoding/binary"
+
+// Field numbers and kinds of the messages in doc.proto, the schema of the
+// output of -proto.
+const (
+	protoPackageName       = 1
+	protoPackageImportPath = 2
+	protoPackageDoc        = 3
+	protoPackageSymbols    = 4
+
+	protoSymbolKind      = 1
+	protoSymbolName      = 2
+	protoSymbolSignature = 3
+	protoSymbolDoc       = 4
+	protoSymbolPosition  = 5
+	protoSymbolChildren  = 6
+
+	protoPositionFile   = 1
+	protoPositionLine   = 2
+	protoPositionColumn = 3
+)
+
+// protoKinds maps the kinds of outline items to the values of doc.proto's
+// Kind enum.
+var protoKinds = map[string]uint64{
+	"const":    1,
+	"var":      2,
+	"func":     3,
+	"type":     4,
+	"method":   5,
+	"field":    6,
+	"embedded": 7,
+}
+
+// Wire types of the protocol buffer encoding.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// protoDoc prints the package's outline, with the signatures and doc
+// comments of its symbols, as a Package message of doc.proto in the
+// protocol buffer binary format.
+func (pkg *Package) protoDoc() {
+	defer pkg.flush()
+	o := pkg.outline()
+	var m protoMessage
+	m.string(protoPackageName, o.Name)
+	m.string(protoPackageImportPath, o.ImportPath)
+	m.string(protoPackageDoc, pkg.doc.Doc)
+	for _, item := range o.Items {
+		m.message(protoPackageSymbols, protoSymbol(item))
+	}
+	pkg.buf.Write(m)
+}
+
+// protoSymbol returns the Symbol message for the outline item.
+func protoSymbol(item *outlineItem) protoMessage {
+	var pos protoMessage
+	pos.string(protoPositionFile, item.File)
+	pos.varint(protoPositionLine, uint64(item.Line))
+	pos.varint(protoPositionColumn, uint64(item.Column))
+	var m protoMessage
+	m.varint(protoSymbolKind, protoKinds[item.Kind])
+	m.string(protoSymbolName, item.Name)
+	m.string(protoSymbolSignature, item.signature)
+	m.string(protoSymbolDoc, item.doc)
+	m.message(protoSymbolPosition, pos)
+	for _, child := range item.Children {
+		m.message(protoSymbolChildren, protoSymbol(child))
+	}
+	return m
+}
+
+// A protoMessage is a message being encoded. As in proto3, fields with
+// zero values are left out.
+type protoMessage []byte
+
+func (m *protoMessage) key(num, wire int) {
+	m.uvarint(uint64(num<<3 | wire))
+}
+
+func (m *protoMessage) uvarint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	*m = append(*m, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+func (m *protoMessage) varint(num int, x uint64) {
+	if x != 0 {
+		m.key(num, wireVarint)
+		m.uvarint(x)
+	}
+}
+
+func (m *protoMessage) string(num int, s string) {
+	if s != "" {
+		m.key(num, wireBytes)
+		m.uvarint(uint64(len(s)))
+		*m = append(*m, s...)
+	}
+}
+
+func (m *protoMessage) message(num int, sub protoMessage) {
+	m.key(num, wireBytes)
+	m.uvarint(uint64(len(sub)))
+	*m = append(*m, sub...)
+}
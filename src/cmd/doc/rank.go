@@ -0,0 +1,205 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Classes of the directories a partial package path matches, best first,
+// as rankPackages orders them.
+const (
+	rankStd     = iota // In the standard library.
+	rankCurrent        // In the repository holding the current directory.
+	rankOther          // Elsewhere in GOPATH.
+	rankVendor         // Vendored, and so importable only beneath its parent.
+)
+
+// rankPackages sorts the directories of the packages a partial package
+// path matches, best first: those whose import paths match the earliest
+// of the patterns preferred by -prefer and $GODOCPREFER, then, with
+// -roots, those in the earliest of its roots, then by class, then those
+// with the fewest elements in their paths, then the most recently
+// modified, then lexically.
+func rankPackages(dirs []string) {
+	type ranked struct {
+		dir    string
+		prefer int
+		root   int
+		class  int
+		depth  int
+		mtime  time.Time
+	}
+	prefer := preferPatterns()
+	list := make([]ranked, len(dirs))
+	for i, dir := range dirs {
+		r := ranked{dir: dir, prefer: preference(dir, prefer), root: rootRank(dir), class: packageClass(dir)}
+		if rel, ok := srcRelative(dir); ok {
+			r.depth = strings.Count(rel, "/") + 1
+		}
+		if fi, err := fileSystem.Stat(dir); err == nil {
+			r.mtime = fi.ModTime()
+		}
+		list[i] = r
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.prefer != b.prefer:
+			return a.prefer < b.prefer
+		case a.root != b.root:
+			return a.root < b.root
+		case a.class != b.class:
+			return a.class < b.class
+		case a.depth != b.depth:
+			return a.depth < b.depth
+		case !a.mtime.Equal(b.mtime):
+			return a.mtime.After(b.mtime)
+		}
+		return a.dir < b.dir
+	})
+	for i, r := range list {
+		dirs[i] = r.dir
+	}
+}
+
+// outranks reports whether the package in directory a is preferred to
+// that in b, or failing that is in an earlier root of -roots or a better
+// class, so that a partial path matching both is not ambiguous.
+func outranks(a, b string) bool {
+	prefer := preferPatterns()
+	if pa, pb := preference(a, prefer), preference(b, prefer); pa != pb {
+		return pa < pb
+	}
+	if ra, rb := rootRank(a), rootRank(b); ra != rb {
+		return ra < rb
+	}
+	return packageClass(a) < packageClass(b)
+}
+
+// preferPatterns returns matchers for the patterns of the import paths to
+// prefer, from -prefer and then $GODOCPREFER, space-separated. As with
+// matchPattern, ... matches any string; a pattern without it, or ending
+// in /*, matches the import paths it is a prefix of, element by element.
+func preferPatterns() []func(string) bool {
+	var matchers []func(string) bool
+	for _, pattern := range append(strings.Fields(prefer), strings.Fields(os.Getenv("GODOCPREFER"))...) {
+		pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "*"), "/")
+		if !strings.Contains(pattern, "...") {
+			pattern += "/..."
+		}
+		matchers = append(matchers, matchPattern(pattern))
+	}
+	return matchers
+}
+
+// preference returns the index of the first of the preferred patterns
+// the import path of the package in dir matches, or the number of them.
+func preference(dir string, prefer []func(string) bool) int {
+	rel, ok := srcRelative(dir)
+	if ok {
+		for i, match := range prefer {
+			if match(rel) {
+				return i
+			}
+		}
+	}
+	return len(prefer)
+}
+
+// searchRoots returns the roots whose trees are searched for the packages
+// partial paths match, in order: those -roots lists, separated as in
+// $GOPATH, each of which must be GOROOT or a GOPATH entry, or by default
+// GOROOT and then the GOPATH entries.
+func searchRoots() []string {
+	all := append([]string{buildCtx.GOROOT}, splitGopath()...)
+	if roots == "" {
+		return all
+	}
+	var list []string
+	for _, root := range filepath.SplitList(roots) {
+		if root == "" {
+			continue
+		}
+		known := false
+		for _, r := range all {
+			if filepath.Clean(root) == filepath.Clean(r) {
+				root, known = r, true
+				break
+			}
+		}
+		if !known {
+			fatalf("-roots: %s is neither GOROOT nor in GOPATH", root)
+		}
+		list = append(list, root)
+	}
+	return list
+}
+
+// rootRank returns, with -roots, the index of the root listed holding the
+// directory, or the number of them. Without it, all directories rank
+// alike.
+func rootRank(dir string) int {
+	if roots == "" {
+		return 0
+	}
+	list := searchRoots()
+	for i, root := range list {
+		if inDir(dir, filepath.Join(root, "src")) {
+			return i
+		}
+	}
+	return len(list)
+}
+
+// rootOf returns the GOROOT or GOPATH entry holding the directory, or "".
+func rootOf(dir string) string {
+	for _, root := range append([]string{buildCtx.GOROOT}, splitGopath()...) {
+		if inDir(dir, filepath.Join(root, "src")) {
+			return root
+		}
+	}
+	return ""
+}
+
+// packageClass returns the class of the package's directory.
+func packageClass(dir string) int {
+	var current string // The top of the repository, if any.
+	if r := gitRepo(pwd()); r != nil {
+		current = r.root
+	}
+	rel, _ := srcRelative(dir)
+	switch {
+	case strings.HasPrefix(rel, "vendor/") || strings.Contains(rel, "/vendor/"):
+		return rankVendor
+	case inDir(dir, filepath.Join(buildCtx.GOROOT, "src")):
+		return rankStd
+	case current != "" && inDir(dir, current):
+		return rankCurrent
+	}
+	return rankOther
+}
+
+// srcRelative returns the slash-separated path of the directory relative
+// to the src directory of GOROOT or the GOPATH entry holding it.
+func srcRelative(dir string) (string, bool) {
+	for _, root := range append([]string{buildCtx.GOROOT}, splitGopath()...) {
+		src := filepath.Join(root, "src")
+		if inDir(dir, src) {
+			rel, err := filepath.Rel(src, dir)
+			return filepath.ToSlash(rel), err == nil
+		}
+	}
+	return "", false
+}
+
+// inDir reports whether the path is the directory dir or beneath it.
+func inDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
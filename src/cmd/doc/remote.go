@@ -0,0 +1,326 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// For the -remote flag, a module is downloaded through the proxies named
+// by $GOPROXY, using the protocol the go command does, and read from its
+// zip file as -zip reads one. The go command checks the zip files in its
+// module cache against go.sum and the checksum database, which go doc
+// does not, so a download is kept in go doc's own cache beneath $GOCACHE
+// instead, and a zip file of the module cache is read only once the go
+// command has verified it. Nothing else is touched: no go.mod file is
+// read or written, so a module can be evaluated without requiring it.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// defaultProxy is the setting of $GOPROXY when it is unset.
+const defaultProxy = "https://proxy.golang.org,direct"
+
+// errNotFound is the error a proxy gives, with status 404 or 410, for a
+// module or version it does not have, after which the next is asked.
+type errNotFound struct {
+	url string
+}
+
+func (e errNotFound) Error() string {
+	return fmt.Sprintf("%s: not found", e.url)
+}
+
+// httpClient fetches from the proxies. It reads file:// addresses as well,
+// as the go command does, for proxies held in directories.
+var httpClient = func() *http.Client {
+	t := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: t}
+}()
+
+// remoteArgs downloads the module holding the package named by the first
+// of the arguments, a package path perhaps followed by @version and, if
+// it has none, by .symbol and .method, and returns the name of its zip
+// file in the cache and the arguments with the @version removed. Without
+// a version, or with a query such as latest or a branch, the version
+// the proxy resolves it to is used, and reported on standard error.
+func remoteArgs(args []string) (file string, rest []string) {
+	if len(args) == 0 {
+		fatalf("-remote needs a package path")
+	}
+	arg, version := args[0], ""
+	if i := strings.Index(arg, "@"); i >= 0 {
+		arg, version = arg[:i], arg[i+1:]
+		if version == "" {
+			fatalf("-remote %s: empty version", args[0])
+		}
+	}
+	rest = append([]string{arg}, args[1:]...)
+	for _, mod := range modulePaths(arg) {
+		v, file, ok := downloadModule(mod, version)
+		if !ok {
+			continue
+		}
+		if v != version {
+			log.Printf("%s: using %s@%s", args[0], mod, v)
+		}
+		return file, rest
+	}
+	failf(errPackage, "no module holds %s%s", arg, atVersion(version))
+	return "", nil
+}
+
+// atVersion returns @version, or "" for no version.
+func atVersion(version string) string {
+	if version == "" {
+		return ""
+	}
+	return "@" + version
+}
+
+// modulePaths returns the module paths that may hold the package named by
+// arg, longest first: arg, and arg cut at each slash or period after its
+// first element, which must hold a period, as a host name does. The cuts
+// at periods allow for a symbol following the path.
+func modulePaths(arg string) []string {
+	slash := strings.Index(arg, "/")
+	if slash < 0 || !strings.Contains(arg[:slash], ".") {
+		return []string{arg}
+	}
+	paths := []string{arg}
+	for i := len(arg) - 1; i > slash; i-- {
+		if arg[i] == '/' || arg[i] == '.' && strings.LastIndex(arg, "/") < i {
+			paths = append(paths, arg[:i])
+		}
+	}
+	return paths
+}
+
+// downloadModule returns the version of the module the version query
+// resolves to and the name of the module's zip file in the cache,
+// downloading it if it is not already there. The boolean is false if the
+// proxies know no such module or version.
+func downloadModule(mod, query string) (version, file string, ok bool) {
+	esc, err := escapeModulePath(mod)
+	if err != nil {
+		tracef("%s is not a module path: %v", mod, err)
+		return "", "", false
+	}
+	// A version given exactly is used from the cache without asking the
+	// proxies, so that a module downloaded once can be read offline.
+	if query != "" && query != "latest" {
+		if ev, err := escapeVersion(query); err == nil {
+			if file, ok := cachedZip(esc, ev); ok {
+				return query, file, true
+			}
+		}
+	}
+	info := "@latest"
+	if query != "" && query != "latest" {
+		ev, err := escapeVersion(query)
+		if err != nil {
+			fatalf("-remote %s@%s: %v", mod, query, err)
+		}
+		info = "@v/" + ev + ".info"
+	}
+	data, err := fromProxies(esc + "/" + info)
+	if _, notFound := err.(errNotFound); notFound {
+		tracef("%s: %v", mod, err)
+		return "", "", false
+	}
+	if err != nil {
+		fatalf("-remote %s%s: %v", mod, atVersion(query), err)
+	}
+	var v struct{ Version string }
+	if err := json.Unmarshal(data, &v); err != nil || v.Version == "" {
+		fatalf("-remote %s%s: invalid version information from proxy", mod, atVersion(query))
+	}
+	ev, err := escapeVersion(v.Version)
+	if err != nil {
+		fatalf("-remote %s%s: proxy gave %v", mod, atVersion(query), err)
+	}
+	if file, ok := cachedZip(esc, ev); ok {
+		return v.Version, file, true
+	}
+	dir := docCacheDir()
+	if dir == "" {
+		fatalf("-remote: no cache to download into: GOCACHE is off")
+	}
+	vdir := filepath.Join(dir, "download", filepath.FromSlash(esc), "@v")
+	file = filepath.Join(vdir, ev+".zip")
+	zip, err := fromProxies(esc + "/@v/" + ev + ".zip")
+	if err != nil {
+		fatalf("-remote %s@%s: %v", mod, v.Version, err)
+	}
+	if err := writeCacheFile(file, zip); err != nil {
+		fatalf("-remote %s@%s: %v", mod, v.Version, err)
+	}
+	writeCacheFile(filepath.Join(vdir, ev+".info"), data)
+	return v.Version, file, true
+}
+
+// cachedZip returns the name of the zip file of the module, given by its
+// escaped path, at the escaped version, if the go command's module cache
+// holds it, verified, or go doc's own cache holds it. The go command
+// writes the hash of a zip file beside it once the zip is verified.
+func cachedZip(esc, ev string) (string, bool) {
+	if dir := moduleCacheDir(); dir != "" {
+		file := filepath.Join(dir, "cache", "download", filepath.FromSlash(esc), "@v", ev+".zip")
+		if _, err := os.Stat(file + "hash"); err == nil {
+			tracef("using %s from the module cache", file)
+			return file, true
+		}
+	}
+	if dir := docCacheDir(); dir != "" {
+		file := filepath.Join(dir, "download", filepath.FromSlash(esc), "@v", ev+".zip")
+		if _, err := os.Stat(file); err == nil {
+			tracef("using %s from the cache", file)
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// fromProxies fetches the file at the path, such as rsc.io/quote/@latest,
+// from the first of the proxies $GOPROXY lists that has it. As for the go
+// command, the proxies are separated by commas, after which the next is
+// asked only if one has no such file, or by pipes, after which it is asked
+// after any error. Direct access to repositories is not supported, so
+// direct entries are skipped; off ends the list.
+func fromProxies(path string) ([]byte, error) {
+	list := goEnv("GOPROXY", defaultProxy)
+	var err error = errNotFound{path}
+	for list != "" {
+		proxy, anyErr := list, false
+		if i := strings.IndexAny(list, ",|"); i >= 0 {
+			proxy, anyErr, list = list[:i], list[i] == '|', list[i+1:]
+		} else {
+			list = ""
+		}
+		switch proxy = strings.TrimSpace(proxy); proxy {
+		case "":
+			continue
+		case "direct":
+			tracef("skipping GOPROXY entry direct: only proxies are supported")
+			continue
+		case "off":
+			return nil, fmt.Errorf("module lookup disabled by GOPROXY=off")
+		}
+		var data []byte
+		data, err = fetch(strings.TrimSuffix(proxy, "/") + "/" + path)
+		if err == nil {
+			return data, nil
+		}
+		if _, notFound := err.(errNotFound); !notFound && !anyErr {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// fetch returns the contents of the file at the address.
+func fetch(url string) ([]byte, error) {
+	tracef("fetching %s", url)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, errNotFound{url}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// moduleCacheDir returns the directory of the module cache: $GOMODCACHE,
+// or pkg/mod in the first entry of GOPATH, as for the go command. It
+// returns "" if there is neither.
+func moduleCacheDir() string {
+	if dir := goEnv("GOMODCACHE", ""); dir != "" {
+		return dir
+	}
+	if list := splitGopath(); len(list) > 0 && list[0] != "" {
+		return filepath.Join(list[0], "pkg", "mod")
+	}
+	return ""
+}
+
+// writeCacheFile writes the file whole and renames it into place, so that
+// another process never reads part of it.
+func writeCacheFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// escapeModulePath returns the module path as the proxy protocol and the
+// module cache spell it, with each upper case letter replaced by an
+// exclamation mark and the letter in lower case, so that paths differing
+// only in case are told apart on file systems that ignore case.
+func escapeModulePath(path string) (string, error) {
+	if path == "" || strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return "", fmt.Errorf("malformed module path %q", path)
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "." || elem == ".." {
+			return "", fmt.Errorf("malformed module path %q", path)
+		}
+	}
+	return escapeCase(path)
+}
+
+// escapeVersion returns the version as the proxy protocol spells it. A
+// version may not name a directory or hold a slash.
+func escapeVersion(version string) (string, error) {
+	if version == "" || version == "." || version == ".." || strings.ContainsAny(version, "/\\") {
+		return "", fmt.Errorf("malformed version %q", version)
+	}
+	return escapeCase(version)
+}
+
+// escapeCase replaces each upper case letter of s by an exclamation mark
+// and the letter in lower case. It fails for an exclamation mark or a
+// character other than printing ASCII.
+func escapeCase(s string) (string, error) {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '!' || r <= ' ' || r >= 0x7f:
+			return "", fmt.Errorf("invalid character %q in %q", r, s)
+		case unicode.IsUpper(r):
+			b.WriteByte('!')
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
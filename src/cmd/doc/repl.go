@@ -0,0 +1,243 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build"
+	"io"
+	"strings"
+)
+
+// A replPlace is where a query of -repl left off: a package and, within
+// it, perhaps a symbol and a method.
+type replPlace struct {
+	pkg    *Package
+	symbol string
+	method string
+}
+
+// A replState is the state carried between the queries of -repl: the
+// packages loaded so far, which are not parsed again, and a stack of
+// places, one for each package queried, the last of which is the one
+// relative queries start from.
+type replState struct {
+	writer   io.Writer
+	packages map[string]*Package // By directory.
+	places   []replPlace
+}
+
+// inREPL is set while -repl answers queries.
+var inREPL bool
+
+// runREPL reads queries from in, one to a line, and prints their
+// documentation to w, for the -repl flag. A query is a package, as for
+// go doc, perhaps followed by .symbol and .method; .name, which looks up
+// a symbol in the current package or a method of the current type; or
+// .., which goes up from a method to its type, from a symbol to its
+// package, and from a package back to the one queried before it. If
+// there is an argument, it is the first query. A query that fails, as
+// for a package that does not parse, prints its error, and the session
+// goes on.
+func runREPL(w io.Writer, in io.Reader, args []string) error {
+	defer func(f func(string, ...interface{})) {
+		inREPL, fatalf = false, f
+	}(fatalf)
+	inREPL, fatalf = true, panicf
+	r := &replState{writer: w, packages: make(map[string]*Package)}
+	if len(args) > 0 {
+		if err := r.run(strings.Join(args, ".")); err != nil {
+			fmt.Fprintln(w, err)
+		}
+	}
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(w, r.prompt())
+		if !scanner.Scan() {
+			fmt.Fprintln(w)
+			return scanner.Err()
+		}
+		if err := r.run(strings.TrimSpace(scanner.Text())); err != nil {
+			fmt.Fprintln(w, err)
+		}
+	}
+}
+
+// run runs the query, as query does, returning its error, if any,
+// including one reported by failf or fatalf.
+func (r *replState) run(q string) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			pkgError, ok := e.(PackageError)
+			if !ok {
+				panic(e)
+			}
+			err = pkgError
+		}
+	}()
+	return r.query(q)
+}
+
+// prompt returns the prompt, which names the current place.
+func (r *replState) prompt() string {
+	if len(r.places) == 0 {
+		return "> "
+	}
+	p := r.places[len(r.places)-1]
+	name := p.pkg.name
+	if p.symbol != "" {
+		name += "." + p.symbol
+	}
+	if p.method != "" {
+		name += "." + p.method
+	}
+	return name + "> "
+}
+
+// query runs a query and prints its documentation. A package query
+// pushes a new place; a relative query or .. moves the current one,
+// and .. at a package pops it, returning to the previous package.
+func (r *replState) query(q string) error {
+	var from replPlace
+	relative := len(q) > 1 && q[0] == '.' && q[1] != '.' && q[1] != '/'
+	switch {
+	case q == "":
+		return nil
+	case q == "..":
+		if len(r.places) == 0 {
+			return nil
+		}
+		p := &r.places[len(r.places)-1]
+		switch {
+		case p.method != "":
+			p.method = ""
+		case p.symbol != "":
+			p.symbol = ""
+		default:
+			r.places = r.places[:len(r.places)-1]
+		}
+		return nil
+	case relative:
+		if len(r.places) == 0 {
+			return fmt.Errorf("no current package for %s", q)
+		}
+		from = r.places[len(r.places)-1]
+		q = q[1:]
+	default:
+		path, rest := q, ""
+		slash := strings.LastIndex(q, "/")
+		if dot := strings.Index(q[slash+1:], "."); dot > 0 {
+			path, rest = q[:slash+1+dot], q[slash+1+dot+1:]
+		}
+		if isUpper(q) {
+			// As for go doc, a symbol in the current directory.
+			path, rest = ".", q
+		}
+		pkg, err := r.load(path)
+		if err != nil {
+			return err
+		}
+		from = replPlace{pkg: pkg}
+		q = rest
+	}
+	to := from
+	var text []byte
+	if q == "" {
+		text, _ = r.show(to)
+	} else {
+		for _, name := range strings.Split(q, ".") {
+			var err error
+			if to, text, err = r.step(to, name); err != nil {
+				return err
+			}
+		}
+	}
+	if relative {
+		r.places[len(r.places)-1] = to
+	} else {
+		r.places = append(r.places, to)
+	}
+	r.writer.Write(text)
+	return nil
+}
+
+// step returns the place reached by name from p, with its documentation:
+// a symbol of p's package if p is a package, a method of p's type if p is
+// a symbol or method, and otherwise a symbol of the package, such as a
+// constructor of p's type.
+func (r *replState) step(p replPlace, name string) (replPlace, []byte, error) {
+	if p.symbol != "" {
+		next := replPlace{pkg: p.pkg, symbol: p.symbol, method: name}
+		if text, ok := r.show(next); ok {
+			return next, text, nil
+		}
+	}
+	next := replPlace{pkg: p.pkg, symbol: name}
+	text, ok := r.show(next)
+	if !ok {
+		if p.symbol != "" {
+			return p, nil, fmt.Errorf("no method or symbol %s for %s.%s", name, p.pkg.name, p.symbol)
+		}
+		return p, nil, fmt.Errorf("no symbol %s in package %s", name, p.pkg.prettyPath())
+	}
+	return next, text, nil
+}
+
+// show returns the documentation for the place, and reports whether
+// there is any.
+func (r *replState) show(p replPlace) (text []byte, ok bool) {
+	pkg := p.pkg
+	var buf bytes.Buffer
+	saveWriter, saveUnexported := pkg.writer, unexported
+	pkg.writer = &buf
+	defer func() {
+		pkg.writer, unexported = saveWriter, saveUnexported
+		if e := recover(); e != nil {
+			if _, isPkgErr := e.(PackageError); !isPkgErr {
+				panic(e)
+			}
+			text, ok = nil, false
+		}
+	}()
+	// As for go doc, the symbols of builtin are always shown.
+	if pkg.build.ImportPath == "builtin" {
+		unexported = true
+	}
+	switch {
+	case p.symbol == "":
+		pkg.packageDoc()
+		ok = true
+	case p.method == "":
+		ok = pkg.symbolDoc(p.symbol)
+	default:
+		ok = pkg.methodDoc(p.symbol, p.method)
+	}
+	pkg.flush()
+	return buf.Bytes(), ok
+}
+
+// load returns the package for path, a full or partial package path as
+// for go doc, parsing it if it has not been loaded already.
+func (r *replState) load(path string) (*Package, error) {
+	bpkg, err := buildCtx.Import(path, pwd(), build.ImportComment)
+	if err != nil {
+		dirs.Reset()
+		dir, ok := findPackage(path)
+		if !ok {
+			return nil, fmt.Errorf("no such package %s", path)
+		}
+		if bpkg, err = buildCtx.ImportDir(dir, build.ImportComment); err != nil {
+			return nil, err
+		}
+	}
+	if pkg := r.packages[bpkg.Dir]; pkg != nil {
+		return pkg, nil
+	}
+	pkg := parsePackage(r.writer, bpkg, path)
+	r.packages[bpkg.Dir] = pkg
+	return pkg, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc"
+	"go/importer"
+	"go/types"
+	"strings"
+)
+
+// An embedding is a type embedded, directly or through other embedded
+// types, in a struct type, at a depth of 1 or more.
+type embedding struct {
+	named *types.Named
+	depth int
+}
+
+// shadowedSummary prints, after the summaries of the methods of the
+// struct type, a one-line summary of each exported method of the types
+// it embeds, at any depth, that is not promoted to it, with a note saying
+// why: a field or method of the same name at a shallower depth shadows
+// it, or another at the same depth makes the name ambiguous. The
+// package's files are type checked to find them, if the type embeds any.
+func (pkg *Package) shadowedSummary(typ *doc.Type) {
+	if !embedsTypes(pkg.findTypeSpec(typ.Decl, typ.Name)) {
+		return
+	}
+	tpkg := pkg.typeCheck()
+	named, ok := tpkg.Scope().Lookup(typ.Name).(*types.TypeName)
+	if !ok {
+		return
+	}
+	t, ok := named.Type().(*types.Named)
+	if !ok {
+		return
+	}
+	qualifier := func(p *types.Package) string {
+		if p == tpkg {
+			return ""
+		}
+		return p.Name()
+	}
+	embeddings := embeddedNamed(t)
+	for _, e := range embeddings {
+		for _, m := range methodsOf(e.named) {
+			if !isExported(m.Name()) {
+				continue
+			}
+			obj, index, _ := types.LookupFieldOrMethod(t, true, tpkg, m.Name())
+			var note string
+			switch {
+			case obj == m:
+				continue // Promoted.
+			case obj != nil:
+				by := ownerName(t, index, qualifier) + "." + obj.Name()
+				if _, ok := obj.(*types.Var); ok {
+					by = "field " + by
+				}
+				note = "shadowed by " + by
+			case index != nil && len(index)-1 < e.depth:
+				// The name is ambiguous at a shallower depth.
+				others := ambiguousWith(embeddings, len(index)-1, nil, m.Name(), qualifier)
+				note = "shadowed by " + strings.Join(others, " and ")
+			case index != nil:
+				note = "not promoted: ambiguous"
+				if others := ambiguousWith(embeddings, e.depth, e.named, m.Name(), qualifier); len(others) > 0 {
+					note += " with " + strings.Join(others, ", ")
+				}
+			default:
+				continue
+			}
+			pkg.Printf("%s  // %s\n", methodString(m, qualifier), note)
+			pkg.annotate(annotateShadowed)
+		}
+	}
+}
+
+// embedsTypes reports whether the type declared by spec is a struct with
+// an embedded field.
+func embedsTypes(spec *ast.TypeSpec) bool {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// typeCheck type checks the package's files, parsed again, since go doc
+// may have trimmed the syntax trees, and returns the package. Errors,
+// such as imports that cannot be found, are ignored, so that what can be
+// checked is.
+func (pkg *Package) typeCheck() *types.Package {
+	fset, sources := pkg.sourceFiles()
+	var files []*ast.File
+	for _, f := range sources {
+		files = append(files, f.file)
+	}
+	conf := types.Config{
+		Importer:    depImporter{importer.Default(), pkg.build.Dir},
+		FakeImportC: true,
+		Error:       func(error) {}, // Do the best we can with what type checks.
+	}
+	tpkg, _ := conf.Check(pkg.build.ImportPath, fset, files, nil)
+	return tpkg
+}
+
+// embeddedNamed returns the named types embedded in the struct type t, in
+// order of depth, each at the shallowest depth at which it is embedded, as
+// the search for a field or method goes through them.
+func embeddedNamed(t *types.Named) []embedding {
+	var list []embedding
+	seen := map[*types.Named]bool{t: true}
+	next := []*types.Named{t}
+	for depth := 1; len(next) > 0; depth++ {
+		var level []*types.Named
+		for _, n := range next {
+			st, ok := n.Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			for i := 0; i < st.NumFields(); i++ {
+				f := st.Field(i)
+				if !f.Anonymous() {
+					continue
+				}
+				ft := f.Type()
+				if p, ok := ft.(*types.Pointer); ok {
+					ft = p.Elem()
+				}
+				if e, ok := ft.(*types.Named); ok && !seen[e] {
+					seen[e] = true
+					level = append(level, e)
+					list = append(list, embedding{e, depth})
+				}
+			}
+		}
+		next = level
+	}
+	return list
+}
+
+// methodsOf returns the methods declared by the named type or, for an
+// interface, those of its method set.
+func methodsOf(n *types.Named) []*types.Func {
+	var methods []*types.Func
+	if iface, ok := n.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			methods = append(methods, iface.Method(i))
+		}
+		return methods
+	}
+	for i := 0; i < n.NumMethods(); i++ {
+		methods = append(methods, n.Method(i))
+	}
+	return methods
+}
+
+// ownerName returns the name of the type in whose declaration the field
+// or method that index, as given by types.LookupFieldOrMethod for t,
+// leads to is declared: t itself, or the type of the last embedded field
+// on the way to it.
+func ownerName(t *types.Named, index []int, qualifier types.Qualifier) string {
+	owner := types.Type(t)
+	for _, i := range index[:len(index)-1] {
+		st, ok := owner.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		owner = st.Field(i).Type()
+		if p, ok := owner.(*types.Pointer); ok {
+			owner = p.Elem()
+		}
+	}
+	return types.TypeString(owner, qualifier)
+}
+
+// ambiguousWith returns the other fields and methods with the name of the
+// types embedded at the depth, as Type.name, apart from those of skip.
+func ambiguousWith(embeddings []embedding, depth int, skip *types.Named, name string, qualifier types.Qualifier) []string {
+	var others []string
+	for _, e := range embeddings {
+		if e.depth != depth || e.named == skip {
+			continue
+		}
+		obj, index, _ := types.LookupFieldOrMethod(e.named, true, e.named.Obj().Pkg(), name)
+		if obj != nil && len(index) == 1 {
+			others = append(others, types.TypeString(e.named, qualifier)+"."+name)
+		}
+	}
+	return others
+}
+
+// methodString returns the method's declaration on one line, as a summary
+// shows it, with the names of types of other packages qualified.
+func methodString(m *types.Func, qualifier types.Qualifier) string {
+	sig := m.Type().(*types.Signature)
+	var b bytes.Buffer
+	b.WriteString("func (")
+	b.WriteString(types.TypeString(sig.Recv().Type(), qualifier))
+	b.WriteString(") ")
+	b.WriteString(m.Name())
+	types.WriteSignature(&b, sig, qualifier)
+	return b.String()
+}
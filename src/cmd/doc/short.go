@@ -0,0 +1,63 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/doc"
+)
+
+// shortDoc prints, for the -short flag, the one-line summary of each
+// declaration that symbolDoc or methodDoc would show for the symbol and
+// method, one to a line, and nothing else: no package clause and no doc
+// comments. It reports whether there were any.
+func (pkg *Package) shortDoc(symbol, method string) bool {
+	defer pkg.flush()
+	var lines []string
+	if method == "" {
+		for _, fun := range pkg.findFuncs(symbol) {
+			lines = append(lines, pkg.oneLineNode(fun.Decl))
+		}
+		for _, values := range [][]*doc.Value{pkg.doc.Consts, pkg.doc.Vars} {
+			for _, value := range values {
+				for _, name := range value.Names {
+					if match(symbol, name) {
+						node, _ := valueDecl([]*doc.Value{value}, name)
+						lines = append(lines, pkg.oneLineNode(node))
+					}
+				}
+			}
+		}
+		for _, typ := range pkg.findTypes(symbol) {
+			lines = append(lines, pkg.oneLineNode(pkg.findTypeSpec(typ.Decl, typ.Name)))
+		}
+		if len(lines) == 0 {
+			lines = pkg.shortMethods("", symbol)
+		}
+	} else {
+		lines = pkg.shortMethods(symbol, method)
+	}
+	for _, line := range lines {
+		pkg.Printf("%s\n", line)
+	}
+	return len(lines) > 0
+}
+
+// shortMethods returns the one-line summaries of the methods of the types
+// matching symbol, or of all types if it is empty, that match method,
+// those of interfaces included.
+func (pkg *Package) shortMethods(symbol, method string) []string {
+	var lines []string
+	for _, typ := range pkg.findTypes(symbol) {
+		for _, meth := range typ.Methods {
+			if match(method, meth.Name) {
+				lines = append(lines, pkg.oneLineNode(meth.Decl))
+			}
+		}
+		for _, meth := range pkg.interfaceMethodFuncs(typ, method) {
+			lines = append(lines, pkg.oneLineNode(meth.Decl))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/doc"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A signatureHelp is a function or method as -sighelp describes it, for
+// an editor's signature help: its declaration on one line, the first
+// sentence of its doc comment and its parameters.
+type signatureHelp struct {
+	Label      string
+	Doc        string
+	Parameters []signatureParam
+}
+
+// A signatureParam is a parameter of a signatureHelp. A parameter list
+// such as (x, y int) gives a parameter for each name.
+type signatureParam struct {
+	Name string `json:",omitempty"`
+	Type string
+	Doc  string `json:",omitempty"` // The first sentence of the doc comment naming it.
+}
+
+// signatureDoc prints, for the -sighelp flag, the signatures of the
+// functions, or given a method the methods, that the symbol matches, as a
+// JSON array. As with symbolDoc, a symbol that matches no function is
+// taken to be the name of a method. It reports whether there were any.
+func (pkg *Package) signatureDoc(symbol, method string) bool {
+	defer pkg.flush()
+	var sigs []signatureHelp
+	if method == "" {
+		for _, fun := range pkg.findFuncs(symbol) {
+			sigs = append(sigs, pkg.signatureHelp(fun.Decl, fun.Doc))
+		}
+		if len(sigs) == 0 {
+			sigs = pkg.methodSignatures("", symbol)
+		}
+	} else {
+		sigs = pkg.methodSignatures(symbol, method)
+	}
+	if len(sigs) == 0 {
+		return false
+	}
+	data, err := json.MarshalIndent(sigs, "", "\t")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	pkg.buf.Write(data)
+	pkg.buf.WriteString("\n")
+	return true
+}
+
+// methodSignatures returns the signatures of the methods, those of
+// interfaces included, of the types matching symbol, or of all types if it
+// is empty, that match method.
+func (pkg *Package) methodSignatures(symbol, method string) []signatureHelp {
+	var sigs []signatureHelp
+	for _, typ := range pkg.findTypes(symbol) {
+		for _, meth := range typ.Methods {
+			if match(method, meth.Name) {
+				sigs = append(sigs, pkg.signatureHelp(meth.Decl, meth.Doc))
+			}
+		}
+		for _, meth := range pkg.interfaceMethodFuncs(typ, method) {
+			sigs = append(sigs, pkg.signatureHelp(meth.Decl, meth.Doc))
+		}
+	}
+	return sigs
+}
+
+// signatureHelp returns the signature of the function declaration, whose
+// doc comment is comment.
+func (pkg *Package) signatureHelp(decl *ast.FuncDecl, comment string) signatureHelp {
+	sentences := docSentences(comment)
+	sig := signatureHelp{
+		Label:      pkg.oneLineNode(decl),
+		Doc:        doc.Synopsis(comment),
+		Parameters: []signatureParam{},
+	}
+	if decl.Type.Params == nil {
+		return sig
+	}
+	for _, field := range decl.Type.Params.List {
+		typ := pkg.oneLineNode(field.Type)
+		if len(field.Names) == 0 {
+			sig.Parameters = append(sig.Parameters, signatureParam{Type: typ})
+			continue
+		}
+		for _, name := range field.Names {
+			param := signatureParam{Name: name.Name, Type: typ}
+			for _, s := range sentences {
+				if name.Name != "_" && hasWord(s, name.Name) {
+					param.Doc = s
+					break
+				}
+			}
+			sig.Parameters = append(sig.Parameters, param)
+		}
+	}
+	return sig
+}
+
+// docSentences splits the doc comment into sentences, each on one line,
+// where doc.Synopsis would end the first: at a period followed by white
+// space, unless it follows a single upper case letter, as in an initial.
+func docSentences(comment string) []string {
+	text := strings.Join(strings.Fields(comment), " ")
+	var sentences []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '.' || i+1 < len(text) && text[i+1] != ' ' {
+			continue
+		}
+		if i >= 1 && 'A' <= text[i-1] && text[i-1] <= 'Z' && (i == 1 || text[i-2] == ' ') {
+			continue
+		}
+		sentences = append(sentences, strings.TrimSpace(text[start:i+1]))
+		start = i + 1
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// hasWord reports whether the name appears in the text as a word of its
+// own, not part of a longer identifier or a contraction such as it's.
+func hasWord(text, name string) bool {
+	isWord := func(r rune) bool {
+		return r == '_' || r == '\'' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; ; {
+		j := strings.Index(text[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		before := start == 0 || !isWord(prev)
+		after := end == len(text) || !isWord(next)
+		if before && after {
+			return true
+		}
+		i = start + 1
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/doc"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A page is one file of split output: the documentation for a single
+// top-level symbol, or for a type together with its associated
+// constants, variables, constructors and methods.
+type page struct {
+	name    string // Symbol used to generate the page.
+	kind    string // What the symbol is: const, var, func or type.
+	file    string // File name, relative to the split directory.
+	summary string // One-line summary for the index.
+}
+
+// splitDoc writes the package documentation into dir as one file per
+// symbol (types carry their methods and constructors along) plus an
+// index file listing each page. The format is "text" or "markdown".
+func (pkg *Package) splitDoc(dir, format string) {
+	var ext string
+	switch format {
+	case "text":
+		ext = ".txt"
+	case "markdown":
+		ext = ".md"
+	default:
+		fatalf("unknown split format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		fatalf("%v", err)
+	}
+
+	// Pages name their symbol exactly, so matching must honor case.
+	// The package clause is printed once, in the index.
+	// Files get no terminal typography.
+	saveMatchCase, saveMatchPrefix, saveUserPath, saveWriter, saveStyled := matchCase, matchPrefix, pkg.userPath, pkg.writer, styled
+	matchCase, matchPrefix, pkg.userPath, styled = true, false, "", false
+	defer func() {
+		matchCase, matchPrefix, pkg.userPath, pkg.writer, styled = saveMatchCase, saveMatchPrefix, saveUserPath, saveWriter, saveStyled
+	}()
+
+	var pages []page
+	used := map[string]bool{"index" + ext: true}
+	for _, p := range pkg.pages() {
+		p.file = pageFile(p.name, ext, used)
+		pkg.writePage(filepath.Join(dir, p.file), func() {
+			pkg.preformatted(format, p.name, func() {
+				pkg.symbolDoc(p.name)
+				pkg.typeMembersDoc(p.name)
+			})
+		})
+		pages = append(pages, p)
+	}
+	pkg.writePage(filepath.Join(dir, "index"+ext), func() {
+		pkg.splitIndex(format, pages)
+	})
+}
+
+// pageFile returns the name of the file of the page for the symbol: the
+// symbol's name with the extension, unless that is the index's name, as
+// it is for a symbol named index with -u, or for one named Index on a
+// file system that ignores case. Then .symbol, which no identifier can
+// hold, comes before the extension. Names are compared ignoring case
+// with those already used, held in lower case in used, as with -u Foo
+// and foo would be; a name that was used gets a number, .2 or more,
+// before the extension.
+func pageFile(name, ext string, used map[string]bool) string {
+	base := name
+	if strings.EqualFold(name, "index") {
+		base += ".symbol"
+	}
+	file := base + ext
+	for n := 2; used[strings.ToLower(file)]; n++ {
+		file = fmt.Sprintf("%s.%d%s", base, n, ext)
+	}
+	used[strings.ToLower(file)] = true
+	return file
+}
+
+// pages returns the pages for the package, in the order packageDoc
+// presents the symbols: constants, variables, functions, then types.
+// Values and constructors grouped with an exported type appear on the
+// type's page only.
+func (pkg *Package) pages() []page {
+	var pages []page
+	isGrouped := make(map[interface{}]bool)
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		for _, c := range typ.Consts {
+			isGrouped[c] = true
+		}
+		for _, v := range typ.Vars {
+			isGrouped[v] = true
+		}
+		for _, f := range typ.Funcs {
+			isGrouped[f] = true
+		}
+	}
+	addValues := func(values []*doc.Value, kind string) {
+		for _, value := range values {
+			if isGrouped[value] {
+				continue
+			}
+			for _, name := range value.Names {
+				if isExported(name) {
+					pages = append(pages, page{name: name, kind: kind, summary: pkg.oneLineNode(value.Decl)})
+					break
+				}
+			}
+		}
+	}
+	addValues(pkg.doc.Consts, "const")
+	addValues(pkg.doc.Vars, "var")
+	for _, fun := range pkg.doc.Funcs {
+		if isExported(fun.Name) && !isGrouped[fun] {
+			pages = append(pages, page{name: fun.Name, kind: "func", summary: pkg.oneLineNode(fun.Decl)})
+		}
+	}
+	for _, typ := range pkg.doc.Types {
+		if isExported(typ.Name) {
+			spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+			pages = append(pages, page{name: typ.Name, kind: "type", summary: pkg.oneLineNode(spec)})
+		}
+	}
+	return pages
+}
+
+// typeMembersDoc prints the full documentation for the constructors and
+// methods of the named type, if it is one. It complements symbolDoc,
+// which only summarizes them.
+func (pkg *Package) typeMembersDoc(name string) {
+	defer pkg.flush()
+	for _, typ := range pkg.findTypes(name) {
+		funcs := append(typ.Funcs[:len(typ.Funcs):len(typ.Funcs)], typ.Methods...)
+		for _, fun := range funcs {
+			if !isExported(fun.Name) {
+				continue
+			}
+			// The summary from symbolDoc has already been flushed.
+			if pkg.buf.Len() == 0 {
+				pkg.Printf("\n")
+			} else {
+				pkg.newlines(2)
+			}
+			fun.Decl.Body = nil
+			pkg.emit(fun.Doc, fun.Decl)
+		}
+	}
+}
+
+// splitIndex prints the index page: the package documentation followed by
+// a reference to each page.
+func (pkg *Package) splitIndex(format string, pages []page) {
+	pkg.preformatted(format, pkg.prettyPath(), func() {
+		defer pkg.flush()
+		pkg.packageClause(false)
+		pkg.toText(pkg.doc.Doc, "")
+		pkg.newlines(1)
+	})
+	defer pkg.flush()
+	pkg.Printf("\n")
+	for _, p := range pages {
+		switch format {
+		case "markdown":
+			pkg.Printf("- [%s](%s)\n", p.name, p.file)
+		default:
+			pkg.Printf("%s\t%s\n", p.file, p.summary)
+		}
+	}
+}
+
+// preformatted runs print, which must print to pkg and flush. For
+// markdown, the output is wrapped in a heading and a preformatted block.
+func (pkg *Package) preformatted(format, title string, print func()) {
+	if format != "markdown" {
+		print()
+		return
+	}
+	fmt.Fprintf(pkg.writer, "# %s\n\n```\n", title)
+	print()
+	io.WriteString(pkg.writer, "```\n")
+}
+
+// writePage creates the named file and directs the output of print to it.
+func (pkg *Package) writePage(file string, print func()) {
+	f, err := os.Create(file)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	pkg.writer = f
+	print()
+	if err := f.Close(); err != nil {
+		fatalf("%v", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"strings"
+)
+
+// apiStats counts the exported symbols of a package.
+type apiStats struct {
+	types, funcs, methods, consts, vars int
+	deprecated                          int // With a paragraph beginning "Deprecated: ".
+	documented, total                   int // Symbols with doc comments, and all of them.
+}
+
+// statsDoc prints, for the -stats flag, how many exported types,
+// functions, methods, constants and variables the package has, how many of
+// them are deprecated and how many are documented. Constructors count as
+// functions and the methods of interfaces as methods; each constant and
+// variable counts separately, documented if its group is.
+func (pkg *Package) statsDoc() {
+	defer pkg.flush()
+	pkg.packageClause(false)
+	var s apiStats
+	for _, fun := range pkg.doc.Funcs { // Constructors included.
+		if isExported(fun.Name) {
+			s.funcs++
+			s.count(fun.Doc)
+		}
+	}
+	s.consts = s.values(pkg.doc.Consts) // Typed constants included.
+	s.vars = s.values(pkg.doc.Vars)
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		s.types++
+		s.count(typ.Doc)
+		for _, meth := range typ.Methods {
+			if isExported(meth.Name) {
+				s.methods++
+				s.count(meth.Doc)
+			}
+		}
+		iface, ok := pkg.findTypeSpec(typ.Decl, typ.Name).Type.(*ast.InterfaceType)
+		if !ok {
+			continue
+		}
+		for _, field := range iface.Methods.List {
+			for _, name := range field.Names {
+				if isExported(name.Name) {
+					s.methods++
+					s.count(field.Doc.Text() + field.Comment.Text())
+				}
+			}
+		}
+	}
+	for _, line := range []struct {
+		name string
+		n    int
+	}{
+		{"types", s.types},
+		{"functions", s.funcs},
+		{"methods", s.methods},
+		{"constants", s.consts},
+		{"variables", s.vars},
+		{"deprecated", s.deprecated},
+	} {
+		pkg.Printf("%-12s%d\n", line.name, line.n)
+	}
+	pkg.Printf("%-12s%d of %d", "documented", s.documented, s.total)
+	if s.total > 0 {
+		pkg.Printf(" (%d%%)", 100*s.documented/s.total)
+	}
+	pkg.Printf("\n")
+}
+
+// count counts a symbol with the doc comment.
+func (s *apiStats) count(comment string) {
+	s.total++
+	if comment != "" {
+		s.documented++
+	}
+	if isDeprecated(comment) {
+		s.deprecated++
+	}
+}
+
+// values counts the exported constants or variables, and returns how many
+// there are.
+func (s *apiStats) values(values []*doc.Value) int {
+	n := 0
+	for _, value := range values {
+		for _, spec := range value.Decl.Specs {
+			vspec := spec.(*ast.ValueSpec)
+			comment := value.Doc
+			if len(value.Decl.Specs) > 1 {
+				comment += vspec.Doc.Text() + vspec.Comment.Text()
+			}
+			for _, name := range vspec.Names {
+				if isExported(name.Name) {
+					n++
+					s.count(comment)
+				}
+			}
+		}
+	}
+	return n
+}
+
+// isDeprecated reports whether the doc comment has a paragraph beginning
+// "Deprecated: ", by convention the mark of a deprecated symbol.
+func isDeprecated(comment string) bool {
+	for _, para := range strings.Split(comment, "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(para), "Deprecated: ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A stubDecl is a top-level declaration, or one spec of a parenthesized
+// type or var declaration, that -stub may write.
+type stubDecl struct {
+	names []string // The names it declares.
+	recv  string   // For a method, the name of its receiver's type.
+	node  ast.Node // What it refers to other declarations in.
+	text  string   // Its source, with its doc comment.
+	keep  bool
+
+	// For a spec, the parenthesized declaration holding it, and the
+	// source of the declaration's doc comment.
+	group    *ast.GenDecl
+	groupDoc string
+}
+
+// stubDoc prints, for the -stub flag, a Go source file declaring the
+// package's exported API as its source does, with the doc comments, but
+// with the bodies of functions and methods replaced: empty if they have no
+// results, and otherwise a panic. So that the file compiles, the
+// unexported declarations the exported ones refer to, directly or not,
+// are written too, and the imports they use. Constant declarations are
+// written whole, as their values may depend on their order; other
+// parenthesized declarations keep only the specs needed.
+func (pkg *Package) stubDoc() {
+	defer pkg.flush()
+	fset, files := pkg.sourceFiles()
+	var decls []*stubDecl
+	for _, f := range files {
+		decls = append(decls, stubDecls(fset, f.file, f.src)...)
+	}
+
+	// Keep the exported declarations and those they refer to, until no
+	// more are found.
+	used := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, d := range decls {
+			if d.keep || !d.wanted(used) {
+				continue
+			}
+			d.keep, changed = true, true
+			ast.Inspect(d.node, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					used[id.Name] = true
+				}
+				return true
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if pkg.doc.Doc != "" {
+		for _, line := range strings.Split(strings.TrimSuffix(pkg.doc.Doc, "\n"), "\n") {
+			buf.WriteString(strings.TrimSuffix("// "+line, " ") + "\n")
+		}
+	}
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.name)
+	if imports := pkg.stubImports(used); len(imports) > 0 {
+		fmt.Fprintf(&buf, "import (\n%s)\n\n", strings.Join(imports, ""))
+	}
+	var open *ast.GenDecl // The group being written.
+	for _, d := range decls {
+		if !d.keep {
+			continue
+		}
+		if open != nil && d.group != open {
+			buf.WriteString(")\n\n")
+			open = nil
+		}
+		if d.group == nil {
+			buf.WriteString(d.text + "\n\n")
+			continue
+		}
+		if open == nil {
+			buf.WriteString(d.groupDoc + d.group.Tok.String() + " (\n")
+			open = d.group
+		}
+		buf.WriteString(d.text + "\n")
+	}
+	if open != nil {
+		buf.WriteString(")\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		fatalf("stub for %s: %v", pkg.prettyPath(), err)
+	}
+	pkg.buf.Write(src)
+}
+
+// wanted reports whether the declaration belongs in the stub: whether it
+// is exported or used, and for a method, whether its receiver's type is
+// kept too.
+func (d *stubDecl) wanted(used map[string]bool) bool {
+	for _, name := range d.names {
+		if name == "_" || d.recv == "" && name == "init" {
+			continue
+		}
+		if d.recv != "" && !used[d.recv] && !isExported(d.recv) {
+			return false
+		}
+		if isExported(name) || used[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// stubDecls returns the declarations of the file, whose source is src.
+func stubDecls(fset *token.FileSet, file *ast.File, src []byte) []*stubDecl {
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	// text returns the source from the doc comment above from to the
+	// comment, if any, on the line of to that follows it.
+	text := func(from, to token.Pos, trailing bool) string {
+		for _, c := range file.Comments {
+			start := offset(c.Pos())
+			lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+			if line(c.End()) == line(from)-1 && len(bytes.TrimSpace(src[lineStart:start])) == 0 {
+				from = c.Pos()
+			}
+			if trailing && c.Pos() >= to && line(c.Pos()) == line(to) {
+				to = c.End()
+			}
+		}
+		return string(src[offset(from):offset(to)])
+	}
+	var decls []*stubDecl
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			sig := *decl
+			sig.Body = nil // Replaced.
+			d := &stubDecl{names: []string{decl.Name.Name}, node: &sig}
+			if decl.Recv != nil {
+				d.recv = typeName(decl.Recv.List[0].Type)
+			}
+			d.text = text(decl.Pos(), decl.Type.End(), false)
+			if decl.Type.Results == nil {
+				d.text += " {}"
+			} else {
+				d.text += " { panic(\"stub\") }"
+			}
+			decls = append(decls, d)
+		case *ast.GenDecl:
+			switch {
+			case decl.Tok == token.IMPORT:
+			case decl.Tok == token.CONST || !decl.Lparen.IsValid():
+				d := &stubDecl{node: decl, text: text(decl.Pos(), decl.End(), true)}
+				for _, spec := range decl.Specs {
+					d.names = append(d.names, specNames(spec)...)
+				}
+				decls = append(decls, d)
+			default:
+				// One for each spec.
+				groupDoc := text(decl.Pos(), decl.Pos(), false)
+				for _, spec := range decl.Specs {
+					decls = append(decls, &stubDecl{
+						names:    specNames(spec),
+						node:     spec,
+						text:     text(spec.Pos(), spec.End(), true),
+						group:    decl,
+						groupDoc: groupDoc,
+					})
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// specNames returns the names the type or value spec declares.
+func specNames(spec ast.Spec) []string {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return []string{spec.Name.Name}
+	case *ast.ValueSpec:
+		var names []string
+		for _, id := range spec.Names {
+			names = append(names, id.Name)
+		}
+		return names
+	}
+	return nil
+}
+
+// stubImports returns the lines of an import block importing the packages
+// the files of the package import under the used names, sorted. Blank
+// imports and imports of C are left out.
+func (pkg *Package) stubImports(used map[string]bool) []string {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, imp := range pkg.file.Imports {
+		ipath, _ := strconv.Unquote(imp.Path.Value)
+		name := ""
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		local := name
+		if local == "" {
+			local = path.Base(ipath)
+			if bpkg, err := buildCtx.Import(ipath, pkg.build.Dir, 0); err == nil {
+				local = bpkg.Name
+			}
+		}
+		if ipath == "C" || local == "_" || local != "." && !used[local] {
+			continue
+		}
+		line := "\t" + strconv.Quote(ipath) + "\n"
+		if name != "" {
+			line = "\t" + name + " " + strconv.Quote(ipath) + "\n"
+		}
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/doc"
+	"sort"
+	"strings"
+)
+
+// maxCandidates is the most symbols suggested when a lookup fails.
+const maxCandidates = 5
+
+// candidates returns the exported symbols of the package closest to the
+// symbol, or with a method the methods of the types matching the symbol,
+// as the package's path and the symbol would be given to go doc again. If
+// typesOnly is set, only types are considered.
+func (pkg *Package) candidates(symbol, method string, typesOnly bool) []string {
+	var names []string
+	name := symbol
+	if method != "" {
+		name = method
+		for _, typ := range pkg.findTypes(symbol) {
+			for _, meth := range typ.Methods {
+				if isExported(meth.Name) {
+					names = append(names, typ.Name+"."+meth.Name)
+				}
+			}
+		}
+	} else {
+		for _, typ := range pkg.doc.Types {
+			names = append(names, typ.Name)
+			if typesOnly {
+				continue
+			}
+			for _, fun := range typ.Funcs {
+				names = append(names, fun.Name)
+			}
+			names = append(names, valueNames(typ.Consts)...)
+			names = append(names, valueNames(typ.Vars)...)
+		}
+		if !typesOnly {
+			for _, fun := range pkg.doc.Funcs {
+				names = append(names, fun.Name)
+			}
+			names = append(names, valueNames(pkg.doc.Consts)...)
+			names = append(names, valueNames(pkg.doc.Vars)...)
+		}
+	}
+	exported := names[:0]
+	for _, n := range names {
+		if isExported(n) || pkg.build.ImportPath == "builtin" {
+			exported = append(exported, n)
+		}
+	}
+	ranked := rankCandidates(name, exported, method != "")
+	if pkg.userPath != "" {
+		for i := range ranked {
+			ranked[i] = pkg.userPath + "." + ranked[i]
+		}
+	}
+	return ranked
+}
+
+// valueNames returns the names declared by the constants or variables.
+func valueNames(values []*doc.Value) []string {
+	var names []string
+	for _, value := range values {
+		names = append(names, value.Names...)
+	}
+	return names
+}
+
+// rankCandidates returns at most maxCandidates of the names, those closest
+// to name first: names that begin with it, ignoring case, and then the
+// rest by their edit distance from it, ignoring case. Names too distant to
+// be a likely misspelling are dropped. If methods is set, the names are
+// Type.Method and only the methods are compared.
+func rankCandidates(name string, names []string, methods bool) []string {
+	type candidate struct {
+		name   string
+		prefix bool
+		dist   int
+	}
+	lower := strings.ToLower(name)
+	var list []candidate
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		key := n
+		if i := strings.Index(n, "."); methods && i >= 0 {
+			key = n[i+1:]
+		}
+		key = strings.ToLower(key)
+		c := candidate{name: n, prefix: strings.HasPrefix(key, lower), dist: editDistance(lower, key)}
+		if !c.prefix && c.dist > len(lower)/2+1 {
+			continue
+		}
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		if a.dist != b.dist {
+			return a.dist < b.dist
+		}
+		return a.name < b.name
+	})
+	if len(list) > maxCandidates {
+		list = list[:maxCandidates]
+	}
+	var ranked []string
+	for _, c := range list {
+		ranked = append(ranked, c.name)
+	}
+	return ranked
+}
+
+// editDistance returns the Levenshtein distance between a and b, counted
+// in runes: the fewest insertions, deletions and substitutions that turn
+// one into the other.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(t)]
+}
+
+// min3 returns the least of a, b and c.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// suggestion returns the lines that follow an error for a failed lookup,
+// listing the candidates, or "" if there are none.
+func suggestion(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString("\ndid you mean:")
+	for _, c := range candidates {
+		b.WriteString("\n\t")
+		b.WriteString(c)
+	}
+	return b.String()
+}
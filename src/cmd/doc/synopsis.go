@@ -0,0 +1,159 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// synopsis prints, for a command, how it is invoked, as the SYNOPSIS of a
+// manual page does, if it can be found. It is taken from the first of:
+// the messages printed by a function named usage or Usage, or assigned to
+// flag.Usage; a string constant or variable with usage in its name; and
+// the package's doc comment. Within them, it is the rest of a line
+// beginning "usage:" and the indented lines after a line beginning
+// "usage:" or "usage of". Failing that, in the doc comment, it is the
+// first indented block that begins with the command's name.
+func (pkg *Package) synopsis() {
+	lines := pkg.usageLines()
+	if len(lines) == 0 {
+		return
+	}
+	pkg.Printf("Usage:\n")
+	for _, line := range lines {
+		pkg.Printf("%s%s\n", indent, line)
+	}
+	pkg.Printf("\n")
+}
+
+// usageLines returns the lines of the command's synopsis, or nil.
+func (pkg *Package) usageLines() []string {
+	name := path.Base(pkg.build.ImportPath)
+	if name == "." || name == "/" {
+		name = path.Base(pkg.build.Dir)
+	}
+	_, files := pkg.sourceFiles()
+	var funcs, consts []string
+	for _, f := range files {
+		ast.Inspect(f.file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if strings.EqualFold(n.Name.Name, "usage") && n.Body != nil {
+					funcs = append(funcs, literalText(n.Body, name))
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if sel, ok := lhs.(*ast.SelectorExpr); ok && sel.Sel.Name == "Usage" && i < len(n.Rhs) {
+						if lit, ok := n.Rhs[i].(*ast.FuncLit); ok {
+							funcs = append(funcs, literalText(lit.Body, name))
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				for i, id := range n.Names {
+					if strings.Contains(strings.ToLower(id.Name), "usage") && i < len(n.Values) {
+						if s, ok := stringValue(n.Values[i]); ok {
+							consts = append(consts, s)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	for _, text := range append(funcs, consts...) {
+		if lines := usageSection(text); lines != nil {
+			return lines
+		}
+	}
+	if lines := usageSection(pkg.doc.Doc); lines != nil {
+		return lines
+	}
+	return firstBlock(pkg.doc.Doc, name)
+}
+
+// literalText returns the string literals in the block, as printed by
+// calls such as fmt.Fprintf(os.Stderr, "usage: %s [flags]\n", os.Args[0]),
+// run together, each beginning a line. Their %s verbs are taken to print
+// the command's name.
+func literalText(body *ast.BlockStmt, name string) string {
+	var text bytes.Buffer
+	ast.Inspect(body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				if text.Len() > 0 && !bytes.HasSuffix(text.Bytes(), []byte("\n")) {
+					text.WriteString("\n")
+				}
+				text.WriteString(strings.Replace(strings.Replace(s, "%s", name, -1), "%%", "%", -1))
+			}
+		}
+		return true
+	})
+	return text.String()
+}
+
+// usageSection returns the synopsis in the text: the rest of its first
+// line beginning "usage:", if any, and the indented lines that follow.
+func usageSection(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		head := strings.ToLower(line[:colon])
+		if head != "usage" && !strings.HasPrefix(head, "usage of ") {
+			continue
+		}
+		var synopsis []string
+		if rest := strings.TrimSpace(line[colon+1:]); rest != "" {
+			synopsis = append(synopsis, rest)
+		}
+		rest := lines[i+1:]
+		for len(synopsis) == 0 && len(rest) > 0 && strings.TrimSpace(rest[0]) == "" {
+			rest = rest[1:] // Blank lines between the heading and the block.
+		}
+		for _, line := range rest {
+			if strings.TrimSpace(line) == "" || !isIndented(line) {
+				break
+			}
+			synopsis = append(synopsis, strings.TrimSpace(line))
+		}
+		if len(synopsis) > 0 {
+			return synopsis
+		}
+	}
+	return nil
+}
+
+// firstBlock returns the lines of the first indented block of the doc
+// comment if it begins with the command's name, as "name [flags]" or "go
+// tool name [flags]" would, or else nil.
+func firstBlock(doc, name string) []string {
+	var block []string
+	for _, line := range strings.Split(doc, "\n") {
+		if isIndented(line) && strings.TrimSpace(line) != "" {
+			block = append(block, strings.TrimSpace(line))
+			continue
+		}
+		if block != nil {
+			break
+		}
+	}
+	if len(block) == 0 {
+		return nil
+	}
+	first := strings.TrimPrefix(block[0], "go tool ")
+	if first != name && !strings.HasPrefix(first, name+" ") {
+		return nil
+	}
+	return block
+}
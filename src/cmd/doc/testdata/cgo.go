@@ -0,0 +1,18 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkg
+
+/*
+#include <math.h>
+
+struct point { int x, y; };
+*/
+import "C"
+
+// Comment about function exported to C.
+//export cgoCallback
+func cgoCallback(p *C.struct_point) C.int {
+	return C.int(C.sqrt(C.double(p.x)))
+}
@@ -172,3 +172,66 @@ const (
 )
 
 const ConstGroup4 ExportedType = ExportedType{}
+
+// DirectiveFunc is never inlined.
+//go:noinline
+func DirectiveFunc() {}
+
+// EmbeddedText holds the text of a file.
+//go:embed embed.txt
+var EmbeddedText string
+
+// Embedded data.
+var (
+	// EmbeddedData holds the contents of a file.
+	//go:embed data.bin
+	EmbeddedData []byte
+	EmbedCount   = 1
+)
+
+type embeddedBase struct{}
+
+// Shared is overridden by Derived.
+func (embeddedBase) Shared() {}
+
+// Inherited is promoted to Derived.
+func (*embeddedBase) Inherited() {}
+
+// Derived embeds embeddedBase and overrides one of its methods.
+type Derived struct {
+	*embeddedBase
+}
+
+// Shared overrides embeddedBase.Shared.
+func (Derived) Shared() {}
+
+// NOTE(r): Notes are collected from comments anywhere in the package.
+
+// TODO(r): Notes other than BUG are shown by -notes.
+
+// SECURITY(r): Teams may use markers of their own.
+
+// LinkedFunc returns an [ExportedType], read as an [io.Reader] is; see
+// [ExportedType.ExportedMethod] and [encoding/json.Marshal]. But a[0],
+// [text] and [Missing] are not links.
+func LinkedFunc() *ExportedType { return nil }
+
+// Hooks that may be replaced.
+var (
+	// OnEvent, if not nil, is called for each event.
+	OnEvent func(name string) error
+
+	// Events receives the events.
+	Events = make(chan string, 1)
+
+	// Filter reports whether to keep an event.
+	Filter = func(name string) bool { return true }
+
+	// Handler handles the events.
+	Handler EventHandler = defaultHandler
+)
+
+// An EventHandler handles an event.
+type EventHandler func(name string)
+
+func defaultHandler(name string) {}
@@ -0,0 +1,10 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build doctag
+
+package pkg
+
+// Comment about function guarded by a build tag.
+func TaggedFunc() int { return 1 }
@@ -0,0 +1,575 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"io"
+)
+
+// specURL is the address of the language specification.
+const specURL = "https://golang.org/ref/spec"
+
+// A topic documents a part of the language that is not a symbol of any
+// package, such as a keyword, for go doc for and the like.
+type topic struct {
+	title string // As in the specification.
+	spec  string // The anchor of its section of the specification.
+	text  string // In the form of a doc comment.
+}
+
+// languageTopics holds the topics, by the word that names them.
+var languageTopics = map[string]topic{
+	"break": {"Break statements", "Break_statements", `
+A break statement ends the innermost for, switch or select statement
+within the same function. With a label, it ends the labeled statement,
+which must enclose it:
+
+	Loop:
+		for {
+			select {
+			case <-done:
+				break Loop
+			case v := <-c:
+				use(v)
+			}
+		}
+
+A plain break in a switch or select case ends the switch or select,
+not a loop around it.
+`},
+	"case": {"Switch statements", "Switch_statements", `
+A case clause of a switch or select statement. In an expression switch
+it lists the values to compare with, in a type switch the types to
+match, and in a select the communication to wait for:
+
+	switch x {
+	case 1, 2, 3:
+	}
+	switch v := i.(type) {
+	case int, string:
+	}
+	select {
+	case v := <-c:
+	}
+
+Cases do not fall through to the next unless they end in fallthrough.
+`},
+	"chan": {"Channel types", "Channel_types", `
+A channel type, chan T, is a conduit by which goroutines send and
+receive values of type T. The types chan<- T and <-chan T may only send
+or only receive. Channels are created by make, with an optional buffer
+size, and closed by close:
+
+	c := make(chan int, 10)
+	c <- 1       // Send.
+	v := <-c     // Receive.
+	v, ok := <-c // ok is false once c is closed and drained.
+
+Sending and receiving on a nil channel block forever.
+`},
+	"const": {"Constant declarations", "Constant_declarations", `
+A const declaration binds names to constant values, computed when
+compiling. Untyped constants take a type from their context and have
+arbitrary precision. Within a parenthesized block, an omitted value
+repeats the previous expression, and iota counts the specs from 0:
+
+	const Pi = 3.14159
+	const (
+		KB = 1 << (10 * (iota + 1))
+		MB
+		GB
+	)
+`},
+	"continue": {"Continue statements", "Continue_statements", `
+A continue statement begins the next iteration of the innermost for
+loop, running its post statement first. With a label, it continues the
+labeled loop, which must enclose it:
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		process(line)
+	}
+`},
+	"default": {"Switch statements", "Switch_statements", `
+The default clause of a switch or select statement runs when no case
+matches. There may be at most one, anywhere among the cases. In a
+select, a default clause makes the select not block:
+
+	select {
+	case c <- v:
+	default:
+		// c is not ready; drop v.
+	}
+`},
+	"defer": {"Defer statements", "Defer_statements", `
+A defer statement schedules a function call to run when the surrounding
+function returns, whether normally or by panicking. Deferred calls run
+in last-in, first-out order. The function value and arguments are
+evaluated when the defer statement runs, not when the call does:
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+A deferred function literal may read and set the named results of the
+function, and may call recover to stop a panic.
+`},
+	"else": {"If statements", "If_statements", `
+The else branch of an if statement runs when the condition is false. It
+is a block or another if statement, and must be on the same line as the
+closing brace before it:
+
+	if x < 0 {
+		return -x
+	} else if x == 0 {
+		return 0
+	} else {
+		return x
+	}
+`},
+	"fallthrough": {"Fallthrough statements", "Fallthrough_statements", `
+A fallthrough statement, as the last statement of an expression switch
+case, transfers control to the first statement of the next case without
+testing it. It may not be used in the last case or in a type switch:
+
+	switch n {
+	case 0:
+		zero()
+		fallthrough
+	case 1:
+		small()
+	}
+`},
+	"for": {"For statements", "For_statements", `
+Go has one loop, the for statement, in three forms: with a condition,
+like while; with an init statement, condition and post statement; and
+with a range clause, over an array, slice, string, map or channel. With
+nothing, it loops forever:
+
+	for n > 0 {
+		n--
+	}
+	for i := 0; i < 10; i++ {
+	}
+	for i, v := range s {
+	}
+	for {
+	}
+
+Variables declared by the for statement are reused by each iteration,
+so function literals that capture them see their last values.
+`},
+	"func": {"Function declarations", "Function_declarations", `
+A func declaration declares a function, or with a receiver a method.
+Functions may take a final variadic parameter, return several results,
+which may be named, and be used as values. A function literal is a
+closure over the variables of its surroundings:
+
+	func Divide(a, b int) (q, r int, err error)
+	func (b *Buffer) Write(p []byte) (n int, err error)
+	add := func(x, y int) int { return x + y }
+`},
+	"go": {"Go statements", "Go_statements", `
+A go statement starts a function call running in a new goroutine, in
+the same address space. The function value and arguments are evaluated
+in the calling goroutine; its results are discarded. The program exits
+when main returns, without waiting for other goroutines:
+
+	go worker(jobs)
+	go func() {
+		done <- compute()
+	}()
+`},
+	"goto": {"Goto statements", "Goto_statements", `
+A goto statement jumps to a label in the same function. It may not jump
+over variable declarations into their scope, or into a block from
+outside it:
+
+	retry:
+		if err := try(); err != nil {
+			goto retry
+		}
+`},
+	"if": {"If statements", "If_statements", `
+An if statement runs a block when a boolean condition is true. The
+condition may be preceded by a simple statement, whose variables are
+scoped to the if and its else branches:
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+The braces are required, and the condition needs no parentheses.
+`},
+	"import": {"Import declarations", "Import_declarations", `
+An import declaration makes the exported names of a package available
+in the file, qualified by the package's name or by a name given. The
+name . imports the names unqualified, and the name _ imports the
+package only for its initialization:
+
+	import (
+		"fmt"
+		str "strings"
+		_ "image/png"
+	)
+
+It is an error to import a package and not use it.
+`},
+	"interface": {"Interface types", "Interface_types", `
+An interface type is a set of methods. A type implements an interface by
+having its methods; there is no implements declaration. An interface
+value holds a value of any type that implements it, and type assertions
+and type switches recover it. The empty interface, interface{}, is
+implemented by every type:
+
+	type Stringer interface {
+		String() string
+	}
+
+An interface value holding a nil pointer is itself not nil.
+`},
+	"map": {"Map types", "Map_types", `
+A map type, map[K]V, is an unordered group of values of type V indexed
+by unique keys of type K, which must be comparable. Maps are made by
+make or a composite literal; a nil map can be read but not written.
+Indexing with a missing key gives the zero value; the two-value form
+reports whether it was there:
+
+	m := map[string]int{"a": 1}
+	m["b"] = 2
+	v, ok := m["c"] // v == 0, ok == false
+	delete(m, "a")
+
+The order of a range over a map is not specified. Maps are not safe for
+concurrent use when any goroutine writes.
+`},
+	"package": {"Package clause", "Package_clause", `
+A package clause begins each source file and names the package the file
+belongs to. All the files of a directory declare the same package,
+except for tests' _test packages. Package main, with a function main,
+is a command:
+
+	package main
+`},
+	"range": {"For statements with range clause", "For_range", `
+A range clause iterates over the elements of an array, slice, string,
+map or channel. For strings it yields byte indexes and runes, decoding
+UTF-8; for maps, keys and values in no fixed order; for channels, the
+values received until it is closed:
+
+	for i, v := range []string{"a", "b"} {
+	}
+	for i, r := range "héllo" {
+	}
+	for k, v := range m {
+	}
+	for v := range c {
+	}
+
+The range expression is evaluated once, before the loop begins.
+`},
+	"return": {"Return statements", "Return_statements", `
+A return statement ends the function, giving its results. A function
+with named results may return with no values, returning the results as
+they are; deferred calls may still change them:
+
+	func split(sum int) (x, y int) {
+		x = sum * 4 / 9
+		y = sum - x
+		return
+	}
+`},
+	"select": {"Select statements", "Select_statements", `
+A select statement waits on several channel operations, and performs
+one that can proceed, chosen at random if several can. A default case
+runs if none can; without one, select blocks. Cases on nil channels
+never proceed, so setting a channel to nil disables its case:
+
+	select {
+	case v := <-in:
+		use(v)
+	case out <- x:
+	case <-time.After(time.Second):
+		return errTimeout
+	}
+
+An empty select, select {}, blocks forever.
+`},
+	"struct": {"Struct types", "Struct_types", `
+A struct type is a sequence of fields, each with a name and type. An
+embedded field, given by a type name alone, promotes its fields and
+methods to the struct. A field may have a tag, a string that reflection
+and packages such as encoding/json read:
+
+	type Point struct {
+		X, Y int
+		Name string ` + "`json:\"name\"`" + `
+		sync.Mutex
+	}
+
+Only fields whose names begin with an upper-case letter are exported.
+`},
+	"switch": {"Switch statements", "Switch_statements", `
+An expression switch runs the first case whose value equals the switch
+expression, or with no expression the first whose condition is true. A
+type switch runs the first case matching the dynamic type of an
+interface value. Cases do not fall through unless they end in
+fallthrough:
+
+	switch {
+	case x < 0:
+	case x > 0:
+	default:
+	}
+	switch v := i.(type) {
+	case nil:
+	case error:
+		use(v.Error())
+	}
+`},
+	"type": {"Type declarations", "Type_declarations", `
+A type declaration defines a new named type with the same underlying
+type as the given one, but none of its methods. Methods may then be
+declared on it in the same package:
+
+	type Celsius float64
+	type Handler func(w io.Writer, r *Request)
+
+The expression x.(T) asserts that the interface value x holds a T,
+and switch x.(type) switches on its type.
+`},
+	"var": {"Variable declarations", "Variable_declarations", `
+A var declaration creates variables, giving each a type and an initial
+value. Either may be omitted: the type is then that of the value, and
+the value the zero value of the type. Inside functions, the short
+declaration x := v declares and initializes in one step:
+
+	var n int
+	var s = "text"
+	var (
+		mu    sync.Mutex
+		count int
+	)
+`},
+	// Operators, and other words that are not symbols.
+	"+":   arithmeticTopic,
+	"-":   arithmeticTopic,
+	"/":   arithmeticTopic,
+	"%":   arithmeticTopic,
+	"|":   arithmeticTopic,
+	"^":   arithmeticTopic,
+	"&^":  arithmeticTopic,
+	"<<":  arithmeticTopic,
+	">>":  arithmeticTopic,
+	"&":   addressTopic,
+	"*":   addressTopic,
+	"==":  comparisonTopic,
+	"!=":  comparisonTopic,
+	"<":   comparisonTopic,
+	"<=":  comparisonTopic,
+	">":   comparisonTopic,
+	">=":  comparisonTopic,
+	"&&":  logicalTopic,
+	"||":  logicalTopic,
+	"!":   logicalTopic,
+	"=":   assignmentTopic,
+	"+=":  assignmentTopic,
+	"-=":  assignmentTopic,
+	"*=":  assignmentTopic,
+	"/=":  assignmentTopic,
+	"%=":  assignmentTopic,
+	"&=":  assignmentTopic,
+	"|=":  assignmentTopic,
+	"^=":  assignmentTopic,
+	"<<=": assignmentTopic,
+	">>=": assignmentTopic,
+	"&^=": assignmentTopic,
+	"++":  incDecTopic,
+	"--":  incDecTopic,
+	"<-": {"Receive operator", "Receive_operator", `
+The operator <- receives a value from a channel, as in v := <-c, and in
+a send statement, c <- v, sends one. Both block until the other side is
+ready, or for a buffered channel until there is a value or room for
+one. In a channel type it gives the direction: chan<- T only sends and
+<-chan T only receives.
+
+	v := <-c
+	v, ok := <-c // ok is false once c is closed and drained.
+	c <- v
+
+Receiving from a closed channel gives the zero value at once; sending
+on one panics. See also the send statement, ` + specURL + `#Send_statements.
+`},
+	":=": {"Short variable declarations", "Short_variable_declarations", `
+A short variable declaration, x := v, declares variables with the types
+of the values and assigns them, inside functions only. With several
+names, at least one must be new in the scope; the others are assigned.
+Declaring a variable that shadows one of an outer scope is a common
+mistake:
+
+	f, err := os.Open(name)
+	n, err := f.Read(buf) // err is assigned, n declared.
+	if err := g(); err != nil { // A new err, for the if only.
+	}
+`},
+	"iota": {"Iota", "Iota", `
+Within a constant declaration, the predeclared identifier iota is the
+index of the spec in the parenthesized block, starting at 0. Since an
+omitted expression repeats the previous one, iota numbers enumerations:
+
+	const (
+		Sunday = iota // 0
+		Monday        // 1
+		Tuesday       // 2
+	)
+	const (
+		_  = iota             // Skip 0.
+		KB = 1 << (10 * iota) // 1 << 10
+		MB                    // 1 << 20
+	)
+
+Iota has the same value for each name of one spec.
+`},
+	"_": {"Blank identifier", "Blank_identifier", `
+The blank identifier, _, may be used like any other identifier in a
+declaration or assignment, but does not introduce a binding; the value
+is discarded. It is used to ignore results, to import a package for its
+initialization alone, and to check that a type implements an interface
+when compiling:
+
+	_, err := io.Copy(dst, src)
+	import _ "image/png"
+	var _ io.Writer = (*Buffer)(nil)
+`},
+}
+
+// Topics shared by several operators.
+var (
+	arithmeticTopic = topic{"Arithmetic operators", "Arithmetic_operators", `
+The arithmetic operators +, -, *, / and % apply to numbers, and + also
+concatenates strings. Integer division truncates toward zero, and x%y
+has the sign of x; dividing an integer by zero panics. The bitwise
+operators &, |, ^ and &^ (bit clear, and not) apply to integers, and
+unary ^ is bitwise complement. The shifts << and >> take an unsigned
+shift count; >> is arithmetic for signed integers:
+
+	q, r := 7/2, 7%2 // 3, 1
+	s := "go" + "pher"
+	mask := x &^ (1 << 3)
+
+Integer arithmetic wraps around on overflow, without panicking.
+`}
+	addressTopic = topic{"Address operators", "Address_operators", `
+Unary &x gives a pointer to x, which must be addressable or a composite
+literal; unary *p gives the variable p points to, panicking if p is
+nil. In a type, *T is the type of pointers to T. As binary operators, *
+multiplies and & is bitwise and, as described at
+` + specURL + `#Arithmetic_operators.
+
+	p := &Point{1, 2}
+	p.X = 3 // Short for (*p).X.
+	var q *int = new(int)
+	*q = 42
+`}
+	comparisonTopic = topic{"Comparison operators", "Comparison_operators", `
+The comparison operators ==, !=, <, <=, > and >= give an untyped
+boolean. Equality applies to comparable types: booleans, numbers,
+strings, pointers, channels, interfaces, and structs and arrays of
+comparable types; slices, maps and functions compare only to nil.
+Ordering applies to integers, floating-point numbers and strings,
+which compare bytewise:
+
+	if err != nil {
+	}
+	if a < b && s == "ok" {
+	}
+
+Interface values are equal if their dynamic types and values are;
+comparing ones holding uncomparable values panics.
+`}
+	logicalTopic = topic{"Logical operators", "Logical_operators", `
+The logical operators apply to booleans: && is and, || is or, and unary
+! is not. The right operand of && and || is evaluated only if needed,
+so it may depend on the left:
+
+	if p != nil && p.ok {
+	}
+	if !found || len(s) == 0 {
+	}
+`}
+	assignmentTopic = topic{"Assignments", "Assignments", `
+An assignment, x = v, stores values in variables, pointer indirections,
+fields, slice and array elements and map entries. A tuple assignment
+evaluates all the operands first, so it can swap values. The assignment
+x op= y, such as x += 1, is x = x op y with x evaluated once:
+
+	a, b = b, a
+	m[k] = v
+	total += n
+	_ = f() // Discard the result.
+
+To declare variables as well, use := or var.
+`}
+	incDecTopic = topic{"IncDec statements", "IncDec_statements", `
+The statements x++ and x-- add or subtract the untyped constant 1. They
+are statements, not expressions, and there are no prefix forms:
+
+	for i := 0; i < n; i++ {
+	}
+	count--
+`}
+)
+
+// topicDoc prints the documentation of the language topic: its title,
+// its explanation and examples, and the address of its section of the
+// specification.
+func topicDoc(writer io.Writer, name string) error {
+	t := languageTopics[name]
+	pkg := &Package{writer: writer}
+	defer pkg.flush()
+	pkg.Printf("%s (%s)\n", name, t.title)
+	pkg.toText(t.text[1:], indent)
+	pkg.newlines(2)
+	pkg.Printf("%sSee %s#%s\n", indent, specURL, t.spec)
+	return nil
+}
+
+// Kinds of argument, as classifyArg finds them.
+const (
+	argPackage  = iota // A package, symbol or pattern, to be resolved.
+	argTopic           // A keyword, operator or other topic.
+	argOperator        // An operator with no topic.
+)
+
+// classifyArg reports what kind of thing the argument names, before any
+// package is resolved: keywords and operators cannot name packages or
+// their symbols, and would otherwise be reported as no such package.
+// The paths ., .. and patterns such as ... are left to be resolved.
+func classifyArg(arg string) int {
+	if _, ok := languageTopics[arg]; ok {
+		return argTopic
+	}
+	if arg == "." || arg == ".." || isPattern(arg) {
+		return argPackage
+	}
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", fset.Base(), len(arg)), []byte(arg), nil, 0)
+	_, tok, _ := s.Scan()
+	_, next, lit := s.Scan()
+	if next == token.SEMICOLON && lit == "\n" {
+		_, next, _ = s.Scan() // Inserted after ++ and the like.
+	}
+	if tok.IsOperator() && next == token.EOF {
+		return argOperator
+	}
+	return argPackage
+}
@@ -0,0 +1,29 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// tracef writes, for the -x flag, a line to warnings describing a step
+// taken to find the package and symbol asked for: the import paths tried,
+// the roots searched and the directories skipped, the candidates a
+// partial path matches and why those passed over were, and the files
+// parsed. Each line begins "doc: x: ".
+func tracef(format string, args ...interface{}) {
+	if !trace {
+		return
+	}
+	writeTrace(warnings, fmt.Sprintf(format, args...))
+}
+
+// writeTrace writes the trace line as tracef does, to w.
+func writeTrace(w io.Writer, message string) {
+	warnMu.Lock()
+	defer warnMu.Unlock()
+	fmt.Fprintf(w, "doc: x: %s\n", message)
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"strings"
+)
+
+// A treeNode is a line of the output of -tree, with the lines beneath it.
+type treeNode struct {
+	text     string
+	children []*treeNode
+}
+
+// treeDoc prints, for the -tree flag, the package's symbols as an
+// indented tree: the package's constants, variables, functions and
+// types, and beneath each type its constants, variables, constructors
+// and methods, so the structure of a large package can be seen.
+func (pkg *Package) treeDoc() {
+	defer pkg.flush()
+	pkg.packageClause(false)
+	if !pkg.showInternals() {
+		return
+	}
+
+	// Constructors and typed values are shown with their types.
+	grouped := make(map[interface{}]bool)
+	var types []*treeNode
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+		for _, v := range typ.Consts {
+			grouped[v] = true
+		}
+		for _, v := range typ.Vars {
+			grouped[v] = true
+		}
+		for _, f := range typ.Funcs {
+			grouped[f] = true
+		}
+		types = append(types, &treeNode{
+			text: pkg.oneLineNode(spec),
+			children: nonEmpty(
+				&treeNode{"constants", pkg.valueNodes(typ.Consts, nil)},
+				&treeNode{"variables", pkg.valueNodes(typ.Vars, nil)},
+				&treeNode{"constructors", pkg.funcNodes(typ.Funcs, nil)},
+				&treeNode{"methods", append(pkg.interfaceMethodNodes(spec), pkg.funcNodes(typ.Methods, nil)...)},
+			),
+		})
+	}
+	pkg.printTree(nonEmpty(
+		&treeNode{"constants", pkg.valueNodes(pkg.doc.Consts, grouped)},
+		&treeNode{"variables", pkg.valueNodes(pkg.doc.Vars, grouped)},
+		&treeNode{"functions", pkg.funcNodes(pkg.doc.Funcs, grouped)},
+		&treeNode{"types", types},
+	), "")
+}
+
+// nonEmpty returns the nodes that have children.
+func nonEmpty(nodes ...*treeNode) []*treeNode {
+	var list []*treeNode
+	for _, n := range nodes {
+		if len(n.children) > 0 {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+// valueNodes returns a node for each declaration of values not in skip.
+func (pkg *Package) valueNodes(values []*doc.Value, skip map[interface{}]bool) []*treeNode {
+	var nodes []*treeNode
+	for _, value := range values {
+		if skip[value] {
+			continue
+		}
+		if decl := pkg.oneLineNode(value.Decl); decl != "" {
+			nodes = append(nodes, &treeNode{text: decl})
+		}
+	}
+	return nodes
+}
+
+// funcNodes returns a node for each exported function not in skip.
+func (pkg *Package) funcNodes(funcs []*doc.Func, skip map[interface{}]bool) []*treeNode {
+	var nodes []*treeNode
+	for _, fun := range funcs {
+		if isExported(fun.Name) && !skip[fun] {
+			nodes = append(nodes, &treeNode{text: pkg.oneLineNode(fun.Decl)})
+		}
+	}
+	return nodes
+}
+
+// interfaceMethodNodes returns a node for each exported method and
+// embedded interface of the type, if it is an interface.
+func (pkg *Package) interfaceMethodNodes(spec *ast.TypeSpec) []*treeNode {
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+	var nodes []*treeNode
+	for _, method := range iface.Methods.List {
+		if len(method.Names) == 0 {
+			nodes = append(nodes, &treeNode{text: pkg.oneLineNode(method.Type)})
+			continue
+		}
+		for _, name := range method.Names {
+			if isExported(name.Name) {
+				sig := strings.TrimPrefix(pkg.oneLineNode(method.Type), "func")
+				nodes = append(nodes, &treeNode{text: name.Name + sig})
+			}
+		}
+	}
+	return nodes
+}
+
+// printTree prints the nodes and their children, each line beginning
+// with prefix and drawn with the lines of a tree.
+func (pkg *Package) printTree(nodes []*treeNode, prefix string) {
+	for i, n := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		pkg.Printf("%s%s%s\n", prefix, branch, n.text)
+		pkg.printTree(n.children, prefix+next)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"sort"
+	"strings"
+)
+
+// A typeRef names a type, as given to -returns and -accepts: its name,
+// the package qualifier, if any, it was given with, and whether only a
+// pointer to it was asked for.
+type typeRef struct {
+	qualifier, name string
+	pointer         bool
+}
+
+// parseTypeName parses the type given to the flag, such as http.Handler,
+// *Request or error.
+func parseTypeName(flag, arg string) typeRef {
+	var t typeRef
+	s := arg
+	if strings.HasPrefix(s, "*") {
+		t.pointer, s = true, s[1:]
+	}
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		t.qualifier, s = s[:i], s[i+1:]
+	}
+	t.name = s
+	if !isIdent(t.name) || t.qualifier != "" && !isIdent(t.qualifier) {
+		fatalf("invalid -%s type %q; want a named type such as http.Handler or *Request", flag, arg)
+	}
+	return t
+}
+
+// returnsDoc prints, for the -returns flag, a one-line summary of each
+// exported function and method of the package with a result of the type
+// named, or of a pointer to it, to show how a value of the type may be
+// obtained. A type qualified by the package's own name, such as
+// http.Handler in net/http, is the package's type of that name.
+func (pkg *Package) returnsDoc(arg string) error {
+	return pkg.typeUseDoc("returns", arg, func(t *ast.FuncType) *ast.FieldList { return t.Results })
+}
+
+// acceptsDoc prints, for the -accepts flag, as returnsDoc does for results,
+// a one-line summary of each exported function and method of the package
+// with a parameter of the type named, or of a pointer to it, including a
+// final ...T, to show what may be done with a value of the type.
+func (pkg *Package) acceptsDoc(arg string) error {
+	return pkg.typeUseDoc("accepts", arg, func(t *ast.FuncType) *ast.FieldList { return t.Params })
+}
+
+// typeUseDoc prints, after the package clause, a one-line summary of each
+// exported function and method of the package of which the fields, its
+// parameters or results, include the type given to the flag, named by
+// verb, as -returns and -accepts are.
+func (pkg *Package) typeUseDoc(verb, arg string, fields func(*ast.FuncType) *ast.FieldList) error {
+	defer pkg.flush()
+	want := parseTypeName(verb, arg)
+	found := false
+	for _, fun := range pkg.exportedFuncs() {
+		if paramIndex(fields(fun.Decl.Type), func(expr ast.Expr) bool { return pkg.isType(expr, want) }) < 0 {
+			continue
+		}
+		if !found {
+			pkg.packageClause(false)
+			found = true
+		}
+		pkg.Printf("%s\n", pkg.summaryLine(fun.Decl))
+	}
+	if !found {
+		pkg.failf(errSymbol, "no exported function or method in package %s %s %s", pkg.prettyPath(), verb, arg)
+	}
+	return nil
+}
+
+// exportedFuncs returns the exported functions of the package, with the
+// constructors of its types, in the order -sort gives, followed by the
+// exported methods of its exported types.
+func (pkg *Package) exportedFuncs() []*doc.Func {
+	var funcs []*doc.Func
+	for _, fun := range pkg.doc.Funcs { // Constructors included.
+		if isExported(fun.Name) {
+			funcs = append(funcs, fun)
+		}
+	}
+	sort.SliceStable(funcs, func(i, j int) bool {
+		if sortOrder == "source" {
+			return funcs[i].Decl.Pos() < funcs[j].Decl.Pos()
+		}
+		return funcs[i].Name < funcs[j].Name
+	})
+	for _, typ := range pkg.doc.Types {
+		if !isExported(typ.Name) {
+			continue
+		}
+		for _, method := range typ.Methods {
+			if isExported(method.Name) {
+				funcs = append(funcs, method)
+			}
+		}
+	}
+	return funcs
+}
+
+// isType reports whether the type expression is the type want or, unless
+// only a pointer to it is wanted, a pointer to it, alone or as the type
+// of a variadic parameter. The qualifiers of other packages are matched
+// by the names the package's files import them by.
+func (pkg *Package) isType(expr ast.Expr, want typeRef) bool {
+	if ellipsis, ok := expr.(*ast.Ellipsis); ok {
+		expr = ellipsis.Elt
+	}
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	} else if want.pointer {
+		return false
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name == want.name && (want.qualifier == "" || want.qualifier == pkg.name)
+	case *ast.SelectorExpr:
+		return t.Sel.Name == want.name && identName(t.X) == want.qualifier
+	}
+	return false
+}
@@ -0,0 +1,358 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/doc"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Terminal escape sequences used by the typography.
+const (
+	bold  = "\x1b[1m"
+	shade = "\x1b[2m" // Faint, for code blocks.
+	reset = "\x1b[0m"
+)
+
+// hyperlink returns text as a terminal hyperlink to url.
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// styled reports whether doc comments are rendered with terminal
+// typography. It is set from the -color flag.
+var styled bool
+
+// colorEnabled interprets the -color flag, which is auto, always or never,
+// for output to w. Auto enables color only when w is a terminal, TERM is
+// not dumb and NO_COLOR is not set.
+func colorEnabled(mode string, w io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false
+		}
+		if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+			return false
+		}
+		return isTerminal(w)
+	}
+	fatalf("invalid -color %q; want auto, always or never", mode)
+	return false
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// lineWidth is the width of the lines to which doc comments are wrapped,
+// indentation included. It is set from the -width flag.
+var lineWidth = punchedCardWidth
+
+// outputWidth interprets the -width flag for output to w. Zero means the
+// width of the terminal if w is one, as given by $COLUMNS or by the
+// terminal itself, and otherwise punchedCardWidth.
+func outputWidth(width int, w io.Writer) int {
+	minWidth := 20 + indentColumns()
+	if width == 0 && isTerminal(w) {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+		if width < minWidth {
+			width = terminalWidth()
+		}
+		if width < minWidth {
+			width = 0
+		}
+	}
+	switch {
+	case width == 0:
+		return punchedCardWidth
+	case width < minWidth:
+		fatalf("invalid -width %d; want at least %d", width, minWidth)
+	}
+	return width
+}
+
+// indentation interprets the -indent flag: a number of spaces, or a
+// string of spaces and tabs, in which \t may stand for a tab.
+func indentation(s string) string {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return strings.Repeat(" ", n)
+	}
+	s = strings.Replace(s, `\t`, "\t", -1)
+	if strings.Trim(s, " \t") != "" {
+		fatalf("invalid -indent %q; want a number of spaces, or spaces and tabs", s)
+	}
+	return s
+}
+
+// toText prints the comment, each line beginning with prefix, as
+// doc.ToText does. If styled is set, headings, including those written
+// "# Heading", are bold, code blocks are shaded and list items are
+// bulleted or numbered, rather than all being printed as flat, reflowed
+// text, and doc links such as [io.Reader] are hyperlinks rather than
+// plain text. Link definitions, [text]: URL, are printed last, one to a
+// line, as go/doc/comment prints them. For -raw, the comment is printed
+// as it is, neither reflowed nor indented.
+func (pkg *Package) toText(comment, prefix string) {
+	if rawComments {
+		// Verbatim, for other tools.
+		pkg.buf.WriteString(comment)
+		return
+	}
+	comment, defs := splitLinkDefs(comment)
+	if len(defs) > 0 {
+		defer func() {
+			pkg.Printf("\n")
+			for _, def := range defs {
+				pkg.Printf("%s%s\n", prefix, def)
+			}
+		}()
+	}
+	comment, urls := pkg.docLinks(comment, styled)
+	if !styled {
+		doc.ToText(&pkg.buf, comment, prefix, indent, indentedWidth())
+		return
+	}
+	start := pkg.buf.Len()
+	defer func() {
+		text := hyperlinks(pkg.buf.String()[start:], urls)
+		pkg.buf.Truncate(start)
+		pkg.buf.WriteString(text)
+	}()
+	for i, b := range commentBlocks(comment) {
+		if i > 0 {
+			pkg.buf.WriteString("\n")
+		}
+		switch b.kind {
+		case paraBlock:
+			doc.ToText(&pkg.buf, strings.Join(b.lines, "\n"), prefix, indent, indentedWidth())
+		case headingBlock:
+			pkg.Printf("%s%s%s%s\n", prefix, bold, b.lines[0], reset)
+		case codeBlock:
+			for _, line := range b.lines {
+				if line == "" {
+					pkg.Printf("\n")
+					continue
+				}
+				pkg.Printf("%s%s%s%s%s\n", prefix, indent, shade, line, reset)
+			}
+		case listBlock:
+			for i, item := range b.lines {
+				marker := b.markers[i]
+				if !isNumbered(marker) {
+					marker = "•"
+				}
+				var buf bytes.Buffer
+				doc.ToText(&buf, item, prefix+"    ", "", indentedWidth())
+				pkg.Printf("%s  %s %s", prefix, marker, strings.TrimPrefix(buf.String(), prefix+"    "))
+			}
+		}
+	}
+}
+
+// Kinds of comment block.
+const (
+	paraBlock = iota
+	headingBlock
+	codeBlock
+	listBlock
+)
+
+// A block is a paragraph, heading, code block or list in a doc comment.
+// The lines of a code block are unindented; those of a list are its items,
+// without their markers, which are kept apart. The line of a heading
+// written "# Heading" does not include the "# ".
+type block struct {
+	kind    int
+	lines   []string
+	markers []string // For a list, the marker of each item, such as - or 1.
+}
+
+// listItem matches the marker at the start of a list item.
+var listItem = regexp.MustCompile(`^([-*+•]|[0-9]+[.)])\s+`)
+
+// isNumbered reports whether the list marker is a number, as in 1. or 1),
+// rather than a bullet.
+func isNumbered(marker string) bool {
+	return marker != "" && '0' <= marker[0] && marker[0] <= '9'
+}
+
+// linkDef matches a link definition, [text]: URL.
+var linkDef = regexp.MustCompile(`^\[[^\]]+\]:[ \t]+\S+$`)
+
+// splitLinkDefs removes from the comment the paragraphs made only of link
+// definitions, which go/doc/comment prints last, and returns them, one
+// definition to a line.
+func splitLinkDefs(comment string) (string, []string) {
+	if !strings.Contains(comment, "]:") {
+		return comment, nil
+	}
+	lines := strings.SplitAfter(comment, "\n")
+	var defs, kept []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+			j++
+		}
+		para := lines[i:j]
+		isDefs := len(para) > 0
+		for _, line := range para {
+			if !linkDef.MatchString(strings.TrimRight(line, "\n")) {
+				isDefs = false
+				break
+			}
+		}
+		if isDefs {
+			for _, line := range para {
+				defs = append(defs, strings.TrimRight(line, "\n"))
+			}
+		} else {
+			kept = append(kept, para...)
+		}
+		if j < len(lines) {
+			kept = append(kept, lines[j]) // The blank line.
+		}
+		i = j + 1
+	}
+	if defs == nil {
+		return comment, nil
+	}
+	return strings.TrimRight(strings.Join(kept, ""), "\n") + "\n", defs
+}
+
+// commentBlocks splits the text of a doc comment into blocks, using the
+// rules of go/doc: indented lines form code blocks, and a single-line
+// paragraph between two paragraphs that looks like a title is a heading.
+// As in Go 1.19 and later, a single-line paragraph written "# Heading" is
+// a heading too, wherever it is. A code block whose lines all begin with
+// a list marker, or continue the item above, is a list.
+func commentBlocks(comment string) []block {
+	lines := strings.Split(strings.TrimRight(comment, "\n"), "\n")
+	var blocks []block
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		start := i
+		if isIndented(lines[i]) {
+			for i < len(lines) && (isIndented(lines[i]) || strings.TrimSpace(lines[i]) == "") {
+				i++
+			}
+			for strings.TrimSpace(lines[i-1]) == "" {
+				i--
+			}
+			blocks = append(blocks, codeOrList(lines[start:i]))
+			continue
+		}
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" && !isIndented(lines[i]) {
+			i++
+		}
+		if i == start+1 && strings.HasPrefix(lines[start], "# ") && strings.TrimSpace(lines[start][2:]) != "" {
+			blocks = append(blocks, block{kind: headingBlock, lines: []string{strings.TrimSpace(lines[start][2:])}})
+			continue
+		}
+		blocks = append(blocks, block{kind: paraBlock, lines: lines[start:i]})
+	}
+
+	// Find the headings, now that the neighbors of each paragraph are known.
+	for i := 1; i+1 < len(blocks); i++ {
+		b := &blocks[i]
+		if b.kind == paraBlock && len(b.lines) == 1 && blocks[i-1].kind == paraBlock &&
+			blocks[i+1].kind == paraBlock && isHeading(b.lines[0]) {
+			b.kind = headingBlock
+		}
+	}
+	return blocks
+}
+
+// isIndented reports whether the line begins with a space or tab.
+func isIndented(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// codeOrList returns the block for the indented lines: a list if each
+// begins with a list marker or is more deeply indented than the first,
+// and otherwise a code block, with the common indentation removed.
+func codeOrList(lines []string) block {
+	margin := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for !strings.HasPrefix(line, margin) {
+			margin = margin[:len(margin)-1]
+		}
+	}
+	var items, markers []string
+	isList := true
+	for _, line := range lines {
+		text := line[len(margin):]
+		if m := listItem.FindStringSubmatch(text); m != nil {
+			items = append(items, text[len(m[0]):])
+			markers = append(markers, m[1])
+		} else if items != nil && isIndented(text) {
+			items[len(items)-1] += " " + strings.TrimSpace(text)
+		} else {
+			isList = false
+			break
+		}
+	}
+	if isList {
+		return block{kind: listBlock, lines: items, markers: markers}
+	}
+	code := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			code[i] = line[len(margin):]
+		}
+	}
+	return block{kind: codeBlock, lines: code}
+}
+
+// isHeading reports whether the line could be a heading, as go/doc
+// decides: it begins with an upper-case letter, ends with a letter or
+// digit, and holds only letters, digits, spaces and apostrophes followed
+// by s.
+func isHeading(line string) bool {
+	line = strings.TrimSpace(line)
+	r, _ := utf8.DecodeRuneInString(line)
+	if !unicode.IsLetter(r) || !unicode.IsUpper(r) {
+		return false
+	}
+	r, _ = utf8.DecodeLastRuneInString(line)
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return false
+	}
+	for i, r := range line {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == ' ':
+		case r == '\'':
+			if !strings.HasPrefix(line[i+1:], "s") || len(line) > i+2 && line[i+2] != ' ' {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
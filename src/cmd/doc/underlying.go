@@ -0,0 +1,98 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "go/ast"
+
+// underlyingSummary prints, beneath the documentation of a type declared
+// as another named type, as type Values Header is, the type at the end
+// of the chain of declarations, and its kind, so that the chain need not
+// be followed by hand. It prints nothing if the chain cannot be followed,
+// as when a package cannot be found.
+func (pkg *Package) underlyingSummary(spec *ast.TypeSpec) {
+	switch t := spec.Type.(type) {
+	case *ast.Ident:
+		if pkg.lookupTypeSpec(t.Name) == nil {
+			return // Predeclared, as in type T int.
+		}
+	case *ast.SelectorExpr:
+	default:
+		return
+	}
+	in, typ := pkg.underlying(spec.Type, 10)
+	if typ == nil {
+		return
+	}
+	line := "underlying: " + in.qualifiedExpr(typ, pkg)
+	if kind := typeKind(typ); kind != "" {
+		line += " (" + kind + ")"
+	}
+	pkg.newlines(2)
+	pkg.Printf("%s%s\n", indent, line)
+	pkg.newlines(2)
+}
+
+// underlying returns the type literal or predeclared type that the type
+// expression, as written in the package, is declared as, following the
+// declarations of named types to at most the given depth, and the package
+// in which it is written; or nil if it cannot be found.
+func (pkg *Package) underlying(expr ast.Expr, depth int) (*Package, ast.Expr) {
+	if depth == 0 {
+		return nil, nil
+	}
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return pkg.underlying(t.X, depth)
+	case *ast.Ident:
+		spec := pkg.lookupTypeSpec(t.Name)
+		if spec == nil {
+			return pkg, t // Predeclared.
+		}
+		return pkg.underlying(spec.Type, depth-1)
+	case *ast.SelectorExpr:
+		other := pkg.findImport(pkg.writer, identName(t.X))
+		if other == nil {
+			return nil, nil
+		}
+		spec := other.lookupTypeSpec(t.Sel.Name)
+		if spec == nil {
+			return nil, nil
+		}
+		return other.underlying(spec.Type, depth-1)
+	}
+	return pkg, expr
+}
+
+// qualifiedExpr returns the one-line summary of the type expression, as
+// written in the package: if that is not in, names of the package's types
+// are qualified by its name.
+func (pkg *Package) qualifiedExpr(expr ast.Expr, in *Package) string {
+	return pkg.qualified(expr, in, func() string { return pkg.oneLineNode(expr) })
+}
+
+// typeKind returns the kind of the type literal, such as map or pointer,
+// or "" for a predeclared type, whose name is its kind.
+func typeKind(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slice"
+		}
+		return "array"
+	case *ast.MapType:
+		return "map"
+	case *ast.ChanType:
+		return "chan"
+	case *ast.FuncType:
+		return "func"
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	case *ast.StarExpr:
+		return "pointer"
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A repoInfo is what -links and -srcurl need to know of the git checkout
+// that holds a directory.
+type repoInfo struct {
+	root string // The top-level directory of the checkout.
+	base string // The web address of the repository, as https://github.com/org/repo, or "".
+	rev  string // The revision checked out.
+}
+
+// repos caches the checkouts of directories, nil for a directory that is
+// not in a checkout.
+var repos = make(map[string]*repoInfo)
+
+// gitRepo returns the git checkout holding dir, asking git for the
+// top-level directory, the revision and the address of the origin remote.
+func gitRepo(dir string) *repoInfo {
+	if r, ok := repos[dir]; ok {
+		return r
+	}
+	var r *repoInfo
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel", "HEAD").Output()
+	if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); err == nil && len(lines) == 2 {
+		r = &repoInfo{root: lines[0], rev: lines[1]}
+		// A checkout without a remote has no web address.
+		if remote, err := exec.Command("git", "-C", dir, "config", "--get", "remote.origin.url").Output(); err == nil {
+			r.base = webURL(strings.TrimSpace(string(remote)))
+		}
+	}
+	repos[dir] = r
+	return r
+}
+
+// webURL returns the web address of the repository with the git remote
+// address, which may be a URL or in the scp-like form git@host:path, or ""
+// if it has none.
+func webURL(remote string) string {
+	var host, path string
+	if i := strings.Index(remote, ":"); i >= 0 && !strings.Contains(remote, "://") {
+		host, path = remote[:i], remote[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		u, err := url.Parse(remote)
+		if err != nil || u.Scheme == "file" {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return "https://" + host + "/" + path
+}
+
+// sourceURL returns the web address of the line of the file, at the
+// revision checked out, or "" if the file is not in a checkout with a
+// remote that has a web address.
+func sourceURL(file string, line int) string {
+	r, path := repoFile(file)
+	if r == nil || r.base == "" {
+		return ""
+	}
+	return fileURL(r.base, r.rev, path, line)
+}
+
+// repoFile returns the git checkout holding the file and the file's
+// slash-separated path within it, or nil if it is not in a checkout.
+func repoFile(file string) (*repoInfo, string) {
+	r := gitRepo(filepath.Dir(file))
+	if r == nil {
+		return nil, ""
+	}
+	rel, err := filepath.Rel(r.root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		// Git reports the top-level directory with symbolic links
+		// evaluated.
+		if file, err = filepath.EvalSymlinks(file); err != nil {
+			return nil, ""
+		}
+		if rel, err = filepath.Rel(r.root, file); err != nil || strings.HasPrefix(rel, "..") {
+			return nil, ""
+		}
+	}
+	return r, filepath.ToSlash(rel)
+}
+
+// fileURL returns the address of the line of the file at the revision on
+// the web site of the repository at base, in the form of its host.
+func fileURL(base, rev, path string, line int) string {
+	host := strings.TrimPrefix(base, "https://")
+	host = host[:strings.Index(host, "/")]
+	switch {
+	case host == "bitbucket.org":
+		return fmt.Sprintf("%s/src/%s/%s#lines-%d", base, rev, path, line)
+	case strings.HasSuffix(host, ".googlesource.com"):
+		return fmt.Sprintf("%s/+/%s/%s#%d", base, rev, path, line)
+	}
+	// GitHub, GitLab, Gitea and others.
+	return fmt.Sprintf("%s/blob/%s/%s#L%d", base, rev, path, line)
+}
+
+// templateFields are the fields of a -srcurl template.
+var templateFields = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkTemplate reports an unknown field of the -srcurl template.
+func checkTemplate(tmpl string) {
+	for _, f := range templateFields.FindAllString(tmpl, -1) {
+		switch f {
+		case "{repo}", "{rev}", "{path}", "{line}":
+		default:
+			fatalf("unknown field %s in -srcurl template; want {repo}, {rev}, {path} or {line}", f)
+		}
+	}
+}
+
+// templateURL returns the address of the line of the file given by the
+// -srcurl template, or "" if the file is not in a git checkout. The
+// repository is named by the path of its web address, such as org/repo.
+// Without a remote, it is named by the import path of its root, without
+// any host, as repoRoot guesses it from the import path of the file's
+// package, or if that is local by the name of the checkout's directory.
+func templateURL(tmpl, file string, line int, importPath string) string {
+	r, path := repoFile(file)
+	if r == nil {
+		return ""
+	}
+	repo := strings.TrimPrefix(r.base, "https://")
+	switch i := strings.Index(repo, "/"); {
+	case i >= 0:
+		repo = repo[i+1:]
+	case importPath == "." || strings.HasPrefix(importPath, "_"):
+		repo = filepath.Base(r.root)
+	default:
+		repo = repoRoot(importPath)
+		if i := strings.Index(repo, "/"); i >= 0 && strings.Contains(repo[:i], ".") {
+			repo = repo[i+1:]
+		}
+	}
+	return strings.NewReplacer(
+		"{repo}", repo,
+		"{rev}", r.rev,
+		"{path}", path,
+		"{line}", strconv.Itoa(line),
+	).Replace(tmpl)
+}
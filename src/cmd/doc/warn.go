@@ -0,0 +1,59 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Kinds of warning.
+const (
+	warnInstall  = "install"  // A package is installed at a path other than its import comment's.
+	warnSkip     = "skip"     // A directory, file or package could not be read and is skipped.
+	warnInvalid  = "invalid"  // The program is not valid Go.
+	warnInternal = "internal" // An internal package is not importable from the current directory.
+	warnModule   = "module"   // A package's import path is not the one its go.mod file implies.
+)
+
+// warnings is where warnings are written: standard error, apart from the
+// documentation, so that pipelines can consume them separately.
+var warnings io.Writer = os.Stderr
+
+// warnMu serializes the writing of warnings and trace lines, which the
+// walk of the trees writes too, from a goroutine of its own.
+var warnMu sync.Mutex
+
+// A warning is what warnf writes for the -json flag.
+type warning struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// warnf writes a warning of the kind to warnings, as a line beginning
+// "doc: warning: kind: ", or for the -json flag as a JSON object on a
+// line of its own, and carries on.
+func warnf(kind, format string, args ...interface{}) {
+	writeWarning(warnings, jsonWarnings, kind, fmt.Sprintf(format, args...))
+}
+
+// writeWarning writes the warning as warnf does, to w, as JSON if asJSON
+// is set.
+func writeWarning(w io.Writer, asJSON bool, kind, message string) {
+	warnMu.Lock()
+	defer warnMu.Unlock()
+	if asJSON {
+		b, err := json.Marshal(warning{kind, message})
+		if err != nil {
+			panic(err) // Cannot happen: strings always marshal.
+		}
+		fmt.Fprintf(w, "%s\n", b)
+		return
+	}
+	fmt.Fprintf(w, "doc: warning: %s: %s\n", kind, message)
+}
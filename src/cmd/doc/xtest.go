@@ -0,0 +1,41 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"strings"
+)
+
+// xtestPackage returns the external test package of the package, package
+// p_test, made of its _test.go files in that package, for -xtest. Its
+// exported helpers and examples are documented as a package's symbols
+// are. It keeps the import path of the package, whose directory it is
+// built in.
+func xtestPackage(pkg *build.Package) *build.Package {
+	if len(pkg.XTestGoFiles) == 0 {
+		fatalf("no external test package in %s", pkg.Dir)
+	}
+	xpkg := *pkg
+	xpkg.Name = pkg.Name + "_test"
+	xpkg.GoFiles = pkg.XTestGoFiles
+	xpkg.CgoFiles = nil
+	xpkg.Imports = pkg.XTestImports
+	return &xpkg
+}
+
+// importXTest imports the package whose external test package the path,
+// such as encoding/json_test, names, and sets -xtest to document it.
+func importXTest(path string) (*build.Package, bool) {
+	if !strings.HasSuffix(path, "_test") {
+		return nil, false
+	}
+	pkg, err := buildCtx.Import(strings.TrimSuffix(path, "_test"), "", build.ImportComment)
+	if err != nil || len(pkg.XTestGoFiles) == 0 {
+		return nil, false
+	}
+	xtest = true
+	return pkg, true
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"path"
+	"strings"
+)
+
+// openZip opens the named archive and returns its contents as a tree.
+// If every file in the archive is beneath a single directory named
+// path@version, as in a module zip file, the @version is dropped, so the
+// tree's paths match import paths. The archive stays open for the life of
+// the process.
+func openZip(file string) (*tree, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	t := newTree()
+	prefix, version := moduleVersion(r.File)
+	for _, f := range r.File {
+		name := f.Name
+		if version != "" {
+			name = prefix + strings.TrimPrefix(name, prefix+version)
+		}
+		name = path.Clean(name)
+		if strings.HasSuffix(f.Name, "/") {
+			t.add(name, dirInfo(path.Base(name)), nil)
+			continue
+		}
+		t.add(name, f.FileInfo(), f.Open)
+	}
+	return t, nil
+}
+
+// moduleVersion reports whether all the files are beneath a single
+// directory path@version and if so returns the path and the @version.
+func moduleVersion(files []*zip.File) (prefix, version string) {
+	if len(files) == 0 {
+		return "", ""
+	}
+	name := files[0].Name
+	at := strings.Index(name, "@")
+	slash := strings.Index(name[at+1:], "/")
+	if at <= 0 || slash < 0 {
+		return "", ""
+	}
+	dir := name[:at+1+slash+1]
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, dir) {
+			return "", ""
+		}
+	}
+	return name[:at], name[at : at+1+slash]
+}
@@ -223,7 +223,12 @@
 //
 // it prints the package documentation for the package in the current directory.
 // If the package is a command (package main), the exported symbols of the package
-// are elided from the presentation unless the -cmd flag is provided.
+// are elided from the presentation unless the -cmd flag is provided. A command's
+// documentation begins with its usage, if found in the messages printed by its
+// usage function, a string constant of its usage, or its doc comment. The flags a
+// command registers with package flag, whose names are string literals, are listed
+// after its documentation, with their defaults and usage messages, as the
+// command's -help flag would show them.
 //
 // When run with one argument, the argument is treated as a Go-syntax-like
 // representation of the item to be documented. What the argument selects depends
@@ -239,10 +244,17 @@
 // is printed. (See the examples below.) However, if the argument starts with a capital
 // letter it is assumed to identify a symbol or method in the current directory.
 //
-// For packages, the order of scanning is determined lexically in breadth-first order.
-// That is, the package presented is the one that matches the search and is nearest
-// the root and lexically first at its level of the hierarchy.  The GOROOT tree is
-// always scanned in its entirety before GOPATH.
+// For packages, the matches of a partial path are ranked: packages preferred
+// by the -prefer flag first, then, with the -roots flag, those in the earlier
+// of the roots it lists, then packages of the standard library, then those
+// in the repository holding the current directory, then the rest of GOPATH,
+// then vendored packages; within each, those with the fewest elements in their
+// paths first, then the most recently modified. The package presented is the
+// best ranked that matches the search. If no symbol is given and the best
+// ranked does not outrank the next by preference, root or class, as rand
+// matches crypto/rand and math/rand, the matches are listed instead, each with
+// the GOROOT or GOPATH entry holding it; give more of the path, or choose one
+// with the -pkg-index flag.
 //
 // If there is no package specified or matched, the package in the current
 // directory is selected, so "go doc Foo" shows the documentation for symbol Foo in
@@ -250,7 +262,15 @@
 //
 // The package path must be either a qualified path or a proper suffix of a
 // path. The go tool's usual package mechanism does not apply: package path
-// elements like . and ... are not implemented by go doc.
+// elements like . and ... are not implemented by go doc, except in package
+// patterns. A path ending in _test, such as encoding/json_test, names the
+// external test package of the package, as the -xtest flag does.
+//
+// If the arguments are package patterns, such as ./... or net/..., in which
+// ... matches any string, or std, which matches the standard library, go doc
+// prints the package documentation for every package they match, separated
+// by rules. Patterns and other package paths may be mixed; symbols may not
+// be given.
 //
 // When run with two arguments, the first must be a full package path (not just a
 // suffix), and the second is a symbol or symbol and method; this is similar to the
@@ -262,6 +282,16 @@
 // either case but upper-case letters match exactly. This means that there may be
 // multiple matches of a lower-case argument in a package if different symbols have
 // different cases. If this occurs, documentation for all matches is printed.
+// If a package has no symbol with the name given, the methods with that name
+// of its exported types, interfaces included, are printed instead, each with
+// its receiver.
+//
+// The -c flag makes matching respect case, and the -prefix flag makes symbols
+// match if they begin with the argument. Scripts wanting exact matches whatever
+// the user's habits can set the environment variable GODOCMATCH, whose
+// space-separated words set the defaults for those flags: case or fold, to
+// respect case or not, and prefix or whole, to match by prefix or not. For
+// instance, GODOCMATCH=case gives exact matches. The flags override it.
 //
 // Examples:
 // 	go doc
@@ -289,6 +319,18 @@
 // 		Show documentation for text/template's New function.
 // 	go doc text/template new # Two arguments
 // 		Show documentation for text/template's New function.
+// 	go doc net/...
+// 		Show package docs for net and each package beneath it.
+// 	go doc make
+// 		Show documentation for the built-in function make, with
+// 		details of how it behaves for each kind of type.
+// 		(The functions of package builtin are found by name alone.)
+// 	go doc select
+// 		Explain the select statement, with examples. Each keyword
+// 		of the language is explained this way.
+// 	go doc "<-"
+// 		Explain the receive operator. Operators, iota and the blank
+// 		identifier _ are explained too.
 //
 // 	At least in the current tree, these invocations all print the
 // 	documentation for json.Decoder's Decode method:
@@ -299,15 +341,492 @@
 // 	cd go/src/encoding/json; go doc decode
 //
 // Flags:
+// 	-accepts type
+// 		List the exported functions and methods of the package with a
+// 		parameter of the named type, or a pointer to it, as in
+// 			go doc -accepts io.Reader net/http
+// 		to show what may be done with a value of the type. The type is
+// 		given as for -returns, and a final ...type parameter counts.
+// 	-annotate
+// 		After the output, explain those of its features that may puzzle
+// 		a newcomer, such as "// Has unexported fields.", the methods and
+// 		functions listed with a type, and the warning given when a
+// 		package's import comment differs from where it is installed.
+// 	-baseline file
+// 		Compare the package's exported API with the baseline recorded
+// 		in file, a JSON object whose API field maps each feature, such
+// 		as "func F", to its declaration, and print each feature added,
+// 		removed or changed. The exit status is 0 if there are no
+// 		differences, 3 if there are only additions, and 4 otherwise.
+// 	-batch
+// 		Read queries from standard input, one to a line, each the
+// 		arguments to go doc, flags included, separated by spaces, and
+// 		write the answer to each: what go doc would print for it, or
+// 		its error. Each answer is ended by a line holding the ASCII
+// 		record separator character (\x1e) followed by ok or error.
+// 		Each package is parsed once however many queries use it, so
+// 		editors and other programs can look up many symbols quickly.
+// 		The files are read from a snapshot taken as the session reads
+// 		them, so the answers agree even if the files change meanwhile.
+// 	-bundle
+// 		Given two arguments, a package and a file, write the Go source
+// 		files of the package and of every package it imports, directly
+// 		or not, as found in GOPATH and vendor directories, to the file
+// 		as a zip archive, each beneath its import path. The archive
+// 		holds the API a binary built from the package was built with,
+// 		and can be read with -zip, as in
+// 			go doc -bundle ./cmd/server server.docz
+// 			go doc -zip server.docz example.com/lib
+// 		The standard library, which -zip reads from GOROOT, is left out.
+// 		Given instead a file ending in .docz and then packages or
+// 		patterns, write the documentation of the packages, rather than
+// 		their source, to the file, for -frombundle, as in
+// 			go doc -bundle out.docz ./...
+// 		It is printed at the width given by -width, or 80 columns,
+// 		without terminal typography.
 // 	-c
-// 		Respect case when matching symbols.
+// 		Respect case when matching symbols. The default is set by
+// 		$GODOCMATCH, as described above.
+// 	-calls
+// 		For a function or method, list the exported functions and methods
+// 		it calls directly, of its own package and of the other packages
+// 		of its repository. Without type information, a method call is
+// 		attributed to the receiver's type, or else to the only type of
+// 		the package with a method of that name.
+// 	-cgo
+// 		For a package that uses cgo, also show the Go functions it
+// 		exports to C with //export directives, and the C names
+// 		(C.name) it refers to, which are otherwise invisible.
+// 	-check-comments
+// 		Report the places in the package's doc comments that will not
+// 		be shown as their line breaks suggest: prose indented by mistake,
+// 		which starts a preformatted block; lists and runs of short lines,
+// 		which are joined into one paragraph; and preformatted lines wider
+// 		than the output, which are not wrapped. Each report suggests a
+// 		fix, and doc exits with a non-zero status if there are any.
+// 	-check-import-comments
+// 		Report the packages in the trees rooted at the arguments, as for
+// 		-list, whose import comments differ from the paths at which they
+// 		are installed, exiting with a non-zero status if there are any.
 // 	-cmd
 // 		Treat a command (package main) like a regular package.
 // 		Otherwise package main's exported symbols are hidden
 // 		when showing the package's top-level documentation.
+// 	-color when
+// 		Render doc comments with terminal typography: headings, including
+// 		those written "# Heading", in bold, code blocks shaded and list
+// 		items bulleted or numbered. When is auto (the
+// 		default), always or never. Auto renders them only when the output
+// 		is a terminal, TERM is not dumb, and NO_COLOR is not set.
+// 	-complete-symbols
+// 		List the exported symbols of the package whose names begin with
+// 		the symbol given, or all of them, or given Type.prefix the
+// 		methods of the type that begin with the prefix, one to a line
+// 		as their kind (func, type, method, const or var), name and
+// 		one-line declaration, separated by tabs, to back an editor's
+// 		completion or a tool such as fzf.
+// 	-conventions
+// 		List the package's exported functions and methods, noting of
+// 		each whether it takes a context.Context as its first parameter,
+// 		or elsewhere, and whether it returns an error as its last
+// 		result, or elsewhere; then how many keep each convention.
+// 	-diff
+// 		Given two packages, compare the exported API of the second with
+// 		that of the first, as -baseline compares a package's with its
+// 		baseline, listing the features added, removed and changed, as in
+// 			go doc -diff example.com/lib example.com/lib/v2
+// 			go doc -diff github.com/upstream/lib github.com/fork/lib
+// 		The packages are named as for a single argument, so partial
+// 		paths are accepted. The exit status is as for -baseline.
+// 	-directives
+// 		Show the compiler and tool directives in a declaration's doc
+// 		comment, such as //go:noinline or //go:linkname, above the
+// 		declaration. Otherwise directives are omitted from the comment,
+// 		except for the //go:embed directives of variables, which are
+// 		always shown.
+// 	-dump
+// 		Print as JSON the package's go/doc model as go doc uses it,
+// 		with each declaration as source and its position, so that
+// 		other tools can reuse go doc's adjustments: the typed constants,
+// 		variables and constructors of each type are listed both beneath
+// 		the type and at the top level, so that they can be found by
+// 		name. Unexported declarations are included.
+// 	-escape mode
+// 		Escape the output as a whole, so that a template can embed it
+// 		as it is. The mode is html, for HTML text; json, for a JSON
+// 		string; or shell, for a single-quoted word of a POSIX shell.
+// 		Invalid UTF-8 is replaced by U+FFFD. It cannot be used with -i
+// 		or -repl.
+// 	-ex
+// 		Show the examples of a function, type or method, found in the
+// 		package's _test.go files, beneath its documentation: the code
+// 		of each, titled with its suffix if it has one, and the output
+// 		it expects.
+// 	-goarch arch
+// 	-goos os
+// 		Select the package's files as for the given target architecture
+// 		and operating system, so that platform-specific declarations,
+// 		such as those of package syscall, can be read on any machine.
+// 		The defaults are those of the current machine, as for go build.
+// 	-follow
+// 		Beneath the documentation of a symbol, show a one-line summary
+// 		of each symbol or package its doc comment links to, as
+// 		[pkg.Name] or [Name] do, so that they can be read without
+// 		asking for them. Symbols of other packages are qualified by
+// 		their package's name.
+// 	-frombundle file
+// 		Print the documentation the arguments ask for from the doc
+// 		bundle written by -bundle, without the packages' source, as in
+// 			go doc -frombundle out.docz json.Decoder.Decode
+// 		A package is named by its path in the bundle or the end of it,
+// 		and its symbols and methods are matched as in its source.
+// 	-hierarchy
+// 		Given an argument [<pkg>.]<interface>, show a tree of the
+// 		interfaces it embeds, and those they embed; of the interfaces
+// 		that embed it, and those that embed them; and beneath each
+// 		interface, the concrete types that implement it, or whose
+// 		pointer types do. Interfaces and implementations are looked
+// 		for in the interface's package and in any packages or patterns
+// 		given as further arguments, as in 'go doc -hierarchy io.Reader ./...'.
+// 	-hooks
+// 		List, after the symbols in the package doc, the exported
+// 		variables whose types are channels or functions, which are
+// 		usually hooks for users to replace, with their types and
+// 		initial values, under "Variables you can override".
+// 	-i
+// 		Browse the package interactively in the terminal: a list of its
+// 		symbols and imports, which can be searched by typing / and moved
+// 		through with the arrow keys or j and k, beside the documentation
+// 		of the selected item. Enter opens an imported package, h or
+// 		backspace returns to the previous one, and q quits.
+// 	-ignore 'pattern list'
+// 		A space-separated list of patterns, in the syntax of
+// 		path/filepath's Match, for directories to skip when searching
+// 		GOROOT and GOPATH for a partial package path. A pattern
+// 		containing a slash is matched against the directory's path
+// 		below src; any other pattern is matched against its name.
+// 		The patterns add to those in $GODOCIGNORE and to the defaults:
+// 		testdata, node_modules, bazel-*, and names beginning with a period.
+// 	-imports
+// 		List the packages the package imports in its package doc, as an
+// 		import block before its symbols, with those of the standard
+// 		library first and the others after a blank line.
+// 	-indent n
+// 		Indent doc comments beneath their declarations, preformatted
+// 		blocks within them, and the constants, variables and
+// 		constructors listed beneath their types by n spaces rather than
+// 		four. The argument may instead be a string of spaces and tabs,
+// 		in which \t stands for a tab, as in -indent '\t'.
+// 	-inline-types
+// 		Beneath the documentation of a function or method, show a
+// 		one-line summary of each named type in its signature, other than
+// 		its receiver's, such as what http.ResponseWriter and
+// 		http.Request are for http.HandleFunc.
+// 	-iota
+// 		In a constant block that uses iota, append a comment to each line
+// 		giving the values of its constants, and show the lines of
+// 		skipped values, named _, too. Values that depend on constants
+// 		declared outside the block are shown as ?.
+// 	-lang version
+// 		Select the package's files by their build constraints as the
+// 		given version of Go, such as go1.7, would: release tags after
+// 		it, such as go1.8, are not satisfied. Files must still be
+// 		written in syntax this version of go doc can parse.
+// 	-json
+// 		Write warnings, and the error if doc fails, to standard error
+// 		as JSON objects, one to a line, each with the kind of warning or
+// 		error and its message, rather than as lines of text. See
+// 		Warnings and Errors, below.
+// 	-layout
+// 		Beneath the documentation of a type, show its size and alignment
+// 		in bytes for the architecture selected by GOARCH or -goarch, as
+// 		the gc compiler lays it out, and for a struct the offset, size
+// 		and alignment of each field and the total bytes of padding.
+// 	-leaks
+// 		Report the exported symbols of the packages in the trees rooted
+// 		at the arguments, as for -list, whose declarations use types of
+// 		internal packages, which the packages' importers cannot name:
+// 		functions and methods in their signatures, types in their
+// 		exported fields and methods, and variables and constants in
+// 		their types. Each line gives the symbol and the internal types
+// 		it uses. The exit status is 1 if there are any.
+// 	-links
+// 		Append a comment giving the web address of each declaration
+// 		printed, at the revision checked out, if its source is in a git
+// 		checkout whose origin remote is on a site such as GitHub. With
+// 		-color, file:line is shown as a hyperlink to the address instead.
+// 	-list
+// 		List the packages in the trees rooted at the arguments, each
+// 		an import path or relative directory optionally followed by /...,
+// 		such as 'net' or './...', with the synopsis of each package.
+// 		With no arguments, list the tree rooted at the current directory.
+// 	-literal
+// 		Given a struct type, print a composite literal of it, such as
+// 		&http.Client{Transport: nil, ...}, with each exported field set
+// 		to its zero value, one to a line, to be pasted into a program
+// 		and filled in.
+// 	-loc
+// 		Append a comment giving the file and line of each declaration
+// 		printed, so that its source can be found.
+// 	-man-all dir
+// 		Write a man page for each package that the arguments, packages
+// 		or patterns, match to the directory, for distributions to ship,
+// 		as in
+// 			go doc -man-all man std
+// 		A package's page, in section 3go, is named by its import path
+// 		with periods for slashes, such as net.http.3go, and a command's,
+// 		in section 1, by its name, such as gofmt.1. Each page gives the
+// 		package's synopsis and doc comment, the doc of each exported
+// 		symbol under CONSTANTS, VARIABLES, FUNCTIONS or TYPES, with each
+// 		type's constructors and methods, and its bugs. A command's page
+// 		gives its usage and flags instead of symbols. The text is printed
+// 		at the width given by -width, or 72 columns, to fit within man's
+// 		indentation, without terminal typography.
+// 	-notes 'marker list'
+// 		After the BUG notes in the package doc, show the notes with the
+// 		given markers, a comma-separated list such as TODO,NOTE, or all
+// 		for every marker. Notes are comments beginning MARKER(uid):, as
+// 		gathered by go/doc, so any marker of two or more capital letters
+// 		can be used, such as -notes=SECURITY,DEPRECATION.
+// 	-o file
+// 		Write the output to the file rather than to standard output, and
+// 		only if there is no error. If the argument is a pattern, the
+// 		file is instead a directory, beneath which the documentation of
+// 		each package matched is written to a file named by its path with
+// 		.txt appended, such as net/http.txt.
+// 	-outline
+// 		Print the package's symbols as JSON, each with its kind and the
+// 		file, line and column of its declaration. Each type holds its
+// 		fields or interface methods, constants, variables, constructors
+// 		and methods, as an editor's outline would show them.
+// 	-overlay file
+// 		Read from file (or standard input, if file is -) a JSON object
+// 		mapping file names to contents, and use those contents in place
+// 		of the files on disk. Files not on disk are added. Relative names
+// 		are relative to the current directory. Editors can use this to
+// 		document unsaved buffers.
+// 	-pkg-index n
+// 		When a partial package path matches more than one package,
+// 		use the nth of them, in the order they are listed.
+// 	-platforms 'goos/goarch list'
+// 		Show the package's documentation merged from its files as
+// 		selected for each of the space-separated platforms, such as
+// 		'linux/amd64 windows/amd64'. Each summary line for a symbol
+// 		that is not present on every platform where the package has
+// 		files is followed by a comment listing the platforms it is on.
+// 	-pos file:line:column
+// 		Show documentation for whatever the identifier at the given
+// 		position in the file refers to. Columns count bytes from 1.
+// 	-prefer patterns
+// 		Rank the packages a partial path matches whose import paths
+// 		match the space-separated patterns first, in the order of the
+// 		patterns, as -prefer 'github.com/myorg/...' prefers an
+// 		organization's packages to forks of them. A pattern without
+// 		... matches the paths beneath it. The patterns come before
+// 		those in $GODOCPREFER, which are preferred in the same way.
+// 	-prefix
+// 		Match symbols that begin with the name given, such as
+// 		ExportedFunc and ExportedType for exported, rather than only
+// 		whole symbols. The default is set by $GODOCMATCH.
+// 	-proto
+// 		Print the package's symbols as -outline does, with the signature
+// 		and doc comment of each, as a Package message in the protocol
+// 		buffer binary format. Its schema, $GOROOT/src/cmd/doc/doc.proto,
+// 		is only ever extended, so that indexers can rely on it.
+// 	-raw
+// 		Print doc comments verbatim, as they are in the source, without
+// 		reflowing or indenting them, for formatters and diff tools.
+// 	-record file
+// 		Write the package's exported API to file as a baseline for
+// 		-baseline, in a canonical form, sorted with one feature to a
+// 		line, that is meant to be committed and reviewed.
+// 	-remote
+// 		Document a package of a module that need not be in GOPATH or
+// 		required by go.mod, named by the first argument, as in
+// 			go doc -remote rsc.io/quote
+// 			go doc -remote rsc.io/quote.Hello
+// 			go doc -remote golang.org/x/text/language@v0.3.0 Tag
+// 		The module holding the package, at the version following @, or
+// 		the latest one, is downloaded through the proxies listed by
+// 		GOPROXY and read from its zip file as -zip reads one. As go doc
+// 		does not verify it against go.sum or GOSUMDB, it is kept in go
+// 		doc's own cache in GOCACHE, not the module cache, whose zip files
+// 		are read only once the go command has verified them. A version
+// 		given exactly is read from the caches without asking the proxies.
+// 		With a version, the symbol is given as a second argument. The
+// 		version a query such as latest resolves to is reported on standard
+// 		error.
+// 		Entries of GOPROXY other than proxies, such as direct, are skipped.
+// 	-repl
+// 		Read queries from standard input, one to a line, printing the
+// 		documentation of each. A query is a package, perhaps followed by
+// 		.symbol and .method; .name, for a symbol of the current package
+// 		or a method of the current type; or .., which goes up to the type
+// 		or package, or back to the previous package. Packages are loaded
+// 		once per session.
+// 	-returns type
+// 		List the exported functions and methods of the package with a
+// 		result of the named type, or a pointer to it, as in
+// 			go doc -returns http.Handler net/http
+// 		to show how a value of the type may be obtained. A type qualified
+// 		by the name of the package itself is its own type of that name;
+// 		those of other packages are qualified as its files import them.
+// 		Given as *type, only results of pointers to the type are listed.
+// 	-roots list
+// 		Search only the trees of the GOROOT and GOPATH entries in the
+// 		list, separated as in $GOPATH, in the order listed, for the
+// 		packages a partial path matches, and rank those in earlier
+// 		entries first, so that with layered GOPATHs the match does not
+// 		depend on which tree holds what. The package chosen and the
+// 		entry holding it are reported on standard error. Complete
+// 		import paths are resolved as usual.
+// 	-satisfies-constraint
+// 		Given two arguments, [*][<pkg>.]<type> and [<pkg>.]<interface>,
+// 		report whether the type satisfies the interface and, if not,
+// 		which methods are missing, have the wrong signature or are only
+// 		in the method set of the pointer type.
+// 	-short
+// 		Print only the one-line summary of each declaration a symbol
+// 		or method matches, as the package's documentation lists it,
+// 		one to a line, without its doc comment or the package clause,
+// 		for status lines, scripts and prompts.
+// 	-sighelp
+// 		For a function or method, print as JSON, for an editor's
+// 		signature help, an array holding for each match its Label, the
+// 		declaration on one line; its Doc, the first sentence of its doc
+// 		comment; and its Parameters, each with its Name, its Type and,
+// 		as Doc, the first sentence of the doc comment that names it.
+// 	-sort order
+// 		List the symbols of the package, and those grouped with each
+// 		type, in the given order: name, the default, which is
+// 		alphabetical, or source, which is the order in which they are
+// 		declared, file by file.
+// 	-split dir
+// 		Write the package's documentation into the directory, one file
+// 		per top-level symbol plus an index file. A type's file also holds
+// 		its constructors, methods and associated constants and variables.
+// 		The file of a symbol named index, in any case, is named as
+// 		index.symbol.txt, so as not to replace the index, and a file whose
+// 		name differs only in case from an earlier one, as with -u Foo and
+// 		foo, gets a number, as in foo.2.txt, so that neither replaces the
+// 		other where case is ignored.
+// 	-splitfmt format
+// 		The format of the files written by -split: text (the default)
+// 		or markdown.
+// 	-srcurl template
+// 		Append a comment giving the address of each declaration printed
+// 		in the organization's code browser, as for -links, formed from
+// 		the template by replacing {repo} with the repository's path,
+// 		such as org/repo, {rev} with the revision checked out, {path}
+// 		with the file's path in the repository, and {line} with the
+// 		line, as in 'https://git.example.com/{repo}/blob/{rev}/{path}#L{line}'.
+// 		The repository and revision are those of the git checkout that
+// 		holds the file; without a remote, the repository is named by
+// 		the import path. Files outside a checkout are given as file:line.
+// 	-stats
+// 		Count the package's exported types, functions, methods,
+// 		constants and variables, and how many of them are deprecated,
+// 		with a paragraph beginning "Deprecated: ", and documented, for
+// 		auditing the growth of an API. Constructors count as functions
+// 		and the methods of interfaces as methods.
+// 	-std-archive
+// 		Write the documentation of the packages of the standard library,
+// 		apart from internal ones, to an archive in the directory doc of
+// 		GOCACHE, with the settings in effect, such as the terminal's
+// 		width. Go doc then answers a query given no flags about one of
+// 		them from the archive, without parsing the package, as long as
+// 		the settings and the package's files are unchanged and the query
+// 		matches a single symbol or method. Write the archive again after
+// 		changing the settings, or it is not used.
+// 	-stub
+// 		Print a Go source file declaring the package's exported API as
+// 		its source does, with the doc comments, but with the bodies of
+// 		functions and methods empty, or for those with results a panic,
+// 		for building mocks, shims and API reviews. So that the file
+// 		compiles, it declares the unexported types, constants, variables
+// 		and functions the exported ones use, too.
+// 	-symlinks=false
+// 		Do not follow symbolic links to directories when searching
+// 		GOROOT and GOPATH for a partial package path. Links are followed
+// 		by default; a directory reachable by several paths, or through
+// 		a cycle of links, is considered only once.
+// 	-tags 'tag list'
+// 		A space-separated list of build tags to consider satisfied when
+// 		selecting the package's files, as for go build. Without it,
+// 		files guarded by custom tags are not documented. The
+// 		experiments enabled by the GOEXPERIMENT environment variable,
+// 		a comma-separated list, are considered satisfied too, as
+// 		goexperiment.name tags.
+// 	-timeout duration
+// 		Give up searching GOROOT and GOPATH for a partial package path
+// 		after the duration (such as 10s) and report how far the search
+// 		got, along with any packages it tried. By default there is no limit.
+// 	-tree
+// 		Show the package's symbols as an indented tree: its constants,
+// 		variables, functions and types, with each type's constants,
+// 		variables, constructors and methods beneath it.
 // 	-u
 // 		Show documentation for unexported as well as exported
 // 		symbols and methods.
+// 	-width n
+// 		Wrap doc comments to lines of n columns. By default, the width
+// 		is that of the terminal, as given by $COLUMNS or the terminal
+// 		itself, or 80 if the output is not a terminal.
+// 	-x
+// 		Trace on standard error, in lines beginning "doc: x: ", how the
+// 		package and symbol are found: the import paths tried and why
+// 		they failed, the GOROOT and GOPATH trees searched and the
+// 		directories skipped in them, the candidates a partial path
+// 		matches, in the order they are tried, and why each passed over
+// 		was rejected, and the files parsed or excluded by build
+// 		constraints.
+// 	-xtest
+// 		Document the external test package of the package, package
+// 		p_test, made of those of its _test.go files in it, with the
+// 		exported helpers and examples they declare.
+// 	-zip file
+// 		Read packages from the zip archive rather than from GOPATH.
+// 		The archive's root takes the place of both GOPATH's src directory
+// 		and the current directory, so its packages are named by their
+// 		paths within the archive. In a module zip file, whose contents
+// 		are all beneath a directory path@version, the @version is ignored.
+// 		The standard library is still read from GOROOT.
+//
+// Go doc reads the settings that affect builds as the go command does: from
+// the environment, or failing that from the file named by GOENV, by default
+// go/env in the user's configuration directory, with a line KEY=VALUE for each
+// setting. So GOPATH, GOOS, GOARCH, CGO_ENABLED and GOEXPERIMENT can be set
+// there, and GOFLAGS, a space-separated list of flags, can set -tags, with
+// commas separating the tags, unless it is given on the command line. The
+// other flags in GOFLAGS are for other commands and are ignored.
+//
+// Go doc keeps the documentation it prints in the directory doc of the build
+// cache, GOCACHE, so that asking again for the same documentation, as shell
+// completion and editors do, need not parse the package. An entry is used
+// only if the arguments, the settings and the package's files are unchanged.
+// Output that depends on more than the package's files, such as that of
+// -follow, -calls, -layout or -links, is not kept. GOCACHE=off turns the
+// cache off.
+//
+// Warnings, such as that a package is installed at a path other than the
+// one its import comment gives, or that a directory could not be read and
+// was skipped, are written to standard error, apart from the documentation.
+// Each is a line beginning "doc: warning: " and its kind (install, skip,
+// invalid, internal or module), or with -json a JSON object with the fields
+// kind and message. An internal package is documented wherever go doc is run,
+// with a warning if the package in the current directory could not import it.
+// If a go.mod file in the package's directory or above gives a module path
+// that, with the directory's path beneath the file's, is not the import path
+// the package was found at, as for a fork, a warning says so.
+//
+// Errors end go doc with a line on standard error beginning "doc: ", or with
+// -json a JSON object with the fields error, giving the kind of error,
+// message and status. The exit status tells the kinds apart, so that scripts
+// need not parse messages: 5 if no package matches (package), 6 if no symbol
+// or method matches (symbol), 7 if a partial path matches several packages
+// (ambiguous) and 8 if a package's files cannot be read or parsed (parse).
+// Invalid usage exits with status 2, differences found by -baseline with 3
+// or 4 (api), as described above, and other errors with 1 (other).
 //
 //
 // Print Go environment information